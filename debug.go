@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/handlers"
+)
+
+// newDebugServer returns the server for -debug-addr: pprof profiles, expvar counters, the
+// effective configuration and request replays. It has its own mux so none of it is reachable
+// on the public port.
+func newDebugServer(cfg *config.Config, handler *handlers.Handler, logger *logrus.Entry) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/config", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(cfg.Redacted()); err != nil {
+			logger.WithError(err).Error("Failed to encode debug config dump")
+		}
+	})
+	// Replays return full configs, so they need an admin token even on the debug port
+	mux.HandleFunc("/api/v1/debug/replay", handler.RequireAdmin(handler.ReplayHandler))
+
+	// No write timeout: CPU profiles and traces stream for as long as requested
+	return &http.Server{
+		Addr:              cfg.Server.DebugAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.Server.ReadTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+}
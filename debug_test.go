@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/handlers"
+)
+
+func TestDebugReplayRequiresAdmin(t *testing.T) {
+	body := `{"url": "https://vpn.example.com/vless/bae71742-94e0-4dd5-935f-070339819ba0?server=x.example.com"}`
+	tests := []struct {
+		name   string
+		tokens []string
+		token  string
+		status int
+	}{
+		{"no tokens configured", nil, "", http.StatusNotFound},
+		{"without token", []string{"secret"}, "", http.StatusUnauthorized},
+		{"wrong token", []string{"secret"}, "nope", http.StatusUnauthorized},
+		{"token", []string{"secret"}, "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := handlers.Options{Defaults: config.DefaultDynamicConfig(), AuthTokens: tt.tokens}
+			server := newDebugServer(&config.Config{}, newTestHandler(t, true, true, options), logrus.NewEntry(logrus.StandardLogger()))
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/debug/replay", strings.NewReader(body))
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			server.Handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestReplayNotOnPublicRouter(t *testing.T) {
+	options := handlers.Options{Defaults: config.DefaultDynamicConfig(), AuthTokens: []string{"secret"}}
+	mux := newRouter(&config.Config{}, newTestHandler(t, true, true, options), nil)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/debug/replay", strings.NewReader(`{}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("public router status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/templates"
+	"vless-generator/internal/utils"
+)
+
+// Values of the generate subcommand's -output flag
+const (
+	outputJSON = "json" // Config on stdout
+	outputURL  = "url"  // Share URL on stdout
+	outputBoth = "both" // Config on stdout, share URL on stderr
+)
+
+// requestOnlyParams are known parameters that only make sense on HTTP requests
+var requestOnlyParams = map[string]bool{"lang": true, "token": true, "sig": true, "exp": true}
+
+// paramFlag stores a dynamic parameter as a query value, so that generate validates its
+// flags with config.ParseDynamicConfig exactly like the HTTP routes validate query strings
+type paramFlag struct {
+	values  url.Values
+	name    string
+	boolean bool
+}
+
+func (f *paramFlag) String() string {
+	return f.values.Get(f.name)
+}
+
+// Set records the flag value as the parameter value
+func (f *paramFlag) Set(value string) error {
+	f.values.Set(f.name, value)
+	return nil
+}
+
+// IsBoolFlag lets boolean parameters be given without a value (e.g., -mux)
+func (f *paramFlag) IsBoolFlag() bool {
+	return f.boolean
+}
+
+// runGenerate implements `vless-generator generate`: it writes one config to stdout without
+// starting the HTTP server and returns the exit code (1 for invalid parameters, 2 for usage errors)
+func runGenerate(args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	configType := flags.String("type", "vless", "Configuration template type")
+	uuid := flags.String("uuid", "", "UUID or password of the client (required)")
+	output := flags.String("output", outputBoth, "What to print: json (config on stdout), url (share URL on stdout) or both (config on stdout, share URL on stderr)")
+	pretty := flags.Bool("pretty", true, "Indent the JSON config")
+	templatesDir := flags.String("templates-dir", "", "Directory to load configuration templates from instead of the embedded ones")
+	defaultsFile := flags.String("defaults-file", "", "JSON or YAML file with default dynamic parameters (flags take precedence)")
+
+	values := registerParamFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: vless-generator generate -type <type> -uuid <uuid> [dynamic parameters...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return 2
+	}
+	if *uuid == "" {
+		fmt.Fprintln(os.Stderr, "-uuid is required")
+		return 2
+	}
+	if *output != outputJSON && *output != outputURL && *output != outputBoth {
+		fmt.Fprintf(os.Stderr, "-output must be json, url or both, got %q\n", *output)
+		return 2
+	}
+
+	// Only problems are worth printing; stdout stays reserved for the result
+	logrus.SetLevel(logrus.WarnLevel)
+
+	cfg, dynamicCfg, code := generateConfig(*configType, *uuid, values, *templatesDir, *defaultsFile)
+	if cfg == nil {
+		return code
+	}
+
+	if *output != outputURL {
+		if err := writeConfigJSON(os.Stdout, cfg, *pretty); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write config: %v\n", err)
+			return 1
+		}
+	}
+	if *output != outputJSON {
+		shareURL, err := shareURLs(*configType, cfg, *uuid, dynamicCfg)
+		switch {
+		case err != nil && *output == outputURL:
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		case err != nil:
+			// The config was written; types without share links simply have nothing more to print
+			fmt.Fprintf(os.Stderr, "no share URL: %v\n", err)
+		case *output == outputURL:
+			fmt.Fprintln(os.Stdout, shareURL)
+		default:
+			fmt.Fprintln(os.Stderr, shareURL)
+		}
+	}
+	return 0
+}
+
+// generateConfig validates the dynamic parameters and renders one config, printing problems to
+// stderr; cfg is nil on failure and code is then the exit code to return
+func generateConfig(configType, uuid string, values url.Values, templatesDir, defaultsFile string) (cfg map[string]interface{}, dynamicCfg *config.DynamicConfig, code int) {
+	defaults := config.DefaultDynamicConfig()
+	if defaultsFile != "" {
+		if err := config.LoadDefaultsFile(defaultsFile, defaults); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, 2
+		}
+	}
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(values, defaults)
+	if len(paramErrs) > 0 {
+		for _, paramErr := range paramErrs {
+			fmt.Fprintln(os.Stderr, paramErr.Error())
+		}
+		return nil, nil, 1
+	}
+
+	templateManager := templates.NewManager(templateFS(templatesDir))
+	if err := templateManager.LoadTemplates([]string{configType}); err != nil {
+		fmt.Fprintf(os.Stderr, "unknown or invalid template type %q: %v\n", configType, err)
+		return nil, nil, 1
+	}
+	if values.Get("strict") != "false" && templateManager.RequiresUUID(configType) {
+		if err := utils.ValidateUUID(uuid); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -uuid: %v (use -strict=false for non-UUID ids)\n", err)
+			return nil, nil, 1
+		}
+	}
+
+	cfg, err := templateManager.GenerateConfig(configType, uuid, dynamicCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, 1
+	}
+	return cfg, dynamicCfg, 0
+}
+
+// registerParamFlags makes every dynamic query parameter a flag (-server x.example.com -port 443 -mux)
+// and returns the values the flags are stored in
+func registerParamFlags(flags *flag.FlagSet) url.Values {
+	values := url.Values{}
+	booleans := boolParams()
+	for _, name := range config.KnownParams {
+		if requestOnlyParams[name] {
+			continue
+		}
+		flags.Var(&paramFlag{values: values, name: name, boolean: booleans[name]}, name, fmt.Sprintf("Dynamic parameter %s (same values as the query parameter)", name))
+	}
+	return values
+}
+
+// shareURLs returns the share URL of a config, one line per node for multi-server vless configs
+func shareURLs(configType string, cfg map[string]interface{}, uuid string, dynamicCfg *config.DynamicConfig) (string, error) {
+	if len(dynamicCfg.Servers) > 0 && (configType == "vless" || configType == "vless-reality") {
+		// Nodes are named by their outbound tags
+		links, err := utils.GenerateVlessURLs(cfg, uuid, nil)
+		return strings.Join(links, "\n"), err
+	}
+	return utils.GenerateShareURL(configType, cfg, uuid, dynamicCfg.Remark(configType))
+}
+
+// writeConfigJSON encodes a generated config like the download route does
+func writeConfigJSON(w io.Writer, cfg map[string]interface{}, pretty bool) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(cfg)
+}
+
+// boolParams returns the dynamic parameters holding booleans, read from the JSON names of
+// config.DynamicConfig, plus strict
+func boolParams() map[string]bool {
+	booleans := map[string]bool{"strict": true}
+	configType := reflect.TypeOf(config.DynamicConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && field.Type.Kind() == reflect.Bool {
+			booleans[name] = true
+		}
+	}
+	return booleans
+}
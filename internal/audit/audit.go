@@ -0,0 +1,67 @@
+// Package audit records generated configs for the admin view and an append-only audit log:
+// the config type, a hash of the credential, the server and the client, but never the
+// credential itself
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Record is one generated config
+type Record struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	UUIDHash  string    `json:"uuid_hash"` // HashUUID of the credential
+	Server    string    `json:"server"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// Filter selects records for Query
+type Filter struct {
+	Type   string    // Config type; empty matches every type
+	Since  time.Time // Inclusive lower bound; zero leaves it open
+	Until  time.Time // Exclusive upper bound; zero leaves it open
+	Offset int       // Number of matching records to skip
+	Limit  int       // Maximum number of records returned; 0 returns every match
+}
+
+// Matches reports whether rec passes the type and time bounds of the filter
+func (f Filter) Matches(rec Record) bool {
+	if f.Type != "" && rec.Type != f.Type {
+		return false
+	}
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !rec.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Appender receives the records written by a Recorder
+type Appender interface {
+	// Append stores records in the order given
+	Append(records []Record) error
+	// Close releases the appender
+	Close() error
+}
+
+// Store persists audit records and answers queries about them
+type Store interface {
+	Appender
+	// Query returns one page of the records matching filter, newest first, and the number of matches
+	Query(filter Filter) ([]Record, int, error)
+	// DeleteBefore removes records older than cutoff and returns how many were removed
+	DeleteBefore(cutoff time.Time) (int, error)
+}
+
+// HashUUID returns the hex SHA-256 of a credential, so records of a known UUID can be
+// found without the store revealing any
+func HashUUID(uuid string) string {
+	sum := sha256.Sum256([]byte(uuid))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+const testUUID = "bae71742-94e0-4dd5-935f-070339819ba0"
+
+// testTime is the base time of test records
+var testTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// testRecord returns a record of configType generated minutes after testTime
+func testRecord(configType string, minutes int) Record {
+	return Record{
+		Time:     testTime.Add(time.Duration(minutes) * time.Minute),
+		Type:     configType,
+		UUIDHash: HashUUID(testUUID),
+		Server:   "x.example.com",
+		ClientIP: "203.0.113.7",
+	}
+}
+
+func TestHashUUID(t *testing.T) {
+	hash := HashUUID(testUUID)
+	if len(hash) != 64 {
+		t.Errorf("HashUUID length = %d, want 64 hex characters", len(hash))
+	}
+	if hash != HashUUID(testUUID) {
+		t.Error("HashUUID is not deterministic")
+	}
+	if hash == HashUUID("0bd8a7c5-3e2f-4c59-9a3c-1d2b3c4d5e6f") {
+		t.Error("HashUUID of different UUIDs is equal")
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	rec := testRecord("vless", 0)
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"same type", Filter{Type: "vless"}, true},
+		{"other type", Filter{Type: "trojan"}, false},
+		{"since is inclusive", Filter{Since: testTime}, true},
+		{"since after", Filter{Since: testTime.Add(time.Second)}, false},
+		{"until is exclusive", Filter{Until: testTime}, false},
+		{"until after", Filter{Until: testTime.Add(time.Second)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(rec); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
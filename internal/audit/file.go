@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore appends records to a JSON Lines file and scans it for queries. Purges
+// rewrite the file atomically.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenFileStore opens the record file at path for appending, creating it when missing
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit store: %w", err)
+	}
+	return &FileStore{path: path, file: file}, nil
+}
+
+// Append writes records to the end of the file
+func (s *FileStore) Append(records []Record) error {
+	var data []byte
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit record: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit records: %w", err)
+	}
+	return nil
+}
+
+// Query returns one page of the records matching filter, newest first, and the number of matches
+func (s *FileStore) Query(filter Filter) ([]Record, int, error) {
+	s.mu.Lock()
+	records, err := s.read()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Records are appended in time order, so walking backwards yields newest first
+	var page []Record
+	total := 0
+	for i := len(records) - 1; i >= 0; i-- {
+		if !filter.Matches(records[i]) {
+			continue
+		}
+		if total >= filter.Offset && (filter.Limit == 0 || len(page) < filter.Limit) {
+			page = append(page, records[i])
+		}
+		total++
+	}
+	return page, total, nil
+}
+
+// DeleteBefore rewrites the file without the records older than cutoff
+func (s *FileStore) DeleteBefore(cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	kept := records[:0]
+	for _, rec := range records {
+		if !rec.Time.Before(cutoff) {
+			kept = append(kept, rec)
+		}
+	}
+	removed := len(records) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.rewrite(kept)
+}
+
+// Close closes the record file
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// read loads every record of the file; the caller holds mu. Lines that fail to parse,
+// such as one cut short by a crash, are skipped.
+func (s *FileStore) read() ([]Record, error) {
+	file, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit store: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec Record
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit store: %w", err)
+	}
+	return records, nil
+}
+
+// rewrite replaces the file with records via a temporary file and reopens it for
+// appending; the caller holds mu
+func (s *FileStore) rewrite(records []Record) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write audit store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, rec := range records {
+		if err := encoder.Encode(rec); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write audit store: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write audit store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write audit store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace audit store: %w", err)
+	}
+
+	// The open descriptor still points at the replaced file
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen audit store: %w", err)
+	}
+	s.file.Close()
+	s.file = file
+	return nil
+}
@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestStore opens a file store in a temporary directory holding records
+func openTestStore(t *testing.T, records ...Record) (*FileStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Append(records); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	return store, path
+}
+
+// minutesOf returns the minutes after testTime of each record, to compare pages briefly
+func minutesOf(records []Record) []int {
+	minutes := make([]int, len(records))
+	for i, rec := range records {
+		minutes[i] = int(rec.Time.Sub(testTime) / time.Minute)
+	}
+	return minutes
+}
+
+func TestFileStoreQuery(t *testing.T) {
+	var records []Record
+	for i := 0; i < 10; i++ {
+		configType := "vless"
+		if i%2 == 1 {
+			configType = "trojan"
+		}
+		records = append(records, testRecord(configType, i))
+	}
+	store, _ := openTestStore(t, records...)
+
+	tests := []struct {
+		name      string
+		filter    Filter
+		want      []int
+		wantTotal int
+	}{
+		{"everything newest first", Filter{}, []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}, 10},
+		{"first page", Filter{Limit: 3}, []int{9, 8, 7}, 10},
+		{"second page", Filter{Offset: 3, Limit: 3}, []int{6, 5, 4}, 10},
+		{"last partial page", Filter{Offset: 9, Limit: 3}, []int{0}, 10},
+		{"offset past the end", Filter{Offset: 20, Limit: 3}, []int{}, 10},
+		{"type", Filter{Type: "trojan"}, []int{9, 7, 5, 3, 1}, 5},
+		{"type page", Filter{Type: "vless", Offset: 1, Limit: 2}, []int{6, 4}, 5},
+		{"unknown type", Filter{Type: "vmess"}, []int{}, 0},
+		{"since", Filter{Since: testTime.Add(7 * time.Minute)}, []int{9, 8, 7}, 3},
+		{"until", Filter{Until: testTime.Add(2 * time.Minute)}, []int{1, 0}, 2},
+		{"date range and type", Filter{Type: "vless", Since: testTime.Add(2 * time.Minute), Until: testTime.Add(6 * time.Minute)}, []int{4, 2}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, total, err := store.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if got := minutesOf(page); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("page = %v, want %v", got, tt.want)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestFileStoreDeleteBefore(t *testing.T) {
+	store, path := openTestStore(t, testRecord("vless", 0), testRecord("vless", 1), testRecord("trojan", 2), testRecord("vless", 3))
+
+	removed, err := store.DeleteBefore(testTime.Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteBefore: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	if removed, err := store.DeleteBefore(testTime.Add(2 * time.Minute)); err != nil || removed != 0 {
+		t.Errorf("second DeleteBefore = %d, %v, want nothing removed", removed, err)
+	}
+
+	// Appends after a purge go to the rewritten file
+	if err := store.Append([]Record{testRecord("vless", 4)}); err != nil {
+		t.Fatalf("Append after DeleteBefore: %v", err)
+	}
+	page, total, err := store.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if got := minutesOf(page); total != 3 || !reflect.DeepEqual(got, []int{4, 3, 2}) {
+		t.Errorf("records after purge = %v (total %d), want [4 3 2]", got, total)
+	}
+
+	// Records survive reopening the store
+	store.Close()
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	defer reopened.Close()
+	if _, total, err := reopened.Query(Filter{}); err != nil || total != 3 {
+		t.Errorf("reopened store has %d records (%v), want 3", total, err)
+	}
+}
+
+func TestFileStoreSkipsMalformedLines(t *testing.T) {
+	store, path := openTestStore(t, testRecord("vless", 0))
+	// A crash can leave a line cut short
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"2026-03-01T12:01:00Z","ty` + "\n")
+	file.Close()
+	if err := store.Append([]Record{testRecord("vless", 2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	page, total, err := store.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if got := minutesOf(page); total != 2 || !reflect.DeepEqual(got, []int{2, 0}) {
+		t.Errorf("records = %v (total %d), want [2 0]", got, total)
+	}
+}
+
+func TestFileStoreKeepsOnlyUUIDHashes(t *testing.T) {
+	_, path := openTestStore(t, testRecord("vless", 0))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), testUUID) {
+		t.Errorf("store contains the UUID: %s", data)
+	}
+	if !strings.Contains(string(data), `"uuid_hash":"`+HashUUID(testUUID)+`"`) {
+		t.Errorf("store lacks the UUID hash: %s", data)
+	}
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/rotate"
+)
+
+// logFlushInterval is how often buffered log lines are written to the file
+const logFlushInterval = time.Second
+
+// maxLogBacklog bounds the lines kept for the next flush after failed writes; beyond it
+// they are dropped and counted
+const maxLogBacklog = 4 << 20
+
+// Log is an append-only JSON Lines audit trail for log shippers and compliance. Lines are
+// buffered and flushed every second; once the file would exceed maxBytes it is rotated to
+// path.1, older files shift to path.2 and beyond, and only keep rotated files are kept.
+type Log struct {
+	mu     sync.Mutex
+	file   *rotate.File
+	buffer bytes.Buffer // Lines not yet written to file
+	lost   int64        // Lines dropped after failed writes
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenLog opens the audit log at path for appending, creating it when missing. A maxBytes
+// of zero disables rotation.
+func OpenLog(path string, maxBytes int64, keep int) (*Log, error) {
+	file, err := rotate.Open(path, maxBytes, keep)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{file: file, stop: make(chan struct{}), done: make(chan struct{})}
+	go l.flushPeriodically()
+	return l, nil
+}
+
+// Append buffers records as lines until the next flush
+func (l *Log) Append(records []Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit record: %w", err)
+		}
+		l.buffer.Write(line)
+		l.buffer.WriteByte('\n')
+	}
+	return nil
+}
+
+// Flush writes buffered lines to the file in one write, so rotation never splits a line
+func (l *Log) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flush()
+}
+
+// flush writes the buffer to the file; the caller holds l.mu. Lines that could not be
+// written stay buffered for the next flush until the backlog exceeds maxLogBacklog, when
+// they are dropped and counted in the error.
+func (l *Log) flush() error {
+	err := l.write()
+	if err == nil {
+		return nil
+	}
+	if l.buffer.Len() <= maxLogBacklog {
+		return fmt.Errorf("failed to write audit log, keeping %d bytes for the next flush: %w", l.buffer.Len(), err)
+	}
+	return l.drop(err)
+}
+
+// write writes the buffer to the file, keeping whatever was not written; the caller holds l.mu
+func (l *Log) write() error {
+	if l.buffer.Len() == 0 {
+		return nil
+	}
+	n, err := l.file.Write(l.buffer.Bytes())
+	l.buffer.Next(n)
+	return err
+}
+
+// drop discards the buffered lines after the write error err and counts them; the caller holds l.mu
+func (l *Log) drop(err error) error {
+	lines := int64(bytes.Count(l.buffer.Bytes(), []byte{'\n'}))
+	l.buffer.Reset()
+	l.lost += lines
+	return fmt.Errorf("failed to write audit log, dropped %d lines (%d in total): %w", lines, l.lost, err)
+}
+
+// flushPeriodically flushes the buffer every logFlushInterval until Close
+func (l *Log) flushPeriodically() {
+	defer close(l.done)
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.Flush(); err != nil {
+				logrus.WithError(err).WithField("component", "audit").Error("Failed to flush audit log")
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Close flushes buffered lines and closes the file; lines that cannot be written then are
+// dropped, since no later flush would retry them
+func (l *Log) Close() error {
+	close(l.stop)
+	<-l.done
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var flushErr error
+	if err := l.write(); err != nil {
+		flushErr = l.drop(err)
+	}
+	return errors.Join(flushErr, l.file.Close())
+}
+
+// tee appends records to several appenders
+type tee []Appender
+
+// Tee returns an appender writing every record to each of appenders in turn
+func Tee(appenders ...Appender) Appender {
+	if len(appenders) == 1 {
+		return appenders[0]
+	}
+	return tee(appenders)
+}
+
+// Append writes records to every appender, returning their errors joined
+func (t tee) Append(records []Record) error {
+	var errs []error
+	for _, appender := range t {
+		if err := appender.Append(records); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every appender, returning their errors joined
+func (t tee) Close() error {
+	var errs []error
+	for _, appender := range t {
+		if err := appender.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
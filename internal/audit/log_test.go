@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"vless-generator/internal/rotate"
+)
+
+// openTestLog opens an audit log in a temporary directory
+func openTestLog(t *testing.T) (*Log, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := OpenLog(path, 0, 0)
+	if err != nil {
+		t.Fatalf("OpenLog: %v", err)
+	}
+	return l, path
+}
+
+// readLines returns the lines of the file at path
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		t.Errorf("log does not end with a newline: %q", data)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestLogLineFormat(t *testing.T) {
+	l, path := openTestLog(t)
+	defer l.Close()
+
+	withAgent := testRecord("trojan", 1)
+	withAgent.UserAgent = "v2rayNG/1.8"
+	records := []Record{testRecord("vless", 0), withAgent}
+	if err := l.Append(records); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	lines := readLines(t, path)
+	if len(lines) != len(records) {
+		t.Fatalf("log has %d lines, want %d", len(lines), len(records))
+	}
+	want := `{"time":"2026-03-01T12:00:00Z","type":"vless","uuid_hash":"` + HashUUID(testUUID) + `","server":"x.example.com","client_ip":"203.0.113.7"}`
+	if lines[0] != want {
+		t.Errorf("line = %s\nwant   %s", lines[0], want)
+	}
+	for i, line := range lines {
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("line %d is not JSON: %v", i, err)
+		}
+		if !reflect.DeepEqual(rec, records[i]) {
+			t.Errorf("line %d = %+v, want %+v", i, rec, records[i])
+		}
+		if strings.Contains(line, testUUID) {
+			t.Errorf("line %d contains the UUID", i)
+		}
+	}
+}
+
+func TestLogFlushesPeriodically(t *testing.T) {
+	l, path := openTestLog(t)
+	defer l.Close()
+	if err := l.Append([]Record{testRecord("vless", 0)}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * logFlushInterval)
+	for len(readLines(t, path)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("record not flushed within %v", 3*logFlushInterval)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestLogCloseFlushes(t *testing.T) {
+	l, path := openTestLog(t)
+	if err := l.Append([]Record{testRecord("vless", 0), testRecord("vless", 1)}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if lines := readLines(t, path); len(lines) != 2 {
+		t.Errorf("log has %d lines after Close, want 2", len(lines))
+	}
+}
+
+func TestLogKeepsLinesAfterFailedWrite(t *testing.T) {
+	l, path := openTestLog(t)
+	defer l.Close()
+
+	l.mu.Lock()
+	l.file.Close()
+	l.mu.Unlock()
+	if err := l.Append([]Record{testRecord("vless", 0)}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := l.Flush(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("Flush error = %v, want os.ErrClosed", err)
+	}
+
+	// Once the file is writable again the kept line is written
+	file, err := rotate.Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.mu.Lock()
+	l.file = file
+	l.mu.Unlock()
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if lines := readLines(t, path); len(lines) != 1 {
+		t.Errorf("log has %d lines, want the kept line", len(lines))
+	}
+}
+
+func TestLogCloseCountsLostLines(t *testing.T) {
+	l, _ := openTestLog(t)
+	l.mu.Lock()
+	l.file.Close()
+	l.mu.Unlock()
+	if err := l.Append([]Record{testRecord("vless", 0), testRecord("vless", 1)}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	err := l.Close()
+	if !errors.Is(err, os.ErrClosed) || !strings.Contains(err.Error(), "dropped 2 lines") {
+		t.Errorf("Close error = %v, want the 2 dropped lines reported", err)
+	}
+	if l.lost != 2 {
+		t.Errorf("lost = %d, want 2", l.lost)
+	}
+}
+
+// failingAppender fails every call
+type failingAppender struct{ err error }
+
+func (a failingAppender) Append([]Record) error { return a.err }
+func (a failingAppender) Close() error          { return a.err }
+
+func TestTee(t *testing.T) {
+	first, second := newMemoryAppender(false), newMemoryAppender(false)
+	if got := Tee(first); got != Appender(first) {
+		t.Errorf("Tee of one appender = %v, want the appender itself", got)
+	}
+
+	records := []Record{testRecord("vless", 0), testRecord("trojan", 1)}
+	appender := Tee(first, second)
+	if err := appender.Append(records); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := appender.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for i, a := range []*memoryAppender{first, second} {
+		if !reflect.DeepEqual(a.records, records) {
+			t.Errorf("appender %d got %v, want every record", i, a.records)
+		}
+		if !a.closed {
+			t.Errorf("appender %d was not closed", i)
+		}
+	}
+
+	// A failing appender does not stop the others
+	errFailed := errors.New("disk full")
+	third := newMemoryAppender(false)
+	appender = Tee(failingAppender{errFailed}, third)
+	if err := appender.Append(records); !errors.Is(err, errFailed) {
+		t.Errorf("Append error = %v, want %v", err, errFailed)
+	}
+	if err := appender.Close(); !errors.Is(err, errFailed) {
+		t.Errorf("Close error = %v, want %v", err, errFailed)
+	}
+	if len(third.records) != len(records) || !third.closed {
+		t.Error("appender after a failing one was skipped")
+	}
+}
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxWriteBatch bounds how many queued records one store write takes
+const maxWriteBatch = 256
+
+// Recorder queues records on a buffered channel and writes them to an appender from a
+// background goroutine, so requests never wait for the store. Records arriving while
+// the buffer is full are dropped and counted.
+type Recorder struct {
+	store   Appender
+	records chan Record
+	done    chan struct{}
+	logger  *logrus.Entry
+
+	mu      sync.RWMutex // Guards closed against concurrent Record and Close
+	closed  bool
+	dropped atomic.Int64
+}
+
+// NewRecorder starts a recorder writing to store with room for buffer queued records
+func NewRecorder(store Appender, buffer int, logger *logrus.Entry) *Recorder {
+	recorder := &Recorder{
+		store:   store,
+		records: make(chan Record, buffer),
+		done:    make(chan struct{}),
+		logger:  logger,
+	}
+	go recorder.run()
+	return recorder
+}
+
+// Record queues rec without blocking
+func (r *Recorder) Record(rec Record) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.records <- rec:
+	default:
+		dropped := r.dropped.Add(1)
+		// Log the first drop and then every thousandth to avoid flooding the log under load
+		if dropped%1000 == 1 {
+			r.logger.WithField("dropped", dropped).Warn("Audit buffer full, dropping records")
+		}
+	}
+}
+
+// Close stops accepting records, waits until queued ones are written and closes the appender
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.records)
+	r.mu.Unlock()
+
+	<-r.done
+	return r.store.Close()
+}
+
+// run writes queued records in batches until the channel is closed
+func (r *Recorder) run() {
+	defer close(r.done)
+	batch := make([]Record, 0, maxWriteBatch)
+	for rec := range r.records {
+		batch = append(batch[:0], rec)
+	drain:
+		for len(batch) < maxWriteBatch {
+			select {
+			case next, ok := <-r.records:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		if err := r.store.Append(batch); err != nil {
+			r.logger.WithError(err).WithField("records", len(batch)).Error("Failed to write audit records")
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// memoryAppender keeps appended records; with block set, Append waits for unblock
+type memoryAppender struct {
+	mu      sync.Mutex
+	records []Record
+	closed  bool
+
+	block   bool
+	started chan struct{} // Closed by the first Append
+	unblock chan struct{}
+	once    sync.Once
+}
+
+func newMemoryAppender(block bool) *memoryAppender {
+	return &memoryAppender{block: block, started: make(chan struct{}), unblock: make(chan struct{})}
+}
+
+func (a *memoryAppender) Append(records []Record) error {
+	a.once.Do(func() { close(a.started) })
+	if a.block {
+		<-a.unblock
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, records...)
+	return nil
+}
+
+func (a *memoryAppender) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	return nil
+}
+
+// quietLogger returns a logger discarding its output
+func quietLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func TestRecorderCloseDrainsQueue(t *testing.T) {
+	appender := newMemoryAppender(false)
+	recorder := NewRecorder(appender, 1000, quietLogger())
+
+	var want []Record
+	for i := 0; i < 600; i++ {
+		rec := testRecord("vless", i)
+		recorder.Record(rec)
+		want = append(want, rec)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	appender.mu.Lock()
+	defer appender.mu.Unlock()
+	if !reflect.DeepEqual(appender.records, want) {
+		t.Errorf("appended %d records, want all %d in order", len(appender.records), len(want))
+	}
+	if !appender.closed {
+		t.Error("Close did not close the appender")
+	}
+	if recorder.dropped.Load() != 0 {
+		t.Errorf("dropped = %d, want 0", recorder.dropped.Load())
+	}
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	appender := newMemoryAppender(true)
+	recorder := NewRecorder(appender, 1, quietLogger())
+
+	// The first record is taken by the writer, which then blocks in Append
+	recorder.Record(testRecord("vless", 0))
+	select {
+	case <-appender.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recorder never appended")
+	}
+
+	// One record fits the buffer; the rest are dropped without blocking
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 4; i++ {
+			recorder.Record(testRecord("vless", i))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record blocked on a full buffer")
+	}
+	if dropped := recorder.dropped.Load(); dropped != 3 {
+		t.Errorf("dropped = %d, want 3", dropped)
+	}
+
+	close(appender.unblock)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	appender.mu.Lock()
+	defer appender.mu.Unlock()
+	if got := minutesOf(appender.records); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("appended records %v, want [0 1]", got)
+	}
+}
+
+func TestRecorderIgnoresRecordsAfterClose(t *testing.T) {
+	appender := newMemoryAppender(false)
+	recorder := NewRecorder(appender, 10, quietLogger())
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	recorder.Record(testRecord("vless", 0))
+	if err := recorder.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if len(appender.records) != 0 {
+		t.Errorf("appended %d records after Close, want 0", len(appender.records))
+	}
+}
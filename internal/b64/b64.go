@@ -0,0 +1,31 @@
+// Package b64 encodes and decodes the base64 values found in share links, subscriptions and
+// query parameters. It imports nothing from the service, so config and utils can both use it.
+package b64
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// EncodeURL encodes bytes to unpadded base64url, safe in query strings and paths
+func EncodeURL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeLenient decodes base64 as users paste it: either alphabet, with or without padding,
+// with line breaks or tabs left by wrapping encoders, and with spaces where an unescaped +
+// in a query string was decoded to a space
+func DecodeLenient(encoded string) ([]byte, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case '\t', '\r', '\n':
+			return -1
+		case '+', ' ':
+			return '-'
+		case '/':
+			return '_'
+		}
+		return r
+	}, encoded)
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(normalized, "="))
+}
@@ -0,0 +1,76 @@
+package b64
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func TestDecodeLenient(t *testing.T) {
+	qrPNG, err := qrcode.Encode("vless://bae71742-94e0-4dd5-935f-070339819ba0@x.example.com:443#Сервер", qrcode.Medium, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string][]byte{
+		"qr png":          {0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0x00, 0xfb, 0xff, 0xfe},
+		"qr code":         qrPNG,
+		"unicode":         []byte("Сервер 🚀 سرور #1"),
+		"unicode remarks": []byte("vless://x@a.example.com:443#%D0%A1%D0%B5%D1%80%D0%B2%D0%B5%D1%80\nss://x@b.example.com:8388#東京 🚀"),
+		"one byte tail":   []byte("a"),
+		"two byte tail":   []byte("ab"),
+		"no tail":         []byte("abc"),
+		"empty":           {},
+		"url alphabet":    bytes.Repeat([]byte{0xfb, 0xff, 0xbf}, 40),
+	}
+	encodings := map[string]func([]byte) string{
+		"std padded":   base64.StdEncoding.EncodeToString,
+		"std raw":      base64.RawStdEncoding.EncodeToString,
+		"url padded":   base64.URLEncoding.EncodeToString,
+		"url raw":      EncodeURL,
+		"wrapped crlf": func(data []byte) string { return wrap(base64.StdEncoding.EncodeToString(data), "\r\n") },
+		"wrapped tab":  func(data []byte) string { return wrap(base64.StdEncoding.EncodeToString(data), "\n\t") },
+		"plus as space": func(data []byte) string {
+			return strings.ReplaceAll(base64.StdEncoding.EncodeToString(data), "+", " ")
+		},
+	}
+	for inputName, input := range inputs {
+		for encodingName, encode := range encodings {
+			encoded := encode(input)
+			decoded, err := DecodeLenient(encoded)
+			if err != nil || !bytes.Equal(decoded, input) {
+				t.Errorf("%s as %s (%q): got %x, %v", inputName, encodingName, encoded, decoded, err)
+			}
+		}
+	}
+}
+
+func TestDecodeLenientRejects(t *testing.T) {
+	for _, encoded := range []string{"a", "ab!c", "abc=d", "éé", "YWJj.ZA"} {
+		if decoded, err := DecodeLenient(encoded); err == nil {
+			t.Errorf("DecodeLenient(%q) = %q, want an error", encoded, decoded)
+		}
+	}
+}
+
+func TestEncodeURL(t *testing.T) {
+	encoded := EncodeURL([]byte{0xfb, 0xff, 0xbf, 0x01})
+	if strings.ContainsAny(encoded, "+/=") {
+		t.Fatalf("EncodeURL = %q, want the unpadded url alphabet", encoded)
+	}
+	if encoded != "-_-_AQ" {
+		t.Fatalf("EncodeURL = %q, want -_-_AQ", encoded)
+	}
+}
+
+// wrap breaks s into 16 character lines joined by sep
+func wrap(s, sep string) string {
+	var lines []string
+	for len(s) > 16 {
+		lines = append(lines, s[:16])
+		s = s[16:]
+	}
+	return strings.Join(append(lines, s), sep)
+}
@@ -0,0 +1,35 @@
+// Package buildinfo holds the version of the running binary and its start time
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// Build metadata, set at build time with
+// -ldflags "-X vless-generator/internal/buildinfo.Version=1.2.3 -X vless-generator/internal/buildinfo.Commit=$(git rev-parse --short HEAD) -X vless-generator/internal/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "1.0.0"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// StartTime is when the process started
+var StartTime = time.Now()
+
+// Uptime returns how long the process has been running
+func Uptime() time.Duration {
+	return time.Since(StartTime)
+}
+
+// GoVersion returns the Go release the binary was built with
+func GoVersion() string {
+	return runtime.Version()
+}
+
+// String returns a one-line description of the build, printed by -version
+func String() string {
+	return fmt.Sprintf("vless-generator %s (commit %s, built %s, %s %s/%s)",
+		Version, Commit, BuildDate, GoVersion(), runtime.GOOS, runtime.GOARCH)
+}
@@ -1,11 +1,31 @@
 package config
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"net/netip"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	logrussyslog "github.com/sirupsen/logrus/hooks/syslog"
+	"gopkg.in/yaml.v3"
+
+	"vless-generator/internal/b64"
+	"vless-generator/internal/buildinfo"
+	"vless-generator/internal/rotate"
 )
 
 // Config holds all application configuration
@@ -13,36 +33,404 @@ type Config struct {
 	Server    ServerConfig
 	Service   ServiceConfig
 	Templates TemplatesConfig
+	Defaults  *DynamicConfig // Deployment defaults for dynamic parameters absent from a request
+	FromEnv   []string       // Environment variables that supplied flag values
+}
+
+// redactedValue replaces secrets in Redacted
+const redactedValue = "REDACTED"
+
+// Redacted returns a copy of the configuration with tokens, keys and passwords replaced,
+// safe to show on debug endpoints
+func (c Config) Redacted() Config {
+	redacted := c
+	if len(c.Server.AuthTokens) > 0 {
+		redacted.Server.AuthTokens = make([]string, len(c.Server.AuthTokens))
+		for i := range redacted.Server.AuthTokens {
+			redacted.Server.AuthTokens[i] = redactedValue
+		}
+	}
+	if c.Server.SigningKey != "" {
+		redacted.Server.SigningKey = redactedValue
+	}
+	if parsed, err := url.Parse(c.Templates.URL); err == nil && parsed.User != nil {
+		redacted.Templates.URL = parsed.Redacted()
+	}
+	if c.Defaults != nil {
+		defaults := *c.Defaults
+		if defaults.ClashAPISecret != "" {
+			defaults.ClashAPISecret = redactedValue
+		}
+		if defaults.ObfsPassword != "" {
+			defaults.ObfsPassword = redactedValue
+		}
+		if defaults.WarpPrivateKey != "" {
+			defaults.WarpPrivateKey = redactedValue
+		}
+		redacted.Defaults = &defaults
+	}
+	return redacted
+}
+
+// Fingerprints lists the uTLS fingerprints accepted by the fp parameter
+var Fingerprints = []string{"chrome", "firefox", "safari", "ios", "android", "edge", "random"}
+
+// IsValidFingerprint reports whether fp is a known uTLS fingerprint
+func IsValidFingerprint(fp string) bool {
+	for _, known := range Fingerprints {
+		if fp == known {
+			return true
+		}
+	}
+	return false
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	Port string // HTTP server port only
+	Port            string        // HTTP server port (shorthand for Listen ":<port>")
+	Listen          string        // Listen address: host:port or unix:/path/to.sock (overrides Port)
+	ShutdownTimeout time.Duration // Time allowed for in-flight requests to finish on shutdown
+	ReadTimeout     time.Duration // Maximum time to read a request, including headers and body
+	WriteTimeout    time.Duration // Maximum time to write a response
+	IdleTimeout     time.Duration // Maximum time a keep-alive connection may stay idle
+	MaxHeaderBytes  int           // Maximum size of request headers, including the request line
+	TLSCert         string        // TLS certificate file (enables HTTPS together with TLSKey)
+	TLSKey          string        // TLS private key file
+	ACMEDomains     []string      // Domains to obtain Let's Encrypt certificates for (serves :443 and :80)
+	ACMECacheDir    string        // Directory where ACME certificates are cached
+
+	TrustProxyHeaders bool           // Build absolute URLs from X-Forwarded-Proto/X-Forwarded-Host
+	TrustedProxies    []netip.Prefix // Peers whose X-Forwarded-For/X-Real-IP are used to find the client IP
+	CORSOrigins       []string       // Origins allowed to call the JSON API from browsers ("*" allows any)
+	AuthTokens        []string       // Tokens accepted by config generation routes; empty leaves them open
+	SigningKey        string         // HMAC key; when set, generation routes accept signed links instead of a token
+	DebugAddr         string         // Separate listen address for pprof, expvar and the config dump; empty disables it
+	OTelEndpoint      string         // OTLP/HTTP collector URL receiving traces; empty disables tracing
+}
+
+// ParseTrustedProxies parses proxy CIDRs; a bare IP address trusts that single address
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -trusted-proxies entry %q: expected a CIDR or IP address", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ParseCORSOrigins checks that every entry is "*" or a scheme://host[:port] origin
+func ParseCORSOrigins(values []string) ([]string, error) {
+	origins := make([]string, 0, len(values))
+	for _, value := range values {
+		origin := strings.TrimSuffix(value, "/")
+		if origin != "*" {
+			parsed, err := url.Parse(origin)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.Path != "" || parsed.RawQuery != "" || parsed.User != nil {
+				return nil, fmt.Errorf("invalid -cors-origins entry %q: expected * or an origin such as https://admin.example.com", value)
+			}
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+// unixSocketPrefix marks a Listen address as a unix socket path
+const unixSocketPrefix = "unix:"
+
+// ListenAddress returns the address to listen on: -listen when set, otherwise
+// :443 for automatic certificates or :<port>
+func (s ServerConfig) ListenAddress() string {
+	switch {
+	case s.Listen != "":
+		return s.Listen
+	case len(s.ACMEDomains) > 0:
+		return ":443"
+	default:
+		return ":" + s.Port
+	}
+}
+
+// UnixSocket returns the socket path when the listen address is a unix socket
+func (s ServerConfig) UnixSocket() (string, bool) {
+	address := s.ListenAddress()
+	if !strings.HasPrefix(address, unixSocketPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(address, unixSocketPrefix), true
+}
+
+// TLSEnabled reports whether the server terminates TLS itself
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCert != "" || len(s.ACMEDomains) > 0
 }
 
-// DynamicConfig holds configuration parameters from GET request
+// ValidateTLS checks that the TLS flags form a usable combination
+func (s ServerConfig) ValidateTLS() error {
+	if (s.TLSCert == "") != (s.TLSKey == "") {
+		return fmt.Errorf("-tls-cert and -tls-key must be set together")
+	}
+	if s.TLSCert != "" && len(s.ACMEDomains) > 0 {
+		return fmt.Errorf("-acme-domain cannot be combined with -tls-cert/-tls-key")
+	}
+	for _, domain := range s.ACMEDomains {
+		if !IsValidHost(domain) {
+			return fmt.Errorf("invalid -acme-domain %q: expected a hostname", domain)
+		}
+	}
+	if len(s.ACMEDomains) > 0 && s.ACMECacheDir == "" {
+		return fmt.Errorf("-acme-cache-dir is required with -acme-domain")
+	}
+	return nil
+}
+
+// DynamicConfig holds configuration parameters from GET request.
+// JSON field names match the query parameter names.
 type DynamicConfig struct {
-	Server     string // VLESS server address
-	ServerPort int    // VLESS server port
-	WSPath     string // WebSocket path
-	DNSServer  string // Remote DNS server
-	DOHServer  string // DNS over HTTPS server
-	TunAddress string // TUN interface address
-	MixedPort  int    // Mixed proxy port
-	TunMTU     int    // TUN interface MTU
+	Server       string `json:"server"`         // VLESS server address
+	ServerPort   int    `json:"port"`           // VLESS server port
+	WSPath       string `json:"ws-path"`        // WebSocket path
+	DNSServer    string `json:"dns-server"`     // Remote DNS server
+	DOHServer    string `json:"doh-server"`     // DNS over HTTPS server
+	TunAddress   string `json:"tun-address"`    // TUN interface address
+	MixedPort    int    `json:"mixed-port"`     // Mixed proxy port
+	TunMTU       int    `json:"tun-mtu"`        // TUN interface MTU
+	Name         string `json:"name,omitempty"` // Remark shown by clients (defaults to <server>-<type>)
+	TunEnabled   bool   `json:"tun"`            // Include the TUN inbound
+	MixedEnabled bool   `json:"mixed"`          // Include the mixed (socks/http) inbound
+	SNI          string `json:"sni,omitempty"`  // TLS server name (defaults to Server)
+	Host         string `json:"host,omitempty"` // WebSocket Host header (defaults to SNI, then Server)
+
+	// Multi-server configs: one proxy outbound per node behind selector and urltest groups
+	Servers []ServerNode `json:"servers,omitempty"` // Empty generates a single-server config
+
+	// Template selection
+	Variant string `json:"variant,omitempty"` // Template variant (e.g., mobile); empty selects <type>.json
+
+	// Transport settings
+	Transport       string `json:"transport"`              // Transport type: ws (default), grpc or tcp
+	GRPCServiceName string `json:"grpc-service,omitempty"` // gRPC service name
+	Flow            string `json:"flow,omitempty"`         // VLESS flow (e.g., xtls-rprx-vision)
+
+	// TLS fingerprint and Reality settings
+	TLS              bool     `json:"tls"`            // TLS enabled (false for plaintext behind a local reverse proxy)
+	Fingerprint      string   `json:"fp"`             // uTLS fingerprint (e.g., chrome)
+	ALPN             []string `json:"alpn,omitempty"` // TLS ALPN protocols (empty keeps the template value)
+	RealityPublicKey string   `json:"pbk,omitempty"`  // Reality public key (pbk)
+	RealityShortID   string   `json:"sid,omitempty"`  // Reality short ID (sid)
+
+	// Routing bypass rules (sent to the direct outbound)
+	BypassDomains []string `json:"bypass-domains,omitempty"` // Domain suffixes (lowercase)
+	BypassCIDRs   []string `json:"bypass-cidrs,omitempty"`   // IP CIDRs
+	BypassGeoIP   []string `json:"bypass-geoip,omitempty"`   // GeoIP country codes (e.g., ru, cn)
+	BlockAds      bool     `json:"block-ads"`                // Route geosite-category-ads-all to a block outbound
+	FakeIP        bool     `json:"fakeip"`                   // Answer proxied A/AAAA queries from a fakeip pool
+
+	// Clash API (external controller) settings
+	ClashAPIPort   int    `json:"clash-api-port,omitempty"`   // Local controller port (0 disables the clash_api block)
+	ClashAPISecret string `json:"clash-api-secret,omitempty"` // Controller secret
+
+	// Multiplex settings (JSON config only; share URLs cannot carry them)
+	Mux           bool   `json:"mux"`                       // Enable the outbound multiplex block
+	MuxProtocol   string `json:"mux-protocol"`              // Multiplex protocol: smux, yamux or h2mux
+	MuxMaxStreams int    `json:"mux-max-streams,omitempty"` // Maximum streams per connection (0 keeps the sing-box default)
+
+	// Cloudflare WARP chaining (JSON config only): proxy outbounds detour through a wireguard outbound
+	Warp              bool     `json:"warp"`                           // Add the wireguard outbound and set it as the detour
+	WarpPrivateKey    string   `json:"warp-private-key,omitempty"`     // WireGuard private key (base64)
+	WarpPeerPublicKey string   `json:"warp-peer-public-key,omitempty"` // WARP peer public key (base64)
+	WarpAddress       []string `json:"warp-address,omitempty"`         // Interface addresses assigned by WARP (CIDRs)
+
+	// Advanced overrides
+	Schema string                 `json:"schema,omitempty"` // Output schema: legacy or modern (sing-box 1.11+); empty follows the template
+	Patch  map[string]interface{} `json:"patch,omitempty"`  // RFC 7386 JSON merge patch applied to the generated config
+
+	// Hysteria2 specific settings
+	UpMbps       int    `json:"up"`                      // Upload bandwidth in Mbps
+	DownMbps     int    `json:"down"`                    // Download bandwidth in Mbps
+	ObfsPassword string `json:"obfs-password,omitempty"` // Salamander obfuscation password (empty disables obfs)
+}
+
+// MaxServers caps the number of nodes of the servers parameter
+const MaxServers = 32
+
+// ServerNode is one entry of the servers parameter, written as [name@]host[:port]
+type ServerNode struct {
+	Name string // Outbound tag and remark; empty uses the host
+	Host string
+	Port int // 0 uses the port parameter
+}
+
+// String formats the node as a servers parameter entry
+func (n ServerNode) String() string {
+	entry := n.Host
+	if strings.Contains(entry, ":") {
+		entry = "[" + entry + "]"
+	}
+	if n.Port != 0 {
+		entry += ":" + strconv.Itoa(n.Port)
+	}
+	if n.Name != "" {
+		entry = n.Name + "@" + entry
+	}
+	return entry
+}
+
+// MarshalText encodes the node as its servers parameter entry, so configs serialize it as a string
+func (n ServerNode) MarshalText() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+// UnmarshalText parses a servers parameter entry
+func (n *ServerNode) UnmarshalText(text []byte) error {
+	node, err := ParseServerNode(string(text))
+	if err != nil {
+		return err
+	}
+	*n = node
+	return nil
+}
+
+// ParseServerNode parses a [name@]host[:port] entry; IPv6 hosts with a port are bracketed
+func ParseServerNode(entry string) (ServerNode, error) {
+	var node ServerNode
+	address := entry
+	if name, rest, found := strings.Cut(entry, "@"); found {
+		node.Name, address = strings.TrimSpace(name), rest
+		if node.Name == "" {
+			return ServerNode{}, fmt.Errorf("empty name before @")
+		}
+	}
+
+	node.Host = address
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		node.Host = host
+		value, err := strconv.Atoi(port)
+		if err != nil || value < 1 || value > 65535 {
+			return ServerNode{}, fmt.Errorf("invalid port %q", port)
+		}
+		node.Port = value
+	} else {
+		node.Host = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	}
+	if !IsValidHost(node.Host) {
+		return ServerNode{}, fmt.Errorf("invalid host %q", node.Host)
+	}
+	return node, nil
+}
+
+// Clone returns a copy of the configuration that does not share slices with the original
+func (c *DynamicConfig) Clone() *DynamicConfig {
+	clone := *c
+	clone.ALPN = append([]string(nil), c.ALPN...)
+	clone.Servers = append([]ServerNode(nil), c.Servers...)
+	clone.BypassDomains = append([]string(nil), c.BypassDomains...)
+	clone.BypassCIDRs = append([]string(nil), c.BypassCIDRs...)
+	clone.BypassGeoIP = append([]string(nil), c.BypassGeoIP...)
+	clone.WarpAddress = append([]string(nil), c.WarpAddress...)
+	return &clone
+}
+
+// Values returns query parameters that ParseDynamicConfig turns back into an equal
+// configuration; the JSON field names are the parameter names
+func (c *DynamicConfig) Values() url.Values {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return url.Values{}
+	}
+	var params map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&params); err != nil {
+		return url.Values{}
+	}
+	values, _ := ParamsToValues(params)
+	return values
+}
+
+// DefaultsFields lists the dynamic parameters whose defaults can be overridden per deployment
+var DefaultsFields = []string{"server", "port", "ws-path", "dns-server", "doh-server", "tun-address", "mixed-port", "tun-mtu"}
+
+// LoadDefaultsFile applies deployment defaults from a JSON or YAML file (by extension) onto defaults.
+// Keys use the query parameter names; keys outside DefaultsFields are rejected.
+func LoadDefaultsFile(path string, defaults *DynamicConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read defaults file: %w", err)
+	}
+
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		err = json.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse defaults file %s: %w", path, err)
+	}
+
+	allowed := make(map[string]bool, len(DefaultsFields))
+	for _, name := range DefaultsFields {
+		allowed[name] = true
+	}
+	for name := range values {
+		if !allowed[name] {
+			return fmt.Errorf("unknown key %q in defaults file %s (supported: %s)", name, path, strings.Join(DefaultsFields, ", "))
+		}
+	}
+
+	// Re-encode as JSON so the DynamicConfig json tags map keys onto fields
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to encode defaults: %w", err)
+	}
+	if err := json.Unmarshal(encoded, defaults); err != nil {
+		return fmt.Errorf("invalid value in defaults file %s: %w", path, err)
+	}
+	return nil
+}
+
+// ValidateDefaults checks deployment defaults with the same rules as query parameters
+func ValidateDefaults(defaults *DynamicConfig) []ParamError {
+	values := url.Values{}
+	values.Set("server", defaults.Server)
+	values.Set("port", strconv.Itoa(defaults.ServerPort))
+	values.Set("ws-path", defaults.WSPath)
+	values.Set("dns-server", defaults.DNSServer)
+	values.Set("doh-server", defaults.DOHServer)
+	values.Set("tun-address", defaults.TunAddress)
+	values.Set("mixed-port", strconv.Itoa(defaults.MixedPort))
+	values.Set("tun-mtu", strconv.Itoa(defaults.TunMTU))
+	_, errs := ParseDynamicConfig(values, nil)
+	return errs
 }
 
 // DefaultDynamicConfig returns default values for dynamic configuration
 func DefaultDynamicConfig() *DynamicConfig {
 	return &DynamicConfig{
-		Server:     "vless.example.com",
-		ServerPort: 443,
-		WSPath:     "/websocket",
-		DNSServer:  "8.8.8.8",
-		DOHServer:  "https://223.5.5.5/dns-query",
-		TunAddress: "172.19.0.1/28",
-		MixedPort:  2080,
-		TunMTU:     9000,
+		Server:       "vless.example.com",
+		ServerPort:   443,
+		WSPath:       "/websocket",
+		DNSServer:    "8.8.8.8",
+		DOHServer:    "https://223.5.5.5/dns-query",
+		TunAddress:   "172.19.0.1/28",
+		MixedPort:    2080,
+		TunMTU:       9000,
+		Transport:    "ws",
+		TunEnabled:   true,
+		MixedEnabled: true,
+		TLS:          true,
+		MuxProtocol:  "smux",
+		Fingerprint:  "chrome",
+		UpMbps:       50,
+		DownMbps:     100,
 	}
 }
 
@@ -57,38 +445,352 @@ type NetworkConfig struct {
 
 // ServiceConfig holds service-specific configuration
 type ServiceConfig struct {
-	LogLevel  string
-	LogFormat string
+	LogLevel     string
+	LogFormat    string
+	MaxBatchSize int // Maximum number of entries accepted by the batch API
+	PreviewBytes int // Maximum size of the JSON preview on config pages; 0 hides the preview
+
+	LogOutput       LogOutput // Destination of the service log
+	LogFileMaxBytes int64     // Size at which a file log output is rotated; 0 disables rotation
+	LogFileKeep     int       // Number of rotated log files kept
+
+	Maintenance        bool   // Start with generation routes answering 503
+	MaintenanceMessage string // Message of maintenance responses; empty uses the localized default
+
+	ConfigCacheMaxAge time.Duration // Cache-Control max-age of config downloads and subscriptions
+	ProfileQR         bool          // Show a QR code of the sing-box profile link on config pages
+	StrictRender      bool          // Render pages into a buffer before sending them
+
+	ResponseCacheTTL        time.Duration // How long config pages and downloads are served from memory; 0 disables the cache
+	ResponseCacheMaxEntries int           // Maximum number of cached responses
+
+	QRWorkers    int // QR codes encoded at once; 0 uses GOMAXPROCS
+	QRQueueDepth int // Requests waiting for a QR worker before new ones get 503
+
+	ShortLinkDB  string        // File storing /s/<id> short links; empty disables them
+	ShortLinkTTL time.Duration // Default lifetime of short links; 0 keeps them forever
+
+	AuditStore     string        // File recording generated configs for GET /admin/configs; empty disables auditing
+	AuditRetention time.Duration // How long audit records are kept; 0 keeps them forever
+
+	AuditLog         string // Append-only JSON Lines audit trail of generated configs; empty disables it
+	AuditLogMaxBytes int64  // Size at which the audit log is rotated; 0 disables rotation
+	AuditLogKeep     int    // Number of rotated audit log files kept
+
+	RedactSecrets       bool     // Mask UUIDs and credentials in logs
+	LogDebugPaths       []string // Path prefixes whose successful requests are logged at Debug
+	LogStaticSampleRate float64  // Fraction of successful /static/ requests written to the access log
 }
 
 // TemplatesConfig holds template-related configuration
 type TemplatesConfig struct {
-	Directory string
-	Types     []string
+	Directory             string        // Directory with <type>.json templates; empty uses the embedded copies
+	URL                   string        // Base URL serving <type>.json templates; empty disables remote templates
+	CacheDirectory        string        // Directory caching remote templates for restarts while the URL is down
+	RefreshInterval       time.Duration // How often remote templates are re-fetched (0 disables refreshing)
+	FetchTimeout          time.Duration // Timeout of a single remote template request
+	TranslationsDirectory string        // Directory with <lang>.json translations; empty uses the embedded copies
+	Types                 []string
+}
+
+// ValidateSource checks that the template source flags form a usable combination
+func (t TemplatesConfig) ValidateSource() error {
+	if t.URL == "" {
+		return nil
+	}
+	if t.Directory != "" {
+		return fmt.Errorf("-templates-url cannot be combined with -templates-dir")
+	}
+	parsed, err := url.Parse(t.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid -templates-url %q: expected an http or https URL", t.URL)
+	}
+	if t.CacheDirectory == "" {
+		return fmt.Errorf("-templates-cache-dir is required with -templates-url")
+	}
+	if t.FetchTimeout <= 0 {
+		return fmt.Errorf("-templates-fetch-timeout must be positive")
+	}
+	if t.RefreshInterval < 0 {
+		return fmt.Errorf("-templates-refresh must not be negative")
+	}
+	return nil
 }
 
 // LoadConfig parses command-line flags and returns configuration
 func LoadConfig() *Config {
 	cfg := &Config{}
 
-	// Server configuration - only port for the HTTP server
-	flag.StringVar(&cfg.Server.Port, "port", "8080", "Port to run the HTTP server on")
+	// Server configuration
+	flag.StringVar(&cfg.Server.Port, "port", "8080", "Port to run the HTTP server on (shorthand for -listen :<port>)")
+	flag.StringVar(&cfg.Server.Listen, "listen", "", "Listen address: host:port (e.g., 127.0.0.1:8080) or unix:/path/to.sock; overrides -port")
+	flag.DurationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", 15*time.Second, "Time to wait for in-flight requests to finish on shutdown")
+	flag.DurationVar(&cfg.Server.ReadTimeout, "read-timeout", 10*time.Second, "Maximum duration for reading a request, including headers and body")
+	flag.DurationVar(&cfg.Server.WriteTimeout, "write-timeout", 30*time.Second, "Maximum duration for writing a response")
+	flag.DurationVar(&cfg.Server.IdleTimeout, "idle-timeout", 60*time.Second, "Maximum time to keep an idle keep-alive connection open")
+	flag.StringVar(&cfg.Server.TLSCert, "tls-cert", "", "TLS certificate file; serves HTTPS together with -tls-key")
+	flag.StringVar(&cfg.Server.TLSKey, "tls-key", "", "TLS private key file")
+	acmeDomains := flag.String("acme-domain", "", "Comma-separated domains to obtain Let's Encrypt certificates for (listens on :443 and redirects :80)")
+	flag.StringVar(&cfg.Server.ACMECacheDir, "acme-cache-dir", "autocert-cache", "Directory for cached ACME certificates")
+	flag.Var((*listFlag)(&cfg.Server.AuthTokens), "auth-token", "Token required by config pages, downloads, subscriptions and the API as ?token= or Authorization: Bearer (repeatable or comma-separated)")
+	flag.StringVar(&cfg.Server.SigningKey, "signing-key", "", "HMAC key for signed links: config pages, downloads and subscriptions then require sig and exp (or an -auth-token); requires -auth-token")
+	corsOrigins := flag.String("cors-origins", "", "Comma-separated origins allowed to call the JSON API from browsers (e.g., https://admin.example.com), or * for any")
+	trustedProxies := flag.String("trusted-proxies", "", "Comma-separated proxy CIDRs or IPs whose X-Forwarded-For is used for the client IP (e.g., 127.0.0.1,10.0.0.0/8)")
+	flag.BoolVar(&cfg.Server.TrustProxyHeaders, "trust-proxy-headers", false, "Build absolute URLs from X-Forwarded-Proto and X-Forwarded-Host (enable only behind a reverse proxy that sets them)")
+	flag.StringVar(&cfg.Server.DebugAddr, "debug-addr", "", "Listen address of a separate debug server with pprof, /debug/vars, /debug/config and request replay (e.g., 127.0.0.1:6060); never expose it publicly")
+	flag.StringVar(&cfg.Server.OTelEndpoint, "otel-endpoint", "", "OTLP/HTTP collector URL to export request traces to (e.g., http://otel-collector:4318); empty disables tracing")
+	flag.IntVar(&cfg.Server.MaxHeaderBytes, "max-header-bytes", 256<<10, "Maximum size of request headers in bytes (query strings count towards it)")
 
 	// Service configuration
 	flag.StringVar(&cfg.Service.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
 	flag.StringVar(&cfg.Service.LogFormat, "log-format", "json", "Log format (json, text)")
+	logOutput := flag.String("log-output", "stdout", "Log destination: stdout, stderr, file:<path> (rotated by size) or syslog:<network>:<addr> (e.g., syslog:udp:localhost:514; plain syslog uses the local daemon)")
+	flag.Int64Var(&cfg.Service.LogFileMaxBytes, "log-file-max-bytes", 100<<20, "Size in bytes at which a file:<path> log output is rotated (0 disables rotation)")
+	flag.IntVar(&cfg.Service.LogFileKeep, "log-file-keep", 5, "Number of rotated log files kept next to the current one")
+	flag.BoolVar(&cfg.Service.RedactSecrets, "redact-secrets", true, "Mask UUIDs and credentials in logs, keeping their first 8 characters (-redact-secrets=false logs them in full)")
+	logDebugPaths := flag.String("log-debug-paths", "/health,/ready,/static/", "Comma-separated path prefixes whose successful requests are logged at debug level instead of info")
+	flag.Float64Var(&cfg.Service.LogStaticSampleRate, "log-static-sample-rate", 1, "Fraction (0 to 1) of successful /static/ requests written to the access log")
+	flag.IntVar(&cfg.Service.MaxBatchSize, "batch-max-size", 1000, "Maximum number of configs generated by one batch API request")
+	flag.IntVar(&cfg.Service.PreviewBytes, "config-preview-max-bytes", 64<<10, "Maximum size in bytes of the JSON preview on config pages (0 hides the preview)")
+	flag.BoolVar(&cfg.Service.StrictRender, "strict-render", false, "Render HTML pages into a buffer before sending them, so a template failure answers with a clean 500 instead of a partial page (costs an extra copy per page view)")
+	flag.BoolVar(&cfg.Service.ProfileQR, "profile-qr", true, "Show a second QR code on config pages that imports the JSON profile into sing-box (-profile-qr=false hides it)")
+	flag.StringVar(&cfg.Service.ShortLinkDB, "shortlink-db", "", "File storing short links created by POST /api/v1/shorten (empty disables short links)")
+	flag.DurationVar(&cfg.Service.ShortLinkTTL, "shortlink-ttl", 30*24*time.Hour, "Default lifetime of short links (0 keeps them until deleted)")
+	flag.StringVar(&cfg.Service.AuditStore, "audit-store", "", "File recording every generated config (type, UUID hash, server, client IP) for GET /admin/configs (empty disables auditing)")
+	flag.DurationVar(&cfg.Service.AuditRetention, "audit-retention", 90*24*time.Hour, "How long audit records are kept (0 keeps them forever)")
+	flag.StringVar(&cfg.Service.AuditLog, "audit-log", "", "Append-only JSON Lines audit log of every generated config (time, type, UUID hash, server, client IP, user agent) for log shippers (empty disables it)")
+	flag.Int64Var(&cfg.Service.AuditLogMaxBytes, "audit-log-max-bytes", 100<<20, "Size in bytes at which the audit log is rotated (0 disables rotation)")
+	flag.IntVar(&cfg.Service.AuditLogKeep, "audit-log-keep", 5, "Number of rotated audit log files kept next to the current one")
+	flag.BoolVar(&cfg.Service.Maintenance, "maintenance", false, "Start in maintenance mode: generation routes answer 503 until POST /admin/maintenance turns it off")
+	flag.StringVar(&cfg.Service.MaintenanceMessage, "maintenance-message", "", "Message shown by generation routes in maintenance mode (empty uses the localized \"temporarily unavailable\" text)")
+	flag.DurationVar(&cfg.Service.ResponseCacheTTL, "response-cache-ttl", 0, "How long identical config page and download requests are answered from memory (0 disables the cache; ignored with -auth-token or -signing-key)")
+	flag.IntVar(&cfg.Service.ResponseCacheMaxEntries, "response-cache-max-entries", 1000, "Maximum number of responses kept by the response cache; the least recently used are evicted")
+	flag.IntVar(&cfg.Service.QRWorkers, "qr-workers", 0, "Maximum number of QR codes encoded at once (0 uses GOMAXPROCS)")
+	flag.IntVar(&cfg.Service.QRQueueDepth, "qr-queue-depth", 64, "Maximum number of requests waiting for a free QR worker; beyond it requests get 503 with Retry-After")
+	flag.DurationVar(&cfg.Service.ConfigCacheMaxAge, "config-cache-max-age", 5*time.Minute, "How long clients may reuse a downloaded config or subscription before revalidating it by ETag (0 revalidates every time)")
+
+	// Default dynamic parameters; query parameters still override them per request
+	cfg.Defaults = DefaultDynamicConfig()
+	flag.StringVar(&cfg.Defaults.Server, "default-server", cfg.Defaults.Server, "Default server address when the server parameter is absent")
+	flag.IntVar(&cfg.Defaults.ServerPort, "default-port", cfg.Defaults.ServerPort, "Default server port")
+	flag.StringVar(&cfg.Defaults.WSPath, "default-ws-path", cfg.Defaults.WSPath, "Default WebSocket path")
+	flag.StringVar(&cfg.Defaults.DNSServer, "default-dns-server", cfg.Defaults.DNSServer, "Default remote DNS server")
+	flag.StringVar(&cfg.Defaults.DOHServer, "default-doh-server", cfg.Defaults.DOHServer, "Default DNS over HTTPS server URL")
+	flag.StringVar(&cfg.Defaults.TunAddress, "default-tun-address", cfg.Defaults.TunAddress, "Default TUN interface address (CIDR)")
+	flag.IntVar(&cfg.Defaults.MixedPort, "default-mixed-port", cfg.Defaults.MixedPort, "Default mixed inbound port")
+	flag.IntVar(&cfg.Defaults.TunMTU, "default-tun-mtu", cfg.Defaults.TunMTU, "Default TUN MTU")
+	defaultsFile := flag.String("defaults-file", "", "JSON or YAML file with default dynamic parameters (default-* flags take precedence)")
 
 	// Templates configuration
-	cfg.Templates.Directory = "templates"
-	cfg.Templates.Types = []string{"vless"}
+	flag.StringVar(&cfg.Templates.Directory, "templates-dir", "", "Directory to load configuration templates from instead of the embedded ones (reloaded on SIGHUP)")
+	flag.StringVar(&cfg.Templates.URL, "templates-url", "", "Base URL to fetch <type>.json configuration templates from instead of the embedded ones")
+	flag.StringVar(&cfg.Templates.CacheDirectory, "templates-cache-dir", "templates-cache", "Directory caching templates fetched from -templates-url")
+	flag.DurationVar(&cfg.Templates.RefreshInterval, "templates-refresh", 0, "Interval for re-fetching templates from -templates-url (0 disables)")
+	flag.DurationVar(&cfg.Templates.FetchTimeout, "templates-fetch-timeout", 10*time.Second, "Timeout for fetching a template from -templates-url")
+	flag.StringVar(&cfg.Templates.TranslationsDirectory, "translations-dir", "", "Directory to load translations from instead of the embedded ones (reloaded on SIGHUP)")
+	templateTypes := flag.String("template-types", "vless,vless-reality,trojan,vmess,shadowsocks,hysteria2", "Comma-separated configuration template types to load")
+
+	showVersion := flag.Bool("version", false, "Print version and build information and exit")
 
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Println(buildinfo.String())
+		os.Exit(0)
+	}
+
+	// Flags given on the command line win over the environment, which wins over defaults
+	fromEnv, err := applyEnv(flag.CommandLine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cfg.FromEnv = fromEnv
+
+	if *defaultsFile != "" {
+		if err := applyDefaultsFile(flag.CommandLine, *defaultsFile, cfg.Defaults); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	cfg.Server.ACMEDomains = splitList(*acmeDomains)
+	cfg.Server.TrustedProxies, err = ParseTrustedProxies(splitList(*trustedProxies))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.Service.LogOutput, err = ParseLogOutput(*logOutput); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.Service.LogFileMaxBytes < 0 {
+		fmt.Fprintln(os.Stderr, "-log-file-max-bytes must not be negative")
+		os.Exit(2)
+	}
+	if cfg.Service.LogFileKeep < 0 {
+		fmt.Fprintln(os.Stderr, "-log-file-keep must not be negative")
+		os.Exit(2)
+	}
+	cfg.Server.CORSOrigins, err = ParseCORSOrigins(splitList(*corsOrigins))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// Without a token anyone could call /api/v1/sign
+	if cfg.Server.SigningKey != "" && len(cfg.Server.AuthTokens) == 0 {
+		fmt.Fprintln(os.Stderr, "-signing-key requires -auth-token to protect /api/v1/sign")
+		os.Exit(2)
+	}
+	cfg.Service.LogDebugPaths = splitList(*logDebugPaths)
+	if cfg.Service.LogStaticSampleRate < 0 || cfg.Service.LogStaticSampleRate > 1 {
+		fmt.Fprintln(os.Stderr, "-log-static-sample-rate must be between 0 and 1")
+		os.Exit(2)
+	}
+	if cfg.Service.ShortLinkTTL < 0 {
+		fmt.Fprintln(os.Stderr, "-shortlink-ttl must not be negative")
+		os.Exit(2)
+	}
+	if cfg.Service.AuditRetention < 0 {
+		fmt.Fprintln(os.Stderr, "-audit-retention must not be negative")
+		os.Exit(2)
+	}
+	if cfg.Service.AuditLogMaxBytes < 0 {
+		fmt.Fprintln(os.Stderr, "-audit-log-max-bytes must not be negative")
+		os.Exit(2)
+	}
+	if cfg.Service.AuditLogKeep < 0 {
+		fmt.Fprintln(os.Stderr, "-audit-log-keep must not be negative")
+		os.Exit(2)
+	}
+	if cfg.Service.ResponseCacheTTL < 0 {
+		fmt.Fprintln(os.Stderr, "-response-cache-ttl must not be negative")
+		os.Exit(2)
+	}
+	if cfg.Service.ResponseCacheMaxEntries < 1 {
+		fmt.Fprintln(os.Stderr, "-response-cache-max-entries must be at least 1")
+		os.Exit(2)
+	}
+	if cfg.Service.QRWorkers < 0 {
+		fmt.Fprintln(os.Stderr, "-qr-workers must not be negative")
+		os.Exit(2)
+	}
+	if cfg.Service.QRQueueDepth < 0 {
+		fmt.Fprintln(os.Stderr, "-qr-queue-depth must not be negative")
+		os.Exit(2)
+	}
+	if cfg.Service.ConfigCacheMaxAge < 0 {
+		fmt.Fprintln(os.Stderr, "-config-cache-max-age must not be negative")
+		os.Exit(2)
+	}
+	cfg.Templates.Types = splitList(*templateTypes)
+
 	return cfg
 }
 
-// SetupLogging configures logrus with the specified settings
-func SetupLogging(cfg *Config) {
+// applyDefaultsFile loads the defaults file and then re-applies default-* flags
+// that were set explicitly, so flags and environment win over the file
+func applyDefaultsFile(flagSet *flag.FlagSet, path string, defaults *DynamicConfig) error {
+	explicit := make(map[string]string)
+	flagSet.Visit(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "default-") {
+			explicit[f.Name] = f.Value.String()
+		}
+	})
+
+	if err := LoadDefaultsFile(path, defaults); err != nil {
+		return err
+	}
+	for name, value := range explicit {
+		if err := flagSet.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnvPrefix is prepended to upper-cased flag names to form their environment variables
+const EnvPrefix = "VLESS_GEN_"
+
+// EnvName returns the environment variable for a flag (e.g., log-level -> VLESS_GEN_LOG_LEVEL)
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets every flag that was not given on the command line from its
+// environment variable and returns the names of the variables that were used
+func applyEnv(flagSet *flag.FlagSet) ([]string, error) {
+	explicit := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var fromEnv []string
+	var errs []error
+	flagSet.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] {
+			return
+		}
+		name := EnvName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := flagSet.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s: %w", value, name, err))
+			return
+		}
+		fromEnv = append(fromEnv, name)
+	})
+
+	return fromEnv, errors.Join(errs...)
+}
+
+// Kinds of log output
+const (
+	LogToStdout = "stdout"
+	LogToStderr = "stderr"
+	LogToFile   = "file"
+	LogToSyslog = "syslog"
+)
+
+// syslogNetworks are the networks accepted by syslog:<network>:<addr> log outputs
+var syslogNetworks = map[string]bool{"udp": true, "udp4": true, "udp6": true, "tcp": true, "tcp4": true, "tcp6": true, "unix": true, "unixgram": true}
+
+// syslogTag is the program name attached to syslog messages
+const syslogTag = "vless-generator"
+
+// LogOutput is where the service log is written, parsed from -log-output
+type LogOutput struct {
+	Kind    string // LogToStdout, LogToStderr, LogToFile or LogToSyslog
+	Path    string // Log file of LogToFile
+	Network string // Syslog network such as udp or tcp; empty for the local syslog daemon
+	Address string // Syslog address such as localhost:514 or /dev/log
+}
+
+// ParseLogOutput parses stdout, stderr, file:<path>, syslog or syslog:<network>:<addr>
+func ParseLogOutput(value string) (LogOutput, error) {
+	kind, rest, hasRest := strings.Cut(value, ":")
+	switch {
+	case (kind == LogToStdout || kind == LogToStderr) && !hasRest:
+		return LogOutput{Kind: kind}, nil
+	case kind == LogToFile && rest != "":
+		return LogOutput{Kind: kind, Path: rest}, nil
+	case kind == LogToSyslog && !hasRest:
+		return LogOutput{Kind: kind}, nil
+	case kind == LogToSyslog:
+		network, address, _ := strings.Cut(rest, ":")
+		if !syslogNetworks[network] || address == "" {
+			return LogOutput{}, fmt.Errorf("invalid -log-output %q: expected syslog:<network>:<addr> with network udp, tcp, unix or unixgram (e.g., syslog:udp:localhost:514)", value)
+		}
+		return LogOutput{Kind: kind, Network: network, Address: address}, nil
+	}
+	return LogOutput{}, fmt.Errorf("invalid -log-output %q: expected stdout, stderr, file:<path> or syslog:<network>:<addr>", value)
+}
+
+// SetupLogging configures logrus with the specified settings and log output. It fails when
+// the log file cannot be opened or the syslog daemon cannot be reached.
+func SetupLogging(cfg *Config) error {
 	// Set log level
 	level, err := logrus.ParseLevel(cfg.Service.LogLevel)
 	if err != nil {
@@ -109,24 +811,134 @@ func SetupLogging(cfg *Config) {
 		})
 	}
 
+	output := cfg.Service.LogOutput
+	switch output.Kind {
+	case LogToStderr:
+		logrus.SetOutput(os.Stderr)
+	case LogToFile:
+		file, err := rotate.Open(output.Path, cfg.Service.LogFileMaxBytes, cfg.Service.LogFileKeep)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		logrus.SetOutput(file)
+	case LogToSyslog:
+		// Syslog stamps messages itself, so entries go to the hook only
+		hook, err := logrussyslog.NewSyslogHook(output.Network, output.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, syslogTag)
+		if err != nil {
+			target := "the local syslog daemon"
+			if output.Network != "" {
+				target = output.Network + " syslog at " + output.Address
+			}
+			return fmt.Errorf("failed to connect to %s: %w", target, err)
+		}
+		logrus.AddHook(hook)
+		logrus.SetOutput(io.Discard)
+	default:
+		logrus.SetOutput(os.Stdout)
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"service": "vless-generator",
-		"version": "1.0.0",
+		"version": buildinfo.Version,
+		"output":  output.Kind,
 	}).Info("Logging configured successfully")
+	return nil
 }
 
-// ParseDynamicConfig parses dynamic configuration from URL query parameters
-func ParseDynamicConfig(query url.Values) *DynamicConfig {
-	config := DefaultDynamicConfig()
+// ParamError describes a query parameter whose value was rejected
+type ParamError struct {
+	Param    string `json:"param"`             // Query parameter name
+	Value    string `json:"value"`             // Rejected value
+	Accepted string `json:"accepted"`          // Description of accepted values (e.g., 1-65535)
+	Message  string `json:"message,omitempty"` // Localized description, set by the handler answering the request
+}
 
-	if server := query.Get("server"); server != "" {
-		config.Server = server
+// Error implements the error interface
+func (e ParamError) Error() string {
+	return fmt.Sprintf("%s: invalid value %q (accepted: %s)", e.Param, e.Value, e.Accepted)
+}
+
+// paramParser collects errors while reading typed query parameters
+type paramParser struct {
+	query  url.Values
+	errors []ParamError
+}
+
+// intParam stores the named parameter in target when present and within [min, max]
+func (p *paramParser) intParam(name string, min, max int, target *int) {
+	value := p.query.Get(name)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < min || parsed > max {
+		p.errors = append(p.errors, ParamError{
+			Param:    name,
+			Value:    value,
+			Accepted: fmt.Sprintf("integer %d-%d", min, max),
+		})
+		return
 	}
-	if port := query.Get("port"); port != "" {
-		if p, err := strconv.Atoi(port); err == nil {
-			config.ServerPort = p
+	*target = parsed
+}
+
+// listParam returns the comma-separated values of the named parameter, dropping empty entries
+func (p *paramParser) listParam(name string) []string {
+	return splitList(p.query.Get(name))
+}
+
+// splitList splits a comma-separated list, trimming spaces and dropping empty entries
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
 		}
 	}
+	return values
+}
+
+// listFlag is a repeatable flag whose values may also be comma-separated
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends the comma-separated values
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// boolParam stores the named parameter in target when present and a valid boolean
+func (p *paramParser) boolParam(name string, target *bool) {
+	value := p.query.Get(name)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		p.errors = append(p.errors, ParamError{Param: name, Value: value, Accepted: "true or false"})
+		return
+	}
+	*target = parsed
+}
+
+// ParseDynamicConfig parses dynamic configuration from URL query parameters.
+// Values that cannot be applied are reported as ParamErrors instead of being silently ignored.
+// Absent parameters fall back to defaults (the built-in DefaultDynamicConfig when nil).
+func ParseDynamicConfig(query url.Values, defaults *DynamicConfig) (*DynamicConfig, []ParamError) {
+	if defaults == nil {
+		defaults = DefaultDynamicConfig()
+	}
+	config := defaults.Clone()
+	params := &paramParser{query: query}
+
+	if server := query.Get("server"); server != "" {
+		config.Server = server
+	}
+	params.intParam("port", 1, 65535, &config.ServerPort)
 	if wsPath := query.Get("ws-path"); wsPath != "" {
 		config.WSPath = wsPath
 	}
@@ -139,16 +951,407 @@ func ParseDynamicConfig(query url.Values) *DynamicConfig {
 	if tunAddress := query.Get("tun-address"); tunAddress != "" {
 		config.TunAddress = tunAddress
 	}
-	if mixedPort := query.Get("mixed-port"); mixedPort != "" {
-		if mp, err := strconv.Atoi(mixedPort); err == nil {
-			config.MixedPort = mp
+	params.intParam("mixed-port", 1, 65535, &config.MixedPort)
+	params.intParam("tun-mtu", 576, 65535, &config.TunMTU)
+	if name := query.Get("name"); name != "" {
+		config.Name = name
+	} else if remark := query.Get("remark"); remark != "" {
+		config.Name = remark
+	}
+	if variant := query.Get("variant"); variant != "" {
+		if !IsValidVariant(variant) {
+			params.errors = append(params.errors, ParamError{Param: "variant", Value: variant, Accepted: "lowercase letters, digits and hyphens"})
+		} else if variant != DefaultVariant {
+			config.Variant = variant
+		}
+	}
+	if sni := query.Get("sni"); sni != "" {
+		config.SNI = sni
+	}
+	if host := query.Get("host"); host != "" {
+		config.Host = host
+	}
+	if entries := params.listParam("servers"); len(entries) > 0 {
+		config.Servers = nil
+		for _, entry := range entries {
+			node, err := ParseServerNode(entry)
+			if err != nil {
+				params.errors = append(params.errors, ParamError{Param: "servers", Value: entry, Accepted: "[name@]host[:port] with a hostname or IP address"})
+				continue
+			}
+			config.Servers = append(config.Servers, node)
+		}
+		if len(entries) > MaxServers {
+			params.errors = append(params.errors, ParamError{Param: "servers", Value: strconv.Itoa(len(entries)) + " entries", Accepted: fmt.Sprintf("at most %d entries", MaxServers)})
 		}
 	}
-	if tunMTU := query.Get("tun-mtu"); tunMTU != "" {
-		if mtu, err := strconv.Atoi(tunMTU); err == nil {
-			config.TunMTU = mtu
+	if transport := query.Get("transport"); transport != "" {
+		config.Transport = transport
+	}
+	if grpcService := query.Get("grpc-service"); grpcService != "" {
+		config.GRPCServiceName = grpcService
+	}
+	if flow := query.Get("flow"); flow != "" {
+		config.Flow = flow
+	}
+	params.boolParam("tls", &config.TLS)
+	params.boolParam("tun", &config.TunEnabled)
+	params.boolParam("mixed", &config.MixedEnabled)
+	params.boolParam("block-ads", &config.BlockAds)
+	params.boolParam("fakeip", &config.FakeIP)
+	params.boolParam("mux", &config.Mux)
+	params.intParam("mux-max-streams", 1, 1024, &config.MuxMaxStreams)
+	if muxProtocol := query.Get("mux-protocol"); muxProtocol != "" {
+		if !isMuxProtocol(muxProtocol) {
+			params.errors = append(params.errors, ParamError{Param: "mux-protocol", Value: muxProtocol, Accepted: "smux, yamux or h2mux"})
+		} else {
+			config.MuxProtocol = muxProtocol
 		}
 	}
+	params.intParam("clash-api-port", 1, 65535, &config.ClashAPIPort)
+	if schema := query.Get("schema"); schema != "" {
+		if !IsSchema(schema) {
+			params.errors = append(params.errors, ParamError{Param: "schema", Value: schema, Accepted: SchemaLegacy + " or " + SchemaModern})
+		} else {
+			config.Schema = schema
+		}
+	}
+	if query.Get("security") == "none" {
+		config.TLS = false
+	}
+	// Plaintext deployments listen on port 80 unless told otherwise
+	if !config.TLS && query.Get("port") == "" {
+		config.ServerPort = 80
+	}
+	if fp := query.Get("fp"); fp != "" {
+		config.Fingerprint = fp
+	}
+	config.ALPN = params.listParam("alpn")
+	for _, domain := range params.listParam("bypass-domains") {
+		domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+		if !IsValidHost(domain) {
+			params.errors = append(params.errors, ParamError{Param: "bypass-domains", Value: domain, Accepted: "domain suffix (e.g., example.com)"})
+			continue
+		}
+		config.BypassDomains = append(config.BypassDomains, domain)
+	}
+	for _, cidr := range params.listParam("bypass-cidrs") {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			params.errors = append(params.errors, ParamError{Param: "bypass-cidrs", Value: cidr, Accepted: "CIDR (e.g., 10.0.0.0/8)"})
+			continue
+		}
+		config.BypassCIDRs = append(config.BypassCIDRs, prefix.Masked().String())
+	}
+	for _, code := range params.listParam("bypass-geoip") {
+		code = strings.ToLower(code)
+		if !isGeoIPCode(code) {
+			params.errors = append(params.errors, ParamError{Param: "bypass-geoip", Value: code, Accepted: "two-letter country code or private"})
+			continue
+		}
+		config.BypassGeoIP = append(config.BypassGeoIP, code)
+	}
+	if pbk := query.Get("pbk"); pbk != "" {
+		config.RealityPublicKey = pbk
+	}
+	if sid := query.Get("sid"); sid != "" {
+		config.RealityShortID = sid
+	}
+	params.intParam("up", 1, 100000, &config.UpMbps)
+	params.intParam("down", 1, 100000, &config.DownMbps)
+	if clashAPISecret := query.Get("clash-api-secret"); clashAPISecret != "" {
+		config.ClashAPISecret = clashAPISecret
+	}
+	if patch := query.Get("patch"); patch != "" {
+		decoded, err := DecodePatch(patch)
+		if err != nil {
+			params.errors = append(params.errors, ParamError{Param: "patch", Value: truncate(patch, 64), Accepted: err.Error()})
+		} else {
+			config.Patch = decoded
+		}
+	}
+	if obfsPassword := query.Get("obfs-password"); obfsPassword != "" {
+		config.ObfsPassword = obfsPassword
+	}
+	params.boolParam("warp", &config.Warp)
+	if privateKey := query.Get("warp-private-key"); privateKey != "" {
+		config.WarpPrivateKey = privateKey
+	}
+	if peerPublicKey := query.Get("warp-peer-public-key"); peerPublicKey != "" {
+		config.WarpPeerPublicKey = peerPublicKey
+	}
+	if addresses := params.listParam("warp-address"); len(addresses) > 0 {
+		config.WarpAddress = nil
+		for _, address := range addresses {
+			prefix, err := netip.ParsePrefix(address)
+			if err != nil {
+				params.errors = append(params.errors, ParamError{Param: "warp-address", Value: address, Accepted: "CIDR address (e.g., 172.16.0.2/32)"})
+				continue
+			}
+			config.WarpAddress = append(config.WarpAddress, prefix.String())
+		}
+	}
+
+	return config, append(params.errors, config.Validate()...)
+}
+
+// KnownParams lists every parameter name understood by ParseDynamicConfig and the handlers
+var KnownParams = []string{
+	"server", "port", "ws-path", "dns-server", "doh-server", "tun-address", "mixed-port", "tun-mtu",
+	"name", "remark", "variant", "sni", "host", "servers", "tun", "mixed", "transport", "grpc-service", "flow",
+	"tls", "security", "fp", "alpn", "pbk", "sid",
+	"bypass-domains", "bypass-cidrs", "bypass-geoip", "block-ads", "fakeip",
+	"clash-api-port", "clash-api-secret", "mux", "mux-protocol", "mux-max-streams",
+	"warp", "warp-private-key", "warp-peer-public-key", "warp-address",
+	"schema", "patch", "up", "down", "obfs-password", "strict", "lang", "token", "sig", "exp",
+}
+
+// ParamsToValues converts JSON-decoded parameters into query values so that
+// JSON bodies share ParseDynamicConfig with query strings. Numbers and booleans
+// are formatted, arrays are comma-joined and a patch object is base64url-encoded.
+// Unknown parameter names are returned as warnings.
+func ParamsToValues(params map[string]interface{}) (url.Values, []string) {
+	known := make(map[string]bool, len(KnownParams))
+	for _, name := range KnownParams {
+		known[name] = true
+	}
+
+	values := url.Values{}
+	var warnings []string
+	for name, raw := range params {
+		if !known[name] {
+			warnings = append(warnings, fmt.Sprintf("unknown parameter %q ignored", name))
+			continue
+		}
+		value, err := paramString(name, raw)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("parameter %q ignored: %v", name, err))
+			continue
+		}
+		values.Set(name, value)
+	}
+	sort.Strings(warnings)
+	return values, warnings
+}
+
+// paramString formats a JSON-decoded parameter value as its query string form
+func paramString(name string, raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			str, err := paramString(name, item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, str)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		if name != "patch" {
+			return "", fmt.Errorf("objects are only accepted for patch")
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return b64.EncodeURL(data), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", raw)
+	}
+}
+
+// Validate checks the shape of string parameters that would otherwise produce broken configs
+func (c *DynamicConfig) Validate() []ParamError {
+	var errs []ParamError
+	if !IsValidHost(c.Server) {
+		errs = append(errs, ParamError{Param: "server", Value: c.Server, Accepted: "hostname (RFC 1123) or IP address"})
+	}
+	if !strings.HasPrefix(c.WSPath, "/") {
+		errs = append(errs, ParamError{Param: "ws-path", Value: c.WSPath, Accepted: "path starting with /"})
+	}
+	if _, err := netip.ParsePrefix(c.TunAddress); err != nil {
+		errs = append(errs, ParamError{Param: "tun-address", Value: c.TunAddress, Accepted: "CIDR address (e.g., 172.19.0.1/28)"})
+	}
+	if u, err := url.Parse(c.DOHServer); err != nil || u.Scheme != "https" || u.Host == "" {
+		errs = append(errs, ParamError{Param: "doh-server", Value: c.DOHServer, Accepted: "https URL"})
+	}
+	if c.Warp {
+		if !isWireGuardKey(c.WarpPrivateKey) {
+			errs = append(errs, ParamError{Param: "warp-private-key", Value: c.WarpPrivateKey, Accepted: "base64 WireGuard key (required by warp)"})
+		}
+		if !isWireGuardKey(c.WarpPeerPublicKey) {
+			errs = append(errs, ParamError{Param: "warp-peer-public-key", Value: c.WarpPeerPublicKey, Accepted: "base64 WireGuard key (required by warp)"})
+		}
+		if len(c.WarpAddress) == 0 {
+			errs = append(errs, ParamError{Param: "warp-address", Value: "", Accepted: "comma-separated CIDR addresses (required by warp)"})
+		}
+	}
+	return errs
+}
+
+// isWireGuardKey reports whether key is a base64-encoded 32-byte WireGuard key
+func isWireGuardKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 32
+}
+
+// MaxPatchSize caps the decoded size of a JSON merge patch
+const MaxPatchSize = 64 << 10
+
+// DecodePatch decodes a base64-encoded JSON merge patch object. Both alphabets are accepted
+// with or without padding, so patches encoded with the standard alphabet work too, even with
+// an unescaped + that query decoding turned into a space.
+func DecodePatch(encoded string) (map[string]interface{}, error) {
+	if base64.RawURLEncoding.DecodedLen(len(encoded)) > MaxPatchSize {
+		return nil, fmt.Errorf("base64url JSON object of at most %d bytes", MaxPatchSize)
+	}
+	data, err := b64.DecodeLenient(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("base64url-encoded JSON object")
+	}
+	// Keep numbers as json.Number so patched integers are written back exactly
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var patch map[string]interface{}
+	if err := decoder.Decode(&patch); err != nil || patch == nil || decoder.More() {
+		return nil, fmt.Errorf("base64url-encoded JSON object")
+	}
+	return patch, nil
+}
+
+// truncate shortens long values echoed back in errors
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	return value[:max] + "..."
+}
+
+// Output schemas of generated sing-box configs
+const (
+	SchemaLegacy = "legacy" // Fields as written in the templates
+	SchemaModern = "modern" // Fields deprecated by sing-box 1.10 and 1.11 rewritten to their replacements
+)
+
+// IsSchema reports whether schema names an output schema
+func IsSchema(schema string) bool {
+	return schema == SchemaLegacy || schema == SchemaModern
+}
+
+// isMuxProtocol reports whether protocol is a sing-box multiplex protocol
+func isMuxProtocol(protocol string) bool {
+	switch protocol {
+	case "smux", "yamux", "h2mux":
+		return true
+	default:
+		return false
+	}
+}
+
+// DefaultVariant is the variant name selecting the base <type>.json template
+const DefaultVariant = "default"
 
-	return config
+// IsValidVariant reports whether name can be used as a template variant (lowercase letters, digits and hyphens)
+func IsValidVariant(name string) bool {
+	if name == "" || len(name) > 32 {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// isGeoIPCode reports whether code names a sing-geoip rule set (two-letter country code or "private")
+func isGeoIPCode(code string) bool {
+	if code == "private" {
+		return true
+	}
+	return len(code) == 2 && code[0] >= 'a' && code[0] <= 'z' && code[1] >= 'a' && code[1] <= 'z'
+}
+
+// IsValidHost reports whether value is an RFC 1123 hostname or a literal IPv4/IPv6 address
+func IsValidHost(value string) bool {
+	if _, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")); err == nil {
+		return true
+	}
+	if value == "" || len(value) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(value, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TLSServerName returns the TLS SNI, falling back to the server address
+func (c *DynamicConfig) TLSServerName() string {
+	if c.SNI != "" {
+		return c.SNI
+	}
+	return c.Server
+}
+
+// HostHeader returns the WebSocket Host header, falling back to the TLS server name
+func (c *DynamicConfig) HostHeader() string {
+	if c.Host != "" {
+		return c.Host
+	}
+	return c.TLSServerName()
+}
+
+// Remark returns the display name for share URLs, defaulting to <server>-<configType>
+func (c *DynamicConfig) Remark(configType string) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Server + "-" + configType
+}
+
+// Nodes returns the nodes of the servers parameter, or the server parameter as the only node
+func (c *DynamicConfig) Nodes() []ServerNode {
+	if len(c.Servers) > 0 {
+		return c.Servers
+	}
+	return []ServerNode{{Host: c.Server}}
+}
+
+// NodeRemarks returns the remark of each node of Nodes: the node name when given, else
+// <name>-<host> when a name is set for several nodes, otherwise <host>-<type>. Remarks may repeat.
+func (c *DynamicConfig) NodeRemarks(configType string) []string {
+	nodes := c.Nodes()
+	remarks := make([]string, len(nodes))
+	for i, node := range nodes {
+		switch {
+		case node.Name != "":
+			remarks[i] = node.Name
+		case c.Name != "" && len(nodes) > 1:
+			remarks[i] = c.Name + "-" + node.Host
+		default:
+			nodeCfg := *c
+			nodeCfg.Server = node.Host
+			remarks[i] = nodeCfg.Remark(configType)
+		}
+	}
+	return remarks
 }
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodePatch(t *testing.T) {
+	// The JSON encodes to both + and / in the standard alphabet
+	patch := `{"log":{"level":"debug"},"x":"??>>~~"}`
+	std := base64.StdEncoding.EncodeToString([]byte(patch))
+	if !strings.ContainsAny(std, "+/") {
+		t.Fatalf("test patch %q should exercise the standard alphabet", std)
+	}
+
+	for name, encoded := range map[string]string{
+		"base64url":     base64.RawURLEncoding.EncodeToString([]byte(patch)),
+		"padded url":    base64.URLEncoding.EncodeToString([]byte(patch)),
+		"standard":      std,
+		"plus as space": strings.ReplaceAll(std, "+", " "),
+		"wrapped":       std[:20] + "\n" + std[20:],
+	} {
+		decoded, err := DecodePatch(encoded)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if level := decoded["log"].(map[string]interface{})["level"]; level != "debug" {
+			t.Errorf("%s: log.level = %v", name, level)
+		}
+	}
+
+	for name, encoded := range map[string]string{
+		"not base64": "not base64!",
+		"array":      base64.RawURLEncoding.EncodeToString([]byte(`[1]`)),
+		"null":       base64.RawURLEncoding.EncodeToString([]byte(`null`)),
+		"trailing":   base64.RawURLEncoding.EncodeToString([]byte(`{} {}`)),
+		"too large":  strings.Repeat("A", MaxPatchSize*2),
+	} {
+		if _, err := DecodePatch(encoded); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for flagName, want := range map[string]string{
+		"port":                "VLESS_GEN_PORT",
+		"log-level":           "VLESS_GEN_LOG_LEVEL",
+		"default-server-port": "VLESS_GEN_DEFAULT_SERVER_PORT",
+		"tls-cert":            "VLESS_GEN_TLS_CERT",
+	} {
+		if got := EnvName(flagName); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", flagName, got, want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	newFlagSet := func() (*flag.FlagSet, *string, *string, *time.Duration) {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		port := flagSet.String("port", "8080", "")
+		level := flagSet.String("log-level", "info", "")
+		timeout := flagSet.Duration("read-timeout", 10*time.Second, "")
+		return flagSet, port, level, timeout
+	}
+
+	t.Run("defaults without env", func(t *testing.T) {
+		flagSet, port, level, timeout := newFlagSet()
+		if err := flagSet.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		fromEnv, err := applyEnv(flagSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fromEnv) != 0 {
+			t.Errorf("fromEnv = %v, want none", fromEnv)
+		}
+		if *port != "8080" || *level != "info" || *timeout != 10*time.Second {
+			t.Errorf("got port=%s level=%s timeout=%v, want the defaults", *port, *level, *timeout)
+		}
+	})
+
+	t.Run("env overrides defaults", func(t *testing.T) {
+		t.Setenv("VLESS_GEN_PORT", "9090")
+		t.Setenv("VLESS_GEN_READ_TIMEOUT", "3s")
+		flagSet, port, level, timeout := newFlagSet()
+		if err := flagSet.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		fromEnv, err := applyEnv(flagSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"VLESS_GEN_PORT", "VLESS_GEN_READ_TIMEOUT"}; !reflect.DeepEqual(fromEnv, want) {
+			t.Errorf("fromEnv = %v, want %v", fromEnv, want)
+		}
+		if *port != "9090" || *timeout != 3*time.Second {
+			t.Errorf("got port=%s timeout=%v, want 9090 and 3s from env", *port, *timeout)
+		}
+		if *level != "info" {
+			t.Errorf("log-level = %s, want the default", *level)
+		}
+	})
+
+	t.Run("flags win over env", func(t *testing.T) {
+		t.Setenv("VLESS_GEN_PORT", "9090")
+		t.Setenv("VLESS_GEN_LOG_LEVEL", "debug")
+		flagSet, port, level, _ := newFlagSet()
+		if err := flagSet.Parse([]string{"-port", "7070"}); err != nil {
+			t.Fatal(err)
+		}
+		fromEnv, err := applyEnv(flagSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"VLESS_GEN_LOG_LEVEL"}; !reflect.DeepEqual(fromEnv, want) {
+			t.Errorf("fromEnv = %v, want %v", fromEnv, want)
+		}
+		if *port != "7070" {
+			t.Errorf("port = %s, want 7070 from the command line", *port)
+		}
+		if *level != "debug" {
+			t.Errorf("log-level = %s, want debug from env", *level)
+		}
+	})
+
+	t.Run("empty env value is used", func(t *testing.T) {
+		t.Setenv("VLESS_GEN_LOG_LEVEL", "")
+		flagSet, _, level, _ := newFlagSet()
+		if err := flagSet.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := applyEnv(flagSet); err != nil {
+			t.Fatal(err)
+		}
+		if *level != "" {
+			t.Errorf("log-level = %q, want the empty env value", *level)
+		}
+	})
+
+	t.Run("invalid env value", func(t *testing.T) {
+		t.Setenv("VLESS_GEN_READ_TIMEOUT", "soon")
+		flagSet, _, _, _ := newFlagSet()
+		if err := flagSet.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		_, err := applyEnv(flagSet)
+		if err == nil || !strings.Contains(err.Error(), "VLESS_GEN_READ_TIMEOUT") {
+			t.Fatalf("err = %v, want an error naming VLESS_GEN_READ_TIMEOUT", err)
+		}
+	})
+}
+
+func TestParseDynamicConfigPort(t *testing.T) {
+	tests := []struct {
+		port string // JSON value of the port parameter
+		want int    // 0 when the port is rejected
+	}{
+		{`1`, 1},
+		{`443`, 443},
+		{`65535`, 65535},
+		{`"8443"`, 8443},
+		{`0`, 0},
+		{`-1`, 0},
+		{`65536`, 0},
+		{`4294967295`, 0},
+		{`18446744073709551616`, 0},
+		{`443.5`, 0},
+		{`4.43e2`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.port, func(t *testing.T) {
+			decoder := json.NewDecoder(strings.NewReader(`{"port":` + tt.port + `}`))
+			decoder.UseNumber()
+			var params map[string]interface{}
+			if err := decoder.Decode(&params); err != nil {
+				t.Fatal(err)
+			}
+			values, warnings := ParamsToValues(params)
+			if len(warnings) > 0 {
+				t.Fatalf("warnings: %v", warnings)
+			}
+
+			for name, query := range map[string]url.Values{
+				"json":  values,
+				"query": {"port": {strings.Trim(tt.port, `"`)}},
+			} {
+				cfg, paramErrs := ParseDynamicConfig(query, nil)
+				if tt.want == 0 {
+					if len(paramErrs) != 1 || paramErrs[0].Param != "port" {
+						t.Errorf("%s: errors = %v, want port rejected", name, paramErrs)
+					}
+					if cfg.ServerPort != DefaultDynamicConfig().ServerPort {
+						t.Errorf("%s: rejected port changed ServerPort to %d", name, cfg.ServerPort)
+					}
+					continue
+				}
+				if len(paramErrs) > 0 {
+					t.Errorf("%s: unexpected errors %v", name, paramErrs)
+				}
+				if cfg.ServerPort != tt.want {
+					t.Errorf("%s: ServerPort = %d, want %d", name, cfg.ServerPort, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseServerNodePort(t *testing.T) {
+	for _, entry := range []string{"host.example.com:0", "host.example.com:65536", "host.example.com:4294967295", "[2001:db8::1]:-1"} {
+		if _, err := ParseServerNode(entry); err == nil {
+			t.Errorf("ParseServerNode(%q) accepted an out-of-range port", entry)
+		}
+	}
+	node, err := ParseServerNode("edge@[2001:db8::1]:65535")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Name != "edge" || node.Host != "2001:db8::1" || node.Port != 65535 {
+		t.Errorf("node = %+v, want edge at 2001:db8::1 port 65535", node)
+	}
+}
@@ -0,0 +1,251 @@
+package converter
+
+import (
+	"fmt"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/utils"
+)
+
+// ClashConfig represents a minimal importable Clash Meta (mihomo) profile
+type ClashConfig struct {
+	MixedPort   int          `yaml:"mixed-port"`
+	AllowLan    bool         `yaml:"allow-lan"`
+	Mode        string       `yaml:"mode"`
+	LogLevel    string       `yaml:"log-level"`
+	Proxies     []ClashProxy `yaml:"proxies"`
+	ProxyGroups []ClashGroup `yaml:"proxy-groups"`
+	Rules       []string     `yaml:"rules"`
+}
+
+// ClashProxy represents a single entry of the Clash proxies list
+type ClashProxy struct {
+	Name              string         `yaml:"name"`
+	Type              string         `yaml:"type"`
+	Server            string         `yaml:"server"`
+	Port              int            `yaml:"port"`
+	UUID              string         `yaml:"uuid,omitempty"`
+	Network           string         `yaml:"network,omitempty"`
+	Flow              string         `yaml:"flow,omitempty"`
+	TLS               bool           `yaml:"tls"`
+	UDP               bool           `yaml:"udp"`
+	ServerName        string         `yaml:"servername,omitempty"`
+	ClientFingerprint string         `yaml:"client-fingerprint,omitempty"`
+	ALPN              []string       `yaml:"alpn,omitempty"`
+	WSOpts            *ClashWSOpts   `yaml:"ws-opts,omitempty"`
+	GRPCOpts          *ClashGRPCOpts `yaml:"grpc-opts,omitempty"`
+
+	Password    string            `yaml:"password,omitempty"`     // Trojan and Shadowsocks password
+	Cipher      string            `yaml:"cipher,omitempty"`       // Shadowsocks method or vmess security
+	AlterID     *int              `yaml:"alterId,omitempty"`      // vmess alter id; mihomo requires it even when 0
+	SNI         string            `yaml:"sni,omitempty"`          // Trojan server name; vless uses servername
+	RealityOpts *ClashRealityOpts `yaml:"reality-opts,omitempty"` // Reality keys of vless proxies
+}
+
+// ClashWSOpts holds WebSocket transport options of a Clash proxy
+type ClashWSOpts struct {
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// ClashGRPCOpts holds gRPC transport options of a Clash proxy
+type ClashGRPCOpts struct {
+	ServiceName string `yaml:"grpc-service-name"`
+}
+
+// ClashRealityOpts holds the Reality keys of a Clash vless proxy
+type ClashRealityOpts struct {
+	PublicKey string `yaml:"public-key"`
+	ShortID   string `yaml:"short-id,omitempty"`
+}
+
+// ClashGroup represents a Clash proxy group
+type ClashGroup struct {
+	Name     string   `yaml:"name"`
+	Type     string   `yaml:"type"`
+	Proxies  []string `yaml:"proxies"`
+	URL      string   `yaml:"url,omitempty"`      // Probe URL of url-test groups
+	Interval int      `yaml:"interval,omitempty"` // Probe interval of url-test groups in seconds
+}
+
+// Settings of the url-test group of multi-node Clash profiles
+const (
+	clashAutoGroup    = "auto"
+	clashTestURL      = "https://www.gstatic.com/generate_204"
+	clashTestInterval = 300
+)
+
+// ToClash converts a generated sing-box configuration into a Clash Meta profile
+func ToClash(singbox map[string]interface{}, dynamicCfg *config.DynamicConfig) (*ClashConfig, error) {
+	outbound, err := proxyOutbound(singbox)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := clashProxy(outbound, dynamicCfg, "")
+	if err != nil {
+		return nil, err
+	}
+	return clashProfile([]ClashProxy{proxy}, dynamicCfg), nil
+}
+
+// ToClashNodes converts the first len(names) proxy outbounds of a generated sing-box
+// configuration, one per node of a multi-server config, into a Clash Meta profile whose
+// proxies carry the given names. Several nodes are also grouped by an url-test group.
+func ToClashNodes(singbox map[string]interface{}, dynamicCfg *config.DynamicConfig, names []string) (*ClashConfig, error) {
+	outbounds := utils.ProxyOutbounds(singbox)
+	if len(outbounds) < len(names) {
+		return nil, fmt.Errorf("expected %d proxy outbounds, found %d", len(names), len(outbounds))
+	}
+	proxies := make([]ClashProxy, len(names))
+	for i, name := range names {
+		proxy, err := clashProxy(outbounds[i], dynamicCfg, name)
+		if err != nil {
+			return nil, err
+		}
+		proxies[i] = proxy
+	}
+	return clashProfile(proxies, dynamicCfg), nil
+}
+
+// clashProfile wraps proxies in a profile selecting between them and DIRECT
+func clashProfile(proxies []ClashProxy, dynamicCfg *config.DynamicConfig) *ClashConfig {
+	names := make([]string, len(proxies))
+	for i, proxy := range proxies {
+		names[i] = proxy.Name
+	}
+	groups := []ClashGroup{{
+		Name:    "PROXY",
+		Type:    "select",
+		Proxies: append(append([]string(nil), names...), "DIRECT"),
+	}}
+	if len(proxies) > 1 {
+		groups[0].Proxies = append([]string{clashAutoGroup}, groups[0].Proxies...)
+		groups = append(groups, ClashGroup{
+			Name:     clashAutoGroup,
+			Type:     "url-test",
+			Proxies:  names,
+			URL:      clashTestURL,
+			Interval: clashTestInterval,
+		})
+	}
+
+	return &ClashConfig{
+		MixedPort:   dynamicCfg.MixedPort,
+		AllowLan:    false,
+		Mode:        "rule",
+		LogLevel:    "info",
+		Proxies:     proxies,
+		ProxyGroups: groups,
+		Rules: []string{
+			"GEOIP,private,DIRECT,no-resolve",
+			"MATCH,PROXY",
+		},
+	}
+}
+
+// clashProxy converts a vless, vmess, trojan or shadowsocks proxy outbound into a Clash proxy named name, or after
+// its server when name is empty; the server and port fall back to the dynamic parameters when the outbound lacks them
+func clashProxy(outbound map[string]interface{}, dynamicCfg *config.DynamicConfig, name string) (ClashProxy, error) {
+	outboundType, _ := outbound["type"].(string)
+	var uuid, password string
+	switch outboundType {
+	case "vless", "vmess":
+		uuid, _ = outbound["uuid"].(string)
+		if uuid == "" {
+			return ClashProxy{}, fmt.Errorf("missing uuid in proxy outbound")
+		}
+	case "trojan", "shadowsocks":
+		password, _ = outbound["password"].(string)
+		if password == "" {
+			return ClashProxy{}, fmt.Errorf("missing password in proxy outbound")
+		}
+	default:
+		return ClashProxy{}, fmt.Errorf("%w: %v", ErrUnsupportedOutbound, outbound["type"])
+	}
+
+	server, _ := outbound["server"].(string)
+	if server == "" {
+		server = dynamicCfg.Server
+	}
+	if name == "" {
+		name = server
+	}
+	port, ok := outbound["server_port"].(int)
+	if !ok {
+		port = dynamicCfg.ServerPort
+	}
+
+	proxy := ClashProxy{
+		Name:     name,
+		Type:     outboundType,
+		Server:   server,
+		Port:     port,
+		UUID:     uuid,
+		Password: password,
+		Network:  "ws",
+		UDP:      true,
+		WSOpts: &ClashWSOpts{
+			Path:    dynamicCfg.WSPath,
+			Headers: map[string]string{"Host": dynamicCfg.HostHeader()},
+		},
+	}
+
+	if transport, ok := outbound["transport"].(map[string]interface{}); ok {
+		if path, ok := transport["path"].(string); ok {
+			proxy.WSOpts.Path = path
+		}
+		if headers, ok := transport["headers"].(map[string]interface{}); ok {
+			if host, ok := headers["Host"].(string); ok {
+				proxy.WSOpts.Headers["Host"] = host
+			}
+		}
+		if transport["type"] == "grpc" {
+			serviceName, _ := transport["service_name"].(string)
+			proxy.Network = "grpc"
+			proxy.WSOpts = nil
+			proxy.GRPCOpts = &ClashGRPCOpts{ServiceName: serviceName}
+		}
+	}
+
+	if _, exists := outbound["transport"]; !exists {
+		proxy.Network = "tcp"
+		proxy.WSOpts = nil
+	}
+	if outboundType == "shadowsocks" {
+		// Clash names the method cipher and has no transport setting for Shadowsocks
+		proxy.Type = "ss"
+		proxy.Cipher, _ = outbound["method"].(string)
+		proxy.Network = ""
+	}
+	if outboundType == "vmess" {
+		var alterID int
+		proxy.Cipher, alterID = vmessSecurity(outbound)
+		proxy.AlterID = &alterID
+	}
+	if flow, ok := outbound["flow"].(string); ok {
+		proxy.Flow = flow
+	}
+
+	if tls, ok := outbound["tls"].(map[string]interface{}); ok && tls["enabled"] == true {
+		proxy.TLS = true
+		proxy.ServerName = dynamicCfg.TLSServerName()
+		if serverName, ok := tls["server_name"].(string); ok && serverName != "" {
+			proxy.ServerName = serverName
+		}
+		if utls, ok := tls["utls"].(map[string]interface{}); ok && utls["enabled"] == true {
+			if fingerprint, ok := utls["fingerprint"].(string); ok {
+				proxy.ClientFingerprint = fingerprint
+			}
+		}
+		proxy.ALPN = utils.StringSlice(tls["alpn"])
+		if reality, ok := tls["reality"].(map[string]interface{}); ok && reality["enabled"] == true {
+			publicKey, _ := reality["public_key"].(string)
+			shortID, _ := reality["short_id"].(string)
+			proxy.RealityOpts = &ClashRealityOpts{PublicKey: publicKey, ShortID: shortID}
+		}
+		if outboundType == "trojan" {
+			proxy.SNI, proxy.ServerName = proxy.ServerName, ""
+		}
+	}
+	return proxy, nil
+}
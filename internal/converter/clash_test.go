@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestToClash(t *testing.T) {
+	tests := []struct {
+		name     string
+		outbound map[string]interface{}
+		port     int
+		want     ClashProxy
+		yaml     []string
+	}{
+		{
+			name:     "vless-reality",
+			outbound: realityOutbound(),
+			port:     443,
+			want: ClashProxy{
+				Name: "x.example.com", Type: "vless", Server: "x.example.com", Port: 443,
+				UUID: "bae71742-94e0-4dd5-935f-070339819ba0", Network: "tcp", Flow: "xtls-rprx-vision",
+				TLS: true, UDP: true, ServerName: "www.microsoft.com", ClientFingerprint: "firefox",
+				RealityOpts: &ClashRealityOpts{PublicKey: "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0", ShortID: "ab12"},
+			},
+			yaml: []string{"reality-opts:", "public-key: jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0", "short-id: ab12", "servername: www.microsoft.com"},
+		},
+		{
+			name:     "trojan",
+			outbound: trojanOutbound(),
+			port:     443,
+			want: ClashProxy{
+				Name: "x.example.com", Type: "trojan", Server: "x.example.com", Port: 443,
+				Password: "secret", Network: "ws", TLS: true, UDP: true, SNI: "x.example.com", ClientFingerprint: "chrome",
+				WSOpts: &ClashWSOpts{Path: "/ws", Headers: map[string]string{"Host": "cdn.example.com"}},
+			},
+			yaml: []string{"type: trojan", "password: secret", "sni: x.example.com", "network: ws"},
+		},
+		{
+			name:     "vmess",
+			outbound: vmessOutbound(),
+			port:     443,
+			want: ClashProxy{
+				Name: "x.example.com", Type: "vmess", Server: "x.example.com", Port: 443,
+				UUID: "bae71742-94e0-4dd5-935f-070339819ba0", Network: "ws", TLS: true, UDP: true,
+				ServerName: "x.example.com", ClientFingerprint: "chrome", Cipher: "auto", AlterID: new(int),
+				WSOpts: &ClashWSOpts{Path: "/ws", Headers: map[string]string{"Host": "cdn.example.com"}},
+			},
+			yaml: []string{"type: vmess", "cipher: auto", "alterId: 0", "servername: x.example.com"},
+		},
+		{
+			name:     "shadowsocks",
+			outbound: shadowsocksOutbound(),
+			port:     8388,
+			want: ClashProxy{
+				Name: "x.example.com", Type: "ss", Server: "x.example.com", Port: 8388,
+				Cipher: "2022-blake3-aes-128-gcm", Password: "AAECAwQFBgcICQoLDA0ODw==", UDP: true,
+			},
+			yaml: []string{"type: ss", "cipher: 2022-blake3-aes-128-gcm", "password: AAECAwQFBgcICQoLDA0ODw=="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := ToClash(singbox(tt.outbound), testDynamicConfig(tt.port))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(profile.Proxies) != 1 {
+				t.Fatalf("got %d proxies, want 1", len(profile.Proxies))
+			}
+			if !reflect.DeepEqual(profile.Proxies[0], tt.want) {
+				t.Errorf("proxy = %+v\nwant    %+v", profile.Proxies[0], tt.want)
+			}
+			if got := profile.ProxyGroups[0].Proxies; !reflect.DeepEqual(got, []string{"x.example.com", "DIRECT"}) {
+				t.Errorf("PROXY group = %v", got)
+			}
+
+			data, err := yaml.Marshal(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.yaml {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("YAML does not contain %q:\n%s", want, data)
+				}
+			}
+			if tt.want.Type != "vless" && tt.want.Type != "vmess" && strings.Contains(string(data), "uuid:") {
+				t.Errorf("%s proxy has a uuid:\n%s", tt.want.Type, data)
+			}
+		})
+	}
+}
+
+func TestToClashNodes(t *testing.T) {
+	second := trojanOutbound()
+	second["server"] = "y.example.com"
+	cfg := map[string]interface{}{"outbounds": []interface{}{trojanOutbound(), second}}
+
+	profile, err := ToClashNodes(cfg, testDynamicConfig(443), []string{"de", "nl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Proxies[0].Name != "de" || profile.Proxies[1].Name != "nl" || profile.Proxies[1].Server != "y.example.com" {
+		t.Errorf("proxies = %+v", profile.Proxies)
+	}
+	if len(profile.ProxyGroups) != 2 || profile.ProxyGroups[1].Type != "url-test" {
+		t.Errorf("groups = %+v, want PROXY and an url-test group", profile.ProxyGroups)
+	}
+	if _, err := ToClashNodes(cfg, testDynamicConfig(443), []string{"de", "nl", "fi"}); err == nil {
+		t.Error("more names than outbounds: want an error")
+	}
+}
+
+func TestToClashRejectsUnsupported(t *testing.T) {
+	hysteria := map[string]interface{}{"type": "hysteria2", "tag": "proxy", "password": "secret"}
+	if _, err := ToClash(singbox(hysteria), testDynamicConfig(443)); !errors.Is(err, ErrUnsupportedOutbound) {
+		t.Errorf("hysteria2: got %v, want ErrUnsupportedOutbound", err)
+	}
+}
@@ -0,0 +1,233 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/utils"
+)
+
+// ErrUnsupportedOutbound is returned when a converter cannot handle the proxy outbound type
+var ErrUnsupportedOutbound = errors.New("unsupported outbound type")
+
+// ToXray converts a generated sing-box configuration into an Xray-core client configuration
+func ToXray(singbox map[string]interface{}, dynamicCfg *config.DynamicConfig) (map[string]interface{}, error) {
+	outbound, err := proxyOutbound(singbox)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol, _ := outbound["type"].(string)
+	settings, err := xraySettings(outbound, dynamicCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stream settings: WebSocket (or gRPC) transport over TLS or Reality
+	wsSettings := map[string]interface{}{
+		"path": dynamicCfg.WSPath,
+		"headers": map[string]interface{}{
+			"Host": dynamicCfg.HostHeader(),
+		},
+	}
+	streamSettings := map[string]interface{}{
+		"network":  "ws",
+		"security": "none",
+	}
+	if transport, ok := outbound["transport"].(map[string]interface{}); ok {
+		if path, ok := transport["path"].(string); ok {
+			wsSettings["path"] = path
+		}
+		if headers, ok := transport["headers"].(map[string]interface{}); ok {
+			if host, ok := headers["Host"].(string); ok {
+				wsSettings["headers"] = map[string]interface{}{"Host": host}
+			}
+		}
+		if transport["type"] == "grpc" {
+			serviceName, _ := transport["service_name"].(string)
+			streamSettings["network"] = "grpc"
+			streamSettings["grpcSettings"] = map[string]interface{}{
+				"serviceName": serviceName,
+			}
+		}
+	}
+	if _, exists := outbound["transport"]; !exists {
+		streamSettings["network"] = "tcp"
+	}
+	if streamSettings["network"] == "ws" {
+		streamSettings["wsSettings"] = wsSettings
+	}
+
+	if tls, ok := outbound["tls"].(map[string]interface{}); ok && tls["enabled"] == true {
+		tlsSettings := map[string]interface{}{
+			"serverName":    dynamicCfg.TLSServerName(),
+			"allowInsecure": tls["insecure"] == true,
+		}
+		if serverName, ok := tls["server_name"].(string); ok && serverName != "" {
+			tlsSettings["serverName"] = serverName
+		}
+		if utls, ok := tls["utls"].(map[string]interface{}); ok && utls["enabled"] == true {
+			if fingerprint, ok := utls["fingerprint"].(string); ok {
+				tlsSettings["fingerprint"] = fingerprint
+			}
+		}
+		if alpn := utils.StringSlice(tls["alpn"]); len(alpn) > 0 {
+			tlsSettings["alpn"] = alpn
+		}
+		streamSettings["security"] = "tls"
+		streamSettings["tlsSettings"] = tlsSettings
+
+		// Reality replaces TLS: Xray needs the server name, fingerprint and key pair of the server
+		if reality, ok := tls["reality"].(map[string]interface{}); ok && reality["enabled"] == true {
+			realitySettings := map[string]interface{}{
+				"serverName":  tlsSettings["serverName"],
+				"fingerprint": "chrome",
+				"publicKey":   reality["public_key"],
+				"shortId":     reality["short_id"],
+			}
+			if fingerprint, ok := tlsSettings["fingerprint"]; ok {
+				realitySettings["fingerprint"] = fingerprint
+			}
+			delete(streamSettings, "tlsSettings")
+			streamSettings["security"] = "reality"
+			streamSettings["realitySettings"] = realitySettings
+		}
+	}
+
+	return map[string]interface{}{
+		"log": map[string]interface{}{
+			"loglevel": "warning",
+		},
+		"dns": map[string]interface{}{
+			"servers": []interface{}{dynamicCfg.DNSServer},
+		},
+		"inbounds": []interface{}{
+			map[string]interface{}{
+				"tag":      "socks-in",
+				"listen":   "127.0.0.1",
+				"port":     dynamicCfg.MixedPort,
+				"protocol": "socks",
+				"settings": map[string]interface{}{
+					"udp": true,
+				},
+				"sniffing": map[string]interface{}{
+					"enabled":      true,
+					"destOverride": []interface{}{"http", "tls"},
+				},
+			},
+		},
+		"outbounds": []interface{}{
+			map[string]interface{}{
+				"tag":            "proxy",
+				"protocol":       protocol,
+				"settings":       settings,
+				"streamSettings": streamSettings,
+			},
+			map[string]interface{}{
+				"tag":      "direct",
+				"protocol": "freedom",
+			},
+			map[string]interface{}{
+				"tag":      "block",
+				"protocol": "blackhole",
+			},
+		},
+		"routing": map[string]interface{}{
+			"domainStrategy": "IPIfNonMatch",
+			"rules":          []interface{}{},
+		},
+	}, nil
+}
+
+// xraySettings returns the Xray outbound settings of a vless, vmess, trojan or shadowsocks proxy outbound
+func xraySettings(outbound map[string]interface{}, dynamicCfg *config.DynamicConfig) (map[string]interface{}, error) {
+	switch outbound["type"] {
+	case "vless":
+		uuid, _ := outbound["uuid"].(string)
+		if uuid == "" {
+			return nil, fmt.Errorf("missing uuid in proxy outbound")
+		}
+		user := map[string]interface{}{
+			"id":         uuid,
+			"encryption": "none",
+			"level":      0,
+		}
+		if flow, ok := outbound["flow"].(string); ok && flow != "" {
+			user["flow"] = flow
+		}
+		return map[string]interface{}{
+			"vnext": []interface{}{
+				map[string]interface{}{
+					"address": dynamicCfg.Server,
+					"port":    dynamicCfg.ServerPort,
+					"users":   []interface{}{user},
+				},
+			},
+		}, nil
+	case "vmess":
+		uuid, _ := outbound["uuid"].(string)
+		if uuid == "" {
+			return nil, fmt.Errorf("missing uuid in proxy outbound")
+		}
+		security, alterID := vmessSecurity(outbound)
+		return map[string]interface{}{
+			"vnext": []interface{}{
+				map[string]interface{}{
+					"address": dynamicCfg.Server,
+					"port":    dynamicCfg.ServerPort,
+					"users": []interface{}{
+						map[string]interface{}{
+							"id":       uuid,
+							"alterId":  alterID,
+							"security": security,
+							"level":    0,
+						},
+					},
+				},
+			},
+		}, nil
+	case "trojan", "shadowsocks":
+		password, _ := outbound["password"].(string)
+		if password == "" {
+			return nil, fmt.Errorf("missing password in proxy outbound")
+		}
+		server := map[string]interface{}{
+			"address":  dynamicCfg.Server,
+			"port":     dynamicCfg.ServerPort,
+			"password": password,
+		}
+		if outbound["type"] == "shadowsocks" {
+			server["method"] = outbound["method"]
+		}
+		return map[string]interface{}{"servers": []interface{}{server}}, nil
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedOutbound, outbound["type"])
+	}
+}
+
+// vmessSecurity returns the cipher and alter id of a vmess outbound, "auto" and 0 when unset;
+// alter_id is a json.Number as decoded from the template
+func vmessSecurity(outbound map[string]interface{}) (security string, alterID int) {
+	security, _ = outbound["security"].(string)
+	if security == "" {
+		security = "auto"
+	}
+	switch v := outbound["alter_id"].(type) {
+	case int:
+		alterID = v
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			alterID = int(n)
+		}
+	case float64:
+		alterID = int(v)
+	}
+	return security, alterID
+}
+
+// proxyOutbound returns the proxy outbound of a generated sing-box configuration
+func proxyOutbound(singbox map[string]interface{}) (map[string]interface{}, error) {
+	return utils.ProxyOutbound(singbox)
+}
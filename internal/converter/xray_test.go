@@ -0,0 +1,192 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"vless-generator/internal/config"
+)
+
+// Outbounds shaped like the generated configurations of the shipped templates
+func realityOutbound() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "vless", "tag": "proxy", "server": "x.example.com", "server_port": 443,
+		"uuid": "bae71742-94e0-4dd5-935f-070339819ba0", "flow": "xtls-rprx-vision",
+		"tls": map[string]interface{}{
+			"enabled": true, "server_name": "www.microsoft.com",
+			"utls":    map[string]interface{}{"enabled": true, "fingerprint": "firefox"},
+			"reality": map[string]interface{}{"enabled": true, "public_key": "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0", "short_id": "ab12"},
+		},
+	}
+}
+
+func trojanOutbound() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "trojan", "tag": "proxy", "server": "x.example.com", "server_port": 443, "password": "secret",
+		"tls": map[string]interface{}{
+			"enabled": true, "server_name": "x.example.com",
+			"utls": map[string]interface{}{"enabled": true, "fingerprint": "chrome"},
+		},
+		"transport": map[string]interface{}{"type": "ws", "path": "/ws", "headers": map[string]interface{}{"Host": "cdn.example.com"}},
+	}
+}
+
+func vmessOutbound() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "vmess", "tag": "proxy", "server": "x.example.com", "server_port": 443,
+		"uuid": "bae71742-94e0-4dd5-935f-070339819ba0", "security": "auto", "alter_id": json.Number("0"),
+		"tls": map[string]interface{}{
+			"enabled": true, "server_name": "x.example.com",
+			"utls": map[string]interface{}{"enabled": true, "fingerprint": "chrome"},
+		},
+		"transport": map[string]interface{}{"type": "ws", "path": "/ws", "headers": map[string]interface{}{"Host": "cdn.example.com"}},
+	}
+}
+
+func shadowsocksOutbound() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "shadowsocks", "tag": "proxy", "server": "x.example.com", "server_port": 8388,
+		"method": "2022-blake3-aes-128-gcm", "password": "AAECAwQFBgcICQoLDA0ODw==",
+	}
+}
+
+// singbox wraps a proxy outbound in a generated sing-box configuration
+func singbox(proxy map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"outbounds": []interface{}{proxy, map[string]interface{}{"type": "direct", "tag": "direct"}},
+	}
+}
+
+// testDynamicConfig returns the dynamic parameters the outbounds above were generated with
+func testDynamicConfig(port int) *config.DynamicConfig {
+	dynamicCfg := config.DefaultDynamicConfig()
+	dynamicCfg.Server = "x.example.com"
+	dynamicCfg.ServerPort = port
+	return dynamicCfg
+}
+
+// xrayProxy returns the proxy outbound of an Xray configuration
+func xrayProxy(t *testing.T, cfg map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	outbounds := cfg["outbounds"].([]interface{})
+	return outbounds[0].(map[string]interface{})
+}
+
+func TestToXrayReality(t *testing.T) {
+	cfg, err := ToXray(singbox(realityOutbound()), testDynamicConfig(443))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := xrayProxy(t, cfg)
+	if proxy["protocol"] != "vless" {
+		t.Errorf("protocol = %v, want vless", proxy["protocol"])
+	}
+	vnext := proxy["settings"].(map[string]interface{})["vnext"].([]interface{})[0].(map[string]interface{})
+	user := vnext["users"].([]interface{})[0].(map[string]interface{})
+	if user["id"] != "bae71742-94e0-4dd5-935f-070339819ba0" || user["flow"] != "xtls-rprx-vision" {
+		t.Errorf("user = %v", user)
+	}
+
+	stream := proxy["streamSettings"].(map[string]interface{})
+	want := map[string]interface{}{
+		"network":  "tcp",
+		"security": "reality",
+		"realitySettings": map[string]interface{}{
+			"serverName":  "www.microsoft.com",
+			"fingerprint": "firefox",
+			"publicKey":   "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0",
+			"shortId":     "ab12",
+		},
+	}
+	if !reflect.DeepEqual(stream, want) {
+		t.Errorf("streamSettings = %v, want %v", stream, want)
+	}
+}
+
+func TestToXrayTrojan(t *testing.T) {
+	cfg, err := ToXray(singbox(trojanOutbound()), testDynamicConfig(443))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := xrayProxy(t, cfg)
+	if proxy["protocol"] != "trojan" {
+		t.Errorf("protocol = %v, want trojan", proxy["protocol"])
+	}
+	servers := proxy["settings"].(map[string]interface{})["servers"].([]interface{})
+	wantServer := map[string]interface{}{"address": "x.example.com", "port": 443, "password": "secret"}
+	if !reflect.DeepEqual(servers[0], wantServer) {
+		t.Errorf("server = %v, want %v", servers[0], wantServer)
+	}
+
+	stream := proxy["streamSettings"].(map[string]interface{})
+	if stream["network"] != "ws" || stream["security"] != "tls" {
+		t.Errorf("network/security = %v/%v, want ws/tls", stream["network"], stream["security"])
+	}
+	ws := stream["wsSettings"].(map[string]interface{})
+	if ws["path"] != "/ws" || ws["headers"].(map[string]interface{})["Host"] != "cdn.example.com" {
+		t.Errorf("wsSettings = %v", ws)
+	}
+	tls := stream["tlsSettings"].(map[string]interface{})
+	if tls["serverName"] != "x.example.com" || tls["fingerprint"] != "chrome" {
+		t.Errorf("tlsSettings = %v", tls)
+	}
+}
+
+func TestToXrayVmess(t *testing.T) {
+	cfg, err := ToXray(singbox(vmessOutbound()), testDynamicConfig(443))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := xrayProxy(t, cfg)
+	if proxy["protocol"] != "vmess" {
+		t.Errorf("protocol = %v, want vmess", proxy["protocol"])
+	}
+	vnext := proxy["settings"].(map[string]interface{})["vnext"].([]interface{})[0].(map[string]interface{})
+	user := vnext["users"].([]interface{})[0]
+	wantUser := map[string]interface{}{"id": "bae71742-94e0-4dd5-935f-070339819ba0", "alterId": 0, "security": "auto", "level": 0}
+	if !reflect.DeepEqual(user, wantUser) {
+		t.Errorf("user = %v, want %v", user, wantUser)
+	}
+
+	stream := proxy["streamSettings"].(map[string]interface{})
+	if stream["network"] != "ws" || stream["security"] != "tls" {
+		t.Errorf("network/security = %v/%v, want ws/tls", stream["network"], stream["security"])
+	}
+}
+
+func TestToXrayShadowsocks(t *testing.T) {
+	cfg, err := ToXray(singbox(shadowsocksOutbound()), testDynamicConfig(8388))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := xrayProxy(t, cfg)
+	if proxy["protocol"] != "shadowsocks" {
+		t.Errorf("protocol = %v, want shadowsocks", proxy["protocol"])
+	}
+	servers := proxy["settings"].(map[string]interface{})["servers"].([]interface{})
+	wantServer := map[string]interface{}{
+		"address": "x.example.com", "port": 8388,
+		"method": "2022-blake3-aes-128-gcm", "password": "AAECAwQFBgcICQoLDA0ODw==",
+	}
+	if !reflect.DeepEqual(servers[0], wantServer) {
+		t.Errorf("server = %v, want %v", servers[0], wantServer)
+	}
+	wantStream := map[string]interface{}{"network": "tcp", "security": "none"}
+	if stream := proxy["streamSettings"]; !reflect.DeepEqual(stream, wantStream) {
+		t.Errorf("streamSettings = %v, want %v", stream, wantStream)
+	}
+}
+
+func TestToXrayRejects(t *testing.T) {
+	hysteria := map[string]interface{}{"type": "hysteria2", "tag": "proxy", "password": "secret"}
+	if _, err := ToXray(singbox(hysteria), testDynamicConfig(443)); !errors.Is(err, ErrUnsupportedOutbound) {
+		t.Errorf("hysteria2: got %v, want ErrUnsupportedOutbound", err)
+	}
+	noPassword := trojanOutbound()
+	delete(noPassword, "password")
+	if _, err := ToXray(singbox(noPassword), testDynamicConfig(443)); err == nil {
+		t.Error("trojan without password: want an error")
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/router"
+	"vless-generator/internal/templates"
+)
+
+// maxTemplateUploadBytes caps the body of PUT /admin/templates/<type>
+const maxTemplateUploadBytes = 4 << 20
+
+// PutTemplateHandler validates the JSON body as the template of a type and serves it from now on
+// (PUT /admin/templates/<type>). Invalid templates get 422 with every problem in the details.
+func (h *Handler) PutTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	templateType := router.Param(r, "type")
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTemplateUploadBytes))
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = h.templateManager.PutTemplate(templateType, data)
+	var templateErr *templates.TemplateError
+	switch {
+	case errors.Is(err, templates.ErrInvalidType):
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "type", map[string]string{"error": err.Error()})
+		return
+	case errors.As(err, &templateErr):
+		h.logger.WithError(err).WithField("type", templateType).Warn("Rejected invalid template upload")
+		problems := make([]string, len(templateErr.Problems))
+		for i, problem := range templateErr.Problems {
+			problems[i] = problem.Error()
+		}
+		apiErr := httperr.Error{
+			Code:    httperr.CodeInvalidTemplate,
+			Message: h.errorMessage(r, httperr.CodeInvalidTemplate, map[string]string{"type": templateType}),
+			Details: problems,
+		}
+		if err := httperr.WriteJSON(w, http.StatusUnprocessableEntity, apiErr); err != nil {
+			h.logger.WithError(err).Error("Failed to encode template errors response")
+		}
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("type", templateType).Error("Failed to install template")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"type":        templateType,
+		"bytes":       len(data),
+		"remote_addr": r.RemoteAddr,
+	}).Info("Template uploaded via admin endpoint")
+	h.options.Cache.Purge()
+	h.writeTemplateInfo(w, templateType)
+}
+
+// DeleteTemplateHandler removes the uploaded template of a type, falling back to the embedded or
+// on-disk file (DELETE /admin/templates/<type>). Types without an upload get 404.
+func (h *Handler) DeleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	templateType := router.Param(r, "type")
+
+	err := h.templateManager.DeleteTemplate(templateType)
+	var templateErr *templates.TemplateError
+	switch {
+	case errors.Is(err, templates.ErrNoOverride):
+		h.writeError(w, r, http.StatusNotFound, httperr.CodeNotFound, "type", nil)
+		return
+	case errors.As(err, &templateErr):
+		// The file underneath is broken, so the upload keeps serving
+		h.logger.WithError(err).WithField("type", templateType).Error("Cannot fall back to an invalid template file")
+		h.writeError(w, r, http.StatusConflict, httperr.CodeInvalidTemplate, "type", map[string]string{"type": templateType})
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("type", templateType).Error("Failed to delete template override")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"type":        templateType,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Template override deleted via admin endpoint")
+	h.options.Cache.Purge()
+	if !h.templateManager.HasTemplate(templateType) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.writeTemplateInfo(w, templateType)
+}
+
+// writeTemplateInfo responds with the description of a loaded template type
+func (h *Handler) writeTemplateInfo(w http.ResponseWriter, templateType string) {
+	info, _ := h.templateManager.GetTemplateInfo(templateType)
+	info.DefaultPort = h.options.Defaults.ServerPort
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeJSON(w, info, false); err != nil {
+		h.logger.WithError(err).Error("Failed to encode template info")
+	}
+}
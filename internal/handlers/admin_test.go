@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/i18n"
+	"vless-generator/internal/templates"
+)
+
+// newOverrideHandler returns a handler whose uploaded templates persist in a temporary
+// directory, as with -templates-dir
+func newOverrideHandler(t *testing.T) (*Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	manager := templates.NewManager(os.DirFS("../../templates"))
+	if err := manager.LoadOverrides(dir); err != nil {
+		t.Fatalf("LoadOverrides: %v", err)
+	}
+	if err := manager.LoadTemplates(testTemplateTypes); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	translations := i18n.NewI18n()
+	if err := translations.LoadTranslations(); err != nil {
+		t.Fatalf("LoadTranslations: %v", err)
+	}
+	return NewHandler(manager, nil, translations, Options{}), dir
+}
+
+// downloadLogLevel returns the log level of the vless config downloaded from h
+func downloadLogLevel(t *testing.T, h *Handler) string {
+	t.Helper()
+	w := serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, "/config/vless/"+testUUID+".json", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("download status = %d: %s", w.Code, w.Body)
+	}
+	var cfg struct {
+		Log struct {
+			Level string `json:"level"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Log.Level
+}
+
+func TestPutTemplateHandler(t *testing.T) {
+	h, dir := newOverrideHandler(t)
+	vless, err := os.ReadFile("../../templates/vless.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := strings.Replace(string(vless), `"level": "panic"`, `"level": "warn"`, 1)
+
+	w := serve("PUT /admin/templates/{type}", h.PutTemplateHandler, http.MethodPut, "/admin/templates/vless", upload, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if level := downloadLogLevel(t, h); level != "warn" {
+		t.Errorf("log level = %q, want the uploaded warn", level)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "vless.json")); err != nil || string(data) != upload {
+		t.Errorf("override file = %v, want the upload written to the templates directory", err)
+	}
+
+	// The upload survives a reload
+	w = serve("POST /admin/reload", h.ReloadHandler, http.MethodPost, "/admin/reload", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reload status = %d: %s", w.Code, w.Body)
+	}
+	if level := downloadLogLevel(t, h); level != "warn" {
+		t.Errorf("log level after reload = %q, want the uploaded warn", level)
+	}
+}
+
+func TestPutTemplateHandlerRejects(t *testing.T) {
+	h, dir := newOverrideHandler(t)
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{"invalid JSON", "/admin/templates/vless", `{"outbounds": [`, http.StatusUnprocessableEntity, httperr.CodeInvalidTemplate},
+		{"no proxy outbound", "/admin/templates/vless", `{"outbounds": [{"type": "direct", "tag": "direct"}]}`, http.StatusUnprocessableEntity, httperr.CodeInvalidTemplate},
+		{"invalid type", "/admin/templates/Vless", `{}`, http.StatusBadRequest, httperr.CodeInvalidParameter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve("PUT /admin/templates/{type}", h.PutTemplateHandler, http.MethodPut, tt.target, tt.body, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var body struct {
+				Error struct {
+					Code    string   `json:"code"`
+					Details []string `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			apiErr := body.Error
+			if apiErr.Code != tt.code {
+				t.Errorf("code = %q, want %q", apiErr.Code, tt.code)
+			}
+			if tt.status == http.StatusUnprocessableEntity && len(apiErr.Details) == 0 {
+				t.Error("422 response lists no validation problems")
+			}
+		})
+	}
+
+	if level := downloadLogLevel(t, h); level != "panic" {
+		t.Errorf("log level = %q, want the repository template still served", level)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("rejected uploads wrote %d files", len(entries))
+	}
+}
+
+func TestDeleteTemplateHandler(t *testing.T) {
+	h, dir := newOverrideHandler(t)
+	w := serve("DELETE /admin/templates/{type}", h.DeleteTemplateHandler, http.MethodDelete, "/admin/templates/vless", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("delete without an override status = %d, want 404", w.Code)
+	}
+
+	vless, err := os.ReadFile("../../templates/vless.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := strings.Replace(string(vless), `"level": "panic"`, `"level": "warn"`, 1)
+	if w := serve("PUT /admin/templates/{type}", h.PutTemplateHandler, http.MethodPut, "/admin/templates/vless", upload, nil); w.Code != http.StatusOK {
+		t.Fatalf("put status = %d: %s", w.Code, w.Body)
+	}
+
+	w = serve("DELETE /admin/templates/{type}", h.DeleteTemplateHandler, http.MethodDelete, "/admin/templates/vless", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", w.Code, w.Body)
+	}
+	if level := downloadLogLevel(t, h); level != "panic" {
+		t.Errorf("log level = %q, want the embedded template back", level)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vless.json")); !os.IsNotExist(err) {
+		t.Errorf("override file after delete: %v, want it removed", err)
+	}
+
+	// An upload-only type is removed entirely
+	if w := serve("PUT /admin/templates/{type}", h.PutTemplateHandler, http.MethodPut, "/admin/templates/custom", upload, nil); w.Code != http.StatusOK {
+		t.Fatalf("put status = %d: %s", w.Code, w.Body)
+	}
+	w = serve("DELETE /admin/templates/{type}", h.DeleteTemplateHandler, http.MethodDelete, "/admin/templates/custom", "", nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete of an upload-only type status = %d, want 204", w.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vless-generator/internal/audit"
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/middleware"
+)
+
+// Page sizes of GET /admin/configs
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditDateLayout is the date-only form accepted by the since and until parameters
+const auditDateLayout = "2006-01-02"
+
+// auditResponse is the JSON body returned by AuditConfigsHandler
+type auditResponse struct {
+	Total   int            `json:"total"` // Number of records matching the filter
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+	Records []audit.Record `json:"records"`
+}
+
+// recordGenerated queues an audit record of a generated config when auditing is enabled
+func (h *Handler) recordGenerated(r *http.Request, configType, uuid, server string) {
+	if h.options.Audit == nil {
+		return
+	}
+	h.options.Audit.Record(audit.Record{
+		Time:      time.Now().UTC(),
+		Type:      configType,
+		UUIDHash:  audit.HashUUID(uuid),
+		Server:    server,
+		ClientIP:  middleware.ClientIP(r, h.options.TrustedProxies),
+		UserAgent: r.UserAgent(),
+	})
+}
+
+// AuditConfigsHandler lists recorded configs, newest first (GET /admin/configs). The type,
+// since and until parameters filter the records; limit and offset page through them.
+func (h *Handler) AuditConfigsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.options.AuditStore == nil {
+		h.writeError(w, r, http.StatusNotFound, httperr.CodeNotFound, "", nil)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{Type: query.Get("type"), Limit: defaultAuditLimit}
+	var err error
+	if filter.Since, err = parseAuditTime(query.Get("since"), false); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "since", map[string]string{"error": err.Error()})
+		return
+	}
+	if filter.Until, err = parseAuditTime(query.Get("until"), true); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "until", map[string]string{"error": err.Error()})
+		return
+	}
+	if value := query.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxAuditLimit {
+			h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "limit", map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d, got %q", maxAuditLimit, value)})
+			return
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		filter.Offset, err = strconv.Atoi(value)
+		if err != nil || filter.Offset < 0 {
+			h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "offset", map[string]string{"error": fmt.Sprintf("offset must be a non-negative integer, got %q", value)})
+			return
+		}
+	}
+
+	records, total, err := h.options.AuditStore.Query(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to query audit records")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+		return
+	}
+	if records == nil {
+		records = []audit.Record{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	response := auditResponse{Total: total, Offset: filter.Offset, Limit: filter.Limit, Records: records}
+	if err := writeJSON(w, response, false); err != nil {
+		h.logger.WithError(err).Error("Failed to encode audit response")
+	}
+}
+
+// parseAuditTime parses an RFC 3339 timestamp or a UTC date. A date used as the upper
+// bound means the end of that day, so until=2024-05-01 includes May 1.
+func parseAuditTime(value string, upper bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(auditDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 time or a YYYY-MM-DD date, got %q", value)
+	}
+	if upper {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/audit"
+)
+
+// stubAuditStore answers queries with a fixed record and counts them
+type stubAuditStore struct {
+	queries int
+}
+
+func (s *stubAuditStore) Append([]audit.Record) error { return nil }
+func (s *stubAuditStore) Close() error                { return nil }
+
+func (s *stubAuditStore) Query(audit.Filter) ([]audit.Record, int, error) {
+	s.queries++
+	return []audit.Record{{Time: time.Now().UTC(), Type: "vless", ClientIP: "203.0.113.7"}}, 1, nil
+}
+
+func (s *stubAuditStore) DeleteBefore(time.Time) (int, error) { return 0, nil }
+
+func TestAuditConfigsRequiresAdmin(t *testing.T) {
+	store := &stubAuditStore{}
+	h := newTestHandler(t, Options{AuditStore: store})
+	w := serve("GET /admin/configs", h.RequireAdmin(h.AuditConfigsHandler), http.MethodGet, "/admin/configs", "", nil)
+	if w.Code != http.StatusNotFound || store.queries != 0 {
+		t.Fatalf("without configured tokens: status = %d, queries = %d; want 404 and no query", w.Code, store.queries)
+	}
+
+	h = newTestHandler(t, Options{AuditStore: store, AuthTokens: []string{"secret"}})
+	w = serve("GET /admin/configs", h.RequireAdmin(h.AuditConfigsHandler), http.MethodGet, "/admin/configs", "", nil)
+	if w.Code != http.StatusUnauthorized || store.queries != 0 {
+		t.Fatalf("without token: status = %d, queries = %d; want 401 and no query", w.Code, store.queries)
+	}
+	w = serve("GET /admin/configs", h.RequireAdmin(h.AuditConfigsHandler), http.MethodGet, "/admin/configs", "", http.Header{"Authorization": {"Bearer secret"}})
+	if w.Code != http.StatusOK || store.queries != 1 {
+		t.Fatalf("with token: status = %d, queries = %d; want 200 and one query", w.Code, store.queries)
+	}
+}
+
+func TestDownloadAuditsSentConfigsOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	store, err := audit.OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := audit.NewRecorder(store, 100, logrus.WithField("component", "audit"))
+	h := newTestHandler(t, Options{Audit: recorder})
+
+	download := func(target string, header http.Header) *httptest.ResponseRecorder {
+		return serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, target, "", header)
+	}
+	w := download("/config/vless/"+testUUID+".json?server=x.example.com", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("download status = %d: %s", w.Code, w.Body)
+	}
+	// A revalidation answered 304 is not a new config
+	if w := download("/config/vless/"+testUUID+".json?server=x.example.com", http.Header{"If-None-Match": {w.Header().Get("ETag")}}); w.Code != http.StatusNotModified {
+		t.Fatalf("revalidation status = %d, want 304", w.Code)
+	}
+	// Generated but not convertible to the requested format
+	if w := download("/config/hysteria2/"+testUUID+".json?server=x.example.com&format=xray", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("unsupported format status = %d, want 400: %s", w.Code, w.Body)
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := audit.OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	records, total, err := reopened.Query(audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Fatalf("audited %d configs, want 1: %+v", total, records)
+	}
+	if rec := records[0]; rec.Type != "vless" || rec.Server != "x.example.com" || rec.UUIDHash != audit.HashUUID(testUUID) {
+		t.Errorf("record = %+v, want the vless download", rec)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), testUUID) {
+		t.Error("audit store contains the UUID, want only its hash")
+	}
+}
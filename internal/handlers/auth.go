@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/utils"
+)
+
+// RequireToken rejects requests without a valid access token (?token= or Authorization: Bearer)
+// with 401; routes stay open when no tokens are configured
+func (h *Handler) RequireToken(next http.HandlerFunc) http.HandlerFunc {
+	if len(h.options.AuthTokens) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.validToken(requestToken(r)) {
+			h.logger.WithFields(logrus.Fields{
+				"path":        utils.RedactPath(r.URL.Path),
+				"remote_addr": r.RemoteAddr,
+			}).Warn("Rejected request without a valid access token")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vless-generator"`)
+			h.writeError(w, r, http.StatusUnauthorized, httperr.CodeUnauthorized, "token", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdmin guards admin routes: requests need a valid access token, and without any
+// configured tokens the routes answer 404 as if they did not exist, since nobody could be
+// trusted to change templates, maintenance mode or read the audit trail
+func (h *Handler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	if len(h.options.AuthTokens) == 0 {
+		return func(w http.ResponseWriter, r *http.Request) {
+			h.logger.WithFields(logrus.Fields{
+				"path":        utils.RedactPath(r.URL.Path),
+				"remote_addr": r.RemoteAddr,
+			}).Warn("Rejected admin request: admin routes are disabled without -auth-token")
+			h.writeError(w, r, http.StatusNotFound, httperr.CodeNotFound, "", nil)
+		}
+	}
+	return h.RequireToken(next)
+}
+
+// RequireLinkIssuer guards routes handing out links that work without a signature, such as
+// short links: with a signing key they need an admin token like signing itself, otherwise
+// they behave like RequireToken
+func (h *Handler) RequireLinkIssuer(next http.HandlerFunc) http.HandlerFunc {
+	if len(h.options.SigningKey) > 0 {
+		return h.RequireAdmin(next)
+	}
+	return h.RequireToken(next)
+}
+
+// RequireSignature protects generation routes with signed links when a signing key is set:
+// requests need valid sig and exp parameters or an access token. Missing signatures get 401,
+// tampered or expired ones 403. Without a signing key it behaves like RequireToken.
+func (h *Handler) RequireSignature(next http.HandlerFunc) http.HandlerFunc {
+	if len(h.options.SigningKey) == 0 {
+		return h.RequireToken(next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.validToken(requestToken(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := utils.VerifyURL(h.options.SigningKey, signedPath(r.URL.EscapedPath()), r.URL.Query(), time.Now())
+		if err == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"path":        utils.RedactPath(r.URL.Path),
+			"remote_addr": r.RemoteAddr,
+		}).Warn("Rejected request without a valid link signature")
+		switch {
+		case errors.Is(err, utils.ErrSignatureMissing):
+			h.writeError(w, r, http.StatusUnauthorized, httperr.CodeSignatureRequired, utils.SignatureParam, nil)
+		case errors.Is(err, utils.ErrSignatureExpired):
+			h.writeError(w, r, http.StatusForbidden, httperr.CodeSignatureExpired, utils.ExpiresParam, nil)
+		default:
+			h.writeError(w, r, http.StatusForbidden, httperr.CodeInvalidSignature, utils.SignatureParam, nil)
+		}
+	})
+}
+
+// signedPath returns the config page path a generation route is signed for, so that one
+// signature covers the page and its /config/, /qrcode/ and /bundle/ links. Segments are
+// re-escaped so equivalent encodings of a credential sign the same way.
+func signedPath(escapedPath string) string {
+	parts := strings.Split(strings.Trim(escapedPath, "/"), "/")
+	for i, part := range parts {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			parts[i] = url.PathEscape(unescaped)
+		}
+	}
+	if len(parts) == 3 && (parts[0] == "config" || parts[0] == "qrcode" || parts[0] == "bundle") {
+		parts = []string{parts[1], strings.TrimSuffix(parts[2], path.Ext(parts[2]))}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// requestToken returns the bearer token of the request, or its token query parameter
+func requestToken(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("token")
+}
+
+// validToken compares token against every configured token in constant time; hashing first
+// keeps the comparison independent of the token lengths
+func (h *Handler) validToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(token))
+	valid := 0
+	for _, expected := range h.options.AuthTokens {
+		expectedSum := sha256.Sum256([]byte(expected))
+		valid |= subtle.ConstantTimeCompare(sum[:], expectedSum[:])
+	}
+	return valid == 1
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/shortlink"
+	"vless-generator/internal/utils"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	template, err := os.ReadFile("../../templates/vless.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearer := http.Header{"Authorization": {"Bearer secret"}}
+
+	tests := []struct {
+		name    string
+		tokens  []string
+		method  string
+		pattern string
+		target  string
+		header  http.Header
+		want    int
+	}{
+		{"put without configured tokens", nil, http.MethodPut, "PUT /admin/templates/{type}", "/admin/templates/vless", nil, http.StatusNotFound},
+		{"put with token but none configured", nil, http.MethodPut, "PUT /admin/templates/{type}", "/admin/templates/vless", bearer, http.StatusNotFound},
+		{"delete without configured tokens", nil, http.MethodDelete, "DELETE /admin/templates/{type}", "/admin/templates/vless", nil, http.StatusNotFound},
+		{"put without token", []string{"secret"}, http.MethodPut, "PUT /admin/templates/{type}", "/admin/templates/vless", nil, http.StatusUnauthorized},
+		{"put with wrong token", []string{"secret"}, http.MethodPut, "PUT /admin/templates/{type}", "/admin/templates/vless?token=nope", nil, http.StatusUnauthorized},
+		{"put with token", []string{"secret"}, http.MethodPut, "PUT /admin/templates/{type}", "/admin/templates/vless", bearer, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, Options{AuthTokens: tt.tokens})
+			next := h.PutTemplateHandler
+			if tt.method == http.MethodDelete {
+				next = h.DeleteTemplateHandler
+			}
+			w := serve(tt.pattern, h.RequireAdmin(next), tt.method, tt.target, string(template), tt.header)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestMaintenanceRequiresAdmin(t *testing.T) {
+	body := `{"enabled": true}`
+
+	h := newTestHandler(t, Options{})
+	w := serve("POST /admin/maintenance", h.RequireAdmin(h.MaintenanceHandler), http.MethodPost, "/admin/maintenance", body, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("without configured tokens: status = %d, want 404", w.Code)
+	}
+	if h.maintenanceStatus().Enabled {
+		t.Fatal("maintenance mode enabled by an anonymous request")
+	}
+
+	h = newTestHandler(t, Options{AuthTokens: []string{"secret"}})
+	w = serve("POST /admin/maintenance", h.RequireAdmin(h.MaintenanceHandler), http.MethodPost, "/admin/maintenance", body, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("without token: status = %d, want 401", w.Code)
+	}
+	w = serve("POST /admin/maintenance", h.RequireAdmin(h.MaintenanceHandler), http.MethodPost, "/admin/maintenance?token=secret", body, nil)
+	if w.Code != http.StatusOK || !h.maintenanceStatus().Enabled {
+		t.Fatalf("with token: status = %d, enabled = %v", w.Code, h.maintenanceStatus().Enabled)
+	}
+}
+
+func TestReloadRequiresAdmin(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	w := serve("POST /admin/reload", h.RequireAdmin(h.ReloadHandler), http.MethodPost, "/admin/reload", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("without configured tokens: status = %d, want 404", w.Code)
+	}
+}
+
+func TestRequireSignature(t *testing.T) {
+	key := []byte("signing-key")
+	h := newTestHandler(t, Options{AuthTokens: []string{"secret"}, SigningKey: key})
+	query := url.Values{"server": {"x.example.com"}}
+	pageLink := utils.SignURL(key, "/vless/"+testUUID, query, time.Now().Add(time.Hour))
+	_, signedQuery, _ := strings.Cut(pageLink, "?")
+	expiredLink := utils.SignURL(key, "/vless/"+testUUID, query, time.Now().Add(-time.Minute))
+	_, expiredQuery, _ := strings.Cut(expiredLink, "?")
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		status int
+		code   string
+	}{
+		{"signed download", "/config/vless/" + testUUID + ".json?" + signedQuery, nil, http.StatusOK, ""},
+		{"signed download with unsigned params", "/config/vless/" + testUUID + ".json?" + signedQuery + "&pretty=false&lang=ru", nil, http.StatusOK, ""},
+		{"token instead of signature", "/config/vless/" + testUUID + ".json?server=x.example.com", http.Header{"Authorization": {"Bearer secret"}}, http.StatusOK, ""},
+		{"unsigned", "/config/vless/" + testUUID + ".json?server=x.example.com", nil, http.StatusUnauthorized, httperr.CodeSignatureRequired},
+		{"expired", "/config/vless/" + testUUID + ".json?" + expiredQuery, nil, http.StatusForbidden, httperr.CodeSignatureExpired},
+		{"tampered", "/config/vless/" + testUUID + ".json?" + strings.Replace(signedQuery, "x.example.com", "evil.example.com", 1), nil, http.StatusForbidden, httperr.CodeInvalidSignature},
+		{"other uuid", "/config/vless/" + strings.Replace(testUUID, "b", "c", 1) + ".json?" + signedQuery, nil, http.StatusForbidden, httperr.CodeInvalidSignature},
+		{"wrong token", "/config/vless/" + testUUID + ".json?" + signedQuery, http.Header{"Authorization": {"Bearer nope"}}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve("GET /config/{type}/{uuid}.json", h.RequireSignature(h.ConfigDownloadHandler), http.MethodGet, tt.target, "", tt.header)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.code != "" && !strings.Contains(w.Body.String(), `"`+tt.code+`"`) {
+				t.Errorf("body %s, want code %s", w.Body, tt.code)
+			}
+		})
+	}
+}
+
+func TestSignedPath(t *testing.T) {
+	for escapedPath, want := range map[string]string{
+		"/vless/" + testUUID:                  "/vless/" + testUUID,
+		"/config/vless/" + testUUID + ".json": "/vless/" + testUUID,
+		"/config/vless/" + testUUID + ".yaml": "/vless/" + testUUID,
+		"/qrcode/vless/" + testUUID + ".png":  "/vless/" + testUUID,
+		"/bundle/vless/" + testUUID + ".zip":  "/vless/" + testUUID,
+		"/sub/" + testUUID:                    "/sub/" + testUUID,
+		"/vless/my%20id":                      "/vless/my%20id",
+		"/vless/my id":                        "/vless/my%20id",
+	} {
+		if got := signedPath(escapedPath); got != want {
+			t.Errorf("signedPath(%q) = %q, want %q", escapedPath, got, want)
+		}
+	}
+}
+
+func TestShortenRequiresLinkIssuer(t *testing.T) {
+	body := `{"type": "vless", "uuid": "` + testUUID + `"}`
+	bearer := http.Header{"Authorization": {"Bearer secret"}}
+	tests := []struct {
+		name       string
+		tokens     []string
+		signingKey string
+		header     http.Header
+		want       int
+	}{
+		{"nothing configured", nil, "", nil, http.StatusCreated},
+		{"signing key only", nil, "key", nil, http.StatusNotFound},
+		{"signing key only with a token", nil, "key", bearer, http.StatusNotFound},
+		{"token without signing key", []string{"secret"}, "", nil, http.StatusUnauthorized},
+		{"signing key without token", []string{"secret"}, "key", nil, http.StatusUnauthorized},
+		{"signing key with token", []string{"secret"}, "key", bearer, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := shortlink.OpenFileStore(filepath.Join(t.TempDir(), "links.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := newTestHandler(t, Options{AuthTokens: tt.tokens, SigningKey: []byte(tt.signingKey), ShortLinks: store})
+			w := serve("POST /api/v1/shorten", h.RequireLinkIssuer(h.ShortenHandler), http.MethodPost, "/api/v1/shorten", body, tt.header)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/utils"
+)
+
+// batchRequest is the JSON body accepted by BatchHandler
+type batchRequest struct {
+	Type   string                 `json:"type"`
+	UUIDs  []string               `json:"uuids"`
+	Count  int                    `json:"count"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// batchEntry is one generated config of a batch response
+type batchEntry struct {
+	UUID   string          `json:"uuid"`
+	URL    string          `json:"url,omitempty"`
+	Config json.RawMessage `json:"config,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// batchChunkFactor sets how many entries per worker are generated before they are written out,
+// which keeps memory bounded by the chunk rather than by the whole batch
+const batchChunkFactor = 4
+
+// BatchHandler generates configs for many UUIDs that share one set of dynamic parameters
+func (h *Handler) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 8<<20)
+	var req batchRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber() // Keep params and patch integers exact
+	if err := decoder.Decode(&req); err != nil {
+		h.logger.WithError(err).Warn("Failed to decode batch request body")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Type == "" {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, "type", map[string]string{"param": "type"})
+		return
+	}
+	if len(req.UUIDs) > 0 && req.Count > 0 {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "count", map[string]string{"error": "uuids and count are mutually exclusive"})
+		return
+	}
+
+	size := len(req.UUIDs)
+	if size == 0 {
+		size = req.Count
+	}
+	if size <= 0 {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, "uuids", map[string]string{"param": "uuids"})
+		return
+	}
+	if size > h.options.MaxBatchSize {
+		h.writeError(w, r, http.StatusRequestEntityTooLarge, httperr.CodeBatchTooLarge, "uuids", map[string]string{"size": strconv.Itoa(size), "max": strconv.Itoa(h.options.MaxBatchSize)})
+		return
+	}
+
+	query, warnings := config.ParamsToValues(req.Params)
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(query, h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.writeParamErrors(w, r, paramErrs)
+		return
+	}
+
+	uuids := req.UUIDs
+	if len(uuids) == 0 {
+		uuids = make([]string, 0, req.Count)
+		for i := 0; i < req.Count; i++ {
+			uuid, err := h.templateManager.NewCredential(req.Type)
+			if err != nil {
+				h.logger.WithError(err).Error("Failed to generate credential for batch")
+				h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+				return
+			}
+			uuids = append(uuids, uuid)
+		}
+	}
+	for _, uuid := range uuids {
+		if !h.checkUUID(w, r, query, req.Type, uuid) {
+			return
+		}
+	}
+
+	// Generate the first entry up front so template and parameter errors
+	// still produce a proper status code before streaming starts
+	if err := h.templateManager.CheckConfig(req.Type, uuids[0], dynamicCfg); err != nil {
+		h.handleGenerateError(w, r, err, req.Type, uuids[0])
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"config_type": req.Type,
+		"count":       len(uuids),
+		"server":      dynamicCfg.Server,
+		"warnings":    warnings,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Generating batch configuration")
+
+	var err error
+	if strings.Contains(r.Header.Get("Accept"), "application/zip") {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", utils.ContentDisposition(req.Type+"-batch.zip"))
+		err = h.writeBatchZip(w, r, req.Type, uuids, dynamicCfg)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = h.writeBatchJSON(w, r, req.Type, uuids, dynamicCfg)
+	}
+	if err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"config_type": req.Type,
+			"count":       len(uuids),
+		}).Error("Failed to write batch response")
+	}
+}
+
+// writeBatchJSON streams the batch as a JSON array of entries
+func (h *Handler) writeBatchJSON(w io.Writer, r *http.Request, configType string, uuids []string, dynamicCfg *config.DynamicConfig) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := h.generateBatch(r, configType, uuids, dynamicCfg, func(entry batchEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode entry %s: %w", entry.UUID, err)
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
+
+// writeBatchZip streams the batch as a zip archive with one <uuid>.json per entry
+func (h *Handler) writeBatchZip(w io.Writer, r *http.Request, configType string, uuids []string, dynamicCfg *config.DynamicConfig) error {
+	archive := zip.NewWriter(w)
+
+	err := h.generateBatch(r, configType, uuids, dynamicCfg, func(entry batchEntry) error {
+		if entry.Error != "" {
+			return nil
+		}
+		// Shadowsocks keys may contain "/", which would create directories in the archive
+		name := strings.ReplaceAll(entry.UUID, "/", "_") + ".json"
+		file, err := archive.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", name, err)
+		}
+		if _, err := file.Write(entry.Config); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// generateBatch generates configs with a bounded worker pool and passes them to emit in request order.
+// Entries are processed in chunks so only one chunk of configs is held in memory at a time.
+func (h *Handler) generateBatch(r *http.Request, configType string, uuids []string, dynamicCfg *config.DynamicConfig, emit func(batchEntry) error) error {
+	workers := runtime.NumCPU()
+	if workers > len(uuids) {
+		workers = len(uuids)
+	}
+	chunkSize := workers * batchChunkFactor
+
+	for start := 0; start < len(uuids); start += chunkSize {
+		end := start + chunkSize
+		if end > len(uuids) {
+			end = len(uuids)
+		}
+
+		entries := make([]batchEntry, end-start)
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for index := range jobs {
+					entries[index] = h.generateBatchEntry(configType, uuids[start+index], dynamicCfg)
+				}
+			}()
+		}
+		for index := range entries {
+			jobs <- index
+		}
+		close(jobs)
+		wg.Wait()
+
+		for _, entry := range entries {
+			if err := emit(entry); err != nil {
+				return err
+			}
+			if entry.Error == "" {
+				h.recordGenerated(r, configType, entry.UUID, dynamicCfg.Server)
+			}
+		}
+	}
+
+	return nil
+}
+
+// generateBatchEntry generates a single batch entry; failures are reported in the entry itself
+func (h *Handler) generateBatchEntry(configType, uuid string, dynamicCfg *config.DynamicConfig) batchEntry {
+	entry := batchEntry{UUID: uuid}
+
+	cfg, err := h.templateManager.GenerateConfig(configType, uuid, dynamicCfg)
+	if err == nil {
+		entry.URL, err = utils.GenerateShareURL(configType, cfg, uuid, dynamicCfg.Remark(configType))
+	}
+	if err == nil {
+		entry.Config, err = json.MarshalIndent(cfg, "", "  ")
+	}
+	if err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"config_type": configType,
+			"uuid":        utils.RedactSecret(uuid),
+		}).Error("Failed to generate batch entry")
+		return batchEntry{UUID: uuid, Error: err.Error()}
+	}
+
+	return entry
+}
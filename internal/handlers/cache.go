@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"vless-generator/internal/middleware"
+)
+
+// writeCacheable writes a generated download with a strong ETag, the SHA-256 of body, and
+// Cache-Control from Options.CacheMaxAge. Since body is derived from the path and query,
+// any parameter that changes the output changes the ETag. When If-None-Match matches it
+// answers 304 without a body, so polling clients skip unchanged configs. It reports whether
+// the body was sent.
+func (h *Handler) writeCacheable(w http.ResponseWriter, r *http.Request, body []byte) (bool, error) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(h.options.CacheMaxAge.Seconds())))
+
+	if middleware.ETagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return false, nil
+	}
+	_, err := w.Write(body)
+	return err == nil, err
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"vless-generator/internal/middleware"
+)
+
+func TestDownloadETag(t *testing.T) {
+	h := newTestHandler(t, Options{CacheMaxAge: 5 * time.Minute})
+	routes := []struct {
+		name    string
+		pattern string
+		handler http.HandlerFunc
+		target  string
+	}{
+		{"download", "GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, "/config/vless/" + testUUID + ".json?server=x.example.com"},
+		{"yaml download", "GET /config/{type}/{uuid}.yaml", h.ConfigDownloadHandler, "/config/vless/" + testUUID + ".yaml?server=x.example.com"},
+		{"subscription", "GET /sub/{uuid}", h.SubscriptionHandler, "/sub/" + testUUID + "?servers=x.example.com"},
+	}
+	for _, route := range routes {
+		t.Run(route.name, func(t *testing.T) {
+			get := func(target, ifNoneMatch string) *http.Response {
+				header := http.Header{}
+				if ifNoneMatch != "" {
+					header.Set("If-None-Match", ifNoneMatch)
+				}
+				return serve(route.pattern, route.handler, http.MethodGet, target, "", header).Result()
+			}
+
+			first := get(route.target, "")
+			etag := first.Header.Get("ETag")
+			if first.StatusCode != http.StatusOK || len(etag) != 66 || !strings.HasPrefix(etag, `"`) {
+				t.Fatalf("status = %d, ETag = %q; want 200 with a strong SHA-256 ETag", first.StatusCode, etag)
+			}
+			if got := first.Header.Get("Cache-Control"); got != "private, max-age=300" {
+				t.Errorf("Cache-Control = %q, want private, max-age=300", got)
+			}
+			if again := get(route.target, ""); again.Header.Get("ETag") != etag {
+				t.Errorf("repeated request ETag = %s, want %s", again.Header.Get("ETag"), etag)
+			}
+
+			for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+				notModified := get(route.target, ifNoneMatch)
+				body, _ := io.ReadAll(notModified.Body)
+				if notModified.StatusCode != http.StatusNotModified || len(body) != 0 {
+					t.Errorf("If-None-Match %s: status = %d with %d bytes, want 304 without a body", ifNoneMatch, notModified.StatusCode, len(body))
+				}
+				if notModified.Header.Get("ETag") != etag {
+					t.Errorf("If-None-Match %s: 304 ETag = %s, want %s", ifNoneMatch, notModified.Header.Get("ETag"), etag)
+				}
+			}
+			if stale := get(route.target, `"stale"`); stale.StatusCode != http.StatusOK {
+				t.Errorf("stale If-None-Match: status = %d, want 200", stale.StatusCode)
+			}
+
+			// Parameters that change the output change the ETag
+			for _, param := range []string{"port=8443", "ws-path=%2Fother", "sni=other.example.com", "fp=firefox"} {
+				changed := get(route.target+"&"+param, etag)
+				if changed.StatusCode != http.StatusOK {
+					t.Errorf("%s: status = %d with the old ETag, want 200", param, changed.StatusCode)
+				}
+				if changed.Header.Get("ETag") == etag {
+					t.Errorf("%s: ETag did not change", param)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadETagCompressed(t *testing.T) {
+	h := newTestHandler(t, Options{CacheMaxAge: time.Minute})
+	handler := middleware.Compress(h.ConfigDownloadHandler)
+	target := "/config/vless/" + testUUID + ".json?server=x.example.com"
+
+	identity := serve("GET /config/{type}/{uuid}.json", handler, http.MethodGet, target, "", nil)
+	strong := identity.Header().Get("ETag")
+	if identity.Code != http.StatusOK || !strings.HasPrefix(strong, `"`) {
+		t.Fatalf("identity: status = %d, ETag = %q; want 200 with a strong ETag", identity.Code, strong)
+	}
+
+	gzipped := serve("GET /config/{type}/{uuid}.json", handler, http.MethodGet, target, "", http.Header{"Accept-Encoding": {"gzip"}})
+	weak := gzipped.Header().Get("ETag")
+	if gzipped.Header().Get("Content-Encoding") != "gzip" || weak != "W/"+strong {
+		t.Fatalf("gzip: Content-Encoding = %q, ETag = %q; want gzip and W/%s", gzipped.Header().Get("Content-Encoding"), weak, strong)
+	}
+	reader, err := gzip.NewReader(gzipped.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != identity.Body.String() {
+		t.Error("gzip body differs from the identity body")
+	}
+
+	// A client revalidates with whichever ETag it received
+	for _, tt := range []struct{ etag, encoding string }{
+		{weak, "gzip"},
+		{weak, ""},
+		{strong, "gzip"},
+	} {
+		header := http.Header{"If-None-Match": {tt.etag}}
+		if tt.encoding != "" {
+			header.Set("Accept-Encoding", tt.encoding)
+		}
+		w := serve("GET /config/{type}/{uuid}.json", handler, http.MethodGet, target, "", header)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s with Accept-Encoding %q: status = %d with %d bytes, want 304", tt.etag, tt.encoding, w.Code, w.Body.Len())
+		}
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("304 has Content-Encoding %q", w.Header().Get("Content-Encoding"))
+		}
+	}
+}
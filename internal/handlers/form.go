@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/utils"
+)
+
+// homeFormFields are the home page form fields forwarded to the config page query string
+var homeFormFields = []string{
+	"variant", "server", "port", "ws-path", "dns-server", "doh-server", "tun-address", "tun-mtu",
+	"mixed-port", "pbk", "sid", "up", "down", "obfs-password", "block-ads",
+	"clash-api-port", "clash-api-secret",
+}
+
+// maxHomeFormBytes caps the size of a submitted home page form
+const maxHomeFormBytes = 64 << 10
+
+// handleHomeForm validates a home page form submitted without JavaScript and redirects
+// to the config page; rejected submissions render the form again with field errors
+func (h *Handler) handleHomeForm(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxHomeFormBytes)
+	if err := r.ParseForm(); err != nil {
+		h.logger.WithError(err).Warn("Failed to parse home page form")
+		h.renderErrorPage(w, r, http.StatusBadRequest, h.errorMessage(r, httperr.CodeInvalidBody, map[string]string{"error": err.Error()}))
+		return
+	}
+
+	configType := strings.TrimSpace(r.PostForm.Get("type"))
+	uuid := strings.TrimSpace(r.PostForm.Get("uuid"))
+	query := homeFormQuery(r.PostForm)
+
+	var formErrs []config.ParamError
+	if !h.templateManager.HasTemplate(configType) {
+		formErrs = append(formErrs, config.ParamError{
+			Param:   "type",
+			Value:   configType,
+			Message: h.errorMessage(r, httperr.CodeTemplateNotFound, map[string]string{"type": configType}),
+		})
+	}
+	if uuid == "" {
+		formErrs = append(formErrs, config.ParamError{
+			Param:   "uuid",
+			Message: h.errorMessage(r, httperr.CodeMissingParameter, map[string]string{"param": "uuid"}),
+		})
+	} else if h.templateManager.RequiresUUID(configType) {
+		if err := utils.ValidateUUID(uuid); err != nil {
+			formErrs = append(formErrs, config.ParamError{
+				Param:    "uuid",
+				Value:    uuid,
+				Accepted: utils.UUIDFormat,
+				Message:  h.errorMessage(r, httperr.CodeInvalidUUID, map[string]string{"uuid": uuid, "format": utils.UUIDFormat}),
+			})
+		}
+	}
+	if _, paramErrs := config.ParseDynamicConfig(query, h.options.Defaults); len(paramErrs) > 0 {
+		h.localizeParamErrors(r, paramErrs)
+		formErrs = append(formErrs, paramErrs...)
+	}
+
+	if len(formErrs) > 0 {
+		h.logger.WithFields(logrus.Fields{
+			"config_type": configType,
+			"errors":      formErrs,
+			"remote_addr": r.RemoteAddr,
+		}).Warn("Rejected home page form")
+		h.renderHomePage(w, r, http.StatusBadRequest, r.PostForm, formErrs)
+		return
+	}
+
+	if language := r.URL.Query().Get("lang"); language != "" {
+		query.Set("lang", h.i18n.DetectLanguage(language))
+	}
+	target := configPagePath(configType, uuid, query)
+
+	h.logger.WithFields(logrus.Fields{
+		"config_type": configType,
+		"uuid":        utils.RedactSecret(uuid),
+		"remote_addr": r.RemoteAddr,
+	}).Info("Redirecting home page form to configuration page")
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// homeFormQuery converts the submitted form into config page query parameters the way the
+// page script does: empty fields are dropped, checkboxes become true and the Clash API
+// fields only apply when their checkbox is checked
+func homeFormQuery(form url.Values) url.Values {
+	query := make(url.Values)
+	for _, field := range homeFormFields {
+		value := strings.TrimSpace(form.Get(field))
+		if value == "" {
+			continue
+		}
+		query.Set(field, value)
+	}
+
+	if query.Get("variant") == config.DefaultVariant {
+		query.Del("variant")
+	}
+	if query.Get("block-ads") != "" {
+		query.Set("block-ads", "true")
+	}
+	if form.Get("clash-api") == "" {
+		query.Del("clash-api-port")
+		query.Del("clash-api-secret")
+	}
+	return query
+}
@@ -1,18 +1,43 @@
 package handlers
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/skip2/go-qrcode"
+	"gopkg.in/yaml.v3"
 
+	"vless-generator/internal/audit"
+	"vless-generator/internal/buildinfo"
 	"vless-generator/internal/config"
+	"vless-generator/internal/converter"
+	"vless-generator/internal/httperr"
 	"vless-generator/internal/i18n"
+	"vless-generator/internal/metrics"
+	"vless-generator/internal/middleware"
+	"vless-generator/internal/qr"
+	"vless-generator/internal/router"
+	"vless-generator/internal/shortlink"
 	"vless-generator/internal/templates"
+	"vless-generator/internal/tracing"
 	"vless-generator/internal/utils"
 )
 
@@ -21,29 +46,76 @@ type Handler struct {
 	templateManager  *templates.Manager
 	templateRenderer *templates.TemplateRenderer
 	i18n             *i18n.I18n
+	options          Options
 	logger           *logrus.Entry
+	maintenance      atomic.Pointer[maintenanceState] // Nil until maintenance mode is first set
+}
+
+// Options holds tunable handler limits
+type Options struct {
+	MaxBatchSize int                   // Maximum number of entries accepted by BatchHandler
+	PreviewBytes int                   // Maximum size of the JSON preview on config pages; 0 hides it
+	TrustProxy   bool                  // Build absolute URLs from X-Forwarded-Proto/X-Forwarded-Host
+	AuthTokens   []string              // Tokens accepted by RequireToken; empty leaves routes open
+	SigningKey   []byte                // HMAC key checked by RequireSignature; empty disables signed links
+	CacheMaxAge  time.Duration         // max-age of config downloads and subscriptions, revalidated by ETag
+	ShortLinks   shortlink.Store       // Store of /s/<id> links; nil disables short links
+	ShortLinkTTL time.Duration         // Default lifetime of short links; 0 keeps them forever
+	ProfileQR    bool                  // Show a second QR code with the sing-box profile link on config pages
+	StrictRender bool                  // Render pages into a buffer first, so render failures never send a partial page
+	QRPool       *qr.Pool              // Bounds concurrent QR encodes; nil encodes without limits
+	Audit        *audit.Recorder       // Records generated configs; nil disables auditing
+	AuditStore   audit.Store           // Store queried by GET /admin/configs; nil disables the route
+	Defaults     *config.DynamicConfig // Deployment defaults for absent dynamic parameters
+
+	TrustedProxies []netip.Prefix            // Proxies whose forwarded headers give the client IP of audit records
+	Cache          *middleware.ResponseCache // Purged whenever templates or translations change; nil when response caching is off
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(templateManager *templates.Manager, templateRenderer *templates.TemplateRenderer, i18nManager *i18n.I18n) *Handler {
+func NewHandler(templateManager *templates.Manager, templateRenderer *templates.TemplateRenderer, i18nManager *i18n.I18n, options Options) *Handler {
+	if options.Defaults == nil {
+		options.Defaults = config.DefaultDynamicConfig()
+	}
 	return &Handler{
 		templateManager:  templateManager,
 		templateRenderer: templateRenderer,
 		i18n:             i18nManager,
+		options:          options,
 		logger:           logrus.WithField("component", "handlers"),
 	}
 }
 
-// HomePageHandler handles the main page with configuration form
+// jsonPathPrefixes are the route prefixes whose unknown paths answer with JSON rather than a page
+var jsonPathPrefixes = []string{"/api/", "/config/", "/sub/", "/qrcode/", "/bundle/"}
+
+// NotFoundHandler answers requests matching no route; unknown API and download paths still get JSON
+func (h *Handler) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	for _, prefix := range jsonPathPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			h.writeError(w, r, http.StatusNotFound, httperr.CodeNotFound, "", nil)
+			return
+		}
+	}
+	h.renderErrorPage(w, r, http.StatusNotFound, h.errorMessage(r, httperr.CodeNotFound, nil))
+}
+
+// MethodNotAllowedHandler answers requests for a routed path with a method it does not
+// accept; the router has already set the Allow header
+func (h *Handler) MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeError(w, r, http.StatusMethodNotAllowed, httperr.CodeMethodNotAllowed, "", nil)
+}
+
+// HomePageHandler handles the main page with configuration form (GET and POST /)
 func (h *Handler) HomePageHandler(w http.ResponseWriter, r *http.Request) {
-	// Only handle root path
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
+	// The form posts back here when JavaScript is unavailable
+	if r.Method == http.MethodPost {
+		h.handleHomeForm(w, r)
 		return
 	}
 
 	// Detect language from query parameter
-	language := i18n.DetectLanguage(r.URL.Query().Get("lang"))
+	language := h.i18n.DetectLanguage(r.URL.Query().Get("lang"))
 
 	h.logger.WithFields(logrus.Fields{
 		"method":      r.Method,
@@ -51,57 +123,121 @@ func (h *Handler) HomePageHandler(w http.ResponseWriter, r *http.Request) {
 		"remote_addr": r.RemoteAddr,
 	}).Info("Serving home page with configuration form")
 
-	// Get texts for the detected language
-	texts := h.i18n.GetTexts(language)
+	h.renderHomePage(w, r, http.StatusOK, nil, nil)
+}
 
-	// Prepare template data
-	data := templates.HomePageData{
+// renderHomePage renders the home page; a rejected form submission passes its values and errors
+func (h *Handler) renderHomePage(w http.ResponseWriter, r *http.Request, status int, submitted url.Values, formErrs []config.ParamError) {
+	language := h.i18n.DetectLanguage(r.URL.Query().Get("lang"))
+	data := h.homePageData(r.URL, language)
+	data.Submitted = submitted
+	data.Errors = formErrs
+
+	rendered := h.writePage(w, r, status, "HomePage", func(out io.Writer) error {
+		return h.templateRenderer.RenderHomePage(out, data)
+	})
+	if !rendered {
+		h.renderErrorPage(w, r, http.StatusInternalServerError, h.errorMessage(r, httperr.CodeInternal, nil))
+	}
+}
+
+// homePageData prepares the home page template data for a language; u is the page URL
+// its language links are built from
+func (h *Handler) homePageData(u *url.URL, language string) templates.HomePageData {
+	texts := h.i18n.GetTexts(language)
+	return templates.HomePageData{
 		Title:         texts["title"],
 		Language:      language,
+		Direction:     h.i18n.Direction(language),
 		Texts:         texts,
-		DefaultConfig: config.DefaultDynamicConfig(),
+		Languages:     h.i18n.LanguageLinks(u),
+		DefaultConfig: h.options.Defaults,
+		TemplateTypes: h.templateManager.Describe(h.options.Defaults),
+		UUIDEndpoint:  uuidEndpoint,
+		NewUUIDPath:   newUUIDSegment,
 	}
+}
 
-	// Render template
-	htmlContent, err := h.templateRenderer.RenderHomePage(data)
+// Server-side UUID generation paths
+const (
+	uuidEndpoint   = "/api/v1/uuid"
+	newUUIDSegment = "new"
+)
+
+// UUIDHandler returns a random UUID as JSON: {"uuid": "..."}
+func (h *Handler) UUIDHandler(w http.ResponseWriter, r *http.Request) {
+	uuid, err := utils.NewUUID()
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to render home page template")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.logger.WithError(err).Error("Failed to generate UUID")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(map[string]string{"uuid": uuid}); err != nil {
+		h.logger.WithError(err).Error("Failed to encode UUID response")
+	}
+}
 
-	if _, err := fmt.Fprint(w, htmlContent); err != nil {
-		h.logger.WithError(err).Error("Failed to write home page response")
+// redirectToNewUUID redirects to the canonical config page for a freshly generated UUID, keeping the query
+func (h *Handler) redirectToNewUUID(w http.ResponseWriter, r *http.Request, configType string) {
+	uuid, err := utils.NewUUID()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to generate UUID")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+		return
 	}
+
+	target := url.URL{Path: "/" + configType + "/" + uuid, RawQuery: r.URL.RawQuery}
+
+	h.logger.WithFields(logrus.Fields{
+		"config_type": configType,
+		"uuid":        utils.RedactSecret(uuid),
+		"remote_addr": r.RemoteAddr,
+	}).Info("Redirecting to configuration page with generated UUID")
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
 }
 
-// ConfigPageHandler handles requests for configuration pages with QR codes
+// ConfigPageHandler handles requests for configuration pages with QR codes: /<type>/<uuid>
 func (h *Handler) ConfigPageHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse URL path: /<type>/<uuid>
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
-		h.logger.WithFields(logrus.Fields{
-			"path":        r.URL.Path,
-			"remote_addr": r.RemoteAddr,
-		}).Warn("Invalid request path format")
-		http.NotFound(w, r)
+	configType := router.Param(r, "type")
+	uuid := router.Param(r, "uuid")
+	// The route matches any two-segment path, so only loaded types are config pages
+	if !h.templateManager.HasTemplate(configType) {
+		h.NotFoundHandler(w, r)
 		return
 	}
 
-	configType := parts[0]
-	uuid := parts[1]
+	// /<type>/new and /<type>/random redirect to a freshly generated UUID so the page is shareable
+	if (uuid == newUUIDSegment || uuid == "random") && h.templateManager.RequiresUUID(configType) {
+		h.redirectToNewUUID(w, r, configType)
+		return
+	}
+
+	h.serveConfigPage(w, r, configType, uuid)
+}
+
+// serveConfigPage renders the config page of a credential with the request's query parameters
+func (h *Handler) serveConfigPage(w http.ResponseWriter, r *http.Request, configType, uuid string) {
+	if !h.checkUUID(w, r, r.URL.Query(), configType, uuid) {
+		return
+	}
 
 	// Detect language from query parameter
-	language := i18n.DetectLanguage(r.URL.Query().Get("lang"))
+	language := h.i18n.DetectLanguage(r.URL.Query().Get("lang"))
 
 	// Parse dynamic configuration from query parameters
-	dynamicCfg := config.ParseDynamicConfig(r.URL.Query())
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(r.URL.Query(), h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.renderParamErrors(w, r, language, configType, uuid, paramErrs)
+		return
+	}
 
 	h.logger.WithFields(logrus.Fields{
 		"config_type": configType,
-		"uuid":        uuid,
+		"uuid":        utils.RedactSecret(uuid),
 		"language":    language,
 		"server":      dynamicCfg.Server,
 		"server_port": dynamicCfg.ServerPort,
@@ -109,239 +245,1198 @@ func (h *Handler) ConfigPageHandler(w http.ResponseWriter, r *http.Request) {
 		"remote_addr": r.RemoteAddr,
 	}).Info("Generating configuration page with dynamic parameters")
 
-	// Generate configuration with dynamic parameters
-	template, err := h.templateManager.GenerateConfig(configType, uuid, dynamicCfg)
-	if err != nil {
-		h.logger.WithError(err).WithFields(logrus.Fields{
-			"config_type": configType,
-			"uuid":        uuid,
-		}).Warn("Invalid configuration type or generation failed")
-		http.NotFound(w, r)
-		return
-	}
-
-	// Generate VLESS URL for QR code
-	vlessURL, err := utils.GenerateVlessURL(template, uuid)
-	if err != nil {
-		h.logger.WithError(err).WithFields(logrus.Fields{
-			"config_type": configType,
-			"uuid":        uuid,
-		}).Error("Failed to generate VLESS URL")
-		http.Error(w, "Failed to generate configuration URL", http.StatusInternalServerError)
-		return
-	}
-
-	// Generate QR code
-	qr, err := qrcode.Encode(vlessURL, qrcode.Medium, 256)
-	if err != nil {
-		h.logger.WithError(err).WithFields(logrus.Fields{
-			"config_type": configType,
-			"uuid":        uuid,
-		}).Error("Failed to generate QR code")
-		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+	// Generate configuration and share URL with dynamic parameters
+	cfg, vlessURL, ok := h.generateShareConfig(w, r, configType, uuid, dynamicCfg)
+	if !ok {
 		return
 	}
+	configJSON, truncated := h.configPreview(cfg)
+	singBoxImport, v2rayNGImport := h.importLinks(r, configType, uuid, cfg, dynamicCfg)
 
 	// Get texts for the detected language
 	texts := h.i18n.GetTexts(language)
 
-	// Prepare query string for download links
-	queryString := r.URL.RawQuery
+	// Prepare query string for download and QR code links; html/template still
+	// normalizes it, but must not escape it as a single query value
+	queryString := htmltemplate.URL(r.URL.RawQuery)
 
 	// Prepare template data
 	data := templates.ConfigPageData{
 		Title:          texts["title"],
 		Language:       language,
+		Direction:      h.i18n.Direction(language),
 		Texts:          texts,
+		Languages:      h.i18n.LanguageLinks(r.URL),
 		ConfigType:     strings.ToUpper(configType),
 		ConfigTypeOrig: configType, // Keep original lowercase for URLs
 		UUID:           uuid,
-		QRCode:         utils.EncodeBase64(qr),
 		VlessURL:       vlessURL,
 		QueryString:    queryString,
+		BaseURL:        utils.BaseURL(r, h.options.TrustProxy),
+		Remark:         dynamicCfg.Remark(configType),
+		MuxEnabled:     dynamicCfg.Mux,
+		ConfigJSON:     configJSON,
+		JSONTruncated:  truncated,
+		SingBoxImport:  htmltemplate.URL(singBoxImport), // Custom schemes would otherwise be replaced by #ZgotmplZ
+		V2RayNGImport:  htmltemplate.URL(v2rayNGImport),
+		ProfileQRCode:  htmltemplate.URL(h.profileQRCode(r.Context(), singBoxImport)),
 	}
 
-	// Render template
-	htmlContent, err := h.templateRenderer.RenderConfigPage(data)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to render config page template")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	rendered := h.writePage(w, r, http.StatusOK, "ConfigPage", func(out io.Writer) error {
+		return h.templateRenderer.RenderConfigPage(out, data)
+	})
+	if !rendered {
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+	}
+}
+
+// ConfigDownloadHandler handles JSON and YAML configuration file downloads
+func (h *Handler) ConfigDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	// Routed as /config/<type>/<uuid>.json and /config/<type>/<uuid>.yaml
+	h.serveConfigDownload(w, r, router.Param(r, "type"), router.Param(r, "uuid"), path.Ext(r.URL.Path))
+}
+
+// serveConfigDownload writes the config of a credential as a .json or .yaml download
+func (h *Handler) serveConfigDownload(w http.ResponseWriter, r *http.Request, configType, uuid, extension string) {
+	if !h.checkUUID(w, r, r.URL.Query(), configType, uuid) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/html")
+	pretty, ok := h.prettyParam(w, r, true)
+	if !ok {
+		return
+	}
 
-	if _, err := fmt.Fprint(w, htmlContent); err != nil {
-		h.logger.WithError(err).Error("Failed to write HTML response")
+	// An explicit filename replaces the one built from the remark or server
+	filename := r.URL.Query().Get("filename")
+	if len(filename) > utils.MaxFilenameLength {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "filename", map[string]string{"error": fmt.Sprintf("filename must be at most %d bytes", utils.MaxFilenameLength)})
+		return
 	}
-}
 
-// ConfigDownloadHandler handles JSON configuration file downloads
-func (h *Handler) ConfigDownloadHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse URL path: /config/<type>/<uuid>.json
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) != 3 || parts[0] != "config" || parts[1] == "" || !strings.HasSuffix(parts[2], ".json") {
-		h.logger.WithFields(logrus.Fields{
-			"path":        r.URL.Path,
+	// Without a format parameter the Accept header may choose the format and encoding
+	requestedFormat := r.URL.Query().Get("format")
+	contentType := ""
+	w.Header().Add("Vary", "Accept")
+	if requestedFormat == "" {
+		media, ok := negotiateDownload(r.Header.Get("Accept"))
+		if !ok {
+			h.logger.WithFields(logrus.Fields{
+				"accept":      r.Header.Get("Accept"),
+				"remote_addr": r.RemoteAddr,
+			}).Warn("No acceptable config download media type")
+			h.writeError(w, r, http.StatusNotAcceptable, httperr.CodeNotAcceptable, "", map[string]string{"types": strings.Join(downloadMediaTypeNames(), ", ")})
+			return
+		}
+		if media != nil {
+			requestedFormat, extension, contentType = media.format, media.extension, media.mediaType
+		}
+	}
+
+	// Resolve output format from the format parameter and file extension
+	format, err := resolveDownloadFormat(requestedFormat, extension)
+	if err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"path":        utils.RedactPath(r.URL.Path),
 			"remote_addr": r.RemoteAddr,
-		}).Warn("Invalid config download path format")
-		http.NotFound(w, r)
+		}).Warn("Unsupported config download format")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeUnsupportedFormat, "format", map[string]string{"format": r.URL.Query().Get("format"), "target": extension})
 		return
 	}
 
-	configType := parts[1]
-	uuid := strings.TrimSuffix(parts[2], ".json")
-
 	// Parse dynamic configuration from query parameters
-	dynamicCfg := config.ParseDynamicConfig(r.URL.Query())
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(r.URL.Query(), h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.writeParamErrors(w, r, paramErrs)
+		return
+	}
 
 	h.logger.WithFields(logrus.Fields{
 		"config_type": configType,
-		"uuid":        uuid,
+		"uuid":        utils.RedactSecret(uuid),
+		"format":      format,
 		"server":      dynamicCfg.Server,
 		"server_port": dynamicCfg.ServerPort,
 		"remote_addr": r.RemoteAddr,
 	}).Info("Generating configuration file download with dynamic parameters")
 
 	// Generate configuration with dynamic parameters
-	cfg, err := h.templateManager.GenerateConfig(configType, uuid, dynamicCfg)
+	cfg, err := h.generate(r, configType, uuid, dynamicCfg)
+	if err != nil {
+		h.handleGenerateError(w, r, err, configType, uuid)
+		return
+	}
+
+	// Convert to the requested client schema
+	var output interface{}
+	switch format {
+	case formatXray:
+		output, err = converter.ToXray(cfg, dynamicCfg)
+	case formatClash:
+		output, err = converter.ToClash(cfg, dynamicCfg)
+	default:
+		output = cfg
+	}
+	if errors.Is(err, converter.ErrUnsupportedOutbound) {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"config_type": configType,
+			"format":      format,
+		}).Warn("Configuration type not supported by requested format")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeUnsupportedFormat, "format", map[string]string{"format": format, "target": configType})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).WithFields(logrus.Fields{
 			"config_type": configType,
-			"uuid":        uuid,
-		}).Warn("Invalid configuration type or generation failed")
-		http.NotFound(w, r)
+			"uuid":        utils.RedactSecret(uuid),
+			"format":      format,
+		}).Error("Failed to convert configuration")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
 		return
 	}
 
+	switch {
+	case filename == "":
+		filename = downloadFilename(dynamicCfg, configType, format, extension)
+	case !strings.EqualFold(path.Ext(filename), extension):
+		filename += extension
+	}
+
 	// Set response headers
+	w.Header().Set("Content-Disposition", utils.ContentDisposition(filename))
+
+	// Encode the configuration up front so its ETag can be checked before sending it
+	var body bytes.Buffer
+	if contentType == "" {
+		contentType = "application/json"
+		if extension == ".yaml" {
+			contentType = "application/yaml"
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	if extension == ".yaml" {
+		encoder := yaml.NewEncoder(&body)
+		encoder.SetIndent(2)
+		err = encoder.Encode(output)
+		if err == nil {
+			err = encoder.Close()
+		}
+	} else {
+		err = writeJSON(&body, output, pretty)
+	}
+	if err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"config_type": configType,
+			"uuid":        utils.RedactSecret(uuid),
+			"format":      format,
+		}).Error("Failed to encode configuration")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+		return
+	}
+
+	// Only configs actually sent are audited, not failed conversions or 304 revalidations
+	sent, err := h.writeCacheable(w, r, body.Bytes())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to write configuration response")
+	}
+	if sent {
+		h.recordGenerated(r, configType, uuid, dynamicCfg.Server)
+	}
+}
+
+// Supported configuration download formats
+const (
+	formatSingBox = "sing-box"
+	formatXray    = "xray"
+	formatClash   = "clash"
+)
+
+// prettyParam reads the pretty query parameter, writing a 400 response for values other than true or false
+func (h *Handler) prettyParam(w http.ResponseWriter, r *http.Request, defaultValue bool) (bool, bool) {
+	value := r.URL.Query().Get("pretty")
+	if value == "" {
+		return defaultValue, true
+	}
+	pretty, err := strconv.ParseBool(value)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "pretty", map[string]string{"error": fmt.Sprintf("pretty must be true or false, got %q", value)})
+		return false, false
+	}
+	return pretty, true
+}
+
+// writeJSON encodes a value without HTML escaping, so URLs keep their "&", indented when pretty is set
+func writeJSON(w io.Writer, value interface{}, pretty bool) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(value)
+}
+
+// pageURL returns the absolute URL of the config page of a credential with the given parameters
+func (h *Handler) pageURL(r *http.Request, configType, uuid string, query url.Values) string {
+	return utils.AbsoluteURL(r, h.options.TrustProxy, configPagePath(configType, uuid, query))
+}
+
+// configPagePath returns the path and query of a config page; the credential is escaped as
+// one path segment since Shadowsocks keys may contain "/"
+func configPagePath(configType, uuid string, query url.Values) string {
+	page := url.URL{
+		Path:     "/" + configType + "/" + uuid,
+		RawPath:  "/" + configType + "/" + url.PathEscape(uuid),
+		RawQuery: query.Encode(),
+	}
+	return page.String()
+}
+
+// downloadURL returns the absolute URL of the JSON download of a credential with the given parameters
+func (h *Handler) downloadURL(r *http.Request, configType, uuid string, query url.Values) string {
+	download := url.URL{
+		Path:     "/config/" + configType + "/" + uuid + ".json",
+		RawPath:  "/config/" + configType + "/" + url.PathEscape(uuid) + ".json",
+		RawQuery: query.Encode(),
+	}
+	return utils.AbsoluteURL(r, h.options.TrustProxy, download.String())
+}
+
+// importLinks returns one-tap import deep links for mobile clients: sing-box imports the
+// JSON download as a remote profile, v2rayNG the same download converted for Xray. The
+// v2rayNG link is empty when the config cannot be converted.
+func (h *Handler) importLinks(r *http.Request, configType, uuid string, cfg map[string]interface{}, dynamicCfg *config.DynamicConfig) (singBox, v2rayNG string) {
+	query := r.URL.Query()
+	query.Del("format")
+	name := url.PathEscape(dynamicCfg.Remark(configType))
+	singBox = "sing-box://import-remote-profile?url=" + url.QueryEscape(h.downloadURL(r, configType, uuid, query)) + "#" + name
+
+	if _, err := converter.ToXray(cfg, dynamicCfg); err != nil {
+		return singBox, ""
+	}
+	query.Set("format", formatXray)
+	v2rayNG = "v2rayng://install-config?url=" + url.QueryEscape(h.downloadURL(r, configType, uuid, query)) + "#" + name
+	return singBox, v2rayNG
+}
+
+// profileQRCode returns a PNG data URL of a QR code encoding the sing-box import link, so
+// iOS users can scan the profile rather than the share URL. It is empty when profile QR
+// codes are disabled or the link does not fit in a QR code.
+func (h *Handler) profileQRCode(ctx context.Context, importLink string) string {
+	if !h.options.ProfileQR {
+		return ""
+	}
+	png, err := h.encodeQRCode(ctx, importLink, qr.DefaultSize, qrcode.Medium)
+	if err != nil {
+		h.logger.WithError(err).Debug("Skipping profile QR code")
+		return ""
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+}
+
+// configPreview returns the indented configuration for the config page, cut at the last
+// line that fits in Options.PreviewBytes; truncated reports whether lines were dropped
+func (h *Handler) configPreview(cfg map[string]interface{}) (preview string, truncated bool) {
+	if h.options.PreviewBytes <= 0 {
+		return "", false
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, cfg, true); err != nil {
+		h.logger.WithError(err).Error("Failed to encode configuration preview")
+		return "", false
+	}
+	if buf.Len() <= h.options.PreviewBytes {
+		return buf.String(), false
+	}
+	data := buf.Bytes()[:h.options.PreviewBytes]
+	if cut := bytes.LastIndexByte(data, '\n'); cut >= 0 {
+		data = data[:cut+1]
+	}
+	return string(data), true
+}
+
+// downloadFilename names a config download after its remark, or its server when it has none:
+// <remark>-<type>[-<format>]-config<extension>. Long remarks are cut to keep the name within
+// utils.MaxFilenameLength.
+func downloadFilename(dynamicCfg *config.DynamicConfig, configType, format, extension string) string {
+	base := dynamicCfg.Name
+	if base == "" {
+		base = dynamicCfg.Server
+	}
+	suffix := "-" + configType
+	if format != formatSingBox {
+		suffix += "-" + format
+	}
+	suffix += "-config" + extension
+
+	if limit := utils.MaxFilenameLength - len(suffix); len(base) > limit {
+		base = strings.ToValidUTF8(base[:limit], "")
+	}
+	return base + suffix
+}
+
+// resolveDownloadFormat validates the requested format against the file extension
+func resolveDownloadFormat(format, extension string) (string, error) {
+	if extension == ".yaml" {
+		switch format {
+		case "", formatClash:
+			return formatClash, nil
+		case formatSingBox, "singbox":
+			return formatSingBox, nil
+		default:
+			return "", fmt.Errorf("unsupported format %q for .yaml downloads: supported formats are clash, sing-box", format)
+		}
+	}
+
+	switch format {
+	case "", formatSingBox, "singbox":
+		return formatSingBox, nil
+	case formatXray:
+		return formatXray, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q for .json downloads: supported formats are sing-box, xray", format)
+	}
+}
+
+// downloadMedia is a media type config downloads can be negotiated to with the Accept header
+type downloadMedia struct {
+	mediaType string
+	format    string
+	extension string // Encoding: .json or .yaml
+}
+
+// downloadMediaTypes lists the negotiable media types; YAML carries the same structure as JSON
+var downloadMediaTypes = []downloadMedia{
+	{"application/json", formatSingBox, ".json"},
+	{"application/yaml", formatSingBox, ".yaml"},
+	{"text/yaml", formatSingBox, ".yaml"},
+	{"application/x-clash", formatClash, ".yaml"},
+}
+
+// downloadMediaTypeNames returns the negotiable media types, for 406 responses
+func downloadMediaTypeNames() []string {
+	names := make([]string, len(downloadMediaTypes))
+	for i, media := range downloadMediaTypes {
+		names[i] = media.mediaType
+	}
+	return names
+}
+
+// negotiateDownload picks the supported media type with the highest quality in an Accept header.
+// It returns nil when the header is absent or a wildcard wins, leaving the choice to the file
+// extension, and false when nothing in the header can be served.
+func negotiateDownload(accept string) (*downloadMedia, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return nil, true
+	}
+
+	var best *downloadMedia
+	bestQuality, acceptable := 0.0, false
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(item)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		// Ties go to the earlier entry, except that a media type beats a wildcard
+		wildcard := mediaType == "*/*" || mediaType == "application/*"
+		if quality <= 0 || (acceptable && (quality < bestQuality || (quality == bestQuality && (wildcard || best != nil)))) {
+			continue
+		}
+		if wildcard {
+			best, bestQuality, acceptable = nil, quality, true
+			continue
+		}
+		for i := range downloadMediaTypes {
+			if downloadMediaTypes[i].mediaType == mediaType {
+				best, bestQuality, acceptable = &downloadMediaTypes[i], quality, true
+				break
+			}
+		}
+	}
+	return best, acceptable
+}
+
+// configRequest is the JSON body accepted by ConfigAPIHandler
+type configRequest struct {
+	Type   string                 `json:"type"`
+	UUID   string                 `json:"uuid"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// configResponse is the JSON body returned by ConfigAPIHandler
+type configResponse struct {
+	Config          map[string]interface{} `json:"config"`
+	URL             string                 `json:"url"`
+	PageURL         string                 `json:"page_url"` // Absolute URL of the config page with the same parameters
+	QRCodePNGBase64 string                 `json:"qrcode_png_base64,omitempty"`
+	Warnings        []string               `json:"warnings,omitempty"`
+}
+
+// ConfigAPIHandler generates a config, share URL and QR code from a JSON body of parameters
+func (h *Handler) ConfigAPIHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	var req configRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber() // Keep params and patch integers exact
+	if err := decoder.Decode(&req); err != nil {
+		h.logger.WithError(err).Warn("Failed to decode config API request body")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Type == "" || req.UUID == "" {
+		field := "type"
+		if req.Type != "" {
+			field = "uuid"
+		}
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, field, map[string]string{"param": field})
+		return
+	}
+
+	// Map JSON params onto query values so both paths share ParseDynamicConfig
+	query, warnings := config.ParamsToValues(req.Params)
+	if !h.checkUUID(w, r, query, req.Type, req.UUID) {
+		return
+	}
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(query, h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.writeParamErrors(w, r, paramErrs)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"config_type": req.Type,
+		"uuid":        utils.RedactSecret(req.UUID),
+		"server":      dynamicCfg.Server,
+		"warnings":    len(warnings),
+		"remote_addr": r.RemoteAddr,
+	}).Info("Generating configuration from API request")
+
+	cfg, shareURL, ok := h.generateShareConfig(w, r, req.Type, req.UUID, dynamicCfg)
+	if !ok {
+		return
+	}
+
+	pretty, ok := h.prettyParam(w, r, false)
+	if !ok {
+		return
+	}
+
+	response := configResponse{
+		Config:   cfg,
+		URL:      shareURL,
+		PageURL:  h.pageURL(r, req.Type, req.UUID, query),
+		Warnings: warnings,
+	}
+	qrPNG, err := h.encodeQRCode(r.Context(), shareURL, qr.DefaultSize, qrcode.Medium)
+	if err != nil {
+		response.Warnings = append(response.Warnings, "QR code omitted: "+err.Error())
+	} else {
+		response.QRCodePNGBase64 = utils.EncodeBase64(qrPNG)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-config.json", configType))
+	if err := writeJSON(w, response, pretty); err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"config_type": req.Type,
+			"uuid":        utils.RedactSecret(req.UUID),
+		}).Error("Failed to encode config API response")
+	}
+}
+
+// importRequest is the JSON body accepted by ImportHandler
+type importRequest struct {
+	URL string `json:"url"`
+}
+
+// ImportHandler converts a vless:// share URL into the full sing-box configuration
+func (h *Handler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	// Accept the URL as a JSON body ({"url": "..."}) or a form field
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var shareURL string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req importRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.logger.WithError(err).Warn("Failed to decode import request body")
+			h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+			return
+		}
+		shareURL = req.URL
+	} else {
+		shareURL = r.FormValue("url")
+	}
+	if shareURL == "" {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, "url", map[string]string{"param": "url"})
+		return
+	}
+
+	dynamicCfg, configType, uuid, err := utils.ParseVlessURL(shareURL, h.options.Defaults)
+	if err != nil {
+		h.logger.WithError(err).WithField("remote_addr", r.RemoteAddr).Warn("Rejected vless URL import")
+		status := http.StatusBadRequest
+		if errors.Is(err, utils.ErrInconsistentShareURL) {
+			status = http.StatusUnprocessableEntity
+		}
+		h.writeError(w, r, status, httperr.CodeInvalidShareURL, "url", map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"config_type": configType,
+		"uuid":        utils.RedactSecret(uuid),
+		"server":      dynamicCfg.Server,
+		"transport":   dynamicCfg.Transport,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Importing configuration from vless URL")
+
+	cfg, err := h.generate(r, configType, uuid, dynamicCfg)
+	if err != nil {
+		h.handleGenerateError(w, r, err, configType, uuid)
+		return
+	}
+
+	pretty, ok := h.prettyParam(w, r, false)
+	if !ok {
+		return
+	}
 
-	// Encode and send JSON
-	if err := json.NewEncoder(w).Encode(cfg); err != nil {
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeJSON(w, cfg, pretty); err != nil {
 		h.logger.WithError(err).WithFields(logrus.Fields{
 			"config_type": configType,
-			"uuid":        uuid,
-		}).Error("Failed to encode configuration JSON")
-		http.Error(w, "Failed to encode configuration", http.StatusInternalServerError)
+			"uuid":        utils.RedactSecret(uuid),
+		}).Error("Failed to encode imported configuration")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
 		return
 	}
+	h.recordGenerated(r, configType, uuid, dynamicCfg.Server)
+}
+
+// TemplatesHandler returns metadata for the available template types as JSON
+func (h *Handler) TemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.templateManager.Describe(h.options.Defaults)); err != nil {
+		h.logger.WithError(err).Error("Failed to encode templates response")
+	}
+}
+
+// defaultsResponse is the JSON body returned by DefaultsHandler
+type defaultsResponse struct {
+	Defaults  *config.DynamicConfig `json:"defaults"`
+	Languages []string              `json:"languages"`
+	Templates []string              `json:"templates"`
+}
+
+// DefaultsHandler returns the default dynamic parameters, languages and template types as JSON
+func (h *Handler) DefaultsHandler(w http.ResponseWriter, r *http.Request) {
+	response := defaultsResponse{
+		Defaults:  h.options.Defaults,
+		Languages: h.i18n.GetSupportedLanguages(),
+		Templates: h.templateManager.GetTemplateTypes(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.WithError(err).Error("Failed to encode defaults response")
+	}
+}
+
+// reloadFileResult is the outcome of reloading one file
+type reloadFileResult struct {
+	File  string `json:"file"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// ReloadReport summarizes a reload of templates and translations
+type ReloadReport struct {
+	OK           bool               `json:"ok"`
+	Templates    []reloadFileResult `json:"templates"`
+	Translations []reloadFileResult `json:"translations"`
+}
+
+// Reload re-reads templates and translations; files that fail keep serving their previous version
+func (h *Handler) Reload() ReloadReport {
+	report := ReloadReport{
+		Templates:    reloadResults(h.templateManager.Reload()),
+		Translations: reloadResults(h.i18n.Reload()),
+	}
+	h.options.Cache.Purge()
+	report.OK = true
+	for _, result := range append(report.Templates, report.Translations...) {
+		report.OK = report.OK && result.OK
+	}
+
+	logEntry := h.logger.WithFields(logrus.Fields{
+		"templates":    len(report.Templates),
+		"translations": len(report.Translations),
+	})
+	if report.OK {
+		logEntry.Info("Templates and translations reloaded")
+	} else {
+		logEntry.Error("Reload finished with errors, failed files keep their previous version")
+	}
+	return report
+}
+
+// reloadResults converts per-file reload errors into results sorted by file name
+func reloadResults(errs map[string]error) []reloadFileResult {
+	results := make([]reloadFileResult, 0, len(errs))
+	for file, err := range errs {
+		result := reloadFileResult{File: file, OK: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].File < results[j].File
+	})
+	return results
+}
+
+// ReloadHandler reloads templates and translations and reports per-file results (POST /admin/reload)
+func (h *Handler) ReloadHandler(w http.ResponseWriter, r *http.Request) {
+	h.logger.WithField("remote_addr", r.RemoteAddr).Info("Reload requested via admin endpoint")
+	report := h.Reload()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.WithError(err).Error("Failed to encode reload response")
+	}
 }
 
 // HealthHandler provides health check endpoint
-func (h *Handler) HealthHandler(w http.ResponseWriter, _ *http.Request) {
+func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"service":   "vless-generator",
-		"version":   "1.0.0",
-		"templates": h.templateManager.GetTemplateTypes(),
+		"status":            "healthy",
+		"timestamp":         time.Now().UTC().Format(time.RFC3339),
+		"service":           "vless-generator",
+		"version":           buildinfo.Version,
+		"commit":            buildinfo.Commit,
+		"build_date":        buildinfo.BuildDate,
+		"go_version":        buildinfo.GoVersion(),
+		"started_at":        buildinfo.StartTime.UTC().Format(time.RFC3339),
+		"uptime_seconds":    int64(buildinfo.Uptime().Seconds()),
+		"templates":         h.templateManager.GetTemplateTypes(),
+		"languages":         h.i18n.GetSupportedLanguages(),
+		"requests":          metrics.RequestCounts(),
+		"configs_generated": metrics.ConfigsGenerated(),
+		"maintenance":       h.maintenanceStatus(),
+		"components": map[string]componentStatus{
+			"templates":    h.templatesStatus(),
+			"translations": h.translationsStatus(),
+		},
+	}
+	// Remote template fetch failures leave the service running on cached templates
+	if fetchErrors := h.templateManager.FetchErrors(); len(fetchErrors) > 0 {
+		response["status"] = "degraded"
+		response["template_fetch_errors"] = fetchErrors
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		h.logger.WithError(err).Error("Failed to encode health response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
 		return
 	}
 
 	h.logger.Debug("Health check completed successfully")
 }
 
-// QRCodeHandler generates QR code for VLESS URL
+// maxQRFormBytes limits the multipart body accepted by QRCodeHandler
+const maxQRFormBytes = 64 << 10
+
+// QRCodeHandler generates QR code for a share URL (GET /qrcode?url=... or multipart POST)
 func (h *Handler) QRCodeHandler(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST requests
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if r.Method == http.MethodPost {
+		// Parse multipart form data; the body is capped since a share URL fits in a few KiB
+		r.Body = http.MaxBytesReader(w, r.Body, maxQRFormBytes)
+		if err := r.ParseMultipartForm(maxQRFormBytes); err != nil {
+			h.logger.WithError(err).Warn("Failed to parse multipart form data for QR code generation")
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				h.writeError(w, r, http.StatusRequestEntityTooLarge, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+				return
+			}
+			h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	// FormValue covers both the query string and multipart fields
+	vlessURL := r.FormValue("url")
+	if vlessURL == "" {
+		h.logger.WithField("method", r.Method).Error("URL parameter is empty or missing")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, "url", map[string]string{"param": "url"})
 		return
 	}
 
-	// Parse multipart form data
-	if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB max memory
-		h.logger.WithError(err).Error("Failed to parse multipart form data for QR code generation")
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+	h.logger.WithField("vless_url", utils.RedactShareURL(vlessURL)).Debug("Received VLESS URL for QR code generation")
+
+	// Validate that it's a supported share URL
+	if !utils.IsShareURL(vlessURL) {
+		h.logger.WithField("url", utils.RedactShareURL(vlessURL)).Warn("Invalid share URL format")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidShareURL, "url", map[string]string{"error": "unsupported scheme"})
+		return
+	}
+
+	h.writeQRCode(w, r, vlessURL, h.parseQRSize(r.FormValue("size")), h.parseQRLevel(r.FormValue("ecc")), "no-cache, no-store, must-revalidate")
+}
+
+// QRCodeConfigHandler streams the QR code PNG for a generated config: /qrcode/<type>/<uuid>.png
+func (h *Handler) QRCodeConfigHandler(w http.ResponseWriter, r *http.Request) {
+	configType := router.Param(r, "type")
+	uuid := router.Param(r, "uuid")
+	if !h.checkUUID(w, r, r.URL.Query(), configType, uuid) {
+		return
+	}
+
+	// Parse dynamic configuration from query parameters
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(r.URL.Query(), h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.writeParamErrors(w, r, paramErrs)
+		return
+	}
+
+	_, shareURL, ok := h.generateShareConfig(w, r, configType, uuid, dynamicCfg)
+	if !ok {
+		return
+	}
+
+	// The image only depends on the path and query string, so short-lived caching is safe
+	query := r.URL.Query()
+	h.writeQRCode(w, r, shareURL, h.parseQRSize(query.Get("size")), h.parseQRLevel(query.Get("ecc")), "public, max-age=300")
+}
+
+// writeQRCode encodes content as a PNG QR code and writes it with the given Cache-Control header.
+// The recovery level is stepped down when the content does not fit at the requested level.
+func (h *Handler) writeQRCode(w http.ResponseWriter, r *http.Request, content string, size int, level qrcode.RecoveryLevel, cacheControl string) {
+	qrPNG, err := h.encodeQRCode(r.Context(), content, size, level)
+	if err != nil {
+		h.writeQRError(w, r, err)
+		return
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", cacheControl)
+
+	// Send QR code as PNG
+	if _, err := w.Write(qrPNG); err != nil {
+		h.logger.WithError(err).Error("Failed to write QR code response")
 		return
 	}
 
-	// Debug: Log all form values
 	h.logger.WithFields(logrus.Fields{
-		"form_values": r.Form,
-		"post_form":   r.PostForm,
-		"multipart":   r.MultipartForm,
-	}).Debug("Received form data")
+		"url_length": len(content),
+		"size":       size,
+	}).Debug("QR code generated successfully")
+}
 
-	// Try multiple ways to get the URL parameter
-	var vlessURL string
+// qrRetryAfterSeconds is the Retry-After of responses rejected because the QR pool is busy
+const qrRetryAfterSeconds = 1
 
-	// Method 1: FormValue
-	vlessURL = r.FormValue("url")
-	h.logger.WithField("method1_formvalue", vlessURL).Debug("Trying FormValue")
+// writeQRError answers a request whose QR code could not be encoded
+func (h *Handler) writeQRError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, qr.ErrContentTooLong):
+		h.writeError(w, r, http.StatusRequestEntityTooLarge, httperr.CodeContentTooLong, "url", nil)
+	case errors.Is(err, qr.ErrBusy):
+		h.logger.WithField("remote_addr", r.RemoteAddr).Warn("QR code queue full, rejecting request")
+		w.Header().Set("Retry-After", strconv.Itoa(qrRetryAfterSeconds))
+		h.writeError(w, r, http.StatusServiceUnavailable, httperr.CodeOverloaded, "", nil)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// The client is gone or out of time; the response only shows up in the access log
+		h.logger.WithField("remote_addr", r.RemoteAddr).Debug("Request ended while waiting for a QR code worker")
+		h.writeError(w, r, http.StatusServiceUnavailable, httperr.CodeOverloaded, "", nil)
+	default:
+		h.logger.WithError(err).Error("Failed to generate QR code")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+	}
+}
 
-	// Method 2: PostFormValue
-	if vlessURL == "" {
-		vlessURL = r.PostFormValue("url")
-		h.logger.WithField("method2_postformvalue", vlessURL).Debug("Trying PostFormValue")
+// encodeQRCode renders content as a PNG QR code through the QR worker pool, stepping the
+// recovery level down when needed
+func (h *Handler) encodeQRCode(ctx context.Context, content string, size int, level qrcode.RecoveryLevel) ([]byte, error) {
+	fitted, ok := qr.FitLevel(len(content), level)
+	if !ok {
+		h.logger.WithField("url_length", len(content)).Warn("Share URL too long to encode as QR code")
+		return nil, qr.ErrContentTooLong
+	}
+	if fitted != level {
+		h.logger.WithFields(logrus.Fields{
+			"url_length":      len(content),
+			"requested_level": level,
+			"ecc_level":       fitted,
+		}).Debug("Lowered QR code error correction level to fit content")
 	}
+	_, span := tracing.Start(ctx, "qr.PNG")
+	span.SetInt("qr.size", int64(size))
+	png, err := h.options.QRPool.PNG(ctx, content, size, level)
+	span.SetError(err)
+	span.End()
+	switch {
+	case err == nil:
+		metrics.QRCodeRendered()
+	case errors.Is(err, qr.ErrBusy):
+		metrics.QRCodeRejected()
+	}
+	return png, err
+}
 
-	// Method 3: MultipartForm
-	if vlessURL == "" && r.MultipartForm != nil {
-		if values, ok := r.MultipartForm.Value["url"]; ok && len(values) > 0 {
-			vlessURL = values[0]
-			h.logger.WithField("method3_multipart", vlessURL).Debug("Trying MultipartForm")
-		}
+// generate runs Manager.GenerateConfig within a span of the request's trace
+func (h *Handler) generate(r *http.Request, configType, uuid string, dynamicCfg *config.DynamicConfig) (map[string]interface{}, error) {
+	_, span := tracing.Start(r.Context(), "templates.GenerateConfig")
+	span.SetString("config.type", configType)
+	cfg, err := h.templateManager.GenerateConfig(configType, uuid, dynamicCfg)
+	span.SetError(err)
+	span.End()
+	return cfg, err
+}
+
+// parseQRLevel parses the ecc parameter (L, M, Q or H), falling back to Medium
+func (h *Handler) parseQRLevel(value string) qrcode.RecoveryLevel {
+	if value == "" {
+		return qrcode.Medium
+	}
+	level, ok := qr.ParseLevel(value)
+	if !ok {
+		h.logger.WithField("ecc", value).Warn("Invalid QR code error correction level, using M")
+		return qrcode.Medium
 	}
+	return level
+}
 
-	if vlessURL == "" {
-		h.logger.Error("URL parameter is empty or missing after all attempts")
-		http.Error(w, "URL parameter is required", http.StatusBadRequest)
+// parseQRSize parses the size parameter, falling back to the default for missing or out-of-range values
+func (h *Handler) parseQRSize(value string) int {
+	if value == "" {
+		return qr.DefaultSize
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < qr.MinSize || size > qr.MaxSize {
+		h.logger.WithField("size", value).Warnf("Invalid QR code size, using %d (allowed %d-%d)", qr.DefaultSize, qr.MinSize, qr.MaxSize)
+		return qr.DefaultSize
+	}
+	return size
+}
+
+// BundleHandler streams a zip archive with the config JSON, QR code PNG and share URL: /bundle/<type>/<uuid>.zip
+func (h *Handler) BundleHandler(w http.ResponseWriter, r *http.Request) {
+	configType := router.Param(r, "type")
+	uuid := router.Param(r, "uuid")
+	if !h.checkUUID(w, r, r.URL.Query(), configType, uuid) {
 		return
 	}
 
-	h.logger.WithField("vless_url", vlessURL).Debug("Received VLESS URL for QR code generation")
+	// Parse dynamic configuration from query parameters
+	query := r.URL.Query()
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(query, h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.writeParamErrors(w, r, paramErrs)
+		return
+	}
 
-	// Validate that it's a VLESS URL
-	if !strings.HasPrefix(vlessURL, "vless://") {
-		h.logger.WithField("url", vlessURL).Warn("Invalid VLESS URL format")
-		http.Error(w, "Invalid VLESS URL", http.StatusBadRequest)
+	h.logger.WithFields(logrus.Fields{
+		"config_type": configType,
+		"uuid":        utils.RedactSecret(uuid),
+		"server":      dynamicCfg.Server,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Generating configuration bundle with dynamic parameters")
+
+	cfg, shareURL, ok := h.generateShareConfig(w, r, configType, uuid, dynamicCfg)
+	if !ok {
 		return
 	}
 
-	// Generate QR code
-	qr, err := qrcode.Encode(vlessURL, qrcode.Medium, 256)
+	// Render the QR code before streaming so failures can still be reported with a status code
+	qrPNG, err := h.encodeQRCode(r.Context(), shareURL, h.parseQRSize(query.Get("size")), h.parseQRLevel(query.Get("ecc")))
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to generate QR code")
-		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		h.writeQRError(w, r, err)
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", utils.ContentDisposition(configType+"-bundle.zip"))
 
-	// Send QR code as PNG
-	if _, err := w.Write(qr); err != nil {
-		h.logger.WithError(err).Error("Failed to write QR code response")
+	if err := writeBundle(w, cfg, qrPNG, shareURL); err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"config_type": configType,
+			"uuid":        utils.RedactSecret(uuid),
+		}).Error("Failed to write configuration bundle")
 		return
 	}
 
-	h.logger.WithField("url_length", len(vlessURL)).Debug("QR code generated successfully")
+	h.logger.WithFields(logrus.Fields{
+		"config_type": configType,
+		"uuid":        utils.RedactSecret(uuid),
+	}).Debug("Configuration bundle generated successfully")
 }
 
-// generateConfig creates a configuration with the specified UUID
-func (h *Handler) generateConfig(template map[string]interface{}, uuid string) map[string]interface{} {
-	// Deep copy the template
-	cfg := utils.DeepCopyMap(template)
+// writeBundle streams the bundle entries into a zip archive
+func writeBundle(w io.Writer, cfg map[string]interface{}, qr []byte, shareURL string) error {
+	archive := zip.NewWriter(w)
 
-	// Set UUID in the first outbound (proxy)
-	if outbounds, ok := cfg["outbounds"].([]interface{}); ok && len(outbounds) > 0 {
-		if outbound, ok := outbounds[0].(map[string]interface{}); ok {
-			if outbound["type"] == "vless" {
-				outbound["uuid"] = uuid
-				h.logger.WithField("uuid", uuid).Debug("UUID set in configuration")
-			}
+	configFile, err := archive.Create("config.json")
+	if err != nil {
+		return fmt.Errorf("failed to create config.json: %w", err)
+	}
+	if err := writeJSON(configFile, cfg, true); err != nil {
+		return fmt.Errorf("failed to write config.json: %w", err)
+	}
+
+	qrFile, err := archive.Create("qrcode.png")
+	if err != nil {
+		return fmt.Errorf("failed to create qrcode.png: %w", err)
+	}
+	if _, err := qrFile.Write(qr); err != nil {
+		return fmt.Errorf("failed to write qrcode.png: %w", err)
+	}
+
+	urlFile, err := archive.Create("url.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create url.txt: %w", err)
+	}
+	if _, err := io.WriteString(urlFile, shareURL+"\n"); err != nil {
+		return fmt.Errorf("failed to write url.txt: %w", err)
+	}
+
+	return archive.Close()
+}
+
+// generateShareConfig generates the configuration and its share URL, writing an
+// error response and returning false when either step fails
+func (h *Handler) generateShareConfig(w http.ResponseWriter, r *http.Request, configType, uuid string, dynamicCfg *config.DynamicConfig) (map[string]interface{}, string, bool) {
+	cfg, err := h.generate(r, configType, uuid, dynamicCfg)
+	if err != nil {
+		h.handleGenerateError(w, r, err, configType, uuid)
+		return nil, "", false
+	}
+
+	shareURL, err := utils.GenerateShareURL(configType, cfg, uuid, dynamicCfg.Remark(configType))
+	if err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"config_type": configType,
+			"uuid":        utils.RedactSecret(uuid),
+		}).Error("Failed to generate share URL")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+		return nil, "", false
+	}
+
+	h.recordGenerated(r, configType, uuid, dynamicCfg.Server)
+	return cfg, shareURL, true
+}
+
+// writeParamErrors responds with 400 and the rejected query parameters as JSON error details
+func (h *Handler) writeParamErrors(w http.ResponseWriter, r *http.Request, paramErrs []config.ParamError) {
+	h.logger.WithFields(logrus.Fields{
+		"path":        utils.RedactPath(r.URL.Path),
+		"errors":      paramErrs,
+		"remote_addr": r.RemoteAddr,
+	}).Warn("Rejected invalid query parameters")
+
+	h.localizeParamErrors(r, paramErrs)
+	apiErr := httperr.Error{
+		Code:    httperr.CodeInvalidParameters,
+		Message: h.errorMessage(r, httperr.CodeInvalidParameters, nil),
+		Details: paramErrs,
+	}
+	if len(paramErrs) == 1 {
+		apiErr.Field = paramErrs[0].Param
+	}
+	if err := httperr.WriteJSON(w, http.StatusBadRequest, apiErr); err != nil {
+		h.logger.WithError(err).Error("Failed to encode parameter errors response")
+	}
+}
+
+// writeError responds with a localized error: JSON for API routes, an error page for HTML config pages.
+// The message is looked up as "error_<code>" in the request language with its {name} placeholders filled from args.
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, status int, code, field string, args map[string]string) {
+	h.writeErrorMessage(w, r, status, code, field, h.errorMessage(r, code, args))
+}
+
+// writeErrorMessage responds like writeError with a message that is already localized
+func (h *Handler) writeErrorMessage(w http.ResponseWriter, r *http.Request, status int, code, field, message string) {
+	if h.isPageRequest(r) {
+		h.renderErrorPage(w, r, status, message)
+		return
+	}
+
+	if err := httperr.WriteJSON(w, status, httperr.Error{Code: code, Message: message, Field: field}); err != nil {
+		h.logger.WithError(err).WithField("code", code).Error("Failed to encode error response")
+	}
+}
+
+// renderErrorPage renders the localized HTML error page with the given status.
+// It falls back to plain text when the page itself cannot be rendered.
+func (h *Handler) renderErrorPage(w http.ResponseWriter, r *http.Request, status int, message string) {
+	language := h.i18n.DetectLanguage(r.URL.Query().Get("lang"))
+	texts := h.i18n.GetTexts(language)
+
+	heading, ok := texts[fmt.Sprintf("error_page_%d", status)]
+	if !ok {
+		heading = http.StatusText(status)
+	}
+
+	data := templates.ErrorPageData{
+		Title:      texts["title"],
+		Language:   language,
+		Direction:  h.i18n.Direction(language),
+		Texts:      texts,
+		StatusCode: status,
+		Heading:    heading,
+		Message:    message,
+		HomeURL:    "/?lang=" + language,
+	}
+
+	rendered := h.writePage(w, r, status, "ErrorPage", func(out io.Writer) error {
+		return h.templateRenderer.RenderErrorPage(out, data)
+	})
+	if !rendered {
+		http.Error(w, message, status)
+	}
+}
+
+// errorMessage returns the translated message for an error code in the request language,
+// with its {name} placeholders filled from args. Unknown codes yield the code itself.
+func (h *Handler) errorMessage(r *http.Request, code string, args map[string]string) string {
+	texts := h.i18n.GetTexts(h.i18n.DetectLanguage(r.URL.Query().Get("lang")))
+	if _, ok := texts["error_"+code]; !ok {
+		return code
+	}
+	return texts.Format("error_"+code, args)
+}
+
+// paramErrorKeys maps query parameters to the translation key describing their rejection;
+// other parameters use error_invalid_param_value
+var paramErrorKeys = map[string]string{
+	"port":           "error_invalid_port",
+	"mixed-port":     "error_invalid_port",
+	"clash-api-port": "error_invalid_port",
+	"server":         "error_invalid_server",
+}
+
+// localizeParamErrors sets the message of every rejected parameter in the request language
+func (h *Handler) localizeParamErrors(r *http.Request, paramErrs []config.ParamError) {
+	texts := h.i18n.GetTexts(h.i18n.DetectLanguage(r.URL.Query().Get("lang")))
+	for idx := range paramErrs {
+		key, ok := paramErrorKeys[paramErrs[idx].Param]
+		if !ok {
+			key = "error_invalid_param_value"
 		}
+		paramErrs[idx].Message = texts.Format(key, map[string]string{
+			"param":    paramErrs[idx].Param,
+			"value":    paramErrs[idx].Value,
+			"accepted": paramErrs[idx].Accepted,
+		})
+	}
+}
+
+// isPageRequest reports whether the request targets an HTML config page (/<type>/<uuid> or /s/<id>)
+func (h *Handler) isPageRequest(r *http.Request) bool {
+	parts := splitPath(r)
+	if len(parts) == 2 && parts[0] == "s" {
+		return path.Ext(parts[1]) == ""
+	}
+	return len(parts) > 0 && h.templateManager.HasTemplate(parts[0])
+}
+
+// renderParamErrors renders the config page with the rejected query parameters and a 400 status
+func (h *Handler) renderParamErrors(w http.ResponseWriter, r *http.Request, language, configType, uuid string, paramErrs []config.ParamError) {
+	h.logger.WithFields(logrus.Fields{
+		"path":        utils.RedactPath(r.URL.Path),
+		"errors":      paramErrs,
+		"remote_addr": r.RemoteAddr,
+	}).Warn("Rejected invalid query parameters")
+
+	h.localizeParamErrors(r, paramErrs)
+	texts := h.i18n.GetTexts(language)
+	data := templates.ConfigPageData{
+		Title:          texts["title"],
+		Language:       language,
+		Direction:      h.i18n.Direction(language),
+		Texts:          texts,
+		Languages:      h.i18n.LanguageLinks(r.URL),
+		ConfigType:     strings.ToUpper(configType),
+		ConfigTypeOrig: configType,
+		UUID:           uuid,
+		Errors:         paramErrs,
 	}
 
-	return cfg
+	rendered := h.writePage(w, r, http.StatusBadRequest, "ConfigPage", func(out io.Writer) error {
+		return h.templateRenderer.RenderConfigPage(out, data)
+	})
+	if !rendered {
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+	}
+}
+
+// checkUUID rejects malformed UUIDs for UUID-keyed config types with a translated 400.
+// strict=false skips the check for intentionally non-UUID VLESS IDs, which sing-box accepts.
+func (h *Handler) checkUUID(w http.ResponseWriter, r *http.Request, query url.Values, configType, uuid string) bool {
+	if query.Get("strict") == "false" || !h.templateManager.RequiresUUID(configType) {
+		return true
+	}
+
+	err := utils.ValidateUUID(uuid)
+	if err == nil {
+		return true
+	}
+
+	h.logger.WithError(err).WithFields(logrus.Fields{
+		"config_type": configType,
+		"remote_addr": r.RemoteAddr,
+	}).Warn("Rejected malformed UUID")
+
+	h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidUUID, "uuid", map[string]string{"uuid": uuid, "format": utils.UUIDFormat})
+	return false
+}
+
+// handleGenerateError responds to a failed GenerateConfig call: missing or
+// invalid parameters are client errors, anything else means an unknown type
+func (h *Handler) handleGenerateError(w http.ResponseWriter, r *http.Request, err error, configType, uuid string) {
+	logEntry := h.logger.WithError(err).WithFields(logrus.Fields{
+		"config_type": configType,
+		"uuid":        utils.RedactSecret(uuid),
+	})
+
+	if errors.Is(err, templates.ErrMissingParameter) || errors.Is(err, templates.ErrInvalidParameter) {
+		logEntry.Warn("Configuration generation rejected due to invalid parameters")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if errors.Is(err, templates.ErrInvalidCredential) {
+		logEntry.Warn("Configuration generation rejected due to an invalid credential")
+		h.writeError(w, r, http.StatusUnprocessableEntity, httperr.CodeInvalidCredential, "uuid", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if errors.Is(err, templates.ErrTemplateRender) {
+		logEntry.Error("Templated configuration could not be rendered")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeTemplateRender, "", map[string]string{"error": err.Error()})
+		return
+	}
+
+	logEntry.Warn("Invalid configuration type or generation failed")
+	h.writeError(w, r, http.StatusNotFound, httperr.CodeTemplateNotFound, "type", map[string]string{"type": configType})
+}
+
+// splitPath splits the escaped request path into unescaped segments so that
+// credentials containing "/" (e.g. base64 passwords sent as %2F) stay in one segment
+func splitPath(r *http.Request) []string {
+	parts := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, part := range parts {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			parts[i] = unescaped
+		}
+	}
+	return parts
 }
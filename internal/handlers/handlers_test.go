@@ -0,0 +1,538 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/i18n"
+	"vless-generator/internal/router"
+	"vless-generator/internal/templates"
+	"vless-generator/internal/utils"
+)
+
+// testUUID is the credential used by the tests
+const testUUID = "bae71742-94e0-4dd5-935f-070339819ba0"
+
+// testTemplateTypes are the template types loaded by newTestHandler
+var testTemplateTypes = []string{"vless", "vless-reality", "trojan", "vmess", "shadowsocks", "hysteria2"}
+
+func init() {
+	logrus.SetOutput(io.Discard)
+}
+
+// newTestHandler returns a handler serving the repository templates and embedded translations.
+// HTML pages are not rendered, so tests stick to JSON routes.
+func newTestHandler(t testing.TB, options Options) *Handler {
+	t.Helper()
+	manager := templates.NewManager(os.DirFS("../../templates"))
+	if err := manager.LoadTemplates(testTemplateTypes); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	translations := i18n.NewI18n()
+	if err := translations.LoadTranslations(); err != nil {
+		t.Fatalf("LoadTranslations: %v", err)
+	}
+	return NewHandler(manager, nil, translations, options)
+}
+
+// update rewrites the golden files with the current output: go test ./internal/handlers -update
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// assertGolden compares a response body with testdata/<name>
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -update and review the diff):\n%s", path, got)
+	}
+}
+
+// serve routes one request to handler registered under pattern and returns the response
+func serve(pattern string, handler http.HandlerFunc, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	mux := router.New()
+	mux.Handle(pattern, handler)
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestImportHandler(t *testing.T) {
+	defaults := config.DefaultDynamicConfig()
+	defaults.DNSServer = "9.9.9.9"
+	h := newTestHandler(t, Options{Defaults: defaults})
+	const link = "vless://bae71742-94e0-4dd5-935f-070339819ba0@x.example.com"
+	const pbk = "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0"
+
+	tests := []struct {
+		name     string
+		url      string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{"tls", link + ":443?security=tls&sni=x.example.com", http.StatusOK, []string{`"9.9.9.9"`, `"server_port":443`}, []string{`"reality"`}},
+		{"plaintext without port", link + "?type=ws&security=none", http.StatusOK, []string{`"server_port":80`}, []string{`"reality"`}},
+		{"reality", link + ":443?security=reality&pbk=" + pbk + "&sid=ab12", http.StatusOK, []string{`"public_key":"` + pbk + `"`, `"short_id":"ab12"`}, nil},
+		{"pbk on tls link", link + ":443?security=tls&pbk=" + pbk, http.StatusUnprocessableEntity, []string{`"invalid_share_url"`}, nil},
+		{"sid without security", link + ":443?sid=ab12", http.StatusUnprocessableEntity, []string{`"invalid_share_url"`}, nil},
+		{"reality without pbk", link + ":443?security=reality", http.StatusBadRequest, []string{`"invalid_share_url"`}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(importRequest{URL: tt.url})
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{"Content-Type": {"application/json"}}
+			w := serve("POST /api/v1/import", h.ImportHandler, http.MethodPost, "/api/v1/import", string(body), header)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("response does not contain %s: %s", want, w.Body)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(w.Body.String(), unwanted) {
+					t.Errorf("response contains %s: %s", unwanted, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	args := map[string]map[string]string{
+		httperr.CodeInvalidUUID:       {"uuid": "not-a-uuid", "format": utils.UUIDFormat},
+		httperr.CodeTemplateNotFound:  {"type": "wireguard"},
+		httperr.CodeInvalidBody:       {"error": "unexpected EOF"},
+		httperr.CodeMissingParameter:  {"param": "uuid"},
+		httperr.CodeInvalidParameter:  {"error": "pretty must be true or false"},
+		httperr.CodeUnsupportedFormat: {"format": "yaml", "target": "hysteria2"},
+		httperr.CodeNotAcceptable:     {"types": "application/json"},
+		httperr.CodeInvalidShareURL:   {"error": "malformed UUID"},
+		httperr.CodeBatchTooLarge:     {"size": "5000", "max": "1000"},
+		httperr.CodeTemplateRender:    {"error": "bad template"},
+		httperr.CodeInvalidTemplate:   {"type": "vless"},
+	}
+	for _, language := range h.i18n.GetSupportedLanguages() {
+		r := httptest.NewRequest(http.MethodGet, "/?lang="+language, nil)
+		for code, codeArgs := range args {
+			message := h.errorMessage(r, code, codeArgs)
+			if strings.ContainsAny(message, "{}%") {
+				t.Errorf("%s %s: unfilled placeholder in %q", language, code, message)
+			}
+			for _, value := range codeArgs {
+				if !strings.Contains(message, value) {
+					t.Errorf("%s %s: %q does not contain %q", language, code, message, value)
+				}
+			}
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := h.errorMessage(r, "no_such_code", nil); got != "no_such_code" {
+		t.Errorf("unknown code: got %q, want the code", got)
+	}
+}
+
+func TestConfigDownloadShadowsocksKey(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	tests := []struct {
+		password string
+		status   int
+	}{
+		{"AAECAwQFBgcICQoLDA0ODw%3D%3D", http.StatusOK},
+		{"bae71742-94e0-4dd5-935f-070339819ba0", http.StatusUnprocessableEntity},
+		{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8%3D", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		w := serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, "/config/shadowsocks/"+tt.password+".json", "", nil)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.password, w.Code, tt.status, w.Body)
+		}
+		if tt.status == http.StatusUnprocessableEntity && !strings.Contains(w.Body.String(), `"invalid_credential"`) {
+			t.Errorf("%s: missing invalid_credential code: %s", tt.password, w.Body)
+		}
+	}
+}
+
+func TestUUIDHandler(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		w := serve("GET /api/v1/uuid", h.UUIDHandler, http.MethodGet, "/api/v1/uuid", "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d", w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", got)
+		}
+		var body struct{ UUID string }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if !utils.IsValidUUID(body.UUID) || body.UUID[14] != '4' {
+			t.Errorf("%q is not a version 4 UUID", body.UUID)
+		}
+		if seen[body.UUID] {
+			t.Errorf("UUID %s returned twice", body.UUID)
+		}
+		seen[body.UUID] = true
+	}
+}
+
+func TestConfigPageNewUUIDRedirect(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	for _, target := range []string{"/vless/new?server=x.example.com&lang=ru", "/vmess/random?server=x.example.com&lang=ru"} {
+		w := serve("GET /{type}/{uuid}", h.ConfigPageHandler, http.MethodGet, target, "", nil)
+		if w.Code != http.StatusFound {
+			t.Fatalf("%s: status = %d, want 302", target, w.Code)
+		}
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		configType, uuid, _ := strings.Cut(strings.TrimPrefix(location.Path, "/"), "/")
+		if !strings.HasPrefix(target, "/"+configType+"/") || !utils.IsValidUUID(uuid) {
+			t.Errorf("%s: redirected to %s", target, location)
+		}
+		if location.RawQuery != "server=x.example.com&lang=ru" {
+			t.Errorf("%s: query = %q, want the original query", target, location.RawQuery)
+		}
+	}
+}
+
+func TestPrettyOutput(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	// The DoH URL carries an & that HTML escaping would turn into \u0026
+	const doh = "https://dns.example.com/dns-query?ct=1&edns=0"
+	download := "/config/vless/" + testUUID + ".json?doh-server=" + url.QueryEscape(doh)
+	apiBody := `{"type":"vless","uuid":"` + testUUID + `","params":{"doh-server":"` + doh + `"}}`
+
+	tests := []struct {
+		name   string
+		w      func() *httptest.ResponseRecorder
+		status int
+		pretty bool
+	}{
+		{"download default", func() *httptest.ResponseRecorder {
+			return serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, download, "", nil)
+		}, http.StatusOK, true},
+		{"download pretty=false", func() *httptest.ResponseRecorder {
+			return serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, download+"&pretty=false", "", nil)
+		}, http.StatusOK, false},
+		{"api default", func() *httptest.ResponseRecorder {
+			return serve("POST /api/v1/config", h.ConfigAPIHandler, http.MethodPost, "/api/v1/config", apiBody, nil)
+		}, http.StatusOK, false},
+		{"api pretty=true", func() *httptest.ResponseRecorder {
+			return serve("POST /api/v1/config", h.ConfigAPIHandler, http.MethodPost, "/api/v1/config?pretty=true", apiBody, nil)
+		}, http.StatusOK, true},
+		{"invalid pretty", func() *httptest.ResponseRecorder {
+			return serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, download+"&pretty=maybe", "", nil)
+		}, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.w()
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			body := w.Body.String()
+			if tt.status != http.StatusOK {
+				if !strings.Contains(body, `"invalid_parameter"`) {
+					t.Errorf("missing invalid_parameter code: %s", body)
+				}
+				return
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Fatalf("invalid JSON: %s", body)
+			}
+			if lines := strings.Count(strings.TrimSuffix(body, "\n"), "\n"); tt.pretty != (lines > 0) {
+				t.Errorf("pretty = %v but the body has %d line breaks", tt.pretty, lines)
+			}
+			if tt.pretty && !strings.Contains(body, "\n  \"") {
+				t.Errorf("body is not indented by two spaces")
+			}
+			if strings.Contains(body, `\u0026`) || !strings.Contains(body, doh) {
+				t.Errorf("DoH URL was escaped: want %s in the body", doh)
+			}
+		})
+	}
+}
+
+func TestInvalidUUIDLocalized(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{"", "Invalid UUID \"not-a-uuid\""},
+		{"en", "Invalid UUID \"not-a-uuid\""},
+		{"ru", "Некорректный UUID \"not-a-uuid\""},
+		{"xx", "Invalid UUID \"not-a-uuid\""},
+	}
+	for _, tt := range tests {
+		t.Run("lang="+tt.lang, func(t *testing.T) {
+			w := serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, "/config/vless/not-a-uuid.json?lang="+tt.lang, "", nil)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+			var body struct {
+				Error struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error.Code != httperr.CodeInvalidUUID {
+				t.Errorf("code = %q, want %q", body.Error.Code, httperr.CodeInvalidUUID)
+			}
+			if !strings.HasPrefix(body.Error.Message, tt.want) {
+				t.Errorf("message = %q, want it to start with %q", body.Error.Message, tt.want)
+			}
+			if !strings.Contains(body.Error.Message, utils.UUIDFormat) {
+				t.Errorf("message = %q, want the expected format", body.Error.Message)
+			}
+		})
+	}
+}
+
+func TestConfigAPIPageURL(t *testing.T) {
+	body := `{"type":"vless","uuid":"` + testUUID + `","params":{"server":"x.example.com"}}`
+	forwarded := http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"public.example.com"}}
+	tests := []struct {
+		name       string
+		trustProxy bool
+		header     http.Header
+		want       string
+	}{
+		{"direct", false, nil, "http://example.com/vless/" + testUUID + "?"},
+		{"proxied", true, forwarded, "https://public.example.com/vless/" + testUUID + "?"},
+		{"untrusted proxy", false, forwarded, "http://example.com/vless/" + testUUID + "?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, Options{TrustProxy: tt.trustProxy})
+			w := serve("POST /api/v1/config", h.ConfigAPIHandler, http.MethodPost, "/api/v1/config", body, tt.header)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var response configResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(response.PageURL, tt.want) {
+				t.Errorf("page_url = %s, want prefix %s", response.PageURL, tt.want)
+			}
+			if !strings.Contains(response.PageURL, "server=x.example.com") {
+				t.Errorf("page_url = %s, want the server parameter", response.PageURL)
+			}
+		})
+	}
+}
+
+func TestConfigDownloadGolden(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	const query = "?server=x.example.com&sni=x.example.com&pbk=jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0&sid=ab12"
+	tests := []struct {
+		golden      string
+		target      string
+		contentType string
+	}{
+		{"vless.sing-box.json", "/config/vless/" + testUUID + ".json" + query, "application/json"},
+		{"vless.sing-box.modern.json", "/config/vless/" + testUUID + ".json" + query + "&schema=modern", "application/json"},
+		{"vless-reality.xray.json", "/config/vless-reality/" + testUUID + ".json" + query + "&format=xray", "application/json"},
+		{"vmess.xray.json", "/config/vmess/" + testUUID + ".json" + query + "&format=xray", "application/json"},
+		{"trojan.xray.json", "/config/trojan/" + testUUID + ".json" + query + "&format=xray", "application/json"},
+		{"shadowsocks.xray.json", "/config/shadowsocks/AAECAwQFBgcICQoLDA0ODw%3D%3D.json" + query + "&format=xray", "application/json"},
+		{"vless-reality.clash.yaml", "/config/vless-reality/" + testUUID + ".yaml" + query, "application/yaml"},
+		{"vmess.clash.yaml", "/config/vmess/" + testUUID + ".yaml" + query, "application/yaml"},
+		{"trojan.clash.yaml", "/config/trojan/" + testUUID + ".yaml" + query, "application/yaml"},
+		{"shadowsocks.clash.yaml", "/config/shadowsocks/AAECAwQFBgcICQoLDA0ODw%3D%3D.yaml" + query, "application/yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			pattern := "GET /config/{type}/{uuid}" + filepath.Ext(tt.golden)
+			w := serve(pattern, h.ConfigDownloadHandler, http.MethodGet, tt.target, "", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.contentType)
+			}
+			assertGolden(t, tt.golden, w.Body.Bytes())
+		})
+	}
+}
+
+func TestDownloadFilenameInjection(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	base := "/config/vless/" + testUUID + ".json?"
+	tests := []struct {
+		name   string
+		query  url.Values
+		status int
+		want   string // file name the browser saves
+	}{
+		{"server", url.Values{"server": {"x.example.com"}}, http.StatusOK, "x.example.com-vless-config.json"},
+		{"server with quote and newline", url.Values{"server": {"x.example.com\"\r\nSet-Cookie: session=1"}}, http.StatusBadRequest, ""},
+		{"remark with quote and newline", url.Values{"name": {"home\"\r\nSet-Cookie: session=1"}}, http.StatusOK, "home_Set-Cookie_ session=1-vless-config.json"},
+		{"filename with quote and newline", url.Values{"filename": {"a\"\r\nX-Injected: 1"}}, http.StatusOK, "a_X-Injected_ 1.json"},
+		{"unicode remark", url.Values{"name": {"Дом"}}, http.StatusOK, "Дом-vless-config.json"},
+		{"filename too long", url.Values{"filename": {strings.Repeat("a", utils.MaxFilenameLength+1)}}, http.StatusBadRequest, ""},
+		{"long remark is cut", url.Values{"name": {strings.Repeat("n", 300)}}, http.StatusOK, strings.Repeat("n", utils.MaxFilenameLength-len("-vless-config.json")) + "-vless-config.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, base+tt.query.Encode(), "", nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			for name, values := range w.Header() {
+				if name == "Set-Cookie" || name == "X-Injected" {
+					t.Errorf("injected header %s: %v", name, values)
+				}
+				for _, value := range values {
+					if strings.ContainsAny(value, "\r\n") {
+						t.Errorf("header %s = %q contains a line break", name, value)
+					}
+				}
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			_, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+			if err != nil {
+				t.Fatalf("Content-Disposition %q: %v", w.Header().Get("Content-Disposition"), err)
+			}
+			if params["filename"] != tt.want {
+				t.Errorf("filename = %q, want %q", params["filename"], tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerMetrics(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	// value reads an expvar integer, or one entry of an expvar map
+	value := func(name, key string) int64 {
+		v := expvar.Get(name)
+		if m, ok := v.(*expvar.Map); ok {
+			v = m.Get(key)
+		}
+		if counter, ok := v.(*expvar.Int); ok {
+			return counter.Value()
+		}
+		return 0
+	}
+	generated, vless, trojan, qrCodes := value("configs_generated", ""), value("configs_by_type", "vless"), value("configs_by_type", "trojan"), value("qr_codes", "")
+
+	for _, target := range []string{
+		"/config/vless/" + testUUID + ".json",
+		"/config/vless/" + testUUID + ".json?format=xray",
+		"/config/trojan/" + testUUID + ".json",
+		"/config/vless/not-a-uuid.json",
+	} {
+		serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, target, "", nil)
+	}
+	serve("GET /qrcode", h.QRCodeHandler, http.MethodGet, "/qrcode?url="+url.QueryEscape("vless://"+testUUID+"@x.example.com:443"), "", nil)
+	serve("GET /qrcode", h.QRCodeHandler, http.MethodGet, "/qrcode", "", nil)
+
+	for _, d := range []struct {
+		name  string
+		delta int64
+		want  int64
+	}{
+		{"configs_generated", value("configs_generated", "") - generated, 3},
+		{"configs_by_type[vless]", value("configs_by_type", "vless") - vless, 2},
+		{"configs_by_type[trojan]", value("configs_by_type", "trojan") - trojan, 1},
+		{"qr_codes", value("qr_codes", "") - qrCodes, 1},
+	} {
+		if d.delta != d.want {
+			t.Errorf("%s delta = %d, want %d", d.name, d.delta, d.want)
+		}
+	}
+}
+
+func TestQRCodeLogsRedactShareURL(t *testing.T) {
+	logrus.SetOutput(io.Discard)
+	hook := test.NewGlobal()
+	previousLevel := logrus.GetLevel()
+	logrus.SetLevel(logrus.DebugLevel)
+	t.Cleanup(func() {
+		logrus.StandardLogger().ReplaceHooks(logrus.LevelHooks{})
+		logrus.SetLevel(previousLevel)
+	})
+	h := newTestHandler(t, Options{})
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		field  string
+		want   string
+	}{
+		{"valid share URL", "vless://" + testUUID + "@x.example.com:443#node", http.StatusOK, "vless_url", "vless://bae71742…@x.example.com:443#node"},
+		{"unsupported scheme", "http://" + testUUID + "@x.example.com", http.StatusBadRequest, "url", "http://bae71742…@x.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook.Reset()
+			w := serve("GET /qrcode", h.QRCodeHandler, http.MethodGet, "/qrcode?url="+url.QueryEscape(tt.url), "", nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			found := false
+			for _, entry := range hook.AllEntries() {
+				for name, value := range entry.Data {
+					if strings.Contains(fmt.Sprint(value), testUUID) {
+						t.Errorf("%q entry field %s = %v, want the UUID masked", entry.Message, name, value)
+					}
+				}
+				if entry.Data[tt.field] == tt.want {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("no log entry with %s = %q", tt.field, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/httperr"
+)
+
+// maintenanceState is reported by /health and POST /admin/maintenance
+type maintenanceState struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"` // Shown instead of the localized default
+	Since   *time.Time `json:"since,omitempty"`   // When maintenance mode was enabled
+}
+
+// maintenanceRequest is the JSON body of POST /admin/maintenance
+type maintenanceRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// SetMaintenance turns maintenance mode on or off; message replaces the localized
+// "temporarily unavailable" text when not empty
+func (h *Handler) SetMaintenance(enabled bool, message string) {
+	state := &maintenanceState{}
+	if enabled {
+		now := time.Now().UTC()
+		state = &maintenanceState{Enabled: true, Message: message, Since: &now}
+	}
+	h.maintenance.Store(state)
+}
+
+// maintenanceStatus returns the current maintenance state
+func (h *Handler) maintenanceStatus() maintenanceState {
+	if state := h.maintenance.Load(); state != nil {
+		return *state
+	}
+	return maintenanceState{}
+}
+
+// RequireService answers generation routes with 503 while maintenance mode is on: config
+// pages get the localized error page, other routes a JSON error
+func (h *Handler) RequireService(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := h.maintenance.Load()
+		if state == nil || !state.Enabled {
+			next(w, r)
+			return
+		}
+		message := state.Message
+		if message == "" {
+			message = h.errorMessage(r, httperr.CodeMaintenance, nil)
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		h.writeErrorMessage(w, r, http.StatusServiceUnavailable, httperr.CodeMaintenance, "", message)
+	}
+}
+
+// MaintenanceHandler turns maintenance mode on or off (POST /admin/maintenance) with a JSON
+// body {"enabled": true, "message": "..."} and responds with the new state
+func (h *Handler) MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Enabled == nil {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, "enabled", map[string]string{"param": "enabled"})
+		return
+	}
+
+	h.SetMaintenance(*req.Enabled, req.Message)
+	h.logger.WithFields(logrus.Fields{
+		"enabled":     *req.Enabled,
+		"message":     req.Message,
+		"remote_addr": r.RemoteAddr,
+	}).Warn("Maintenance mode changed via admin endpoint")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.maintenanceStatus()); err != nil {
+		h.logger.WithError(err).Error("Failed to encode maintenance response")
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"vless-generator/internal/buildinfo"
+	"vless-generator/internal/openapi"
+)
+
+// OpenAPIHandler serves the OpenAPI 3 document of the service (GET /openapi.json); it is
+// built per request so reloaded template types show up
+func (h *Handler) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	document := openapi.Build(openapi.Options{
+		Version:      buildinfo.Version,
+		Types:        h.templateManager.GetTemplateTypes(),
+		Defaults:     h.options.Defaults,
+		AuthRequired: len(h.options.AuthTokens) > 0,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeJSON(w, document, true); err != nil {
+		h.logger.WithError(err).Error("Failed to encode OpenAPI document")
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/qr"
+)
+
+func TestWriteQRError(t *testing.T) {
+	tests := []struct {
+		err        error
+		status     int
+		code       string
+		retryAfter string
+	}{
+		{qr.ErrBusy, http.StatusServiceUnavailable, httperr.CodeOverloaded, "1"},
+		{fmt.Errorf("encode: %w", context.Canceled), http.StatusServiceUnavailable, httperr.CodeOverloaded, ""},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable, httperr.CodeOverloaded, ""},
+		{qr.ErrContentTooLong, http.StatusRequestEntityTooLarge, httperr.CodeContentTooLong, ""},
+		{fmt.Errorf("boom"), http.StatusInternalServerError, httperr.CodeInternal, ""},
+	}
+	h := newTestHandler(t, Options{})
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.writeQRError(w, httptest.NewRequest(http.MethodGet, "/qrcode?url=x", nil), tt.err)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Fatalf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			var body struct{ Error httperr.Error }
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error.Code != tt.code {
+				t.Fatalf("body = %s, want code %s", w.Body, tt.code)
+			}
+		})
+	}
+}
+
+func TestQRCodeHandlerCancelledRequest(t *testing.T) {
+	// A request whose client left before a worker freed up is not encoded
+	h := newTestHandler(t, Options{QRPool: qr.NewPool(1, 0)})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/qrcode?url=vless://x@example.com:443", nil).WithContext(ctx)
+
+	w := httptest.NewRecorder()
+	h.QRCodeHandler(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.QRCodeHandler(w, httptest.NewRequest(http.MethodGet, "/qrcode?url=vless://x@example.com:443", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("status = %d (%s), want a PNG", w.Code, w.Body)
+	}
+}
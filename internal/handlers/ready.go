@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"vless-generator/internal/i18n"
+)
+
+// Component states reported by HealthHandler and ReadyHandler
+const (
+	componentOK       = "ok"
+	componentDegraded = "degraded"
+	componentFailed   = "failed"
+)
+
+// componentStatus is the state of one dependency in health and readiness responses
+type componentStatus struct {
+	Status  string      `json:"status"` // ok, degraded or failed
+	Error   string      `json:"error,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// readinessPublicKey fills the Reality key of the test configurations generated by ReadyHandler
+const readinessPublicKey = "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw"
+
+// ReadyHandler reports whether the service can serve traffic: templates and translations are
+// loaded, the home page renders and every template type generates a configuration. It answers
+// 503 otherwise; /health stays a cheap liveness check.
+func (h *Handler) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	components := map[string]componentStatus{
+		"templates":    h.templatesStatus(),
+		"translations": h.translationsStatus(),
+	}
+	if components["translations"].Status != componentFailed {
+		components["home_page"] = h.homePageStatus()
+	}
+	if components["templates"].Status != componentFailed {
+		components["generation"] = h.generationStatus()
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, component := range components {
+		if component.Status == componentFailed {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	response := map[string]interface{}{
+		"status":     status,
+		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+		"components": components,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.WithError(err).Error("Failed to encode readiness response")
+	}
+}
+
+// templatesStatus reports whether configuration templates are loaded; remote fetch
+// failures served from the cache are degraded, not failed
+func (h *Handler) templatesStatus() componentStatus {
+	templateStatus := h.templateManager.Status()
+	switch {
+	case !templateStatus.Loaded:
+		return componentStatus{Status: componentFailed, Error: "templates not loaded", Details: templateStatus}
+	case len(templateStatus.FetchErrors) > 0:
+		return componentStatus{Status: componentDegraded, Details: templateStatus}
+	default:
+		return componentStatus{Status: componentOK, Details: templateStatus}
+	}
+}
+
+// translationsStatus reports whether translations are loaded
+func (h *Handler) translationsStatus() componentStatus {
+	translationStatus := h.i18n.Status()
+	if !translationStatus.Loaded {
+		return componentStatus{Status: componentFailed, Error: "translations not loaded", Details: translationStatus}
+	}
+	return componentStatus{Status: componentOK, Details: translationStatus}
+}
+
+// homePageStatus renders the home page in the default language
+func (h *Handler) homePageStatus() componentStatus {
+	data := h.homePageData(&url.URL{Path: "/"}, i18n.DefaultLanguage)
+	if err := h.templateRenderer.RenderHomePage(io.Discard, data); err != nil {
+		return componentStatus{Status: componentFailed, Error: err.Error()}
+	}
+	return componentStatus{Status: componentOK}
+}
+
+// generationStatus generates a test configuration from the defaults for every template type
+func (h *Handler) generationStatus() componentStatus {
+	sample := *h.options.Defaults
+	if sample.RealityPublicKey == "" {
+		sample.RealityPublicKey = readinessPublicKey
+	}
+
+	failures := make(map[string]string)
+	for _, configType := range h.templateManager.GetTemplateTypes() {
+		credential, err := h.templateManager.NewCredential(configType)
+		if err == nil {
+			err = h.templateManager.CheckConfig(configType, credential, &sample)
+		}
+		if err != nil {
+			failures[configType] = err.Error()
+		}
+	}
+	if len(failures) > 0 {
+		return componentStatus{Status: componentFailed, Error: "some template types fail to generate", Details: failures}
+	}
+	return componentStatus{Status: componentOK}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/tracing"
+)
+
+// maxPooledRenderBuffer is the largest buffer returned to renderBuffers; larger pages are rare
+// enough that keeping their buffers around would only hold memory
+const maxPooledRenderBuffer = 1 << 20
+
+// renderBuffers holds the buffers pages are rendered into with Options.StrictRender
+var renderBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// pageWriter sends the status and Content-Type of an HTML page before its first byte, so a
+// render that fails before writing anything can still be answered with an error
+type pageWriter struct {
+	w       http.ResponseWriter
+	status  int
+	started bool
+}
+
+func (pw *pageWriter) Write(data []byte) (int, error) {
+	if !pw.started {
+		pw.started = true
+		pw.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		pw.w.WriteHeader(pw.status)
+	}
+	return pw.w.Write(data)
+}
+
+// writePage renders an HTML page with status using render, named page in traces and logs
+// (e.g., HomePage). Pages are streamed straight into the response; when rendering fails
+// midway the partial page is left as is and the error logged. With Options.StrictRender the
+// page is rendered into a pooled buffer first instead. It returns false when nothing was
+// written, so the caller can still answer with an error.
+func (h *Handler) writePage(w http.ResponseWriter, r *http.Request, status int, page string, render func(io.Writer) error) bool {
+	_, span := tracing.Start(r.Context(), "templates.Render"+page)
+	defer span.End()
+
+	pw := &pageWriter{w: w, status: status}
+	var err error
+	if h.options.StrictRender {
+		buf := renderBuffers.Get().(*bytes.Buffer)
+		defer func() {
+			if buf.Cap() <= maxPooledRenderBuffer {
+				buf.Reset()
+				renderBuffers.Put(buf)
+			}
+		}()
+		if err = render(buf); err == nil {
+			_, err = pw.Write(buf.Bytes())
+		}
+	} else {
+		err = render(pw)
+	}
+	if err == nil {
+		return true
+	}
+
+	span.SetError(err)
+	logEntry := h.logger.WithError(err).WithFields(logrus.Fields{"page": page, "status_code": status})
+	if pw.started {
+		logEntry.Error("Failed to render or write page after the response started")
+		return true
+	}
+	logEntry.Error("Failed to render page template")
+	return false
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/templates"
+	"vless-generator/internal/utils"
+)
+
+// replayRequest is the JSON body accepted by ReplayHandler: either the full URL a user
+// reported, or a type, credential and parameter set
+type replayRequest struct {
+	URL    string                 `json:"url"`
+	Type   string                 `json:"type"`
+	UUID   string                 `json:"uuid"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// replayResponse is the result of a replayed request
+type replayResponse struct {
+	Type     string                 `json:"type"`
+	Trace    []templates.Mutation   `json:"trace"`
+	Config   map[string]interface{} `json:"config"`
+	URL      string                 `json:"url"`
+	Warnings []string               `json:"warnings,omitempty"`
+}
+
+// ReplayHandler reruns the generation pipeline for a reported config URL or parameter set
+// and returns every template mutation with the final config and share URL. Replays are not
+// audited or counted in the generation metrics.
+func (h *Handler) ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.writeError(w, r, http.StatusMethodNotAllowed, httperr.CodeMethodNotAllowed, "", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	var req replayRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber() // Keep params and patch integers exact
+	if err := decoder.Decode(&req); err != nil {
+		h.logger.WithError(err).Warn("Failed to decode replay request body")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+		return
+	}
+
+	configType, uuid := req.Type, req.UUID
+	query, warnings := config.ParamsToValues(req.Params)
+	if req.URL != "" {
+		var err error
+		configType, uuid, query, err = parseReplayURL(req.URL)
+		if err != nil {
+			h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "url", map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if configType == "" {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, "type", map[string]string{"param": "type"})
+		return
+	}
+	if uuid == "" {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, "uuid", map[string]string{"param": "uuid"})
+		return
+	}
+	if !h.checkUUID(w, r, query, configType, uuid) {
+		return
+	}
+
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(query, h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.writeParamErrors(w, r, paramErrs)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"config_type": configType,
+		"uuid":        utils.RedactSecret(uuid),
+		"remote_addr": r.RemoteAddr,
+	}).Info("Replaying configuration request")
+
+	cfg, trace, err := h.templateManager.ReplayConfig(configType, uuid, dynamicCfg)
+	if err != nil {
+		h.handleGenerateError(w, r, err, configType, uuid)
+		return
+	}
+	shareURL, err := utils.GenerateShareURL(configType, cfg, uuid, dynamicCfg.Remark(configType))
+	if err != nil {
+		h.logger.WithError(err).WithField("config_type", configType).Warn("Failed to generate share URL of replayed configuration")
+		warnings = append(warnings, "share URL: "+err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := writeJSON(w, replayResponse{
+		Type:     configType,
+		Trace:    trace,
+		Config:   cfg,
+		URL:      shareURL,
+		Warnings: warnings,
+	}, true); err != nil {
+		h.logger.WithError(err).Error("Failed to write replay response")
+	}
+}
+
+// errReplayPath is returned for replay URLs not pointing at a config
+var errReplayPath = errors.New("url must point at a config page, download, QR code or bundle")
+
+// parseReplayURL splits a reported URL - a config page, /config/ download, /qrcode/ or
+// /bundle/ link - into its type, credential and query
+func parseReplayURL(rawURL string) (configType, uuid string, query url.Values, err error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", nil, err
+	}
+	parts := strings.Split(strings.TrimPrefix(signedPath(parsed.EscapedPath()), "/"), "/")
+	if len(parts) != 2 {
+		return "", "", nil, errReplayPath
+	}
+	uuid, err = url.PathUnescape(parts[1])
+	if err != nil {
+		return "", "", nil, err
+	}
+	return parts[0], uuid, parsed.Query(), nil
+}
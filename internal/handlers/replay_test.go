@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"vless-generator/internal/templates"
+	"vless-generator/internal/utils"
+)
+
+func TestReplayHandler(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"config page url", `{"url": "https://vpn.example.com/vless/` + testUUID + `?server=x.example.com&port=8443&lang=ru"}`},
+		{"download url", `{"url": "https://vpn.example.com/config/vless/` + testUUID + `.json?server=x.example.com&port=8443"}`},
+		{"parameter set", `{"type": "vless", "uuid": "` + testUUID + `", "params": {"server": "x.example.com", "port": 8443}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve("POST /api/v1/debug/replay", h.ReplayHandler, http.MethodPost, "/api/v1/debug/replay", tt.body, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Type   string                 `json:"type"`
+				Trace  []templates.Mutation   `json:"trace"`
+				Config map[string]interface{} `json:"config"`
+				URL    string                 `json:"url"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Type != "vless" || !strings.HasPrefix(resp.URL, "vless://"+testUUID+"@x.example.com:8443") {
+				t.Errorf("type = %q, url = %q", resp.Type, resp.URL)
+			}
+			if proxy := utils.ProxyOutbounds(resp.Config)[0]; proxy["server"] != "x.example.com" {
+				t.Errorf("config server = %v", proxy["server"])
+			}
+			paths := make(map[string]interface{})
+			for _, mutation := range resp.Trace {
+				paths[mutation.Path] = mutation.New
+			}
+			if paths["/outbounds/0/uuid"] != utils.RedactSecret(testUUID) {
+				t.Errorf("uuid mutation = %v, want the credential masked", paths["/outbounds/0/uuid"])
+			}
+			if paths["/outbounds/0/server_port"] != 8443.0 {
+				t.Errorf("server_port mutation = %v, want 8443", paths["/outbounds/0/server_port"])
+			}
+		})
+	}
+}
+
+func TestReplayHandlerRejects(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"get", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"malformed body", http.MethodPost, `{`, http.StatusBadRequest},
+		{"missing type", http.MethodPost, `{"uuid": "` + testUUID + `"}`, http.StatusBadRequest},
+		{"missing uuid", http.MethodPost, `{"type": "vless"}`, http.StatusBadRequest},
+		{"url without credential", http.MethodPost, `{"url": "https://vpn.example.com/health"}`, http.StatusBadRequest},
+		{"malformed uuid", http.MethodPost, `{"url": "https://vpn.example.com/vless/not-a-uuid"}`, http.StatusBadRequest},
+		{"invalid parameter", http.MethodPost, `{"url": "https://vpn.example.com/vless/` + testUUID + `?port=0"}`, http.StatusBadRequest},
+		{"unknown type", http.MethodPost, `{"type": "unknown", "uuid": "` + testUUID + `"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve("/api/v1/debug/replay", h.ReplayHandler, tt.method, "/api/v1/debug/replay", tt.body, nil)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
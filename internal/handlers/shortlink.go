@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/router"
+	"vless-generator/internal/shortlink"
+	"vless-generator/internal/utils"
+)
+
+// shortLinkAttempts bounds retries when a random id is already taken
+const shortLinkAttempts = 3
+
+// shortLinkPrefix is the path of short link pages; the raw config lives at <prefix><id>.json
+const shortLinkPrefix = "/s/"
+
+// shortenRequest is the JSON body accepted by ShortenHandler
+type shortenRequest struct {
+	Type   string                 `json:"type"`
+	UUID   string                 `json:"uuid"`
+	Params map[string]interface{} `json:"params"` // Query parameters, as in the config API
+	TTL    string                 `json:"ttl"`    // Lifetime as a Go duration (e.g., 24h); defaults to -shortlink-ttl
+}
+
+// shortenResponse is the JSON body returned by ShortenHandler
+type shortenResponse struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`      // Absolute URL of the config page
+	JSONURL   string   `json:"json_url"` // Absolute URL of the raw JSON config
+	ExpiresAt string   `json:"expires_at,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// ShortenHandler stores a config type, credential and parameters under a random id and
+// returns its /s/<id> links (POST /api/v1/shorten)
+func (h *Handler) ShortenHandler(w http.ResponseWriter, r *http.Request) {
+	if h.options.ShortLinks == nil {
+		h.writeError(w, r, http.StatusNotFound, httperr.CodeNotFound, "", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	var req shortenRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		h.logger.WithError(err).Warn("Failed to decode shorten request body")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Type == "" || req.UUID == "" {
+		field := "type"
+		if req.Type != "" {
+			field = "uuid"
+		}
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeMissingParameter, field, map[string]string{"param": field})
+		return
+	}
+
+	ttl := h.options.ShortLinkTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 {
+			h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "ttl", map[string]string{"error": fmt.Sprintf("ttl must be a positive duration such as 24h, got %q", req.TTL)})
+			return
+		}
+		ttl = parsed
+	}
+
+	query, warnings := config.ParamsToValues(req.Params)
+	if !h.checkUUID(w, r, query, req.Type, req.UUID) {
+		return
+	}
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(query, h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.writeParamErrors(w, r, paramErrs)
+		return
+	}
+	// Only links that generate are stored, so resolving one never fails on its parameters
+	if err := h.templateManager.CheckConfig(req.Type, req.UUID, dynamicCfg); err != nil {
+		h.handleGenerateError(w, r, err, req.Type, req.UUID)
+		return
+	}
+
+	now := time.Now()
+	link := shortlink.Link{Type: req.Type, UUID: req.UUID, Config: dynamicCfg, CreatedAt: now.UTC()}
+	if ttl > 0 {
+		link.ExpiresAt = now.Add(ttl).UTC()
+	}
+	var err error
+	for attempt := 0; attempt < shortLinkAttempts; attempt++ {
+		if link.ID, err = shortlink.NewID(); err != nil {
+			break
+		}
+		if err = h.options.ShortLinks.Put(link); !errors.Is(err, shortlink.ErrDuplicate) {
+			break
+		}
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to store short link")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"id":          link.ID,
+		"config_type": link.Type,
+		"uuid":        utils.RedactSecret(link.UUID),
+		"remote_addr": r.RemoteAddr,
+	}).Info("Created short link")
+
+	response := shortenResponse{
+		ID:       link.ID,
+		URL:      utils.AbsoluteURL(r, h.options.TrustProxy, shortLinkPrefix+link.ID),
+		JSONURL:  utils.AbsoluteURL(r, h.options.TrustProxy, shortLinkPrefix+link.ID+".json"),
+		Warnings: warnings,
+	}
+	if !link.ExpiresAt.IsZero() {
+		response.ExpiresAt = link.ExpiresAt.Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.WithError(err).Error("Failed to encode shorten response")
+	}
+}
+
+// ShortLinkHandler serves the config page of a short link (GET /s/<id>)
+func (h *Handler) ShortLinkHandler(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.resolveShortLink(w, r)
+	if !ok {
+		return
+	}
+	h.serveConfigPage(w, h.shortLinkRequest(r, link), link.Type, link.UUID)
+}
+
+// ShortLinkDownloadHandler serves the raw JSON config of a short link (GET /s/<id>.json)
+func (h *Handler) ShortLinkDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.resolveShortLink(w, r)
+	if !ok {
+		return
+	}
+	h.serveConfigDownload(w, h.shortLinkRequest(r, link), link.Type, link.UUID, ".json")
+}
+
+// resolveShortLink looks up the link of the request's id, answering 404 for unknown and expired ids
+func (h *Handler) resolveShortLink(w http.ResponseWriter, r *http.Request) (shortlink.Link, bool) {
+	id := router.Param(r, "id")
+	if h.options.ShortLinks == nil || !shortlink.IsValidID(id) {
+		h.writeError(w, r, http.StatusNotFound, httperr.CodeNotFound, "", nil)
+		return shortlink.Link{}, false
+	}
+
+	link, err := h.options.ShortLinks.Get(id, time.Now())
+	if err != nil {
+		if !errors.Is(err, shortlink.ErrNotFound) {
+			h.logger.WithError(err).WithField("id", id).Error("Failed to look up short link")
+		}
+		h.writeError(w, r, http.StatusNotFound, httperr.CodeNotFound, "", nil)
+		return shortlink.Link{}, false
+	}
+	return link, true
+}
+
+// shortLinkRequest returns a copy of r carrying the stored parameters of link as its query,
+// keeping the presentation parameters of the original request. With -signing-key the query
+// is signed until the link expires, so the page's download and QR code links work without a token.
+func (h *Handler) shortLinkRequest(r *http.Request, link shortlink.Link) *http.Request {
+	query := link.Config.Values()
+	// The credential was validated when the link was created
+	query.Set("strict", "false")
+
+	if len(h.options.SigningKey) > 0 {
+		expires := link.ExpiresAt
+		if expires.IsZero() {
+			expires = time.Now().Add(defaultSignatureTTL)
+		}
+		pagePath := "/" + url.PathEscape(link.Type) + "/" + url.PathEscape(link.UUID)
+		_, rawQuery, _ := strings.Cut(utils.SignURL(h.options.SigningKey, pagePath, query, expires), "?")
+		query, _ = url.ParseQuery(rawQuery)
+	}
+
+	// Unsigned presentation parameters may still be chosen per request
+	original := r.URL.Query()
+	for _, name := range []string{"lang", "format", "pretty"} {
+		if value := original.Get(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	resolved := r.Clone(r.Context())
+	resolved.URL.RawQuery = query.Encode()
+	return resolved
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/shortlink"
+)
+
+// newShortLinkHandler returns a handler storing short links in a temporary file
+func newShortLinkHandler(t *testing.T) (*Handler, *shortlink.FileStore) {
+	t.Helper()
+	store, err := shortlink.OpenFileStore(filepath.Join(t.TempDir(), "links.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestHandler(t, Options{ShortLinks: store, ShortLinkTTL: time.Hour}), store
+}
+
+func TestShortenAndResolve(t *testing.T) {
+	h, _ := newShortLinkHandler(t)
+	body := `{"type": "vless", "uuid": "` + testUUID + `", "params": {"server": "x.example.com", "port": 8443}}`
+	w := serve("POST /api/v1/shorten", h.ShortenHandler, http.MethodPost, "/api/v1/shorten", body, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("shorten status = %d: %s", w.Code, w.Body)
+	}
+	var resp shortenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !shortlink.IsValidID(resp.ID) {
+		t.Errorf("id = %q, not a short link id", resp.ID)
+	}
+	if resp.URL != "http://example.com/s/"+resp.ID || resp.JSONURL != resp.URL+".json" {
+		t.Errorf("urls = %q, %q", resp.URL, resp.JSONURL)
+	}
+	expires, err := time.Parse(time.RFC3339, resp.ExpiresAt)
+	if err != nil || time.Until(expires) <= 0 || time.Until(expires) > time.Hour {
+		t.Errorf("expires_at = %q, want within -shortlink-ttl", resp.ExpiresAt)
+	}
+
+	// The short link serves the same config as the long download URL
+	got := serve("GET /s/{id}.json", h.ShortLinkDownloadHandler, http.MethodGet, "/s/"+resp.ID+".json", "", nil)
+	if got.Code != http.StatusOK {
+		t.Fatalf("resolve status = %d: %s", got.Code, got.Body)
+	}
+	want := serve("GET /config/{type}/{uuid}.json", h.ConfigDownloadHandler, http.MethodGet, "/config/vless/"+testUUID+".json?server=x.example.com&port=8443", "", nil)
+	if got.Body.String() != want.Body.String() {
+		t.Errorf("short link config differs from the download:\n%s\nwant\n%s", got.Body, want.Body)
+	}
+
+	// Presentation parameters still apply per request
+	xray := serve("GET /s/{id}.json", h.ShortLinkDownloadHandler, http.MethodGet, "/s/"+resp.ID+".json?format=xray", "", nil)
+	if xray.Code != http.StatusOK || !strings.Contains(xray.Body.String(), `"vnext"`) {
+		t.Errorf("format=xray status = %d, want an Xray config: %s", xray.Code, xray.Body)
+	}
+}
+
+func TestShortenRejectsInvalidRequests(t *testing.T) {
+	h, _ := newShortLinkHandler(t)
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"invalid JSON", `{`, http.StatusBadRequest},
+		{"missing type", `{"uuid": "` + testUUID + `"}`, http.StatusBadRequest},
+		{"missing uuid", `{"type": "vless"}`, http.StatusBadRequest},
+		{"invalid uuid", `{"type": "vless", "uuid": "nope"}`, http.StatusBadRequest},
+		{"invalid ttl", `{"type": "vless", "uuid": "` + testUUID + `", "ttl": "-1h"}`, http.StatusBadRequest},
+		{"invalid parameter", `{"type": "vless", "uuid": "` + testUUID + `", "params": {"port": 0}}`, http.StatusBadRequest},
+		{"unknown type", `{"type": "nope", "uuid": "` + testUUID + `"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve("POST /api/v1/shorten", h.ShortenHandler, http.MethodPost, "/api/v1/shorten", tt.body, nil)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestShortLinkNotFound(t *testing.T) {
+	h, store := newShortLinkHandler(t)
+	expired := shortlink.Link{
+		ID:        "Expired1",
+		Type:      "vless",
+		UUID:      testUUID,
+		Config:    config.DefaultDynamicConfig(),
+		CreatedAt: time.Now().Add(-2 * time.Hour),
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}
+	if err := store.Put(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"/s/Unknown1.json", "/s/Expired1.json", "/s/bad-id.json", "/s/" + strings.Repeat("a", 40) + ".json"} {
+		t.Run(target, func(t *testing.T) {
+			w := serve("GET /s/{id}.json", h.ShortLinkDownloadHandler, http.MethodGet, target, "", nil)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want 404: %s", w.Code, w.Body)
+			}
+		})
+	}
+
+	// Without a store short links do not exist
+	h = newTestHandler(t, Options{})
+	w := serve("POST /api/v1/shorten", h.ShortenHandler, http.MethodPost, "/api/v1/shorten", `{"type": "vless", "uuid": "`+testUUID+`"}`, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("shorten without a store status = %d, want 404", w.Code)
+	}
+}
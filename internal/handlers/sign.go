@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/utils"
+)
+
+// defaultSignatureTTL is how long links signed by SignHandler stay valid when no ttl is given
+const defaultSignatureTTL = 30 * 24 * time.Hour
+
+// signRequest is the JSON body accepted by SignHandler
+type signRequest struct {
+	Path   string                 `json:"path"`   // Generation route, e.g. /vless/<uuid> or /sub/<uuid>
+	Params map[string]interface{} `json:"params"` // Query parameters, as in the config API
+	TTL    string                 `json:"ttl"`    // Validity as a Go duration (e.g., 24h); defaults to 30 days
+}
+
+// signResponse is the JSON body returned by SignHandler
+type signResponse struct {
+	URL       string   `json:"url"`
+	ExpiresAt string   `json:"expires_at"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// SignHandler returns a signed absolute URL for a generation route (POST /api/v1/sign)
+func (h *Handler) SignHandler(w http.ResponseWriter, r *http.Request) {
+	if len(h.options.SigningKey) == 0 {
+		h.writeError(w, r, http.StatusNotFound, httperr.CodeNotFound, "", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req signRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		h.logger.WithError(err).Warn("Failed to decode sign request body")
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidBody, "", map[string]string{"error": err.Error()})
+		return
+	}
+
+	target, err := url.Parse(req.Path)
+	if req.Path == "" || err != nil || target.Scheme != "" || target.Host != "" || !strings.HasPrefix(target.Path, "/") {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "path", map[string]string{"error": fmt.Sprintf("path must be a generation route such as /vless/<uuid>, got %q", req.Path)})
+		return
+	}
+
+	ttl := defaultSignatureTTL
+	if req.TTL != "" {
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			h.writeError(w, r, http.StatusBadRequest, httperr.CodeInvalidParameter, "ttl", map[string]string{"error": fmt.Sprintf("ttl must be a positive duration such as 24h, got %q", req.TTL)})
+			return
+		}
+	}
+
+	// Parameters from the body override those already in the path's query
+	query := target.Query()
+	params, warnings := config.ParamsToValues(req.Params)
+	for name, values := range params {
+		query[name] = values
+	}
+	// Signed links are handed to end users and must not carry the admin token
+	query.Del("token")
+
+	expires := time.Now().Add(ttl)
+	signed := utils.SignURL(h.options.SigningKey, signedPath(target.EscapedPath()), query, expires)
+	_, signedQuery, _ := strings.Cut(signed, "?")
+	signedURL := utils.AbsoluteURL(r, h.options.TrustProxy, target.EscapedPath()+"?"+signedQuery)
+
+	h.logger.WithFields(logrus.Fields{
+		"path":        utils.RedactPath(target.Path),
+		"expires_at":  expires.UTC().Format(time.RFC3339),
+		"remote_addr": r.RemoteAddr,
+	}).Info("Signed generation link")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	response := signResponse{URL: signedURL, ExpiresAt: expires.UTC().Format(time.RFC3339), Warnings: warnings}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.WithError(err).Error("Failed to encode sign response")
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/converter"
+	"vless-generator/internal/httperr"
+	"vless-generator/internal/router"
+	"vless-generator/internal/utils"
+)
+
+// formatBase64 is the subscription format of base64-encoded share links, understood by most clients
+const formatBase64 = "base64"
+
+// subscriptionUpdateHours is the Profile-Update-Interval of subscriptions: how often clients refetch them
+const subscriptionUpdateHours = 24
+
+// subscriptionAgents maps User-Agent substrings of clients, lowercased, to the subscription format they expect
+var subscriptionAgents = []struct {
+	substring string
+	format    string
+}{
+	{"clash", formatClash},
+	{"mihomo", formatClash},
+	{"sing-box", formatSingBox},
+	{"sfa/", formatSingBox}, // sing-box for Android
+	{"sfi/", formatSingBox}, // sing-box for iOS
+	{"sfm/", formatSingBox}, // sing-box for macOS
+}
+
+// subscriptionFormat returns the format parameter, or the format the client's User-Agent expects
+// with base64 links for unknown clients; ok is false for unsupported format values
+func subscriptionFormat(r *http.Request) (format string, ok bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case formatBase64, formatClash, formatSingBox:
+		return format, true
+	case "":
+	default:
+		return format, false
+	}
+
+	agent := strings.ToLower(r.Header.Get("User-Agent"))
+	for _, client := range subscriptionAgents {
+		if strings.Contains(agent, client.substring) {
+			return client.format, true
+		}
+	}
+	return formatBase64, true
+}
+
+// SubscriptionHandler serves a subscription of every node of the servers parameter: /sub/<uuid>.
+// The format parameter or the User-Agent selects base64-encoded share URLs (the default), a Clash
+// YAML profile or a sing-box JSON profile.
+func (h *Handler) SubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	configType := "vless"
+	uuid := router.Param(r, "uuid")
+	if !h.checkUUID(w, r, r.URL.Query(), configType, uuid) {
+		return
+	}
+
+	if r.URL.Query().Get("format") == "" {
+		w.Header().Add("Vary", "User-Agent")
+	}
+	format, ok := subscriptionFormat(r)
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeUnsupportedFormat, "format", map[string]string{"format": format, "target": "subscriptions"})
+		return
+	}
+	pretty := true
+	if format == formatSingBox {
+		if pretty, ok = h.prettyParam(w, r, true); !ok {
+			return
+		}
+	}
+
+	// Parse dynamic configuration from query parameters
+	dynamicCfg, paramErrs := config.ParseDynamicConfig(r.URL.Query(), h.options.Defaults)
+	if len(paramErrs) > 0 {
+		h.writeParamErrors(w, r, paramErrs)
+		return
+	}
+
+	// One node per servers entry, the same nodes the multi-server config is built from
+	nodes := dynamicCfg.Nodes()
+	remarks := utils.UniqueRemarks(dynamicCfg.NodeRemarks(configType))
+
+	h.logger.WithFields(logrus.Fields{
+		"config_type": configType,
+		"uuid":        utils.RedactSecret(uuid),
+		"format":      format,
+		"servers":     len(nodes),
+		"remote_addr": r.RemoteAddr,
+	}).Info("Generating subscription with dynamic parameters")
+
+	template, err := h.generate(r, configType, uuid, dynamicCfg)
+	if err != nil {
+		h.handleGenerateError(w, r, err, configType, uuid)
+		return
+	}
+
+	var body []byte
+	switch format {
+	case formatClash:
+		body, err = encodeClashSubscription(template, dynamicCfg, remarks)
+		w.Header().Set("Content-Type", "application/yaml")
+	case formatSingBox:
+		var buf bytes.Buffer
+		err = writeJSON(&buf, template, pretty)
+		body = buf.Bytes()
+		w.Header().Set("Content-Type", "application/json")
+	default:
+		var links []string
+		links, err = utils.GenerateVlessURLs(template, uuid, remarks)
+		// A single-server template may carry more proxy outbounds; the subscription lists one link per node
+		links = links[:min(len(links), len(nodes))]
+		body = []byte(utils.EncodeSubscription(links))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	if errors.Is(err, converter.ErrUnsupportedOutbound) {
+		h.writeError(w, r, http.StatusBadRequest, httperr.CodeUnsupportedFormat, "format", map[string]string{"format": format, "target": configType})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"config_type": configType,
+			"uuid":        utils.RedactSecret(uuid),
+			"format":      format,
+		}).Error("Failed to build subscription")
+		h.writeError(w, r, http.StatusInternalServerError, httperr.CodeInternal, "", nil)
+		return
+	}
+	w.Header().Set("Subscription-Userinfo", "upload=0; download=0; total=0; expire=0")
+	w.Header().Set("Profile-Update-Interval", strconv.Itoa(subscriptionUpdateHours))
+	w.Header().Set("Profile-Web-Page-Url", h.pageURL(r, configType, uuid, r.URL.Query()))
+
+	sent, err := h.writeCacheable(w, r, body)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to write subscription response")
+	}
+	if sent {
+		for _, node := range nodes {
+			h.recordGenerated(r, configType, uuid, node.Host)
+		}
+	}
+}
+
+// encodeClashSubscription converts the nodes of a generated config into a Clash profile as YAML
+func encodeClashSubscription(template map[string]interface{}, dynamicCfg *config.DynamicConfig, remarks []string) ([]byte, error) {
+	profile, err := converter.ToClashNodes(template, dynamicCfg, remarks)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(profile); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSubscriptionBase64(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	const uuid = "bae71742-94e0-4dd5-935f-070339819ba0"
+	target := "/sub/" + uuid + "?servers=" + url.QueryEscape("de@de.example.com:443,nl@nl.example.com:8443,fi.example.com") + "&ws-path=%2Fws"
+	w := serve("GET /sub/{uuid}", h.SubscriptionHandler, http.MethodGet, target, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if w.Header().Get("Profile-Update-Interval") != "24" {
+		t.Errorf("Profile-Update-Interval = %q, want 24", w.Header().Get("Profile-Update-Interval"))
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(w.Body.String())
+	if err != nil {
+		t.Fatalf("body is not base64: %v", err)
+	}
+	lines := strings.Split(string(decoded), "\n")
+	want := []struct{ host, port, remark string }{
+		{"de.example.com", "443", "de"},
+		{"nl.example.com", "8443", "nl"},
+		{"fi.example.com", "443", "fi.example.com-vless"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d links, want %d:\n%s", len(lines), len(want), decoded)
+	}
+	for i, line := range lines {
+		link, err := url.Parse(line)
+		if err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if link.Scheme != "vless" || link.User.Username() != uuid {
+			t.Errorf("line %d: scheme/uuid = %s/%s", i, link.Scheme, link.User.Username())
+		}
+		if link.Hostname() != want[i].host || link.Port() != want[i].port || link.Fragment != want[i].remark {
+			t.Errorf("line %d: %s:%s#%s, want %s:%s#%s", i, link.Hostname(), link.Port(), link.Fragment, want[i].host, want[i].port, want[i].remark)
+		}
+		if path := link.Query().Get("path"); path != "/ws" {
+			t.Errorf("line %d: path = %q, want /ws", i, path)
+		}
+	}
+}
+
+func TestSubscriptionFormat(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	tests := []struct {
+		query       string
+		agent       string
+		contentType string
+		status      int
+	}{
+		{"", "v2rayNG/1.8.5", "text/plain; charset=utf-8", http.StatusOK},
+		{"", "clash-verge/v1.3.8", "application/yaml", http.StatusOK},
+		{"", "SFA/1.9.0", "application/json", http.StatusOK},
+		{"?format=clash", "SFA/1.9.0", "application/yaml", http.StatusOK},
+		{"?format=xml", "", "application/json", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		header := http.Header{"User-Agent": {tt.agent}}
+		w := serve("GET /sub/{uuid}", h.SubscriptionHandler, http.MethodGet, "/sub/bae71742-94e0-4dd5-935f-070339819ba0"+tt.query, "", header)
+		if w.Code != tt.status {
+			t.Errorf("%q %q: status = %d, want %d: %s", tt.query, tt.agent, w.Code, tt.status, w.Body)
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+			t.Errorf("%q %q: Content-Type = %q, want %q", tt.query, tt.agent, got, tt.contentType)
+		}
+	}
+}
+
+func TestSubscriptionGolden(t *testing.T) {
+	h := newTestHandler(t, Options{})
+	target := "/sub/" + testUUID + "?servers=" + url.QueryEscape("de@de.example.com:443,nl@nl.example.com:8443,fi.example.com") + "&ws-path=%2Fws&sni=cdn.example.com"
+	tests := []struct {
+		golden      string
+		query       string
+		agent       string
+		contentType string
+	}{
+		{"sub.base64.txt", "", "v2rayNG/1.8.5", "text/plain; charset=utf-8"},
+		{"sub.clash.yaml", "", "mihomo/1.18.0", "application/yaml"},
+		{"sub.clash.yaml", "&format=clash", "", "application/yaml"},
+		{"sub.sing-box.json", "", "SFA/1.9.0", "application/json"},
+		{"sub.sing-box.json", "&format=sing-box", "curl/8.0", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden+tt.query, func(t *testing.T) {
+			w := serve("GET /sub/{uuid}", h.SubscriptionHandler, http.MethodGet, target+tt.query, "", http.Header{"User-Agent": {tt.agent}})
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := w.Header().Get("Subscription-Userinfo"); got != "upload=0; download=0; total=0; expire=0" {
+				t.Errorf("Subscription-Userinfo = %q", got)
+			}
+			if got := w.Header().Get("Profile-Update-Interval"); got != "24" {
+				t.Errorf("Profile-Update-Interval = %q, want 24", got)
+			}
+
+			body := w.Body.Bytes()
+			if tt.golden == "sub.base64.txt" {
+				// The golden file holds the decoded links so diffs stay readable
+				decoded, err := base64.StdEncoding.DecodeString(w.Body.String())
+				if err != nil {
+					t.Fatalf("body is not base64: %v", err)
+				}
+				body = append(decoded, '\n')
+			}
+			assertGolden(t, tt.golden, body)
+		})
+	}
+}
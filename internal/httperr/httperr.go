@@ -0,0 +1,58 @@
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes returned in JSON error responses
+const (
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodeUnauthorized      = "unauthorized"
+	CodeSignatureRequired = "signature_required"
+	CodeInvalidSignature  = "invalid_signature"
+	CodeSignatureExpired  = "signature_expired"
+	CodeNotFound          = "not_found"
+	CodeTemplateNotFound  = "template_not_found"
+	CodeInvalidBody       = "invalid_body"
+	CodeMissingParameter  = "missing_parameter"
+	CodeInvalidParameter  = "invalid_parameter"
+	CodeInvalidParameters = "invalid_parameters"
+	CodeInvalidUUID       = "invalid_uuid"
+	CodeInvalidCredential = "invalid_credential"
+	CodeUnsupportedFormat = "unsupported_format"
+	CodeNotAcceptable     = "not_acceptable"
+	CodeInvalidShareURL   = "invalid_share_url"
+	CodeContentTooLong    = "content_too_long"
+	CodeBatchTooLarge     = "batch_too_large"
+	CodeTemplateRender    = "template_render_failed"
+	CodeInvalidTemplate   = "invalid_template"
+	CodeMaintenance       = "maintenance"
+	CodeOverloaded        = "overloaded"
+	CodeInternal          = "internal_error"
+)
+
+// Error is a machine-readable API error
+type Error struct {
+	Code    string      `json:"code"`              // Stable error code (e.g., invalid_uuid)
+	Message string      `json:"message"`           // Human-readable, localized message
+	Field   string      `json:"field,omitempty"`   // Offending parameter, when the error concerns one
+	Details interface{} `json:"details,omitempty"` // Extra structured data (e.g., rejected parameters)
+}
+
+func (e Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// response is the JSON envelope written by WriteJSON
+type response struct {
+	Error Error `json:"error"`
+}
+
+// WriteJSON responds with the given status and {"error": err} as JSON
+func WriteJSON(w http.ResponseWriter, status int, err Error) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(response{Error: err})
+}
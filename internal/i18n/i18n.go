@@ -4,6 +4,13 @@ import (
 	"embed"
 	"encoding/json"
 	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -14,69 +21,307 @@ var translationFiles embed.FS
 // Texts represents all translatable strings
 type Texts map[string]string
 
+// Format returns the text of key with every {name} placeholder replaced by args[name].
+// Placeholders without an argument are kept as-is; a missing key yields the key itself.
+func (t Texts) Format(key string, args map[string]string) string {
+	text, ok := t[key]
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, len(args)*2)
+	for name, value := range args {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
 // I18n handles internationalization
 type I18n struct {
+	mu           sync.RWMutex // Guards translations, merged, meta and loadedAt; Reload swaps the maps while requests read them
 	translations map[string]Texts
+	loadedAt     time.Time        // When translations were first stored; zero until then
+	merged       map[string]Texts // Translations merged over the default language, served by GetTexts
+	meta         map[string]languageMeta
+	files        fs.FS
 	logger       *logrus.Entry
+
+	reportedMu sync.Mutex
+	reported   map[string]bool // Missing "<lang>.<key>" pairs already logged
 }
 
-// NewI18n creates a new internationalization manager
+// NewI18n creates a new internationalization manager using the embedded translations
 func NewI18n() *I18n {
+	return NewI18nFS(translationFiles)
+}
+
+// NewI18nFS creates a new internationalization manager reading <lang>.json files from files
+func NewI18nFS(files fs.FS) *I18n {
 	return &I18n{
 		translations: make(map[string]Texts),
+		merged:       make(map[string]Texts),
+		meta:         make(map[string]languageMeta),
+		files:        files,
 		logger:       logrus.WithField("component", "i18n"),
+		reported:     make(map[string]bool),
 	}
 }
 
-// LoadTranslations loads embedded translation files
-func (i *I18n) LoadTranslations() error {
-	i.logger.Info("Loading embedded translation files")
+// languageMetaKey is the translation file key holding language metadata instead of a text
+const languageMetaKey = "_meta"
+
+// languageMeta is the optional metadata of a translation file
+type languageMeta struct {
+	Direction string `json:"direction"` // Text direction: ltr or rtl; derived from the language when empty
+}
+
+// rtlLanguages are written right-to-left when their file does not set a direction
+var rtlLanguages = map[string]bool{"ar": true, "fa": true, "he": true, "ur": true}
 
-	languages := []string{"en", "ru"}
+// DefaultLanguage is served when the requested language is not available; its file is required
+const DefaultLanguage = "en"
 
+// discoverLanguages lists the languages with a <lang>.json file, sorted by code
+func (i *I18n) discoverLanguages() ([]string, error) {
+	matches, err := fs.Glob(i.files, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list translation files: %w", err)
+	}
+	languages := make([]string, 0, len(matches))
+	for _, match := range matches {
+		languages = append(languages, strings.TrimSuffix(path.Base(match), ".json"))
+	}
+	sort.Strings(languages)
+	return languages, nil
+}
+
+// LoadTranslations loads every <lang>.json translation file
+func (i *I18n) LoadTranslations() error {
+	i.logger.Info("Loading translation files")
+
+	languages, err := i.discoverLanguages()
+	if err != nil {
+		return err
+	}
+	loaded := make(map[string]Texts, len(languages))
+	metas := make(map[string]languageMeta, len(languages))
 	for _, lang := range languages {
-		if err := i.loadLanguage(lang); err != nil {
+		texts, meta, err := i.loadLanguage(lang)
+		if err != nil {
 			return fmt.Errorf("failed to load language %s: %w", lang, err)
 		}
+		loaded[lang] = texts
+		metas[lang] = meta
 	}
+	if _, exists := loaded[DefaultLanguage]; !exists {
+		return fmt.Errorf("translation file %s.json for the default language is missing", DefaultLanguage)
+	}
+
+	i.store(loaded, metas)
 
-	i.logger.WithField("languages", len(i.translations)).Info("All translations loaded successfully")
+	i.logger.WithField("languages", len(loaded)).Info("All translations loaded successfully")
 	return nil
 }
 
-// loadLanguage loads a single embedded language file
-func (i *I18n) loadLanguage(language string) error {
+// Reload re-reads every translation file and returns the outcome per file.
+// Languages that fail to load keep serving their previous translations; new
+// files are picked up and languages whose files were removed are dropped,
+// except the default language.
+func (i *I18n) Reload() map[string]error {
+	languages, err := i.discoverLanguages()
+	if err != nil {
+		i.logger.WithError(err).Error("Failed to list translation files, keeping previous translations")
+		return map[string]error{"*.json": err}
+	}
+	results := make(map[string]error, len(languages))
+
+	i.mu.RLock()
+	reloaded := make(map[string]Texts, len(i.translations))
+	metas := make(map[string]languageMeta, len(i.translations))
+	for lang, texts := range i.translations {
+		if lang == DefaultLanguage || containsLanguage(languages, lang) {
+			reloaded[lang] = texts
+			metas[lang] = i.meta[lang]
+		}
+	}
+	i.mu.RUnlock()
+
+	for _, lang := range languages {
+		texts, meta, err := i.loadLanguage(lang)
+		results[lang+".json"] = err
+		if err != nil {
+			i.logger.WithError(err).WithField("language", lang).Error("Failed to reload translation, keeping previous version")
+			continue
+		}
+		reloaded[lang] = texts
+		metas[lang] = meta
+	}
+
+	i.store(reloaded, metas)
+
+	return results
+}
+
+// store swaps in a new set of translations, merging every language over the default
+// language so a missing key falls back to its English text
+func (i *I18n) store(translations map[string]Texts, metas map[string]languageMeta) {
+	i.checkCompleteness(translations)
+
+	base := translations[DefaultLanguage]
+	merged := make(map[string]Texts, len(translations))
+	for lang, texts := range translations {
+		combined := make(Texts, len(base))
+		for key, text := range base {
+			combined[key] = text
+		}
+		for key, text := range texts {
+			combined[key] = text
+		}
+		merged[lang] = combined
+	}
+
+	i.mu.Lock()
+	i.translations = translations
+	i.merged = merged
+	i.meta = metas
+	if i.loadedAt.IsZero() {
+		i.loadedAt = time.Now()
+	}
+	i.mu.Unlock()
+}
+
+// Status reports whether translations have been loaded, for readiness and health checks
+type Status struct {
+	Loaded    bool       `json:"loaded"`
+	LoadedAt  *time.Time `json:"loaded_at,omitempty"`
+	Languages []string   `json:"languages"`
+}
+
+// Status returns the current loading state of the translations
+func (i *I18n) Status() Status {
+	i.mu.RLock()
+	status := Status{Loaded: !i.loadedAt.IsZero()}
+	if status.Loaded {
+		loadedAt := i.loadedAt.UTC()
+		status.LoadedAt = &loadedAt
+	}
+	i.mu.RUnlock()
+
+	status.Languages = i.GetSupportedLanguages()
+	return status
+}
+
+// checkCompleteness compares every language's keys against the default language, logs
+// a summary per language and warns once about every missing key
+func (i *I18n) checkCompleteness(translations map[string]Texts) {
+	base := translations[DefaultLanguage]
+	languages := make([]string, 0, len(translations))
+	for lang := range translations {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+
+	i.reportedMu.Lock()
+	defer i.reportedMu.Unlock()
+
+	for _, lang := range languages {
+		if lang == DefaultLanguage {
+			continue
+		}
+		var missing []string
+		for key := range base {
+			if _, exists := translations[lang][key]; !exists {
+				missing = append(missing, key)
+			}
+		}
+		sort.Strings(missing)
+
+		i.logger.WithFields(logrus.Fields{
+			"language": lang,
+			"keys":     len(base) - len(missing),
+			"total":    len(base),
+			"missing":  len(missing),
+		}).Info("Translation completeness")
+
+		for _, key := range missing {
+			if i.reported[lang+"."+key] {
+				continue
+			}
+			i.reported[lang+"."+key] = true
+			i.logger.WithFields(logrus.Fields{
+				"language": lang,
+				"key":      key,
+			}).Warn("Translation key missing, falling back to English")
+		}
+	}
+}
+
+// containsLanguage reports whether languages contains language
+func containsLanguage(languages []string, language string) bool {
+	for _, lang := range languages {
+		if lang == language {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLanguage reads and parses a single language file and its optional metadata
+func (i *I18n) loadLanguage(language string) (Texts, languageMeta, error) {
 	fileName := language + ".json"
 
 	i.logger.WithFields(logrus.Fields{
 		"language": language,
 		"file":     fileName,
-	}).Debug("Loading embedded translation file")
+	}).Debug("Loading translation file")
 
-	data, err := translationFiles.ReadFile(fileName)
+	data, err := fs.ReadFile(i.files, fileName)
 	if err != nil {
-		return fmt.Errorf("failed to read embedded translation file: %w", err)
+		return nil, languageMeta{}, fmt.Errorf("failed to read translation file: %w", err)
 	}
 
-	var texts Texts
-	if err := json.Unmarshal(data, &texts); err != nil {
-		return fmt.Errorf("failed to parse translation JSON: %w", err)
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, languageMeta{}, fmt.Errorf("failed to parse translation JSON: %w", err)
 	}
 
-	i.translations[language] = texts
+	var meta languageMeta
+	if metaData, exists := raw[languageMetaKey]; exists {
+		if err := json.Unmarshal(metaData, &meta); err != nil {
+			return nil, languageMeta{}, fmt.Errorf("failed to parse %s: %w", languageMetaKey, err)
+		}
+		if meta.Direction != "" && meta.Direction != "ltr" && meta.Direction != "rtl" {
+			return nil, languageMeta{}, fmt.Errorf("invalid %s.direction %q: expected ltr or rtl", languageMetaKey, meta.Direction)
+		}
+		delete(raw, languageMetaKey)
+	}
+
+	texts := make(Texts, len(raw))
+	for key, value := range raw {
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return nil, languageMeta{}, fmt.Errorf("failed to parse translation %s: %w", key, err)
+		}
+		texts[key] = text
+	}
 
 	i.logger.WithField("language", language).Info("Translation loaded successfully")
-	return nil
+	return texts, meta, nil
 }
 
-// GetTexts returns translations for the specified language
+// GetTexts returns translations for the specified language; keys missing from it carry the English text
 func (i *I18n) GetTexts(language string) Texts {
-	if texts, exists := i.translations[language]; exists {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if texts, exists := i.merged[language]; exists {
 		return texts
 	}
 
 	// Fallback to English if requested language is not available
-	if texts, exists := i.translations["en"]; exists {
+	if texts, exists := i.merged[DefaultLanguage]; exists {
 		i.logger.WithField("requested_language", language).Warn("Language not found, falling back to English")
 		return texts
 	}
@@ -86,25 +331,84 @@ func (i *I18n) GetTexts(language string) Texts {
 	return make(Texts)
 }
 
-// GetSupportedLanguages returns list of supported languages
+// GetSupportedLanguages returns the sorted list of supported languages
 func (i *I18n) GetSupportedLanguages() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	languages := make([]string, 0, len(i.translations))
 	for lang := range i.translations {
 		languages = append(languages, lang)
 	}
+	sort.Strings(languages)
 	return languages
 }
 
-// DetectLanguage detects language from query parameter or returns default
-func DetectLanguage(langParam string) string {
-	supportedLanguages := map[string]bool{
-		"en": true,
-		"ru": true,
+// Language describes a loaded language for language selectors
+type Language struct {
+	Code string `json:"code"`
+	Name string `json:"name"`          // The language_name text of the language itself (e.g., Русский)
+	URL  string `json:"url,omitempty"` // Current page in this language; set by LanguageLinks
+}
+
+// GetLanguages returns the loaded languages sorted by code
+func (i *I18n) GetLanguages() []Language {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	languages := make([]Language, 0, len(i.translations))
+	for code, texts := range i.translations {
+		name := texts["language_name"]
+		if name == "" {
+			name = code
+		}
+		languages = append(languages, Language{Code: code, Name: name})
 	}
+	sort.Slice(languages, func(a, b int) bool { return languages[a].Code < languages[b].Code })
+	return languages
+}
+
+// Direction returns the text direction of a language ("ltr" or "rtl"): the _meta.direction
+// of its file when set, otherwise rtl for right-to-left scripts
+func (i *I18n) Direction(language string) string {
+	i.mu.RLock()
+	direction := i.meta[language].Direction
+	i.mu.RUnlock()
+
+	if direction != "" {
+		return direction
+	}
+	if rtlLanguages[language] {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// LanguageLinks returns the loaded languages, each linking to u in that language
+func (i *I18n) LanguageLinks(u *url.URL) []Language {
+	languages := i.GetLanguages()
+	for idx := range languages {
+		languages[idx].URL = LanguageURL(u, languages[idx].Code)
+	}
+	return languages
+}
+
+// LanguageURL returns the path and query of u with only the lang parameter set to
+// language; every other parameter is kept
+func LanguageURL(u *url.URL, language string) string {
+	query := u.Query()
+	query.Set("lang", language)
+	return (&url.URL{Path: u.Path, RawQuery: query.Encode()}).String()
+}
+
+// DetectLanguage returns the requested language when it is loaded, otherwise the default language
+func (i *I18n) DetectLanguage(langParam string) string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 
-	if langParam != "" && supportedLanguages[langParam] {
+	if _, exists := i.translations[langParam]; exists && langParam != "" {
 		return langParam
 	}
 
-	return "en" // Default language
+	return DefaultLanguage
 }
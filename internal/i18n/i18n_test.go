@@ -0,0 +1,112 @@
+package i18n
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// testUUID is the config UUID in the test paths
+const testUUID = "bae71742-94e0-4dd5-935f-070339819ba0"
+
+func TestTextsFormat(t *testing.T) {
+	texts := Texts{
+		"download":   "Download config for {server}",
+		"port_range": "Port must be between {min} and {max}",
+		"repeated":   "{name}, {name}!",
+		"plain":      "No placeholders",
+	}
+	tests := []struct {
+		name string
+		key  string
+		args map[string]string
+		want string
+	}{
+		{"substitutes", "download", map[string]string{"server": "x.example.com"}, "Download config for x.example.com"},
+		{"several args", "port_range", map[string]string{"min": "1", "max": "65535"}, "Port must be between 1 and 65535"},
+		{"repeated placeholder", "repeated", map[string]string{"name": "Bob"}, "Bob, Bob!"},
+		{"missing arg kept", "port_range", map[string]string{"min": "1"}, "Port must be between 1 and {max}"},
+		{"nil args", "download", nil, "Download config for {server}"},
+		{"extra args ignored", "plain", map[string]string{"server": "x.example.com"}, "No placeholders"},
+		{"missing key", "no_such_key", map[string]string{"server": "x.example.com"}, "no_such_key"},
+		{"value is not expanded", "download", map[string]string{"server": "{server}"}, "Download config for {server}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := texts.Format(tt.key, tt.args); got != tt.want {
+				t.Errorf("Format(%q, %v) = %q, want %q", tt.key, tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLanguageURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		lang   string
+		want   string
+	}{
+		{"adds lang", "/vless/" + testUUID + "?server=x.example.com&port=8443", "ru",
+			"/vless/" + testUUID + "?lang=ru&port=8443&server=x.example.com"},
+		{"swaps lang only", "/?lang=en&server=x.example.com", "fa", "/?lang=fa&server=x.example.com"},
+		{"drops duplicate lang values", "/?lang=en&lang=ru&mux=true", "fa", "/?lang=fa&mux=true"},
+		{"keeps escaped values", "/vless/" + testUUID + "?ws-path=%2Fws%3Fed%3D2048&name=My+Node+%231", "ru",
+			"/vless/" + testUUID + "?lang=ru&name=My+Node+%231&ws-path=%2Fws%3Fed%3D2048"},
+		{"keeps repeated params", "/?server=a.example.com&server=b.example.com", "en", "/?lang=en&server=a.example.com&server=b.example.com"},
+		{"escapes the language", "/?server=x.example.com", "x&y=<z>", "/?lang=x%26y%3D%3Cz%3E&server=x.example.com"},
+		{"escapes the path", "/config/my%20node?lang=en", "ru", "/config/my%20node?lang=ru"},
+		{"drops the host", "https://x.example.com/?lang=en", "ru", "/?lang=ru"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := LanguageURL(u, tt.lang)
+			if got != tt.want {
+				t.Errorf("LanguageURL(%s, %q) = %s, want %s", tt.target, tt.lang, got, tt.want)
+			}
+
+			// Every parameter except lang survives unchanged
+			parsed, err := url.Parse(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := parsed.Query()
+			if query.Get("lang") != tt.lang {
+				t.Errorf("lang = %q, want %q", query.Get("lang"), tt.lang)
+			}
+			original := u.Query()
+			original.Del("lang")
+			query.Del("lang")
+			if query.Encode() != original.Encode() {
+				t.Errorf("query = %s, want %s", query.Encode(), original.Encode())
+			}
+		})
+	}
+}
+
+func TestLanguageLinks(t *testing.T) {
+	translations := NewI18nFS(fstest.MapFS{
+		"en.json": {Data: []byte(`{"language_name": "English"}`)},
+		"ru.json": {Data: []byte(`{"language_name": "Русский"}`)},
+	})
+	if err := translations.LoadTranslations(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("/vless/" + testUUID + "?server=x.example.com&lang=en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Language{
+		{Code: "en", Name: "English", URL: "/vless/" + testUUID + "?lang=en&server=x.example.com"},
+		{Code: "ru", Name: "Русский", URL: "/vless/" + testUUID + "?lang=ru&server=x.example.com"},
+	}
+	if got := translations.LanguageLinks(u); !reflect.DeepEqual(got, want) {
+		t.Errorf("LanguageLinks = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+// Package metrics keeps the service counters as expvar variables, published on the debug
+// listener's /debug/vars without any metrics library
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+	"runtime"
+	"strconv"
+
+	"vless-generator/internal/router"
+)
+
+// unmatchedRoute labels requests that matched no route, such as 404 and 405 responses
+const unmatchedRoute = "unmatched"
+
+// statusClasses are the keys of the responses counter
+var statusClasses = []string{"2xx", "3xx", "4xx", "5xx"}
+
+// Counters published on /debug/vars; expvar counters are updated atomically
+var (
+	requestsTotal    = expvar.NewInt("requests")
+	requestsByRoute  = expvar.NewMap("requests_by_route") // Keyed by route pattern, such as "GET /sub/{uuid}"
+	responsesByClass = expvar.NewMap("responses")         // Keyed by status class: 2xx, 3xx, 4xx, 5xx
+	configsGenerated = expvar.NewInt("configs_generated")
+	configsByType    = expvar.NewMap("configs_by_type") // Keyed by template type
+	qrCodes          = expvar.NewInt("qr_codes")        // QR codes rendered by the HTTP handlers
+	qrRejected       = expvar.NewInt("qr_rejected")     // QR codes refused because the worker pool queue was full
+	responseCache    = expvar.NewMap("response_cache")  // Keyed by lookup result: hits, misses
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *statusRecorder) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Middleware counts every request passed to next by route pattern and by status class
+func Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		route := router.Pattern(r)
+		if route == "" {
+			route = unmatchedRoute
+		}
+		requestsTotal.Add(1)
+		requestsByRoute.Add(route, 1)
+		responsesByClass.Add(strconv.Itoa(rw.statusCode/100)+"xx", 1)
+	}
+}
+
+// ConfigGenerated counts a configuration generated from a template type
+func ConfigGenerated(templateType string) {
+	configsGenerated.Add(1)
+	configsByType.Add(templateType, 1)
+}
+
+// QRCodeRendered counts a QR code rendered for a response
+func QRCodeRendered() {
+	qrCodes.Add(1)
+}
+
+// QRCodeRejected counts a QR code refused because the worker pool queue was full
+func QRCodeRejected() {
+	qrRejected.Add(1)
+}
+
+// ResponseCacheLookup counts a response cache hit or miss
+func ResponseCacheLookup(hit bool) {
+	if hit {
+		responseCache.Add("hits", 1)
+	} else {
+		responseCache.Add("misses", 1)
+	}
+}
+
+// RequestCounts returns the number of requests handled since the process started,
+// in total and per status class
+func RequestCounts() map[string]int64 {
+	counts := map[string]int64{"total": requestsTotal.Value()}
+	for _, class := range statusClasses {
+		counts[class] = 0
+		if counter, ok := responsesByClass.Get(class).(*expvar.Int); ok {
+			counts[class] = counter.Value()
+		}
+	}
+	return counts
+}
+
+// ConfigsGenerated returns the number of configurations generated since the process started
+func ConfigsGenerated() int64 {
+	return configsGenerated.Value()
+}
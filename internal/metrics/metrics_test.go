@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vless-generator/internal/router"
+)
+
+// debugVars fetches the expvar variables as served on /debug/vars
+func debugVars(t *testing.T) map[string]json.RawMessage {
+	t.Helper()
+	w := httptest.NewRecorder()
+	expvar.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("decode /debug/vars: %v", err)
+	}
+	return vars
+}
+
+// counter returns the integer variable name, or the key entry of the map variable name when key is set
+func counter(t *testing.T, vars map[string]json.RawMessage, name, key string) int64 {
+	t.Helper()
+	raw, ok := vars[name]
+	if !ok {
+		t.Fatalf("%s is not published", name)
+	}
+	if key == "" {
+		var value int64
+		if err := json.Unmarshal(raw, &value); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return value
+	}
+	var values map[string]int64
+	if err := json.Unmarshal(raw, &values); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return values[key]
+}
+
+func TestMiddlewareCounters(t *testing.T) {
+	mux := router.New()
+	mux.Handle("GET /sub/{uuid}", Middleware(func(w http.ResponseWriter, r *http.Request) {}))
+	mux.Handle("GET /fail", Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	mux.NotFound = Middleware(http.NotFound)
+
+	before := debugVars(t)
+	for _, target := range []string{"/sub/a", "/sub/b", "/fail", "/missing"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	after := debugVars(t)
+
+	deltas := []struct {
+		name, key string
+		want      int64
+	}{
+		{"requests", "", 4},
+		{"requests_by_route", "GET /sub/{uuid}", 2},
+		{"requests_by_route", "GET /fail", 1},
+		{"requests_by_route", unmatchedRoute, 1},
+		{"responses", "2xx", 2},
+		{"responses", "4xx", 1},
+		{"responses", "5xx", 1},
+	}
+	for _, d := range deltas {
+		if got := counter(t, after, d.name, d.key) - counter(t, before, d.name, d.key); got != d.want {
+			t.Errorf("%s[%s] delta = %d, want %d", d.name, d.key, got, d.want)
+		}
+	}
+
+	counts := RequestCounts()
+	if counts["total"] != counter(t, after, "requests", "") || counts["5xx"] != counter(t, after, "responses", "5xx") {
+		t.Errorf("RequestCounts = %v, out of step with /debug/vars", counts)
+	}
+}
+
+func TestEventCounters(t *testing.T) {
+	before := debugVars(t)
+	ConfigGenerated("vless")
+	ConfigGenerated("vless")
+	ConfigGenerated("trojan")
+	QRCodeRendered()
+	QRCodeRejected()
+	ResponseCacheLookup(true)
+	ResponseCacheLookup(false)
+	ResponseCacheLookup(false)
+	after := debugVars(t)
+
+	deltas := []struct {
+		name, key string
+		want      int64
+	}{
+		{"configs_generated", "", 3},
+		{"configs_by_type", "vless", 2},
+		{"configs_by_type", "trojan", 1},
+		{"qr_codes", "", 1},
+		{"qr_rejected", "", 1},
+		{"response_cache", "hits", 1},
+		{"response_cache", "misses", 2},
+	}
+	for _, d := range deltas {
+		if got := counter(t, after, d.name, d.key) - counter(t, before, d.name, d.key); got != d.want {
+			t.Errorf("%s[%s] delta = %d, want %d", d.name, d.key, got, d.want)
+		}
+	}
+	if ConfigsGenerated() != counter(t, after, "configs_generated", "") {
+		t.Errorf("ConfigsGenerated = %d, out of step with /debug/vars", ConfigsGenerated())
+	}
+	if goroutines := counter(t, after, "goroutines", ""); goroutines < 1 {
+		t.Errorf("goroutines = %d", goroutines)
+	}
+}
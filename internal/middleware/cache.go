@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vless-generator/internal/metrics"
+)
+
+// maxCachedBody is the largest response body kept by ResponseCache
+const maxCachedBody = 1 << 20
+
+// ResponseCache keeps successful responses in memory for a TTL so identical requests skip
+// rendering and generation. Entries are keyed by method, path and sorted query, which holds
+// the lang parameter, and evicted least recently used first. A nil *ResponseCache caches nothing.
+type ResponseCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Values are *cachedResponse
+	order   *list.List               // Most recently used first
+}
+
+// cachedResponse is a stored response with the headers set by the handler
+type cachedResponse struct {
+	key      string
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+}
+
+// NewResponseCache creates a cache keeping at most maxEntries responses for ttl each
+func NewResponseCache(ttl time.Duration, maxEntries int) *ResponseCache {
+	return &ResponseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Purge drops every cached response, for example after templates or translations change
+func (c *ResponseCache) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Middleware returns a wrapper answering repeated requests from the cache. Handlers whose
+// output depends on request headers name them in vary, such as Accept for content
+// negotiation or Host for absolute links, so their values become part of the key. Only 200 responses without
+// Cache-Control: no-store are stored; hits carry an Age header and answer If-None-Match
+// with 304 like the handler would. On a nil cache the wrapper returns handlers unchanged.
+func (c *ResponseCache) Middleware(vary ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if c == nil {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := cacheKey(r, vary)
+			if cached, ok := c.get(key); ok {
+				metrics.ResponseCacheLookup(true)
+				writeCached(w, r, cached)
+				return
+			}
+			metrics.ResponseCacheLookup(false)
+
+			rec := &cacheRecorder{ResponseWriter: w, header: make(http.Header), statusCode: http.StatusOK}
+			next(rec, r)
+			if !rec.wroteHeader {
+				rec.WriteHeader(http.StatusOK)
+			}
+			if rec.cacheable() {
+				c.put(&cachedResponse{key: key, header: rec.header, body: rec.body, storedAt: time.Now()})
+			}
+		}
+	}
+}
+
+// cacheKey joins the method, path, sorted query and the vary request headers
+func cacheKey(r *http.Request, vary []string) string {
+	var key strings.Builder
+	key.WriteString(r.Method)
+	key.WriteByte(' ')
+	key.WriteString(r.URL.Path)
+	key.WriteByte('?')
+	key.WriteString(r.URL.Query().Encode())
+	for _, name := range vary {
+		key.WriteByte('\n')
+		if name == "Host" {
+			// Go moves the Host header into the request
+			key.WriteString(r.Host)
+			continue
+		}
+		key.WriteString(r.Header.Get(name))
+	}
+	return key.String()
+}
+
+// get returns the fresh entry stored under key, dropping it when expired
+func (c *ResponseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cached := element.Value.(*cachedResponse)
+	if time.Since(cached.storedAt) >= c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return cached, true
+}
+
+// put stores a response, evicting the least recently used ones beyond maxEntries
+func (c *ResponseCache) put(cached *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[cached.key]; ok {
+		element.Value = cached
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[cached.key] = c.order.PushFront(cached)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// writeCached answers a request from a stored response
+func writeCached(w http.ResponseWriter, r *http.Request, cached *cachedResponse) {
+	header := w.Header()
+	for name, values := range cached.header {
+		header[name] = append(header[name], values...)
+	}
+	header.Set("Age", strconv.Itoa(int(time.Since(cached.storedAt).Seconds())))
+	if etag := cached.header.Get("ETag"); etag != "" && ETagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(cached.body)
+}
+
+// ETagMatches reports whether an If-None-Match header matches etag. It uses the weak
+// comparison required for If-None-Match, so the W/ form set by compression still matches.
+func ETagMatches(ifNoneMatch, etag string) bool {
+	ifNoneMatch = strings.TrimSpace(ifNoneMatch)
+	if ifNoneMatch == "" {
+		return false
+	}
+	if ifNoneMatch == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheRecorder passes a response through while keeping a copy of the headers the handler
+// set and of the body. Headers set by outer middleware, such as CORS, stay out of the copy.
+type cacheRecorder struct {
+	http.ResponseWriter
+	header      http.Header
+	statusCode  int
+	wroteHeader bool
+	body        []byte
+	tooLarge    bool
+}
+
+func (rec *cacheRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *cacheRecorder) WriteHeader(code int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.statusCode = code
+	header := rec.ResponseWriter.Header()
+	for name, values := range rec.header {
+		header[name] = append(header[name], values...)
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *cacheRecorder) Write(data []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	if !rec.tooLarge {
+		if len(rec.body)+len(data) > maxCachedBody {
+			rec.tooLarge, rec.body = true, nil
+		} else {
+			rec.body = append(rec.body, data...)
+		}
+	}
+	return rec.ResponseWriter.Write(data)
+}
+
+// cacheable reports whether the recorded response may be stored
+func (rec *cacheRecorder) cacheable() bool {
+	return rec.statusCode == http.StatusOK && !rec.tooLarge &&
+		!strings.Contains(rec.header.Get("Cache-Control"), "no-store")
+}
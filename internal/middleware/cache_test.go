@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingHandler answers with a JSON body naming the host and counts its calls
+func countingHandler(calls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_ = json.NewEncoder(w).Encode(map[string]string{"base_url": "http://" + r.Host + "/config"})
+	}
+}
+
+func TestResponseCacheHit(t *testing.T) {
+	calls := 0
+	handler := NewResponseCache(time.Minute, 10).Middleware()(countingHandler(&calls))
+
+	first := httptest.NewRecorder()
+	handler(first, httptest.NewRequest(http.MethodGet, "/vless/u?lang=en&b=2", nil))
+	second := httptest.NewRecorder()
+	handler(second, httptest.NewRequest(http.MethodGet, "/vless/u?b=2&lang=en", nil))
+
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if second.Body.String() != first.Body.String() || second.Header().Get("Age") == "" {
+		t.Fatalf("cached response = %q (Age %q), want %q with Age", second.Body, second.Header().Get("Age"), first.Body)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/vless/u?b=2&lang=en", nil)
+	r.Header.Set("If-None-Match", `W/"v1"`)
+	notModified := httptest.NewRecorder()
+	handler(notModified, r)
+	if notModified.Code != http.StatusNotModified {
+		t.Fatalf("revalidation status = %d, want 304", notModified.Code)
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	calls := 0
+	handler := NewResponseCache(time.Minute, 10).Middleware("Host", "X-Forwarded-Host", "X-Forwarded-Proto")(countingHandler(&calls))
+
+	requests := []func(*http.Request){
+		func(r *http.Request) { r.Host = "a.example.com" },
+		func(r *http.Request) { r.Host = "b.example.com" },
+		func(r *http.Request) { r.Host = "a.example.com"; r.Header.Set("X-Forwarded-Host", "c.example.com") },
+		func(r *http.Request) { r.Host = "a.example.com"; r.Header.Set("X-Forwarded-Proto", "https") },
+	}
+	for i, prepare := range requests {
+		r := httptest.NewRequest(http.MethodGet, "/vless/u", nil)
+		prepare(r)
+		handler(httptest.NewRecorder(), r)
+		if calls != i+1 {
+			t.Fatalf("request %d served from another host's entry", i)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/vless/u", nil)
+	r.Host = "b.example.com"
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if calls != len(requests) {
+		t.Fatal("repeated host missed the cache")
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["base_url"] != "http://b.example.com/config" {
+		t.Fatalf("cached body = %s, want links of b.example.com", w.Body)
+	}
+}
+
+func TestResponseCacheSkipsUncacheable(t *testing.T) {
+	calls := 0
+	cache := NewResponseCache(time.Minute, 10)
+	handler := cache.Middleware()(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("fail") != "" {
+			http.Error(w, "bad", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte("secret"))
+	})
+	for _, target := range []string{"/a?fail=1", "/a?fail=1", "/b", "/b"} {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	if calls != 4 {
+		t.Fatalf("handler called %d times, want 4: errors and no-store responses must not be cached", calls)
+	}
+}
+
+func TestResponseCacheExpiryAndEviction(t *testing.T) {
+	calls := 0
+	cache := NewResponseCache(time.Minute, 2)
+	handler := cache.Middleware()(countingHandler(&calls))
+	get := func(target string) {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	get("/1")
+	get("/2")
+	get("/3") // Evicts /1
+	get("/1")
+	if calls != 4 {
+		t.Fatalf("handler called %d times, want 4 after eviction", calls)
+	}
+
+	cache.Purge()
+	get("/1")
+	if calls != 5 {
+		t.Fatal("purged entry still served")
+	}
+
+	expiring := NewResponseCache(time.Nanosecond, 10).Middleware()(countingHandler(&calls))
+	expiring(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	time.Sleep(time.Millisecond)
+	expiring(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	if calls != 7 {
+		t.Fatal("expired entry still served")
+	}
+}
+
+func TestNilResponseCache(t *testing.T) {
+	var cache *ResponseCache
+	calls := 0
+	handler := cache.Middleware()(countingHandler(&calls))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	cache.Purge()
+	if calls != 2 {
+		t.Fatalf("nil cache: handler called %d times, want 2", calls)
+	}
+}
+
+// discardWriter is a ResponseWriter that throws the response away, so benchmarks measure
+// the handler rather than a recorder
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// renderConfig stands in for config generation: it builds and encodes a sing-box sized document
+func renderConfig(w http.ResponseWriter, r *http.Request) {
+	outbounds := make([]map[string]interface{}, 0, 8)
+	for i := 0; i < 8; i++ {
+		outbounds = append(outbounds, map[string]interface{}{
+			"type": "vless", "tag": "proxy", "server": r.Host, "server_port": 443,
+			"tls": map[string]interface{}{"enabled": true, "server_name": r.Host, "alpn": []string{"h2", "http/1.1"}},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"outbounds": outbounds, "route": map[string]interface{}{"final": "proxy"}})
+}
+
+func BenchmarkResponseCache(b *testing.B) {
+	r := httptest.NewRequest(http.MethodGet, "/vless/bae71742-94e0-4dd5-935f-070339819ba0?server=x.example.com&lang=en", nil)
+	run := func(b *testing.B, handler http.HandlerFunc) {
+		handler(&discardWriter{header: make(http.Header)}, r)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			handler(&discardWriter{header: make(http.Header)}, r)
+		}
+	}
+	b.Run("uncached", func(b *testing.B) {
+		run(b, renderConfig)
+	})
+	b.Run("hit", func(b *testing.B) {
+		run(b, NewResponseCache(time.Hour, 100).Middleware("Host")(renderConfig))
+	})
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{`"abc"`, `"abc"`, true},
+		{`W/"abc"`, `"abc"`, true},
+		{`"abc"`, `W/"abc"`, true},
+		{`W/"abc"`, `W/"abc"`, true},
+		{`"x", "abc"`, `"abc"`, true},
+		{` "x" ,W/"abc" `, `"abc"`, true},
+		{`*`, `"abc"`, true},
+		{``, `"abc"`, false},
+		{`"abd"`, `"abc"`, false},
+		{`abc`, `"abc"`, false},
+		{`"x", "y"`, `"abc"`, false},
+	}
+	for _, tt := range tests {
+		if got := ETagMatches(tt.ifNoneMatch, tt.etag); got != tt.want {
+			t.Errorf("ETagMatches(%q, %q) = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+		}
+	}
+}
+
+func TestCompressWeakensETag(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		etag           string
+		want           string
+	}{
+		{"gzip", "gzip", "application/json", `"abc"`, `W/"abc"`},
+		{"deflate", "deflate", "application/json", `"abc"`, `W/"abc"`},
+		{"identity", "", "application/json", `"abc"`, `"abc"`},
+		{"already weak", "gzip", "application/json", `W/"abc"`, `W/"abc"`},
+		{"not compressed", "gzip", "image/png", `"abc"`, `"abc"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Compress(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("ETag", tt.etag)
+				_, _ = w.Write([]byte(`{"ok":true}`))
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if got := w.Header().Get("ETag"); got != tt.want {
+				t.Errorf("ETag = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes are the response media types worth compressing; images and
+// archives (QR code PNGs, zip bundles) are already compressed
+var compressibleTypes = map[string]bool{
+	"text/html":        true,
+	"application/json": true,
+	"application/yaml": true,
+}
+
+// gzipWriters reuses gzip writers across responses
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// deflateWriters hold zlib writers: the HTTP deflate coding is the zlib format, not raw deflate
+var deflateWriters = sync.Pool{
+	New: func() interface{} {
+		return zlib.NewWriter(io.Discard)
+	},
+}
+
+// Compress compresses HTML, JSON and YAML responses with gzip or deflate, as accepted by the client
+func Compress(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       negotiateEncoding(r.Header.Get("Accept-Encoding")),
+			head:           r.Method == http.MethodHead,
+		}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header, or "" for identity
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, entry := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > 0 {
+			accepted[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+	switch {
+	case accepted["gzip"] || accepted["*"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// compressWriter decides on the first write whether the response is compressed,
+// based on the Content-Type the handler set
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string // Negotiated encoding; empty when the client accepts none
+	head     bool   // HEAD requests have no body to compress
+
+	decided bool
+	writer  io.WriteCloser // Compressor, nil when the response is written as-is
+}
+
+// decide sets the encoding headers and creates the compressor for compressible responses
+func (cw *compressWriter) decide(status int) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+
+	header := cw.Header()
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if !compressibleTypes[mediaType] || header.Get("Content-Encoding") != "" {
+		return
+	}
+	header.Add("Vary", "Accept-Encoding")
+	if cw.encoding == "" || cw.head || status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length")
+	// The encoded bytes differ from the identity ones, so a strong validator becomes weak
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		header.Set("ETag", "W/"+etag)
+	}
+	switch cw.encoding {
+	case "gzip":
+		writer := gzipWriters.Get().(*gzip.Writer)
+		writer.Reset(cw.ResponseWriter)
+		cw.writer = writer
+	case "deflate":
+		writer := deflateWriters.Get().(*zlib.Writer)
+		writer.Reset(cw.ResponseWriter)
+		cw.writer = writer
+	}
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	cw.decide(code)
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	cw.decide(http.StatusOK)
+	if cw.writer == nil {
+		return cw.ResponseWriter.Write(data)
+	}
+	return cw.writer.Write(data)
+}
+
+// close flushes the compressed stream and returns the compressor to its pool
+func (cw *compressWriter) close() {
+	if cw.writer == nil {
+		return
+	}
+	cw.writer.Close()
+	switch writer := cw.writer.(type) {
+	case *gzip.Writer:
+		writer.Reset(io.Discard)
+		gzipWriters.Put(writer)
+	case *zlib.Writer:
+		writer.Reset(io.Discard)
+		deflateWriters.Put(writer)
+	}
+	cw.writer = nil
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsMaxAge is how long browsers may cache a preflight answer, in seconds
+const corsMaxAge = "600"
+
+// NewCORSMiddleware returns a middleware allowing cross-origin requests from the given
+// origins ("*" allows any origin). With no origins it leaves handlers unchanged.
+func NewCORSMiddleware(origins []string) func(http.HandlerFunc) http.HandlerFunc {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if len(allowed) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Responses differ by origin, so caches must key on it even for same-origin requests
+			header := w.Header()
+			header.Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			switch {
+			case allowed["*"]:
+				header.Set("Access-Control-Allow-Origin", "*")
+			case allowed[origin]:
+				header.Set("Access-Control-Allow-Origin", origin)
+			case preflight:
+				// Answer without CORS headers; the browser then blocks the actual request
+				w.WriteHeader(http.StatusNoContent)
+				return
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if preflight {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+				header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+					header.Set("Access-Control-Allow-Headers", requested)
+				}
+				header.Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			// Let scripts read the download file name
+			header.Set("Access-Control-Expose-Headers", "Content-Disposition")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	const admin = "https://admin.example.com"
+	tests := []struct {
+		name        string
+		origins     []string
+		method      string
+		header      http.Header
+		wantStatus  int
+		wantOrigin  string // Access-Control-Allow-Origin; empty when the origin is refused
+		wantMethods bool   // Access-Control-Allow-Methods is set
+		wantHandled bool   // the wrapped handler ran
+	}{
+		{"allowed origin", []string{admin}, http.MethodGet, http.Header{"Origin": {admin}}, http.StatusOK, admin, false, true},
+		{"allowed origin with trailing slash in the flag", []string{admin + "/"}, http.MethodGet, http.Header{"Origin": {admin}}, http.StatusOK, admin, false, true},
+		{"allowed preflight", []string{admin}, http.MethodOptions, http.Header{"Origin": {admin}, "Access-Control-Request-Method": {"POST"}, "Access-Control-Request-Headers": {"Content-Type, Authorization"}}, http.StatusNoContent, admin, true, false},
+		{"disallowed origin", []string{admin}, http.MethodGet, http.Header{"Origin": {"https://evil.example.com"}}, http.StatusOK, "", false, true},
+		{"disallowed preflight", []string{admin}, http.MethodOptions, http.Header{"Origin": {"https://evil.example.com"}, "Access-Control-Request-Method": {"POST"}}, http.StatusNoContent, "", false, false},
+		{"similar origin", []string{admin}, http.MethodGet, http.Header{"Origin": {admin + ".evil.com"}}, http.StatusOK, "", false, true},
+		{"same-origin request", []string{admin}, http.MethodGet, nil, http.StatusOK, "", false, true},
+		{"wildcard", []string{"*"}, http.MethodGet, http.Header{"Origin": {"https://any.example.com"}}, http.StatusOK, "*", false, true},
+		{"wildcard preflight", []string{"*"}, http.MethodOptions, http.Header{"Origin": {"https://any.example.com"}, "Access-Control-Request-Method": {"GET"}}, http.StatusNoContent, "*", true, false},
+		{"plain OPTIONS is not a preflight", []string{admin}, http.MethodOptions, http.Header{"Origin": {admin}}, http.StatusOK, admin, false, true},
+		{"disabled", nil, http.MethodGet, http.Header{"Origin": {admin}}, http.StatusOK, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled := false
+			handler := NewCORSMiddleware(tt.origins)(func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+				w.WriteHeader(http.StatusOK)
+			})
+			r := httptest.NewRequest(tt.method, "/api/v1/config", nil)
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if handled != tt.wantHandled {
+				t.Errorf("handler ran = %v, want %v", handled, tt.wantHandled)
+			}
+			header := w.Header()
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := header.Get("Access-Control-Allow-Methods") != ""; got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods set = %v, want %v", got, tt.wantMethods)
+			}
+			if tt.wantOrigin == "" && header.Get("Access-Control-Expose-Headers") != "" {
+				t.Errorf("refused origin got Access-Control-Expose-Headers")
+			}
+			if tt.wantOrigin != "" && !tt.wantMethods && header.Get("Access-Control-Expose-Headers") != "Content-Disposition" {
+				t.Errorf("Access-Control-Expose-Headers = %q, want Content-Disposition", header.Get("Access-Control-Expose-Headers"))
+			}
+			if tt.origins != nil && header.Get("Vary") != "Origin" {
+				t.Errorf("Vary = %q, want Origin", header.Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSPreflightHeaders(t *testing.T) {
+	handler := NewCORSMiddleware([]string{"https://admin.example.com"})(func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest(http.MethodOptions, "/api/v1/config", nil)
+	r.Header.Set("Origin", "https://admin.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "Content-Type, Authorization")
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		"Access-Control-Max-Age":       corsMaxAge,
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	vary := w.Header().Values("Vary")
+	if len(vary) != 3 {
+		t.Errorf("Vary = %v, want Origin and the request method and headers", vary)
+	}
+}
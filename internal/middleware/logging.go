@@ -1,10 +1,17 @@
 package middleware
 
 import (
+	"math/rand"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/utils"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -25,8 +32,29 @@ func (rw *responseWriter) Write(data []byte) (int, error) {
 	return n, err
 }
 
-// LoggingMiddleware provides structured HTTP request logging
-func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// staticPrefix is the path prefix of embedded static assets, subject to LoggerOptions.StaticSampleRate
+const staticPrefix = "/static/"
+
+// LoggerOptions configures the access log written by Logger
+type LoggerOptions struct {
+	TrustedProxies   []netip.Prefix // Peers whose forwarded headers are believed for the client IP
+	DebugPaths       []string       // Path prefixes whose successful requests are logged at Debug instead of Info
+	StaticSampleRate float64        // Fraction of successful /static/ requests that are logged (1 logs all, 0 none)
+}
+
+// Logger is a structured HTTP access logger
+type Logger struct {
+	options LoggerOptions
+}
+
+// NewLogger creates an access logger with the given options
+func NewLogger(options LoggerOptions) *Logger {
+	return &Logger{options: options}
+}
+
+// Middleware logs every request passed to next. Client and server errors are always
+// logged at Warn and Error; quieter levels and sampling only apply to successful requests.
+func (l *Logger) Middleware(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
@@ -39,17 +67,21 @@ func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		// Process the request
 		next.ServeHTTP(rw, r)
 
+		if rw.statusCode < 400 && !l.sampled(r.URL.Path) {
+			return
+		}
+
 		// Log the request with structured fields
 		duration := time.Since(start)
 
 		logEntry := logrus.WithFields(logrus.Fields{
 			"method":        r.Method,
-			"path":          r.URL.Path,
-			"query":         r.URL.RawQuery,
+			"path":          utils.RedactPath(r.URL.Path),
+			"query":         redactQuery(r.URL.RawQuery),
 			"status_code":   rw.statusCode,
 			"duration_ms":   duration.Milliseconds(),
 			"bytes_written": rw.written,
-			"remote_addr":   getClientIP(r),
+			"remote_addr":   ClientIP(r, l.options.TrustedProxies),
 			"user_agent":    r.UserAgent(),
 			"referer":       r.Referer(),
 		})
@@ -60,6 +92,8 @@ func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			logEntry.Error("HTTP request completed with server error")
 		case rw.statusCode >= 400:
 			logEntry.Warn("HTTP request completed with client error")
+		case l.debugPath(r.URL.Path):
+			logEntry.Debug("HTTP request completed")
 		case rw.statusCode >= 300:
 			logEntry.Info("HTTP request completed with redirect")
 		default:
@@ -68,15 +102,117 @@ func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	})
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check for forwarded headers first
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
+// debugPath reports whether successful requests to path are logged at Debug
+func (l *Logger) debugPath(path string) bool {
+	for _, prefix := range l.options.DebugPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampled reports whether a successful request to path is logged
+func (l *Logger) sampled(path string) bool {
+	if !strings.HasPrefix(path, staticPrefix) || l.options.StaticSampleRate >= 1 {
+		return true
 	}
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+	return rand.Float64() < l.options.StaticSampleRate
+}
+
+// redactedParams are query parameters whose values never reach the access log: access
+// tokens, signatures and the secrets config.Config.Redacted hides as well
+var redactedParams = []string{"token", "sig", "warp-private-key", "clash-api-secret", "obfs-password"}
+
+// secretParams are query parameters masked with utils.RedactSecret, unless redaction is off
+var secretParams = []string{"uuid"}
+
+// redactQuery replaces the values of credential parameters in a raw query string
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	// Values parsed before a malformed pair are still returned alongside the error
+	query, err := url.ParseQuery(rawQuery)
+	redacted := false
+	for _, name := range redactedParams {
+		if _, ok := query[name]; ok {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	for _, name := range secretParams {
+		for i, value := range query[name] {
+			if masked := utils.RedactSecret(value); masked != value {
+				query[name][i] = masked
+				redacted = true
+			}
+		}
+	}
+	// A malformed query is re-encoded too, since a credential may hide in the dropped pairs
+	if !redacted && err == nil {
+		return rawQuery
+	}
+	return query.Encode()
+}
+
+// ClientIP returns the client IP address of the request. Forwarded headers are ignored
+// unless the direct peer is a trusted proxy; X-Forwarded-For is then read right-to-left,
+// skipping trusted hops, so addresses prepended by the client cannot spoof the result.
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	peer, ok := parseIP(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !isTrusted(peer, trusted) {
+		return peer.String()
+	}
+
+	// Every proxy appends the address it received the request from
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseIP(strings.TrimSpace(hops[i]))
+		if !ok {
+			// A malformed entry was not written by a trusted proxy; keep the last hop that was
+			return client.String()
+		}
+		client = hop
+		if !isTrusted(hop, trusted) {
+			return hop.String()
+		}
+	}
+	if len(hops) > 0 {
+		return client.String()
+	}
+
+	if realIP, ok := parseIP(r.Header.Get("X-Real-IP")); ok {
+		return realIP.String()
+	}
+	return peer.String()
+}
+
+// parseIP parses an IP address with an optional port (e.g., a RemoteAddr)
+func parseIP(value string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// isTrusted reports whether addr belongs to one of the trusted proxy ranges
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
 	}
-	// Fall back to remote address
-	return r.RemoteAddr
+	return false
 }
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"vless-generator/internal/utils"
+)
+
+// testUUID is the config UUID in the test requests
+const testUUID = "bae71742-94e0-4dd5-935f-070339819ba0"
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("127.0.0.1/32"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string // X-Forwarded-For header lines
+		realIP     string
+		trusted    []netip.Prefix
+		want       string
+	}{
+		{"direct client", "203.0.113.7:51000", nil, "", trusted, "203.0.113.7"},
+		{"untrusted peer spoofs xff", "203.0.113.7:51000", []string{"1.2.3.4"}, "", trusted, "203.0.113.7"},
+		{"untrusted peer spoofs x-real-ip", "203.0.113.7:51000", nil, "1.2.3.4", trusted, "203.0.113.7"},
+		{"no trusted proxies configured", "10.0.0.1:51000", []string{"198.51.100.9"}, "", nil, "10.0.0.1"},
+		{"trusted proxy", "10.0.0.1:51000", []string{"198.51.100.9"}, "", trusted, "198.51.100.9"},
+		{"client prepends a spoofed hop", "10.0.0.1:51000", []string{"1.2.3.4, 198.51.100.9"}, "", trusted, "198.51.100.9"},
+		{"spoofed hop in a separate header line", "10.0.0.1:51000", []string{"1.2.3.4", "198.51.100.9"}, "", trusted, "198.51.100.9"},
+		{"trusted hops skipped", "127.0.0.1:51000", []string{"198.51.100.9, 10.1.2.3, 10.0.0.5"}, "", trusted, "198.51.100.9"},
+		{"spoofed trusted address before the real client", "10.0.0.1:51000", []string{"10.9.9.9, 198.51.100.9"}, "", trusted, "198.51.100.9"},
+		{"all hops trusted", "10.0.0.1:51000", []string{"10.0.0.2, 10.0.0.3"}, "", trusted, "10.0.0.2"},
+		{"malformed hop", "10.0.0.1:51000", []string{"198.51.100.9, not-an-ip, 10.0.0.2"}, "", trusted, "10.0.0.2"},
+		{"malformed last hop", "10.0.0.1:51000", []string{"198.51.100.9, <script>"}, "", trusted, "10.0.0.1"},
+		{"hop with port", "10.0.0.1:51000", []string{"198.51.100.9:4711"}, "", trusted, "198.51.100.9"},
+		{"ipv6 hop", "[fd00::1]:51000", []string{"2001:db8::9"}, "", trusted, "2001:db8::9"},
+		{"bracketed ipv6 hop with port", "10.0.0.1:51000", []string{"[2001:db8::9]:4711"}, "", trusted, "2001:db8::9"},
+		{"ipv4-mapped peer", "[::ffff:10.0.0.1]:51000", []string{"198.51.100.9"}, "", trusted, "198.51.100.9"},
+		{"x-real-ip from trusted proxy", "10.0.0.1:51000", nil, "198.51.100.9", trusted, "198.51.100.9"},
+		{"xff wins over x-real-ip", "10.0.0.1:51000", []string{"198.51.100.9"}, "1.2.3.4", trusted, "198.51.100.9"},
+		{"malformed x-real-ip", "10.0.0.1:51000", nil, "nope", trusted, "10.0.0.1"},
+		{"unparsable remote addr", "@unix", []string{"198.51.100.9"}, "", trusted, "@unix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := ClientIP(r, tt.trusted); got != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		redact bool
+		want   string
+	}{
+		{"empty", "", true, ""},
+		{"nothing to redact", "server=x.example.com&port=443", true, "server=x.example.com&port=443"},
+		{"uuid", "uuid=" + testUUID + "&server=x.example.com", true, "server=x.example.com&uuid=bae71742%E2%80%A6"},
+		{"repeated uuid", "uuid=" + testUUID + "&uuid=0123456789ab", true, "uuid=bae71742%E2%80%A6&uuid=01234567%E2%80%A6"},
+		{"token and sig", "token=secret&sig=abcdef&exp=1767225600", true, "exp=1767225600&sig=REDACTED&token=REDACTED"},
+		{"warp private key", "warp=true&warp-private-key=cGFzc3dvcmQ%3D", true, "warp=true&warp-private-key=REDACTED"},
+		{"clash api secret and obfs password", "clash-api-secret=s3cret&obfs-password=hunter2&up=100", true, "clash-api-secret=REDACTED&obfs-password=REDACTED&up=100"},
+		{"token with redaction off", "token=secret", false, "token=REDACTED"},
+		{"obfs password with redaction off", "obfs-password=hunter2", false, "obfs-password=REDACTED"},
+		{"uuid with redaction off", "uuid=" + testUUID, false, "uuid=" + testUUID},
+		{"malformed pair", "token=secret&bad=%zz", true, "token=REDACTED"},
+		{"malformed without secrets", "server=x&bad=%zz", true, "server=x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utils.SetSecretRedaction(tt.redact)
+			defer utils.SetSecretRedaction(true)
+			if got := redactQuery(tt.query); got != tt.want {
+				t.Errorf("redactQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerRedactsFields(t *testing.T) {
+	// The access log writes to the standard logger
+	logrus.SetOutput(io.Discard)
+	hook := test.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(logrus.LevelHooks{})
+
+	handler := NewLogger(LoggerOptions{}).Middleware(func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest(http.MethodGet, "/config/vless/"+testUUID+".json?uuid="+testUUID+"&token=secret&server=x.example.com", nil)
+	handler(httptest.NewRecorder(), r)
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("no access log entry")
+	}
+	if got := entry.Data["path"]; got != "/config/vless/bae71742….json" {
+		t.Errorf("path = %v, want the UUID masked", got)
+	}
+	query, _ := entry.Data["query"].(string)
+	if strings.Contains(query, testUUID) || strings.Contains(query, "secret") {
+		t.Errorf("query = %q leaks a credential", query)
+	}
+	for name, value := range entry.Data {
+		if s, ok := value.(string); ok && strings.Contains(s, testUUID) {
+			t.Errorf("field %s = %q leaks the UUID", name, s)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package openapi builds the OpenAPI 3 description of the service's HTTP endpoints
+package openapi
+
+import (
+	"reflect"
+	"strings"
+
+	"vless-generator/internal/config"
+)
+
+// Version is the OpenAPI specification version of the generated document
+const Version = "3.0.3"
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps lowercase HTTP methods to operations
+type PathItem map[string]*Operation
+
+// Operation describes one method of a path
+type Operation struct {
+	Summary     string                `json:"summary"`
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path or query
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+	Style       string  `json:"style,omitempty"`
+	Explode     *bool   `json:"explode,omitempty"`
+}
+
+// Schema is a subset of the OpenAPI schema object
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Maximum              *int               `json:"maximum,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // true or a *Schema
+}
+
+// RequestBody describes the body of an operation
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response describes one response status
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Components holds reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how access tokens are passed
+type SecurityScheme struct {
+	Type   string `json:"type"`             // http or apiKey
+	Scheme string `json:"scheme,omitempty"` // bearer for http schemes
+	Name   string `json:"name,omitempty"`   // Parameter name for apiKey schemes
+	In     string `json:"in,omitempty"`     // query for apiKey schemes
+}
+
+// SecurityRequirement maps security scheme names to scopes
+type SecurityRequirement map[string][]string
+
+// Options describes the deployment the document is built for
+type Options struct {
+	Version      string                // Service version shown in info.version
+	Types        []string              // Loaded template types, the values of the {type} path parameter
+	Defaults     *config.DynamicConfig // Deployment defaults of the dynamic query parameters
+	AuthRequired bool                  // Routes require -auth-token; adds the token security schemes
+}
+
+// paramDescriptions documents the dynamic query parameters by name; names and types come
+// from config.DynamicConfig so parameters cannot go missing from the document
+var paramDescriptions = map[string]string{
+	"server":               "Server hostname or IP address",
+	"port":                 "Server port",
+	"ws-path":              "WebSocket path",
+	"dns-server":           "Remote DNS server",
+	"doh-server":           "DNS over HTTPS server URL",
+	"tun-address":          "TUN interface address (CIDR)",
+	"mixed-port":           "Mixed (socks/http) inbound port",
+	"tun-mtu":              "TUN interface MTU",
+	"name":                 "Remark shown by clients (defaults to <server>-<type>)",
+	"tun":                  "Include the TUN inbound",
+	"mixed":                "Include the mixed (socks/http) inbound",
+	"sni":                  "TLS server name (defaults to server)",
+	"host":                 "WebSocket Host header (defaults to sni, then server)",
+	"servers":              "Comma-separated [name@]host[:port] nodes; generates one outbound per node behind selector and urltest groups",
+	"variant":              "Template variant (e.g., mobile); see /api/v1/templates",
+	"transport":            "Transport type: ws, grpc or tcp",
+	"grpc-service":         "gRPC service name",
+	"flow":                 "VLESS flow (e.g., xtls-rprx-vision)",
+	"tls":                  "Enable TLS (false for plaintext behind a local reverse proxy)",
+	"fp":                   "uTLS fingerprint",
+	"alpn":                 "Comma-separated TLS ALPN protocols",
+	"pbk":                  "Reality public key (required by vless-reality)",
+	"sid":                  "Reality short ID",
+	"bypass-domains":       "Comma-separated domain suffixes routed directly",
+	"bypass-cidrs":         "Comma-separated IP CIDRs routed directly",
+	"bypass-geoip":         "Comma-separated GeoIP country codes routed directly",
+	"block-ads":            "Block geosite-category-ads-all",
+	"fakeip":               "Answer proxied DNS queries from a fakeip pool",
+	"clash-api-port":       "Local Clash API controller port (0 disables it)",
+	"clash-api-secret":     "Clash API controller secret",
+	"mux":                  "Enable outbound multiplexing (JSON config only)",
+	"mux-protocol":         "Multiplex protocol: smux, yamux or h2mux",
+	"mux-max-streams":      "Maximum streams per multiplexed connection",
+	"warp":                 "Chain proxy outbounds through a Cloudflare WARP wireguard outbound (JSON config only)",
+	"warp-private-key":     "WARP WireGuard private key (base64, required by warp)",
+	"warp-peer-public-key": "WARP peer public key (base64, required by warp)",
+	"warp-address":         "Comma-separated WARP interface addresses as CIDRs (required by warp)",
+	"schema":               "Output schema: legacy keeps template fields, modern rewrites fields deprecated by sing-box 1.10 and 1.11 (defaults to the template's _meta.schema, then legacy)",
+	"patch":                "Base64url-encoded RFC 7386 JSON merge patch applied to the generated config",
+	"up":                   "Hysteria2 upload bandwidth in Mbps",
+	"down":                 "Hysteria2 download bandwidth in Mbps",
+	"obfs-password":        "Hysteria2 Salamander obfuscation password",
+}
+
+// paramEnums lists the accepted values of enumerated dynamic parameters
+var paramEnums = map[string][]string{
+	"transport":    {"ws", "grpc", "tcp"},
+	"fp":           config.Fingerprints,
+	"mux-protocol": {"smux", "yamux", "h2mux"},
+	"schema":       {config.SchemaLegacy, config.SchemaModern},
+}
+
+// DynamicParameters returns the dynamic query parameters understood by config.ParseDynamicConfig,
+// derived from the fields of config.DynamicConfig with defaults read from defaults
+func DynamicParameters(defaults *config.DynamicConfig) []Parameter {
+	value := reflect.ValueOf(*defaults)
+	parameters := make([]Parameter, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		name, ok := jsonName(value.Type().Field(i))
+		if !ok {
+			continue
+		}
+		schema := fieldSchema(name, value.Field(i))
+		parameter := Parameter{Name: name, In: "query", Description: paramDescriptions[name], Schema: schema}
+		if schema.Type == "array" {
+			// Lists are passed comma-separated: alpn=h2,http/1.1
+			explode := false
+			parameter.Style, parameter.Explode = "form", &explode
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
+
+// DynamicConfigSchema returns the JSON schema of config.DynamicConfig, as accepted in
+// the params object of the JSON API and returned by /api/v1/defaults
+func DynamicConfigSchema(defaults *config.DynamicConfig) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, parameter := range DynamicParameters(defaults) {
+		property := *parameter.Schema
+		property.Description = parameter.Description
+		schema.Properties[parameter.Name] = &property
+	}
+	// The API accepts the patch as a JSON object rather than base64url
+	schema.Properties["patch"] = &Schema{Type: "object", Description: "RFC 7386 JSON merge patch applied to the generated config", AdditionalProperties: true}
+	return schema
+}
+
+// jsonName returns the JSON (and query parameter) name of a struct field
+func jsonName(field reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" || !field.IsExported() {
+		return "", false
+	}
+	return name, true
+}
+
+// fieldSchema maps a DynamicConfig field to its query parameter schema; zero values are
+// omitted as defaults except for booleans, where false is a meaningful default
+func fieldSchema(name string, field reflect.Value) *Schema {
+	schema := &Schema{Enum: paramEnums[name]}
+	switch field.Kind() {
+	case reflect.Bool:
+		schema.Type = "boolean"
+		schema.Default = field.Bool()
+	case reflect.Int:
+		schema.Type = "integer"
+		if field.Int() != 0 {
+			schema.Default = field.Int()
+		}
+	case reflect.Slice:
+		schema.Type = "array"
+		schema.Items = &Schema{Type: "string"}
+		if field.Len() > 0 {
+			schema.Default = field.Interface()
+		}
+	case reflect.Map:
+		schema.Type = "string"
+		schema.Format = "base64url"
+	default:
+		schema.Type = "string"
+		if field.String() != "" {
+			schema.Default = field.String()
+		}
+	}
+	return schema
+}
@@ -0,0 +1,114 @@
+package openapi
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"vless-generator/internal/config"
+)
+
+// pathParam matches the {name} parameters of a path template
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// validMethods are the operation keys OpenAPI 3 allows in a path item
+var validMethods = map[string]bool{"get": true, "put": true, "post": true, "delete": true, "options": true, "head": true, "patch": true, "trace": true}
+
+func TestBuildIsValid(t *testing.T) {
+	for _, authRequired := range []bool{false, true} {
+		document := Build(Options{
+			Version:      "test",
+			Types:        []string{"vless", "trojan"},
+			Defaults:     config.DefaultDynamicConfig(),
+			AuthRequired: authRequired,
+		})
+		validate(t, document)
+	}
+}
+
+// validate checks the structural rules of OpenAPI 3 that a hand-written document can break
+func validate(t *testing.T, document Document) {
+	t.Helper()
+	if document.OpenAPI != Version || document.Info.Title == "" || document.Info.Version == "" {
+		t.Errorf("incomplete header: openapi %q, info %+v", document.OpenAPI, document.Info)
+	}
+
+	operationIDs := make(map[string]string)
+	for path, item := range document.Paths {
+		if !strings.HasPrefix(path, "/") {
+			t.Errorf("path %q must start with /", path)
+		}
+		for method, operation := range item {
+			where := method + " " + path
+			if !validMethods[method] {
+				t.Errorf("%s: %q is not an HTTP method key", where, method)
+			}
+			if operation.OperationID == "" || operation.Summary == "" {
+				t.Errorf("%s: missing operationId or summary", where)
+			}
+			if other, ok := operationIDs[operation.OperationID]; ok {
+				t.Errorf("%s: operationId %q already used by %s", where, operation.OperationID, other)
+			}
+			operationIDs[operation.OperationID] = where
+			if len(operation.Responses) == 0 {
+				t.Errorf("%s: no responses", where)
+			}
+
+			// Every path template parameter is declared as a required path parameter, and
+			// nothing else is declared in the path
+			declared := make(map[string]bool)
+			for _, parameter := range operation.Parameters {
+				if parameter.In != "path" && parameter.In != "query" {
+					t.Errorf("%s: parameter %q is in %q", where, parameter.Name, parameter.In)
+				}
+				if parameter.Schema == nil {
+					t.Errorf("%s: parameter %q has no schema", where, parameter.Name)
+				}
+				key := parameter.In + ":" + parameter.Name
+				if declared[key] {
+					t.Errorf("%s: parameter %q declared twice", where, parameter.Name)
+				}
+				declared[key] = true
+				if parameter.In == "path" && !parameter.Required {
+					t.Errorf("%s: path parameter %q must be required", where, parameter.Name)
+				}
+			}
+			for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+				if !declared["path:"+match[1]] {
+					t.Errorf("%s: path parameter %q is not declared", where, match[1])
+				}
+			}
+			for key := range declared {
+				if strings.HasPrefix(key, "path:") && !strings.Contains(path, "{"+strings.TrimPrefix(key, "path:")+"}") {
+					t.Errorf("%s: declared path parameter %q is not in the path", where, key)
+				}
+			}
+			if len(operation.Security) > 0 && document.Components.SecuritySchemes == nil {
+				t.Errorf("%s: security without security schemes", where)
+			}
+		}
+	}
+
+	// Every $ref resolves to a component schema
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, match := range regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(string(data), -1) {
+		if document.Components.Schemas[match[1]] == nil {
+			t.Errorf("$ref to missing schema %q", match[1])
+		}
+	}
+}
+
+func TestBuildSecurity(t *testing.T) {
+	document := Build(Options{Types: []string{"vless"}, Defaults: config.DefaultDynamicConfig(), AuthRequired: true})
+	for path, item := range document.Paths {
+		for method, operation := range item {
+			if secured := len(operation.Security) > 0; secured == openPaths[path] {
+				t.Errorf("%s %s: secured = %v, open path = %v", method, path, secured, openPaths[path])
+			}
+		}
+	}
+}
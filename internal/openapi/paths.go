@@ -0,0 +1,492 @@
+package openapi
+
+import "sort"
+
+// Build returns the OpenAPI document of every HTTP route registered by the service
+func Build(options Options) Document {
+	dynamic := DynamicParameters(options.Defaults)
+	types := append([]string(nil), options.Types...)
+	sort.Strings(types)
+
+	typeParam := Parameter{Name: "type", In: "path", Required: true, Description: "Configuration template type", Schema: &Schema{Type: "string", Enum: types}}
+	uuidParam := Parameter{Name: "uuid", In: "path", Required: true, Description: "VLESS/VMess UUID, or the password of Trojan, Shadowsocks and Hysteria2", Schema: &Schema{Type: "string"}}
+	generation := append([]Parameter{typeParam, uuidParam, langParam, strictParam}, dynamic...)
+
+	document := Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "VLESS Config Generator",
+			Description: "Generates sing-box, Xray and Clash client configurations, share URLs and QR codes.",
+			Version:     options.Version,
+		},
+		Paths: map[string]PathItem{
+			"/": {
+				"get":  htmlOperation("homePage", "Home page with the configuration form", "pages", langParam),
+				"post": htmlOperation("submitHomeForm", "Submit the configuration form; redirects to the config page", "pages"),
+			},
+			"/{type}/{uuid}": {
+				"get": htmlOperation("configPage", "Configuration page with share URL and QR code", "pages", generation...),
+			},
+			"/config/{type}/{uuid}.json": {
+				"get": {
+					Summary:     "Download the generated configuration as JSON",
+					OperationID: "downloadConfigJSON",
+					Tags:        []string{"configs"},
+					Parameters:  append(append([]Parameter(nil), generation...), formatParam, prettyParam, filenameParam),
+					Responses: withErrors(map[string]Response{
+						"200": negotiatedResponse("sing-box (default) or Xray configuration; without format, the Accept header may select YAML or Clash"),
+						"406": jsonResponse("None of the accepted media types is supported", ref("Error")),
+					}),
+				},
+			},
+			"/config/{type}/{uuid}.yaml": {
+				"get": {
+					Summary:     "Download the generated configuration as a Clash YAML file",
+					OperationID: "downloadConfigYAML",
+					Tags:        []string{"configs"},
+					Parameters:  append(append([]Parameter(nil), generation...), yamlFormatParam, filenameParam),
+					Responses: withErrors(map[string]Response{
+						"200": negotiatedResponse("Clash (default) or sing-box configuration; without format, the Accept header may select JSON"),
+						"406": jsonResponse("None of the accepted media types is supported", ref("Error")),
+					}),
+				},
+			},
+			"/sub/{uuid}": {
+				"get": {
+					Summary:     "Subscription of every server: base64-encoded share URLs, a Clash profile or a sing-box profile",
+					OperationID: "subscription",
+					Tags:        []string{"configs"},
+					Parameters:  append([]Parameter{uuidParam, subFormatParam, prettyParam}, dynamic...),
+					Responses: withErrors(map[string]Response{
+						"200": {
+							Description: "Subscription; without format, Clash and mihomo User-Agents get the Clash profile and sing-box ones the sing-box profile",
+							Content: map[string]MediaType{
+								"text/plain":       {Schema: &Schema{Type: "string", Format: "byte"}},
+								"application/yaml": {Schema: &Schema{Type: "string"}},
+								"application/json": {Schema: &Schema{Type: "object", AdditionalProperties: true}},
+							},
+						},
+					}),
+				},
+			},
+			"/qrcode": {
+				"get": {
+					Summary:     "QR code PNG for a share URL",
+					OperationID: "qrcode",
+					Tags:        []string{"qrcodes"},
+					Parameters:  []Parameter{{Name: "url", In: "query", Required: true, Description: "vless://, trojan://, vmess://, ss:// or hysteria2:// share URL", Schema: &Schema{Type: "string"}}, sizeParam, eccParam},
+					Responses:   withErrors(map[string]Response{"200": pngResponse}),
+				},
+				"post": {
+					Summary:     "QR code PNG for a share URL sent as multipart form data",
+					OperationID: "qrcodeForm",
+					Tags:        []string{"qrcodes"},
+					RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{"multipart/form-data": {Schema: &Schema{
+						Type:       "object",
+						Required:   []string{"url"},
+						Properties: map[string]*Schema{"url": {Type: "string"}, "size": sizeParam.Schema, "ecc": eccParam.Schema},
+					}}}},
+					Responses: withErrors(map[string]Response{"200": pngResponse}),
+				},
+			},
+			"/qrcode/{type}/{uuid}.png": {
+				"get": {
+					Summary:     "QR code PNG of the share URL of a generated configuration",
+					OperationID: "configQRCode",
+					Tags:        []string{"qrcodes"},
+					Parameters:  append(append([]Parameter(nil), generation...), sizeParam, eccParam),
+					Responses:   withErrors(map[string]Response{"200": pngResponse}),
+				},
+			},
+			"/bundle/{type}/{uuid}.zip": {
+				"get": {
+					Summary:     "Zip archive with config.json, qrcode.png and url.txt",
+					OperationID: "bundle",
+					Tags:        []string{"configs"},
+					Parameters:  generation,
+					Responses:   withErrors(map[string]Response{"200": zipResponse}),
+				},
+			},
+			"/api/v1/config": {
+				"post": {
+					Summary:     "Generate a configuration, share URL and QR code",
+					OperationID: "generateConfig",
+					Tags:        []string{"api"},
+					Parameters:  []Parameter{prettyParam},
+					RequestBody: jsonBody(ref("ConfigRequest")),
+					Responses:   withErrors(map[string]Response{"200": jsonResponse("Generated configuration", ref("ConfigResponse"))}),
+				},
+			},
+			"/api/v1/batch": {
+				"post": {
+					Summary:     "Generate configurations for many UUIDs sharing one set of parameters",
+					OperationID: "generateBatch",
+					Tags:        []string{"api"},
+					RequestBody: jsonBody(ref("BatchRequest")),
+					Responses: withErrors(map[string]Response{"200": {
+						Description: "Generated configurations; send Accept: application/zip for a zip archive",
+						Content: map[string]MediaType{
+							"application/json": {Schema: &Schema{Type: "array", Items: ref("BatchEntry")}},
+							"application/zip":  {Schema: &Schema{Type: "string", Format: "binary"}},
+						},
+					}}),
+				},
+			},
+			"/api/v1/import": {
+				"post": {
+					Summary:     "Convert a vless:// share URL into a sing-box configuration",
+					OperationID: "importShareURL",
+					Tags:        []string{"api"},
+					Parameters:  []Parameter{prettyParam},
+					RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{
+						"application/json":                  {Schema: &Schema{Type: "object", Required: []string{"url"}, Properties: map[string]*Schema{"url": {Type: "string"}}}},
+						"application/x-www-form-urlencoded": {Schema: &Schema{Type: "object", Required: []string{"url"}, Properties: map[string]*Schema{"url": {Type: "string"}}}},
+					}},
+					Responses: withErrors(map[string]Response{"200": jsonResponse("sing-box configuration", &Schema{Type: "object", AdditionalProperties: true})}),
+				},
+			},
+			"/api/v1/defaults": {
+				"get": {
+					Summary:     "Default dynamic parameters, languages and template types",
+					OperationID: "defaults",
+					Tags:        []string{"api"},
+					Responses: withErrors(map[string]Response{"200": jsonResponse("Defaults", &Schema{Type: "object", Properties: map[string]*Schema{
+						"defaults":  ref("DynamicConfig"),
+						"languages": {Type: "array", Items: &Schema{Type: "string"}},
+						"templates": {Type: "array", Items: &Schema{Type: "string"}},
+					}})}),
+				},
+			},
+			"/api/v1/templates": {
+				"get": {
+					Summary:     "Metadata of the available template types",
+					OperationID: "templates",
+					Tags:        []string{"api"},
+					Responses:   withErrors(map[string]Response{"200": jsonResponse("Template types", &Schema{Type: "array", Items: ref("TemplateInfo")})}),
+				},
+			},
+			"/api/v1/uuid": {
+				"get": {
+					Summary:     "Random version 4 UUID",
+					OperationID: "newUUID",
+					Tags:        []string{"api"},
+					Responses: withErrors(map[string]Response{"200": jsonResponse("UUID", &Schema{Type: "object", Properties: map[string]*Schema{
+						"uuid": {Type: "string", Format: "uuid"},
+					}})}),
+				},
+			},
+			"/api/v1/sign": {
+				"post": {
+					Summary:     "Signed link for a generation route (requires -signing-key)",
+					OperationID: "signURL",
+					Tags:        []string{"api"},
+					RequestBody: jsonBody(&Schema{Type: "object", Required: []string{"path"}, Properties: map[string]*Schema{
+						"path":   {Type: "string", Description: "Generation route, e.g. /vless/<uuid>"},
+						"params": ref("DynamicConfig"),
+						"ttl":    {Type: "string", Description: "Validity as a Go duration (e.g., 24h)", Default: "720h"},
+					}}),
+					Responses: withErrors(map[string]Response{"200": jsonResponse("Signed URL", &Schema{Type: "object", Properties: map[string]*Schema{
+						"url":        {Type: "string"},
+						"expires_at": {Type: "string", Format: "date-time"},
+						"warnings":   {Type: "array", Items: &Schema{Type: "string"}},
+					}})}),
+				},
+			},
+			"/api/v1/shorten": {
+				"post": {
+					Summary:     "Short link to a configuration (requires -shortlink-db)",
+					OperationID: "shorten",
+					Tags:        []string{"api"},
+					RequestBody: jsonBody(&Schema{Type: "object", Required: []string{"type", "uuid"}, Properties: map[string]*Schema{
+						"type":   {Type: "string", Enum: types},
+						"uuid":   {Type: "string"},
+						"params": ref("DynamicConfig"),
+						"ttl":    {Type: "string", Description: "Lifetime as a Go duration (e.g., 24h); defaults to -shortlink-ttl"},
+					}}),
+					Responses: withErrors(map[string]Response{"201": jsonResponse("Short link", &Schema{Type: "object", Properties: map[string]*Schema{
+						"id":         {Type: "string"},
+						"url":        {Type: "string", Description: "Absolute URL of the config page"},
+						"json_url":   {Type: "string", Description: "Absolute URL of the raw JSON configuration"},
+						"expires_at": {Type: "string", Format: "date-time"},
+						"warnings":   {Type: "array", Items: &Schema{Type: "string"}},
+					}})}),
+				},
+			},
+			"/s/{id}": {
+				"get": htmlOperation("shortLinkPage", "Configuration page of a short link", "pages", shortLinkParam, langParam),
+			},
+			"/s/{id}.json": {
+				"get": {
+					Summary:     "Download the configuration of a short link as JSON",
+					OperationID: "downloadShortLink",
+					Tags:        []string{"configs"},
+					Parameters:  []Parameter{shortLinkParam, formatParam, prettyParam, filenameParam},
+					Responses: withErrors(map[string]Response{
+						"200": negotiatedResponse("sing-box (default) or Xray configuration; without format, the Accept header may select YAML or Clash"),
+						"406": jsonResponse("None of the accepted media types is supported", ref("Error")),
+					}),
+				},
+			},
+			"/admin/reload": {
+				"post": {
+					Summary:     "Reload templates and translations",
+					OperationID: "reload",
+					Tags:        []string{"admin"},
+					Responses: withErrors(map[string]Response{
+						"200": jsonResponse("Every file reloaded", &Schema{Type: "object", AdditionalProperties: true}),
+					}),
+				},
+			},
+			"/admin/templates/{type}": {
+				"put": {
+					Summary:     "Upload or replace the template of a type at runtime",
+					OperationID: "putTemplate",
+					Tags:        []string{"admin"},
+					Parameters:  []Parameter{{Name: "type", In: "path", Required: true, Description: "Template type: lowercase letters, digits and hyphens", Schema: &Schema{Type: "string"}}},
+					RequestBody: jsonBody(&Schema{Type: "object", AdditionalProperties: true, Description: "sing-box template, as in <type>.json"}),
+					Responses: withErrors(map[string]Response{
+						"200": jsonResponse("Installed template", ref("TemplateInfo")),
+						"422": jsonResponse("Invalid template; details lists every problem", ref("Error")),
+					}),
+				},
+				"delete": {
+					Summary:     "Remove an uploaded template and fall back to the embedded or on-disk file",
+					OperationID: "deleteTemplate",
+					Tags:        []string{"admin"},
+					Parameters:  []Parameter{{Name: "type", In: "path", Required: true, Schema: &Schema{Type: "string"}}},
+					Responses: withErrors(map[string]Response{
+						"200": jsonResponse("Template the type falls back to", ref("TemplateInfo")),
+						"204": {Description: "The type only existed as an upload and was removed"},
+						"409": jsonResponse("The file to fall back to is invalid; the upload keeps serving", ref("Error")),
+					}),
+				},
+			},
+			"/admin/configs": {
+				"get": {
+					Summary:     "Recorded configs, newest first (requires -audit-store)",
+					OperationID: "auditConfigs",
+					Tags:        []string{"admin"},
+					Parameters: []Parameter{
+						{Name: "type", In: "query", Description: "Config type", Schema: &Schema{Type: "string", Enum: types}},
+						{Name: "since", In: "query", Description: "Inclusive lower bound as an RFC 3339 time or a YYYY-MM-DD date", Schema: &Schema{Type: "string"}},
+						{Name: "until", In: "query", Description: "Exclusive upper bound as an RFC 3339 time, or a YYYY-MM-DD date included whole", Schema: &Schema{Type: "string"}},
+						{Name: "limit", In: "query", Description: "Page size", Schema: &Schema{Type: "integer", Default: 100, Minimum: intPtr(1), Maximum: intPtr(1000)}},
+						{Name: "offset", In: "query", Description: "Number of matching records to skip", Schema: &Schema{Type: "integer", Default: 0, Minimum: intPtr(0)}},
+					},
+					Responses: withErrors(map[string]Response{"200": jsonResponse("One page of audit records", &Schema{Type: "object", Properties: map[string]*Schema{
+						"total":  {Type: "integer", Description: "Number of records matching the filter"},
+						"offset": {Type: "integer"},
+						"limit":  {Type: "integer"},
+						"records": {Type: "array", Items: &Schema{Type: "object", Properties: map[string]*Schema{
+							"time":      {Type: "string", Format: "date-time"},
+							"type":      {Type: "string"},
+							"uuid_hash": {Type: "string", Description: "Hex SHA-256 of the UUID or password"},
+							"server":    {Type: "string"},
+							"client_ip": {Type: "string"},
+						}}},
+					}})}),
+				},
+			},
+			"/admin/maintenance": {
+				"post": {
+					Summary:     "Turn maintenance mode on or off; generation routes answer 503 while it is on",
+					OperationID: "maintenance",
+					Tags:        []string{"admin"},
+					RequestBody: jsonBody(&Schema{Type: "object", Required: []string{"enabled"}, Properties: map[string]*Schema{
+						"enabled": {Type: "boolean"},
+						"message": {Type: "string", Description: "Shown instead of the localized \"temporarily unavailable\" text"},
+					}}),
+					Responses: withErrors(map[string]Response{"200": jsonResponse("New maintenance state", &Schema{Type: "object", Properties: map[string]*Schema{
+						"enabled": {Type: "boolean"},
+						"message": {Type: "string"},
+						"since":   {Type: "string", Format: "date-time", Description: "When maintenance mode was enabled"},
+					}})}),
+				},
+			},
+			"/health": {
+				"get": {
+					Summary:     "Liveness check with build information and counters",
+					OperationID: "health",
+					Tags:        []string{"status"},
+					Responses:   map[string]Response{"200": jsonResponse("Service is running", &Schema{Type: "object", AdditionalProperties: true})},
+				},
+			},
+			"/ready": {
+				"get": {
+					Summary:     "Readiness check",
+					OperationID: "ready",
+					Tags:        []string{"status"},
+					Responses: map[string]Response{
+						"200": jsonResponse("Service is ready", &Schema{Type: "object", AdditionalProperties: true}),
+						"503": jsonResponse("A component is not ready", &Schema{Type: "object", AdditionalProperties: true}),
+					},
+				},
+			},
+			"/openapi.json": {
+				"get": {
+					Summary:     "This OpenAPI document",
+					OperationID: "openapi",
+					Tags:        []string{"status"},
+					Responses:   map[string]Response{"200": jsonResponse("OpenAPI document", &Schema{Type: "object", AdditionalProperties: true})},
+				},
+			},
+			"/static/{path}": {
+				"get": {
+					Summary:     "Embedded stylesheets and scripts",
+					OperationID: "static",
+					Tags:        []string{"pages"},
+					Parameters:  []Parameter{{Name: "path", In: "path", Required: true, Description: "File path under web/static", Schema: &Schema{Type: "string"}}},
+					Responses:   map[string]Response{"200": {Description: "Static file"}, "404": {Description: "Unknown file"}},
+				},
+			},
+		},
+		Components: Components{Schemas: map[string]*Schema{
+			"DynamicConfig": DynamicConfigSchema(options.Defaults),
+			"ConfigRequest": {Type: "object", Required: []string{"type", "uuid"}, Properties: map[string]*Schema{
+				"type":   {Type: "string", Enum: types},
+				"uuid":   {Type: "string"},
+				"params": ref("DynamicConfig"),
+			}},
+			"ConfigResponse": {Type: "object", Properties: map[string]*Schema{
+				"config":            {Type: "object", AdditionalProperties: true},
+				"url":               {Type: "string", Description: "Share URL"},
+				"page_url":          {Type: "string", Description: "Absolute URL of the config page with the same parameters"},
+				"qrcode_png_base64": {Type: "string", Format: "byte"},
+				"warnings":          {Type: "array", Items: &Schema{Type: "string"}},
+			}},
+			"BatchRequest": {Type: "object", Required: []string{"type"}, Properties: map[string]*Schema{
+				"type":   {Type: "string", Enum: types},
+				"uuids":  {Type: "array", Items: &Schema{Type: "string"}},
+				"count":  {Type: "integer", Description: "Number of random UUIDs to generate instead of uuids"},
+				"params": ref("DynamicConfig"),
+			}},
+			"BatchEntry": {Type: "object", Properties: map[string]*Schema{
+				"uuid":   {Type: "string"},
+				"url":    {Type: "string"},
+				"config": {Type: "object", AdditionalProperties: true},
+				"error":  {Type: "string"},
+			}},
+			"TemplateInfo": {Type: "object", Properties: map[string]*Schema{
+				"type":                {Type: "string"},
+				"label":               {Type: "string"},
+				"description":         {Type: "string"},
+				"recommended_for":     {Type: "array", Items: &Schema{Type: "string"}},
+				"min_singbox_version": {Type: "string"},
+				"transports":          {Type: "array", Items: &Schema{Type: "string"}},
+				"default_port":        {Type: "integer"},
+				"variants":            {Type: "array", Items: &Schema{Type: "string"}},
+			}},
+			"Error": {Type: "object", Properties: map[string]*Schema{
+				"error": {Type: "object", Required: []string{"code", "message"}, Properties: map[string]*Schema{
+					"code":    {Type: "string"},
+					"message": {Type: "string"},
+					"field":   {Type: "string"},
+					"details": {},
+				}},
+			}},
+		}},
+	}
+
+	if options.AuthRequired {
+		document.Components.SecuritySchemes = map[string]SecurityScheme{
+			"bearerToken": {Type: "http", Scheme: "bearer"},
+			"queryToken":  {Type: "apiKey", Name: "token", In: "query"},
+		}
+		for path, item := range document.Paths {
+			if openPaths[path] {
+				continue
+			}
+			for _, operation := range item {
+				operation.Security = []SecurityRequirement{{"bearerToken": {}}, {"queryToken": {}}}
+			}
+		}
+	}
+	return document
+}
+
+// openPaths never require an access token
+var openPaths = map[string]bool{
+	"/":              true,
+	"/qrcode":        true,
+	"/health":        true,
+	"/ready":         true,
+	"/s/{id}":        true,
+	"/s/{id}.json":   true,
+	"/static/{path}": true,
+}
+
+// Query parameters shared by several routes
+var (
+	langParam       = Parameter{Name: "lang", In: "query", Description: "Language of pages and error messages", Schema: &Schema{Type: "string"}}
+	strictParam     = Parameter{Name: "strict", In: "query", Description: "false accepts non-UUID VLESS IDs", Schema: &Schema{Type: "boolean", Default: true}}
+	prettyParam     = Parameter{Name: "pretty", In: "query", Description: "Indent the JSON output", Schema: &Schema{Type: "boolean"}}
+	formatParam     = Parameter{Name: "format", In: "query", Description: "Output format of .json downloads; overrides the Accept header", Schema: &Schema{Type: "string", Enum: []string{"sing-box", "xray"}, Default: "sing-box"}}
+	filenameParam   = Parameter{Name: "filename", In: "query", Description: "Download file name (defaults to <remark or server>-<type>-config with the extension, which is appended when missing)", Schema: &Schema{Type: "string", MaxLength: intPtr(128)}}
+	yamlFormatParam = Parameter{Name: "format", In: "query", Description: "Output format of .yaml downloads; overrides the Accept header", Schema: &Schema{Type: "string", Enum: []string{"clash", "sing-box"}, Default: "clash"}}
+	subFormatParam  = Parameter{Name: "format", In: "query", Description: "Subscription format; overrides the User-Agent", Schema: &Schema{Type: "string", Enum: []string{"base64", "clash", "sing-box"}, Default: "base64"}}
+	sizeParam       = Parameter{Name: "size", In: "query", Description: "QR code size in pixels", Schema: &Schema{Type: "integer", Default: 256, Minimum: intPtr(128), Maximum: intPtr(1024)}}
+	eccParam        = Parameter{Name: "ecc", In: "query", Description: "QR code error correction level", Schema: &Schema{Type: "string", Enum: []string{"L", "M", "Q", "H"}}}
+	shortLinkParam  = Parameter{Name: "id", In: "path", Required: true, Description: "Short link id", Schema: &Schema{Type: "string"}}
+)
+
+// Binary responses
+var (
+	pngResponse = Response{Description: "QR code", Content: map[string]MediaType{"image/png": {Schema: &Schema{Type: "string", Format: "binary"}}}}
+	zipResponse = Response{Description: "Zip archive", Content: map[string]MediaType{"application/zip": {Schema: &Schema{Type: "string", Format: "binary"}}}}
+)
+
+// htmlOperation describes a route answering with an HTML page
+func htmlOperation(id, summary, tag string, parameters ...Parameter) *Operation {
+	return &Operation{
+		Summary:     summary,
+		OperationID: id,
+		Tags:        []string{tag},
+		Parameters:  parameters,
+		Responses: map[string]Response{
+			"200": {Description: "HTML page", Content: map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+			"303": {Description: "Redirect after a form submission"},
+			"400": {Description: "Localized HTML error page"},
+			"404": {Description: "Localized HTML error page"},
+		},
+	}
+}
+
+// withErrors adds the JSON error responses shared by API routes, keeping those already described
+func withErrors(responses map[string]Response) map[string]Response {
+	for _, status := range []string{"400", "401", "403", "404", "405", "422", "500"} {
+		if _, ok := responses[status]; !ok {
+			responses[status] = jsonResponse("Error", ref("Error"))
+		}
+	}
+	return responses
+}
+
+// negotiatedResponse describes a config download served in the media type chosen by the Accept header
+func negotiatedResponse(description string) Response {
+	text := MediaType{Schema: &Schema{Type: "string"}}
+	return Response{Description: description, Content: map[string]MediaType{
+		"application/json":    {Schema: &Schema{Type: "object", AdditionalProperties: true}},
+		"application/yaml":    text,
+		"text/yaml":           text,
+		"application/x-clash": text,
+	}}
+}
+
+// jsonResponse describes a JSON response
+func jsonResponse(description string, schema *Schema) Response {
+	return Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+// jsonBody describes a required JSON request body
+func jsonBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+// ref references a schema of the document's components
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func intPtr(value int) *int {
+	return &value
+}
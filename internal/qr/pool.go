@@ -0,0 +1,74 @@
+package qr
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// ErrBusy is returned by Pool.PNG when every worker is busy and the queue is full
+var ErrBusy = errors.New("too many QR codes being encoded, try again later")
+
+// Pool bounds how many QR codes are encoded at once, so a burst of large codes cannot take
+// every CPU. Callers wait for a free worker in a queue of bounded depth; beyond it they get
+// ErrBusy right away, and waiting callers give up when their context is done. A nil *Pool
+// encodes without limits.
+type Pool struct {
+	workers  chan struct{} // One token per running encode
+	maxQueue int64
+	waiting  atomic.Int64
+}
+
+// NewPool creates a pool running at most workers encodes at a time (runtime.GOMAXPROCS when
+// workers is 0) with at most queueDepth callers waiting for a worker
+func NewPool(workers, queueDepth int) *Pool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &Pool{workers: make(chan struct{}, workers), maxQueue: int64(queueDepth)}
+}
+
+// Workers returns the number of encodes that may run at once
+func (p *Pool) Workers() int {
+	return cap(p.workers)
+}
+
+// PNG renders content like the package-level PNG once a worker is free
+func (p *Pool) PNG(ctx context.Context, content string, size int, level qrcode.RecoveryLevel) ([]byte, error) {
+	if p == nil {
+		return PNG(content, size, level)
+	}
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { <-p.workers }()
+	return PNG(content, size, level)
+}
+
+// acquire takes a worker, waiting in the queue while all are busy. Callers whose context is
+// already done get no worker, since nobody is left to receive the code.
+func (p *Pool) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case p.workers <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if p.waiting.Add(1) > p.maxQueue {
+		p.waiting.Add(-1)
+		return ErrBusy
+	}
+	defer p.waiting.Add(-1)
+	select {
+	case p.workers <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,152 @@
+package qr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const testURL = "vless://bae71742-94e0-4dd5-935f-070339819ba0@x.example.com:443?security=tls#test"
+
+func TestPoolCapsConcurrentEncodes(t *testing.T) {
+	const workers, callers = 2, 50
+	p := NewPool(workers, callers)
+
+	var running, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.acquire(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() { <-p.workers }()
+			n := running.Add(1)
+			for {
+				max := peak.Load()
+				if n <= max || peak.CompareAndSwap(max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != workers {
+		t.Fatalf("peak concurrency = %d, want %d", got, workers)
+	}
+	if got := p.waiting.Load(); got != 0 {
+		t.Fatalf("%d callers still counted as waiting", got)
+	}
+}
+
+func TestPoolRejectsBeyondQueueDepth(t *testing.T) {
+	p := NewPool(1, 1)
+	p.workers <- struct{}{} // The only worker is busy
+
+	queued := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { queued <- p.acquire(ctx) }()
+	waitForQueue(t, p, 1)
+
+	if _, err := p.PNG(context.Background(), testURL, DefaultSize, qrcode.Medium); !errors.Is(err, ErrBusy) {
+		t.Fatalf("PNG with a full queue: err = %v, want ErrBusy", err)
+	}
+
+	<-p.workers // The worker frees up and the queued caller takes it
+	if err := <-queued; err != nil {
+		t.Fatalf("queued caller: %v", err)
+	}
+	<-p.workers
+	png, err := p.PNG(context.Background(), testURL, DefaultSize, qrcode.Medium)
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatalf("PNG with a free worker: err = %v", err)
+	}
+}
+
+func TestPoolCancelledWhileQueued(t *testing.T) {
+	p := NewPool(1, 4)
+	p.workers <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.PNG(ctx, testURL, DefaultSize, qrcode.Medium)
+		done <- err
+	}()
+	waitForQueue(t, p, 1)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelled caller still waiting for a worker")
+	}
+	if got := p.waiting.Load(); got != 0 {
+		t.Fatalf("cancelled caller still holds a queue slot (%d waiting)", got)
+	}
+	if len(p.workers) != 1 {
+		t.Fatal("cancelled caller took a worker")
+	}
+}
+
+func TestPoolDeadline(t *testing.T) {
+	p := NewPool(1, 4)
+	p.workers <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.PNG(ctx, testURL, DefaultSize, qrcode.Medium); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPoolSkipsDoneContext(t *testing.T) {
+	p := NewPool(1, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.PNG(ctx, testURL, DefaultSize, qrcode.Medium); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled although a worker is free", err)
+	}
+}
+
+func TestNilPoolEncodes(t *testing.T) {
+	var p *Pool
+	if _, err := p.PNG(context.Background(), testURL, DefaultSize, qrcode.Medium); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewPoolDefaultsToGOMAXPROCS(t *testing.T) {
+	if NewPool(0, 0).Workers() < 1 {
+		t.Fatal("pool without workers")
+	}
+	if got := NewPool(3, 0).Workers(); got != 3 {
+		t.Fatalf("Workers() = %d, want 3", got)
+	}
+}
+
+// waitForQueue waits until n callers wait for a worker
+func waitForQueue(t *testing.T, p *Pool, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for p.waiting.Load() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers waiting, want %d", p.waiting.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
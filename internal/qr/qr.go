@@ -0,0 +1,93 @@
+// Package qr renders share URLs as PNG or SVG QR codes for the HTTP handlers and the qr subcommand
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Image size bounds in pixels
+const (
+	DefaultSize = 256
+	MinSize     = 128
+	MaxSize     = 1024
+)
+
+// Levels maps the ecc values (L, M, Q or H) to go-qrcode recovery levels
+var Levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// capacity is the byte-mode capacity of the largest QR code (version 40) per recovery level
+var capacity = map[qrcode.RecoveryLevel]int{
+	qrcode.Low:     2953,
+	qrcode.Medium:  2331,
+	qrcode.High:    1663,
+	qrcode.Highest: 1273,
+}
+
+// ErrContentTooLong is returned when content exceeds the capacity of the largest QR code
+var ErrContentTooLong = fmt.Errorf("URL too long for a QR code (max %d bytes)", capacity[qrcode.Low])
+
+// ParseLevel parses an ecc value, case-insensitively
+func ParseLevel(value string) (qrcode.RecoveryLevel, bool) {
+	level, ok := Levels[strings.ToUpper(value)]
+	return level, ok
+}
+
+// FitLevel returns the highest recovery level, at most the requested one, whose capacity fits length bytes
+func FitLevel(length int, level qrcode.RecoveryLevel) (qrcode.RecoveryLevel, bool) {
+	for ; level > qrcode.Low; level-- {
+		if length <= capacity[level] {
+			return level, true
+		}
+	}
+	return qrcode.Low, length <= capacity[qrcode.Low]
+}
+
+// New encodes content at the requested recovery level, stepped down when the content does not fit
+func New(content string, level qrcode.RecoveryLevel) (*qrcode.QRCode, error) {
+	fitted, ok := FitLevel(len(content), level)
+	if !ok {
+		return nil, ErrContentTooLong
+	}
+	return qrcode.New(content, fitted)
+}
+
+// PNG renders content as a size x size PNG QR code
+func PNG(content string, size int, level qrcode.RecoveryLevel) ([]byte, error) {
+	code, err := New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	return code.PNG(size)
+}
+
+// SVG renders content as a size x size SVG QR code, one path covering the dark modules
+func SVG(content string, size int, level qrcode.RecoveryLevel) ([]byte, error) {
+	code, err := New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := code.Bitmap()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, len(bitmap), len(bitmap))
+	b.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString("\"/></svg>\n")
+	return b.Bytes(), nil
+}
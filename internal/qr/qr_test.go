@@ -0,0 +1,96 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// longURL returns a share URL of exactly length bytes
+func longURL(length int) string {
+	const prefix = "vless://bae71742-94e0-4dd5-935f-070339819ba0@x.example.com:443?path=/"
+	return prefix + strings.Repeat("a", length-len(prefix))
+}
+
+func TestFitLevel(t *testing.T) {
+	tests := []struct {
+		length    int
+		requested qrcode.RecoveryLevel
+		want      qrcode.RecoveryLevel
+		ok        bool
+	}{
+		{100, qrcode.Highest, qrcode.Highest, true},
+		{1273, qrcode.Highest, qrcode.Highest, true},
+		{1274, qrcode.Highest, qrcode.High, true},
+		{1664, qrcode.Highest, qrcode.Medium, true},
+		{2332, qrcode.Highest, qrcode.Low, true},
+		{2332, qrcode.Medium, qrcode.Low, true},
+		{1000, qrcode.Medium, qrcode.Medium, true},
+		{1000, qrcode.Low, qrcode.Low, true},
+		{2953, qrcode.Low, qrcode.Low, true},
+		{2954, qrcode.Highest, qrcode.Low, false},
+	}
+	for _, tt := range tests {
+		got, ok := FitLevel(tt.length, tt.requested)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FitLevel(%d, %d) = %d, %v; want %d, %v", tt.length, tt.requested, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNewDowngradesLongURL(t *testing.T) {
+	// A URL over the Highest capacity is encoded at the level that fits instead of failing
+	content := longURL(2000)
+	code, err := New(content, qrcode.Highest)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if code.Level != qrcode.Medium {
+		t.Errorf("level = %d, want Medium (%d)", code.Level, qrcode.Medium)
+	}
+	if code.Content != content {
+		t.Error("content changed")
+	}
+
+	data, err := PNG(longURL(2900), 512, qrcode.Highest)
+	if err != nil {
+		t.Fatalf("PNG at the Low capacity: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 512 || bounds.Dy() != 512 {
+		t.Errorf("image is %dx%d, want 512x512", bounds.Dx(), bounds.Dy())
+	}
+
+	if _, err := New(longURL(3000), qrcode.Highest); !errors.Is(err, ErrContentTooLong) {
+		t.Errorf("beyond every capacity: got %v, want ErrContentTooLong", err)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for value, want := range map[string]qrcode.RecoveryLevel{"l": qrcode.Low, "M": qrcode.Medium, "q": qrcode.High, "H": qrcode.Highest} {
+		if got, ok := ParseLevel(value); !ok || got != want {
+			t.Errorf("ParseLevel(%q) = %d, %v; want %d", value, got, ok, want)
+		}
+	}
+	if _, ok := ParseLevel("X"); ok {
+		t.Error("ParseLevel(X) accepted")
+	}
+}
+
+func TestSVG(t *testing.T) {
+	data, err := SVG("vless://x@example.com:443", 300, qrcode.Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(data)
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="300" height="300"`) || !strings.Contains(svg, `d="M`) {
+		t.Errorf("unexpected SVG: %.200s", svg)
+	}
+}
@@ -0,0 +1,119 @@
+// Package rotate provides an append-only file that rotates itself by size, keeping a fixed
+// number of old files next to it: path.1 is the most recent, path.2 the one before, and so on
+package rotate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// File is an io.WriteCloser appending to a file that is rotated once a write would grow it
+// past maxBytes. Each write lands in one file, so writes of whole lines keep lines intact.
+type File struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	keep     int
+	file     *os.File
+	size     int64
+}
+
+// Open opens the file at path for appending, creating it when missing. A maxBytes of zero
+// disables rotation; keep is the number of rotated files kept, older ones are removed.
+func Open(path string, maxBytes int64, keep int) (*File, error) {
+	f := &File{path: path, maxBytes: maxBytes, keep: keep}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file and reads its current size
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p, rotating the file first when p would grow it past the size limit. A
+// single write larger than the limit still goes to one fresh file. When old files cannot be
+// shifted the current file is reopened and keeps growing rather than losing the write.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil && f.file == nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate closes the current file, shifts the rotated files by one and opens a fresh file
+func (f *File) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", f.path, err)
+	}
+	shiftErr := f.shift()
+	if err := f.open(); err != nil {
+		return err
+	}
+	return shiftErr
+}
+
+// shift renames path to path.1, path.1 to path.2 and so on, dropping the oldest file
+func (f *File) shift() error {
+	if f.keep == 0 {
+		if err := os.Remove(f.path); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+		}
+		return nil
+	}
+	for i := f.keep - 1; i >= 1; i-- {
+		if err := os.Rename(f.rotatedPath(i), f.rotatedPath(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+		}
+	}
+	if err := os.Rename(f.path, f.rotatedPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// rotatedPath returns the path of the n-th most recent rotated file
+func (f *File) rotatedPath(n int) string {
+	return f.path + "." + strconv.Itoa(n)
+}
+
+// Close closes the file; later writes fail with os.ErrClosed
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package rotate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeLines writes each line to f as its own write
+func writeLines(t *testing.T, f *File, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q): %v", line, err)
+		}
+	}
+}
+
+// assertFiles checks the contents of path and its rotated files; a missing want entry
+// means the file must not exist
+func assertFiles(t *testing.T, path string, want map[string]string) {
+	t.Helper()
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if content == "" {
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s exists, want it removed", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("read %s: %v", name, err)
+			continue
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestRotateSizeThreshold(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int64
+		lines    []string
+		want     map[string]string
+	}{
+		{
+			name:     "fits exactly",
+			maxBytes: 8,
+			lines:    []string{"aaaa", "bbbb"},
+			want:     map[string]string{"app.log": "aaaabbbb", "app.log.1": ""},
+		},
+		{
+			name:     "rotates when a write would exceed the limit",
+			maxBytes: 8,
+			lines:    []string{"aaaa", "bbbb", "c"},
+			want:     map[string]string{"app.log": "c", "app.log.1": "aaaabbbb"},
+		},
+		{
+			name:     "oversized write goes to a fresh file",
+			maxBytes: 4,
+			lines:    []string{"aa", "bbbbbbbb", "cc"},
+			want:     map[string]string{"app.log": "cc", "app.log.1": "bbbbbbbb", "app.log.2": "aa"},
+		},
+		{
+			name:     "oversized first write is not rotated",
+			maxBytes: 4,
+			lines:    []string{"aaaaaaaa"},
+			want:     map[string]string{"app.log": "aaaaaaaa", "app.log.1": ""},
+		},
+		{
+			name:     "zero disables rotation",
+			maxBytes: 0,
+			lines:    []string{"aaaa", "bbbb", "cccc"},
+			want:     map[string]string{"app.log": "aaaabbbbcccc", "app.log.1": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			f, err := Open(path, tt.maxBytes, 3)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer f.Close()
+			writeLines(t, f, tt.lines...)
+			assertFiles(t, path, tt.want)
+		})
+	}
+}
+
+func TestRotateCountsExistingSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("old!"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path, 6, 1)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	writeLines(t, f, "new")
+	assertFiles(t, path, map[string]string{"app.log": "new", "app.log.1": "old!"})
+}
+
+func TestRotateRetention(t *testing.T) {
+	tests := []struct {
+		name string
+		keep int
+		want map[string]string
+	}{
+		{
+			name: "keeps the newest files",
+			keep: 2,
+			want: map[string]string{"app.log": "5", "app.log.1": "4", "app.log.2": "3", "app.log.3": ""},
+		},
+		{
+			name: "keep one",
+			keep: 1,
+			want: map[string]string{"app.log": "5", "app.log.1": "4", "app.log.2": ""},
+		},
+		{
+			name: "keep none truncates",
+			keep: 0,
+			want: map[string]string{"app.log": "5", "app.log.1": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			f, err := Open(path, 1, tt.keep)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer f.Close()
+			writeLines(t, f, "1", "2", "3", "4", "5")
+			assertFiles(t, path, tt.want)
+
+			entries, err := os.ReadDir(filepath.Dir(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.keep+1 {
+				t.Errorf("directory has %d files, want %d", len(entries), tt.keep+1)
+			}
+		})
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	f, err := Open(filepath.Join(t.TempDir(), "app.log"), 0, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close error = %v, want os.ErrClosed", err)
+	}
+}
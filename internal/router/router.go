@@ -0,0 +1,304 @@
+// Package router is a minimal HTTP router with method constraints and path parameters
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// paramsKey is the context key of the path parameters of a matched route
+type paramsKey struct{}
+
+// patternKey is the context key of the pattern of a matched route
+type patternKey struct{}
+
+// segmentKind orders pattern segments by specificity; higher kinds win over lower ones
+type segmentKind int
+
+const (
+	catchAll segmentKind = iota // {name...}: the rest of the path, possibly empty
+	wildcard                    // {name}: one non-empty segment
+	suffixed                    // {name}.ext: one segment ending in a literal suffix
+	literal                     // Matched exactly
+)
+
+// segment is one "/"-separated part of a route pattern
+type segment struct {
+	kind   segmentKind
+	name   string // Parameter name, empty for literals
+	suffix string // Literal text after the parameter, such as ".json"
+	value  string // Literal text
+}
+
+// route is a registered pattern with its handler
+type route struct {
+	pattern  string // As registered, such as "GET /config/{type}/{uuid}.json"
+	method   string // Empty matches any method
+	segments []segment
+	handler  http.HandlerFunc
+}
+
+// Router dispatches requests to the handler of the most specific matching route.
+// Patterns are "[METHOD] /path" where a segment is a literal, a {name} parameter,
+// a {name} parameter followed by a literal suffix such as {uuid}.json, or a final
+// {name...} matching the rest of the path. GET routes also match HEAD requests.
+type Router struct {
+	routes []*route
+
+	// NotFound handles requests matching no route; defaults to http.NotFound
+	NotFound http.HandlerFunc
+	// NotAllowed handles requests matching routes for other methods only, after the
+	// Allow header has been set; defaults to a plain 405 response
+	NotAllowed http.HandlerFunc
+}
+
+// New returns an empty router
+func New() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for pattern. It panics on malformed or duplicate patterns,
+// which are programming errors.
+func (rt *Router) Handle(pattern string, handler http.HandlerFunc) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = "", pattern
+	}
+	if !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("router: pattern %q must start with /", pattern))
+	}
+
+	parts := strings.Split(path[1:], "/")
+	segments := make([]segment, len(parts))
+	for i, part := range parts {
+		seg, err := parseSegment(part, i == len(parts)-1)
+		if err != nil {
+			panic(fmt.Sprintf("router: pattern %q: %v", pattern, err))
+		}
+		segments[i] = seg
+	}
+
+	for _, existing := range rt.routes {
+		if existing.method == method && samePattern(existing.segments, segments) {
+			panic(fmt.Sprintf("router: pattern %q is registered twice", pattern))
+		}
+	}
+	rt.routes = append(rt.routes, &route{pattern: pattern, method: method, segments: segments, handler: handler})
+}
+
+// ServeHTTP dispatches the request to the most specific route matching its path and method,
+// answering 404 when no route matches the path and 405 when the most specific pattern
+// matching the path is only routed for other methods. A less specific pattern never answers
+// for a method the more specific one lacks, so GET /admin/reload is a 405 rather than a
+// request for the {type}/{uuid} page.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(r.URL.EscapedPath())
+	top := rt.mostSpecific(parts)
+	if top == nil {
+		if rt.NotFound != nil {
+			rt.NotFound(w, r)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	for _, candidate := range rt.routes {
+		if !samePattern(candidate.segments, top.segments) || !candidate.allows(r.Method) {
+			continue
+		}
+		params, _ := candidate.match(parts)
+		ctx := context.WithValue(r.Context(), patternKey{}, candidate.pattern)
+		if len(params) > 0 {
+			ctx = context.WithValue(ctx, paramsKey{}, params)
+		}
+		candidate.handler(w, r.WithContext(ctx))
+		return
+	}
+	rt.MethodNotAllowed(w, r)
+}
+
+// mostSpecific returns a route of the most specific pattern matching the path segments,
+// whatever its method, or nil when no pattern matches
+func (rt *Router) mostSpecific(parts []string) *route {
+	var top *route
+	for _, candidate := range rt.routes {
+		if _, ok := candidate.match(parts); !ok {
+			continue
+		}
+		if top == nil || moreSpecific(candidate.segments, top.segments) {
+			top = candidate
+		}
+	}
+	return top
+}
+
+// MethodNotAllowed sets the Allow header to the methods routed for the most specific
+// pattern matching the request path and answers with NotAllowed. It is exported so that middleware answering only some requests
+// of a method, such as CORS preflights, can fall back to it.
+func (rt *Router) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", strings.Join(rt.allowed(splitPath(r.URL.EscapedPath())), ", "))
+	if rt.NotAllowed != nil {
+		rt.NotAllowed(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// Patterns returns the registered patterns in registration order, for tests and
+// documentation that must cover every route
+func (rt *Router) Patterns() []string {
+	patterns := make([]string, len(rt.routes))
+	for i, registered := range rt.routes {
+		patterns[i] = registered.pattern
+	}
+	return patterns
+}
+
+// Param returns the value of a path parameter of the route that matched r, unescaped;
+// it is empty when the route has no such parameter
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+// Pattern returns the pattern of the route that matched r, or "" when no route matched
+func Pattern(r *http.Request) string {
+	pattern, _ := r.Context().Value(patternKey{}).(string)
+	return pattern
+}
+
+// allowed returns the sorted methods routed for the most specific pattern matching the
+// given path segments
+func (rt *Router) allowed(parts []string) []string {
+	top := rt.mostSpecific(parts)
+	seen := make(map[string]bool)
+	for _, candidate := range rt.routes {
+		if top == nil || !samePattern(candidate.segments, top.segments) || candidate.method == "" {
+			continue
+		}
+		seen[candidate.method] = true
+		if candidate.method == http.MethodGet {
+			seen[http.MethodHead] = true
+		}
+	}
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// allows reports whether the route serves the request method
+func (rt *route) allows(method string) bool {
+	return rt.method == "" || rt.method == method || (rt.method == http.MethodGet && method == http.MethodHead)
+}
+
+// match matches unescaped path segments against the route and returns its parameters
+func (rt *route) match(parts []string) (map[string]string, bool) {
+	var params map[string]string
+	set := func(name, value string) {
+		if params == nil {
+			params = make(map[string]string, len(rt.segments))
+		}
+		params[name] = value
+	}
+
+	for i, seg := range rt.segments {
+		if seg.kind == catchAll {
+			if i > len(parts) {
+				return nil, false
+			}
+			set(seg.name, strings.Join(parts[i:], "/"))
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		part := parts[i]
+		switch seg.kind {
+		case literal:
+			if part != seg.value {
+				return nil, false
+			}
+		case wildcard:
+			if part == "" {
+				return nil, false
+			}
+			set(seg.name, part)
+		case suffixed:
+			value, found := strings.CutSuffix(part, seg.suffix)
+			if !found || value == "" {
+				return nil, false
+			}
+			set(seg.name, value)
+		}
+	}
+	return params, len(parts) == len(rt.segments)
+}
+
+// parseSegment parses one segment of a route pattern
+func parseSegment(part string, last bool) (segment, error) {
+	if !strings.HasPrefix(part, "{") {
+		if strings.ContainsAny(part, "{}") {
+			return segment{}, fmt.Errorf("segment %q mixes literal text and a parameter", part)
+		}
+		return segment{kind: literal, value: part}, nil
+	}
+
+	name, suffix, found := strings.Cut(part[1:], "}")
+	switch {
+	case !found || name == "" || strings.ContainsAny(suffix, "{}"):
+		return segment{}, fmt.Errorf("malformed parameter segment %q", part)
+	case strings.HasSuffix(name, "..."):
+		if !last || suffix != "" {
+			return segment{}, fmt.Errorf("%q must be the whole last segment", part)
+		}
+		return segment{kind: catchAll, name: strings.TrimSuffix(name, "...")}, nil
+	case suffix != "":
+		return segment{kind: suffixed, name: name, suffix: suffix}, nil
+	default:
+		return segment{kind: wildcard, name: name}, nil
+	}
+}
+
+// moreSpecific reports whether pattern a should win over pattern b: the first segment
+// where they differ decides, literals beating suffixed parameters beating plain ones
+func moreSpecific(a, b []segment) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].kind != b[i].kind {
+			return a[i].kind > b[i].kind
+		}
+	}
+	return len(a) > len(b)
+}
+
+// samePattern reports whether two patterns match exactly the same paths
+func samePattern(a, b []segment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].kind != b[i].kind || a[i].value != b[i].value || a[i].suffix != b[i].suffix {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPath splits an escaped request path into unescaped segments, so that parameters
+// containing "/" (e.g. base64 passwords sent as %2F) stay in one segment
+func splitPath(escapedPath string) []string {
+	parts := strings.Split(strings.TrimPrefix(escapedPath, "/"), "/")
+	for i, part := range parts {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			parts[i] = unescaped
+		}
+	}
+	return parts
+}
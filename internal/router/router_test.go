@@ -0,0 +1,137 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestRouter registers the shapes of the service's routes; every handler answers with
+// its pattern and parameters
+func newTestRouter() *Router {
+	rt := New()
+	for _, pattern := range []string{
+		"GET /",
+		"POST /",
+		"GET /{type}/{uuid}",
+		"GET /config/{type}/{uuid}.json",
+		"GET /config/{type}/{uuid}.yaml",
+		"GET /sub/{uuid}",
+		"GET /s/{id}",
+		"GET /s/{id}.json",
+		"POST /api/v1/config",
+		"OPTIONS /api/v1/config",
+		"GET /qrcode",
+		"POST /qrcode",
+		"GET /health",
+		"POST /admin/reload",
+		"GET /admin/configs",
+		"POST /admin/maintenance",
+		"PUT /admin/templates/{type}",
+		"DELETE /admin/templates/{type}",
+		"GET /static/{path...}",
+	} {
+		pattern := pattern
+		rt.Handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Pattern", Pattern(r))
+			for _, name := range []string{"type", "uuid", "id", "path"} {
+				if value := Param(r, name); value != "" {
+					w.Header().Set("X-Param-"+name, value)
+				}
+			}
+		})
+	}
+	return rt
+}
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		method  string
+		path    string
+		status  int
+		pattern string // Pattern that answered, for 200
+		allow   string // Allow header, for 405
+		params  map[string]string
+	}{
+		{"GET", "/", 200, "GET /", "", nil},
+		{"HEAD", "/", 200, "GET /", "", nil},
+		{"POST", "/", 200, "POST /", "", nil},
+		{"GET", "/vless/bae71742", 200, "GET /{type}/{uuid}", "", map[string]string{"type": "vless", "uuid": "bae71742"}},
+		{"GET", "/shadowsocks/a%2Fb", 200, "GET /{type}/{uuid}", "", map[string]string{"uuid": "a/b"}},
+		{"GET", "/config/vless/u.json", 200, "GET /config/{type}/{uuid}.json", "", map[string]string{"type": "vless", "uuid": "u"}},
+		{"GET", "/config/vless/u.yaml", 200, "GET /config/{type}/{uuid}.yaml", "", nil},
+		{"GET", "/s/abc", 200, "GET /s/{id}", "", map[string]string{"id": "abc"}},
+		{"GET", "/s/abc.json", 200, "GET /s/{id}.json", "", map[string]string{"id": "abc"}},
+		{"GET", "/static/css/app.css", 200, "GET /static/{path...}", "", map[string]string{"path": "css/app.css"}},
+		{"GET", "/admin/configs", 200, "GET /admin/configs", "", nil},
+		{"PUT", "/admin/templates/vless", 200, "PUT /admin/templates/{type}", "", map[string]string{"type": "vless"}},
+
+		// Wrong methods on literal routes must not fall back to the {type}/{uuid} page
+		{"GET", "/admin/reload", 405, "", "POST", nil},
+		{"GET", "/admin/maintenance", 405, "", "POST", nil},
+		{"DELETE", "/admin/configs", 405, "", "GET, HEAD", nil},
+		{"POST", "/admin/configs", 405, "", "GET, HEAD", nil},
+		{"GET", "/admin/templates/vless", 405, "", "DELETE, PUT", nil},
+		{"GET", "/api/v1/config", 405, "", "OPTIONS, POST", nil},
+		{"DELETE", "/qrcode", 405, "", "GET, HEAD, POST", nil},
+		{"POST", "/vless/bae71742", 405, "", "GET, HEAD", nil},
+		{"POST", "/config/vless/u.json", 405, "", "GET, HEAD", nil},
+		{"POST", "/static/app.css", 405, "", "GET, HEAD", nil},
+		{"PUT", "/health", 405, "", "GET, HEAD", nil},
+
+		{"GET", "/config/vless/u.txt", 404, "", "", nil},
+		{"GET", "/a/b/c/d", 404, "", "", nil},
+		{"GET", "/vless/", 404, "", "", nil},
+		{"GET", "/admin/templates/", 404, "", "", nil},
+	}
+	rt := newTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (pattern %q)", w.Code, tt.status, w.Header().Get("X-Pattern"))
+			}
+			if got := w.Header().Get("X-Pattern"); got != tt.pattern {
+				t.Errorf("pattern = %q, want %q", got, tt.pattern)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+			for name, want := range tt.params {
+				if got := w.Header().Get("X-Param-" + name); got != want {
+					t.Errorf("param %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterHandlers(t *testing.T) {
+	rt := New()
+	rt.Handle("GET /a", func(w http.ResponseWriter, r *http.Request) {})
+	var notFound, notAllowed int
+	rt.NotFound = func(w http.ResponseWriter, r *http.Request) { notFound++; w.WriteHeader(http.StatusNotFound) }
+	rt.NotAllowed = func(w http.ResponseWriter, r *http.Request) { notAllowed++; w.WriteHeader(http.StatusMethodNotAllowed) }
+
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/a", nil))
+	if notFound != 1 || notAllowed != 1 {
+		t.Fatalf("NotFound called %d times, NotAllowed %d times; want 1 each", notFound, notAllowed)
+	}
+}
+
+func TestHandlePanics(t *testing.T) {
+	for _, pattern := range []string{"GET a", "GET /{a", "GET /x{a}", "GET /{a...}/b", "GET /{}", "GET /dup"} {
+		t.Run(pattern, func(t *testing.T) {
+			rt := New()
+			rt.Handle("GET /dup", func(http.ResponseWriter, *http.Request) {})
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Handle(%q) did not panic", pattern)
+				}
+			}()
+			rt.Handle(pattern, func(http.ResponseWriter, *http.Request) {})
+		})
+	}
+}
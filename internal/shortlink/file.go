@@ -0,0 +1,123 @@
+package shortlink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore keeps short links in memory and persists them to a JSON file, rewritten
+// atomically on every change. It suits the few thousand links a deployment hands out.
+type FileStore struct {
+	mu    sync.Mutex
+	path  string
+	links map[string]Link
+}
+
+// OpenFileStore loads the links stored at path, starting empty when the file does not exist yet
+func OpenFileStore(path string) (*FileStore, error) {
+	store := &FileStore{path: path, links: make(map[string]Link)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read short link store: %w", err)
+	}
+
+	var links []Link
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("failed to parse short link store %s: %w", path, err)
+	}
+	for _, link := range links {
+		store.links[link.ID] = link
+	}
+	return store, nil
+}
+
+// Put stores a new link and persists the store
+func (s *FileStore) Put(link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.links[link.ID]; exists {
+		return ErrDuplicate
+	}
+	s.links[link.ID] = link
+	if err := s.save(); err != nil {
+		delete(s.links, link.ID)
+		return err
+	}
+	return nil
+}
+
+// Get returns the link with the given id unless it is unknown or expired at now
+func (s *FileStore) Get(id string, now time.Time) (Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[id]
+	if !ok || link.Expired(now) {
+		return Link{}, ErrNotFound
+	}
+	return link, nil
+}
+
+// DeleteExpired removes links expired at now and persists the store when any were removed
+func (s *FileStore) DeleteExpired(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, link := range s.links {
+		if link.Expired(now) {
+			delete(s.links, id)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save()
+}
+
+// Close is a no-op: every change is already on disk
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// save writes all links to a temporary file and renames it over the store, so a crash
+// never leaves a truncated file behind; the caller holds mu
+func (s *FileStore) save() error {
+	links := make([]Link, 0, len(s.links))
+	for _, link := range s.links {
+		links = append(links, link)
+	}
+	data, err := json.Marshal(links)
+	if err != nil {
+		return fmt.Errorf("failed to encode short links: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write short link store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write short link store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write short link store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace short link store: %w", err)
+	}
+	return nil
+}
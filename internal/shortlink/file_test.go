@@ -0,0 +1,142 @@
+package shortlink
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"vless-generator/internal/config"
+)
+
+// testNow is the current time of store tests
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// testLink returns a vless link with id expiring after ttl, or never for a zero ttl
+func testLink(id string, ttl time.Duration) Link {
+	cfg := config.DefaultDynamicConfig()
+	cfg.Server = "x.example.com"
+	link := Link{ID: id, Type: "vless", UUID: "bae71742-94e0-4dd5-935f-070339819ba0", Config: cfg, CreatedAt: testNow}
+	if ttl != 0 {
+		link.ExpiresAt = testNow.Add(ttl)
+	}
+	return link
+}
+
+func openTestStore(t *testing.T) (*FileStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "links.json")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	return store, path
+}
+
+func TestFileStorePutGet(t *testing.T) {
+	store, _ := openTestStore(t)
+	link := testLink("Ab3dEf9Z", time.Hour)
+	if err := store.Put(link); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := store.Get(link.ID, testNow)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, link) {
+		t.Errorf("Get = %+v, want %+v", got, link)
+	}
+	if _, err := store.Get("Zz9Yy8Xx", testNow); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an unknown id error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Get(link.ID, testNow.Add(time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an expired link error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileStorePutDuplicate(t *testing.T) {
+	store, _ := openTestStore(t)
+	first := testLink("Ab3dEf9Z", 0)
+	if err := store.Put(first); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	second := testLink("Ab3dEf9Z", time.Hour)
+	second.Type = "trojan"
+	if err := store.Put(second); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("Put of a taken id error = %v, want ErrDuplicate", err)
+	}
+	if got, err := store.Get(first.ID, testNow); err != nil || got.Type != "vless" {
+		t.Errorf("Get = %+v, %v, want the first link kept", got, err)
+	}
+}
+
+func TestFileStorePersists(t *testing.T) {
+	store, path := openTestStore(t)
+	links := []Link{testLink("Ab3dEf9Z", 0), testLink("Zz9Yy8Xx", time.Hour)}
+	for _, link := range links {
+		if err := store.Put(link); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	for _, link := range links {
+		got, err := reopened.Get(link.ID, testNow)
+		if err != nil {
+			t.Fatalf("Get(%s) after reopening: %v", link.ID, err)
+		}
+		if !reflect.DeepEqual(got, link) {
+			t.Errorf("Get(%s) after reopening = %+v, want %+v", link.ID, got, link)
+		}
+	}
+}
+
+func TestOpenFileStoreRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.json")
+	if err := os.WriteFile(path, []byte(`[{"id":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore(path); err == nil {
+		t.Error("OpenFileStore of a corrupt file succeeded, want an error")
+	}
+}
+
+func TestFileStoreDeleteExpired(t *testing.T) {
+	store, path := openTestStore(t)
+	for _, link := range []Link{testLink("Ab3dEf9Z", 0), testLink("Zz9Yy8Xx", time.Hour), testLink("Qq1Ww2Ee", 2*time.Hour)} {
+		if err := store.Put(link); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	removed, err := store.DeleteExpired(testNow.Add(time.Hour))
+	if err != nil || removed != 1 {
+		t.Fatalf("DeleteExpired = %d, %v, want 1 removed", removed, err)
+	}
+	if removed, err := store.DeleteExpired(testNow.Add(time.Hour)); err != nil || removed != 0 {
+		t.Errorf("second DeleteExpired = %d, %v, want nothing removed", removed, err)
+	}
+
+	// The purge is persisted, and links that never expire stay
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	if _, err := reopened.Get("Zz9Yy8Xx", testNow); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a purged link error = %v, want ErrNotFound", err)
+	}
+	for _, id := range []string{"Ab3dEf9Z", "Qq1Ww2Ee"} {
+		if _, err := reopened.Get(id, testNow); err != nil {
+			t.Errorf("Get(%s) after purge: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package shortlink stores short links to generated configs: a random id mapped to a
+// config type, credential and dynamic parameters
+package shortlink
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"time"
+
+	"vless-generator/internal/config"
+)
+
+// IDLength is the number of characters of a short link id
+const IDLength = 8
+
+// idAlphabet holds the characters of short link ids; they need no escaping in URLs
+const idAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// Store errors
+var (
+	ErrNotFound  = errors.New("short link not found")
+	ErrDuplicate = errors.New("short link id already exists")
+)
+
+// Link is a stored short link
+type Link struct {
+	ID        string                `json:"id"`
+	Type      string                `json:"type"`
+	UUID      string                `json:"uuid"`
+	Config    *config.DynamicConfig `json:"config"`
+	CreatedAt time.Time             `json:"created_at"`
+	ExpiresAt time.Time             `json:"expires_at,omitempty"` // Zero for links that never expire
+}
+
+// Expired reports whether the link has expired at now
+func (l Link) Expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// Store persists short links
+type Store interface {
+	// Put stores a new link; it returns ErrDuplicate when the id is taken
+	Put(link Link) error
+	// Get returns the link with the given id, or ErrNotFound when it is unknown or expired at now
+	Get(id string, now time.Time) (Link, error)
+	// DeleteExpired removes links expired at now and returns how many were removed
+	DeleteExpired(now time.Time) (int, error)
+	// Close releases the store
+	Close() error
+}
+
+// NewID returns a random short link id
+func NewID() (string, error) {
+	id := make([]byte, IDLength)
+	limit := big.NewInt(int64(len(idAlphabet)))
+	for i := range id {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		id[i] = idAlphabet[n.Int64()]
+	}
+	return string(id), nil
+}
+
+// IsValidID reports whether id has the shape of a short link id, so lookups of
+// arbitrary paths can be rejected without touching the store
+func IsValidID(id string) bool {
+	if len(id) != IDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package shortlink
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := NewID()
+		if err != nil {
+			t.Fatalf("NewID: %v", err)
+		}
+		if !IsValidID(id) {
+			t.Errorf("NewID = %q, not a valid id", id)
+		}
+		if seen[id] {
+			t.Errorf("NewID returned %q twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestIsValidID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"Ab3dEf9Z", true},
+		{"00000000", true},
+		{"", false},
+		{"Ab3dEf9", false},
+		{"Ab3dEf9Z0", false},
+		{"Ab3d-f9Z", false},
+		{"Ab3d.f9Z", false},
+		{"Ab3d%2fZ", false},
+		{"Ab3dEf9ё", false},
+		{strings.Repeat("a", 64), false},
+	}
+	for _, tt := range tests {
+		if got := IsValidID(tt.id); got != tt.want {
+			t.Errorf("IsValidID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestLinkExpired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		expires time.Time
+		want    bool
+	}{
+		{"never expires", time.Time{}, false},
+		{"expires later", now.Add(time.Second), false},
+		{"expires now", now, true},
+		{"expired", now.Add(-time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Link{ExpiresAt: tt.expires}).Expired(now); got != tt.want {
+				t.Errorf("Expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
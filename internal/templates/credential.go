@@ -0,0 +1,75 @@
+package templates
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"vless-generator/internal/utils"
+)
+
+// ErrInvalidCredential is returned by GenerateConfig when the credential cannot key the outbound,
+// such as a Shadowsocks 2022 password that is not a base64 key of the method's length
+var ErrInvalidCredential = errors.New("invalid credential")
+
+// shadowsocks2022KeySizes maps Shadowsocks 2022 methods to the size of their pre-shared key in bytes
+var shadowsocks2022KeySizes = map[string]int{
+	"2022-blake3-aes-128-gcm":       16,
+	"2022-blake3-aes-256-gcm":       32,
+	"2022-blake3-chacha20-poly1305": 32,
+}
+
+// checkCredentials rejects credentials set in the proxy outbounds of cfg that their protocol cannot use
+func checkCredentials(cfg map[string]interface{}) error {
+	for _, outbound := range utils.ProxyOutbounds(cfg) {
+		if outboundType, _ := outbound["type"].(string); outboundType != "shadowsocks" {
+			continue
+		}
+		method, _ := outbound["method"].(string)
+		password, _ := outbound["password"].(string)
+		if err := checkShadowsocks2022Key(method, password); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkShadowsocks2022Key validates the base64 pre-shared key of a 2022-blake3 method. Multi-user
+// servers take "<server key>:<user key>", so every colon-separated key is checked.
+// Other methods accept any password.
+func checkShadowsocks2022Key(method, password string) error {
+	size, ok := shadowsocks2022KeySizes[method]
+	if !ok {
+		return nil
+	}
+	for _, key := range strings.Split(password, ":") {
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return fmt.Errorf("%w: %s needs a base64 key of %d bytes: %v", ErrInvalidCredential, method, size, err)
+		}
+		if len(decoded) != size {
+			return fmt.Errorf("%w: %s needs a base64 key of %d bytes, got %d", ErrInvalidCredential, method, size, len(decoded))
+		}
+	}
+	return nil
+}
+
+// NewCredential generates a random credential for a template type: a base64 key of the right
+// size for Shadowsocks 2022 methods and a UUID otherwise
+func (m *Manager) NewCredential(templateType string) (string, error) {
+	template, exists := m.lookup(templateType)
+	if exists {
+		outbound := m.proxyOutbound(template)
+		method, _ := outbound["method"].(string)
+		if size, ok := shadowsocks2022KeySizes[method]; ok && outbound["type"] == "shadowsocks" {
+			key := make([]byte, size)
+			if _, err := rand.Read(key); err != nil {
+				return "", fmt.Errorf("failed to read random bytes: %w", err)
+			}
+			return base64.StdEncoding.EncodeToString(key), nil
+		}
+	}
+	return utils.NewUUID()
+}
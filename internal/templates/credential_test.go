@@ -0,0 +1,77 @@
+package templates
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"vless-generator/internal/config"
+)
+
+func TestCheckShadowsocks2022Key(t *testing.T) {
+	key16 := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	key32 := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	tests := []struct {
+		method   string
+		password string
+		valid    bool
+	}{
+		{"2022-blake3-aes-128-gcm", key16, true},
+		{"2022-blake3-aes-128-gcm", key32, false},
+		{"2022-blake3-aes-256-gcm", key32, true},
+		{"2022-blake3-aes-256-gcm", key16, false},
+		{"2022-blake3-chacha20-poly1305", key32, true},
+		{"2022-blake3-chacha20-poly1305", key16, false},
+		{"2022-blake3-aes-128-gcm", key16 + ":" + key16, true},
+		{"2022-blake3-aes-128-gcm", key16 + ":" + key32, false},
+		{"2022-blake3-aes-128-gcm", testUUID, false},
+		{"2022-blake3-aes-128-gcm", base64.RawURLEncoding.EncodeToString(make([]byte, 16)), false},
+		{"2022-blake3-aes-128-gcm", "", false},
+		{"aes-128-gcm", testUUID, true},
+	}
+	for _, tt := range tests {
+		err := checkShadowsocks2022Key(tt.method, tt.password)
+		if tt.valid && err != nil {
+			t.Errorf("%s %q: unexpected error %v", tt.method, tt.password, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidCredential) {
+			t.Errorf("%s %q: got %v, want ErrInvalidCredential", tt.method, tt.password, err)
+		}
+	}
+}
+
+func TestGenerateConfigRejectsInvalidShadowsocksKey(t *testing.T) {
+	m := newTestManager(t)
+	if _, err := m.GenerateConfig("shadowsocks", testSSKey, config.DefaultDynamicConfig()); err != nil {
+		t.Fatalf("valid key: %v", err)
+	}
+	if _, err := m.GenerateConfig("shadowsocks", testUUID, config.DefaultDynamicConfig()); !errors.Is(err, ErrInvalidCredential) {
+		t.Fatalf("UUID as key: got %v, want ErrInvalidCredential", err)
+	}
+
+	// A patch replacing the outbounds is checked against the final method
+	dynamicCfg := config.DefaultDynamicConfig()
+	dynamicCfg.Patch = map[string]interface{}{
+		"outbounds": []interface{}{map[string]interface{}{
+			"type": "shadowsocks", "tag": "proxy", "method": "2022-blake3-aes-256-gcm", "password": testSSKey,
+		}},
+	}
+	if _, err := m.GenerateConfig("shadowsocks", testSSKey, dynamicCfg); !errors.Is(err, ErrInvalidCredential) {
+		t.Fatalf("aes-256 with a 16-byte key: got %v, want ErrInvalidCredential", err)
+	}
+}
+
+func TestNewCredential(t *testing.T) {
+	m := newTestManager(t)
+	for _, templateType := range testTypes {
+		credential, err := m.NewCredential(templateType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dynamicCfg := config.DefaultDynamicConfig()
+		dynamicCfg.RealityPublicKey = testRealityKey
+		if _, err := m.GenerateConfig(templateType, credential, dynamicCfg); err != nil {
+			t.Errorf("%s: credential %q rejected: %v", templateType, credential, err)
+		}
+	}
+}
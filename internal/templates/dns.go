@@ -0,0 +1,100 @@
+package templates
+
+import (
+	"vless-generator/internal/config"
+)
+
+// DNS server tag conventions: every server tagged remoteDNSTag gets the dns-server
+// address and every server tagged directDNSTag gets the doh-server address
+const (
+	remoteDNSTag = "dns-remote"
+	directDNSTag = "dns-direct"
+)
+
+// FakeIP DNS settings
+const (
+	fakeIPServerTag  = "dns-fakeip"
+	fakeIPInet4Range = "198.18.0.0/15"
+	fakeIPInet6Range = "fc00::/18"
+)
+
+// dnsSection wraps a template's dns object so transformations merge with
+// whatever servers and rules the template already defines
+type dnsSection struct {
+	raw map[string]interface{}
+}
+
+// dnsSectionOf returns the template's dns section, creating it if missing
+func dnsSectionOf(template map[string]interface{}) *dnsSection {
+	raw, ok := template["dns"].(map[string]interface{})
+	if !ok {
+		raw = make(map[string]interface{})
+		template["dns"] = raw
+	}
+	return &dnsSection{raw: raw}
+}
+
+// list returns the named array field, or nil when it is missing
+func (d *dnsSection) list(key string) []interface{} {
+	items, _ := d.raw[key].([]interface{})
+	return items
+}
+
+// hasServer reports whether a server with the given tag exists
+func (d *dnsSection) hasServer(tag string) bool {
+	for _, item := range d.list("servers") {
+		if server, ok := item.(map[string]interface{}); ok && server["tag"] == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// setServerAddress sets the address of every server with the given tag
+func (d *dnsSection) setServerAddress(tag, address string) {
+	for _, item := range d.list("servers") {
+		if server, ok := item.(map[string]interface{}); ok && server["tag"] == tag {
+			server["address"] = address
+		}
+	}
+}
+
+// addServer appends a server unless one with the same tag exists
+func (d *dnsSection) addServer(server map[string]interface{}) {
+	if tag, _ := server["tag"].(string); d.hasServer(tag) {
+		return
+	}
+	d.raw["servers"] = append(d.list("servers"), server)
+}
+
+// appendRules adds rules after the template's existing rules, which take precedence
+func (d *dnsSection) appendRules(rules ...interface{}) {
+	d.raw["rules"] = append(d.list("rules"), rules...)
+}
+
+// applyFakeIP adds a fakeip server, the dns.fakeip ranges and a rule answering
+// A/AAAA queries from the fakeip pool; bypassed domains keep resolving directly
+func applyFakeIP(template map[string]interface{}, dynamicCfg *config.DynamicConfig) {
+	dns := dnsSectionOf(template)
+
+	dns.addServer(map[string]interface{}{
+		"tag":     fakeIPServerTag,
+		"address": "fakeip",
+	})
+	dns.raw["fakeip"] = map[string]interface{}{
+		"enabled":     true,
+		"inet4_range": fakeIPInet4Range,
+		"inet6_range": fakeIPInet6Range,
+	}
+
+	if len(dynamicCfg.BypassDomains) > 0 && dns.hasServer(directDNSTag) {
+		dns.appendRules(map[string]interface{}{
+			"domain_suffix": dynamicCfg.BypassDomains,
+			"server":        directDNSTag,
+		})
+	}
+	dns.appendRules(map[string]interface{}{
+		"query_type": []string{"A", "AAAA"},
+		"server":     fakeIPServerTag,
+	})
+}
@@ -0,0 +1,102 @@
+package templates
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"vless-generator/internal/config"
+)
+
+// decodeJSON decodes a JSON document the way template files are decoded
+func decodeJSON(t *testing.T, document string) map[string]interface{} {
+	t.Helper()
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(document), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+// assertJSONEqual compares got with the want document after a JSON round trip, so []string and []interface{} compare equal
+func assertJSONEqual(t *testing.T, got map[string]interface{}, want string) {
+	t.Helper()
+	encoded, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decodeJSON(t, string(encoded)), decodeJSON(t, want)) {
+		t.Errorf("got  %s\nwant %s", encoded, want)
+	}
+}
+
+func TestApplyFakeIP(t *testing.T) {
+	tests := []struct {
+		name          string
+		before        string
+		bypassDomains []string
+		after         string
+	}{
+		{
+			name: "merges with existing servers and rules",
+			before: `{"dns": {
+				"servers": [{"tag": "dns-remote", "address": "8.8.8.8"}, {"tag": "dns-direct", "address": "local"}],
+				"rules": [{"outbound": "any", "server": "dns-direct"}],
+				"final": "dns-remote"
+			}}`,
+			bypassDomains: []string{"example.ru"},
+			after: `{"dns": {
+				"servers": [
+					{"tag": "dns-remote", "address": "8.8.8.8"},
+					{"tag": "dns-direct", "address": "local"},
+					{"tag": "dns-fakeip", "address": "fakeip"}
+				],
+				"rules": [
+					{"outbound": "any", "server": "dns-direct"},
+					{"domain_suffix": ["example.ru"], "server": "dns-direct"},
+					{"query_type": ["A", "AAAA"], "server": "dns-fakeip"}
+				],
+				"fakeip": {"enabled": true, "inet4_range": "198.18.0.0/15", "inet6_range": "fc00::/18"},
+				"final": "dns-remote"
+			}}`,
+		},
+		{
+			name:   "creates a missing dns section",
+			before: `{"outbounds": []}`,
+			after: `{"outbounds": [], "dns": {
+				"servers": [{"tag": "dns-fakeip", "address": "fakeip"}],
+				"rules": [{"query_type": ["A", "AAAA"], "server": "dns-fakeip"}],
+				"fakeip": {"enabled": true, "inet4_range": "198.18.0.0/15", "inet6_range": "fc00::/18"}
+			}}`,
+		},
+		{
+			name:          "bypass without a direct server only adds the fakeip rule",
+			before:        `{"dns": {"servers": [{"tag": "dns-remote", "address": "8.8.8.8"}]}}`,
+			bypassDomains: []string{"example.ru"},
+			after: `{"dns": {
+				"servers": [{"tag": "dns-remote", "address": "8.8.8.8"}, {"tag": "dns-fakeip", "address": "fakeip"}],
+				"rules": [{"query_type": ["A", "AAAA"], "server": "dns-fakeip"}],
+				"fakeip": {"enabled": true, "inet4_range": "198.18.0.0/15", "inet6_range": "fc00::/18"}
+			}}`,
+		},
+		{
+			name:   "keeps a template fakeip server",
+			before: `{"dns": {"servers": [{"tag": "dns-fakeip", "address": "fakeip", "strategy": "ipv4_only"}]}}`,
+			after: `{"dns": {
+				"servers": [{"tag": "dns-fakeip", "address": "fakeip", "strategy": "ipv4_only"}],
+				"rules": [{"query_type": ["A", "AAAA"], "server": "dns-fakeip"}],
+				"fakeip": {"enabled": true, "inet4_range": "198.18.0.0/15", "inet6_range": "fc00::/18"}
+			}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := decodeJSON(t, tt.before)
+			dynamicCfg := config.DefaultDynamicConfig()
+			dynamicCfg.FakeIP = true
+			dynamicCfg.BypassDomains = tt.bypassDomains
+			applyFakeIP(template, dynamicCfg)
+			assertJSONEqual(t, template, tt.after)
+		})
+	}
+}
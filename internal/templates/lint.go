@@ -0,0 +1,92 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/utils"
+)
+
+// Sample credential and Reality key used to generate every file checked by Lint
+const (
+	lintCredential = placeholderSampleUUID
+	lintPublicKey  = "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw"
+)
+
+// LintResult lists the problems found in one template file; it passed when Problems is empty
+type LintResult struct {
+	File     string
+	Problems []error
+}
+
+// Lint checks every *.json file of the template filesystem without a running server: each file
+// is parsed and validated like LoadTemplates does, generated with the default dynamic parameters
+// and, for built-in types, turned into a share URL. Problems are collected per file instead of
+// stopping at the first one. Files that load are served by the manager afterwards.
+func (m *Manager) Lint() ([]LintResult, error) {
+	files, err := fs.Glob(m.configFS, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list template files: %w", err)
+	}
+	sort.Strings(files)
+
+	results := make([]LintResult, len(files))
+	loaded := make(map[string]*loadedTemplate, len(files))
+	for i, file := range files {
+		results[i].File = file
+		key := strings.TrimSuffix(file, ".json")
+		template, err := m.loadTemplate(key)
+		if err != nil {
+			results[i].Problems = templateProblems(err)
+			continue
+		}
+		loaded[key] = template
+	}
+
+	m.mu.Lock()
+	m.templates = loaded
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
+
+	sample := config.DefaultDynamicConfig()
+	sample.RealityPublicKey = lintPublicKey
+	for i, file := range files {
+		key := strings.TrimSuffix(file, ".json")
+		if _, ok := loaded[key]; !ok {
+			continue
+		}
+		templateType, variant, _ := strings.Cut(key, variantSeparator)
+		if _, ok := loaded[templateType]; !ok {
+			results[i].Problems = append(results[i].Problems, fmt.Errorf("variant of %s, which has no valid %s", templateType, templateFileName(templateType)))
+			continue
+		}
+		dynamicCfg := sample.Clone()
+		dynamicCfg.Variant = variant
+
+		cfg, err := m.buildConfig(templateType, lintCredential, dynamicCfg)
+		if err != nil {
+			results[i].Problems = append(results[i].Problems, fmt.Errorf("failed to generate config: %w", err))
+			continue
+		}
+		if _, builtIn := expectedOutbounds[templateType]; builtIn {
+			if _, err := utils.GenerateShareURL(templateType, cfg, lintCredential, dynamicCfg.Remark(templateType)); err != nil {
+				results[i].Problems = append(results[i].Problems, fmt.Errorf("failed to build share URL: %w", err))
+			}
+		}
+	}
+	return results, nil
+}
+
+// templateProblems returns the validation problems of a load error, or the error itself
+func templateProblems(err error) []error {
+	var templateErr *TemplateError
+	if errors.As(err, &templateErr) {
+		return templateErr.Problems
+	}
+	return []error{err}
+}
@@ -1,196 +1,1083 @@
 package templates
 
 import (
-	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
 
 	"vless-generator/internal/config"
+	"vless-generator/internal/metrics"
+	"vless-generator/internal/utils"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Errors returned by GenerateConfig for bad dynamic parameters
+var (
+	// ErrMissingParameter is returned when a template requires a dynamic parameter that was not supplied
+	ErrMissingParameter = errors.New("missing required parameter")
+	// ErrInvalidParameter is returned when a dynamic parameter value cannot be applied to the template
+	ErrInvalidParameter = errors.New("invalid parameter")
+)
+
 // Manager handles template loading and management
 type Manager struct {
-	templates map[string]map[string]interface{}
+	mu        sync.RWMutex // Guards templates, overrides and loadedAt; Reload swaps the map while requests read it
+	templates map[string]*loadedTemplate
+	loadedAt  time.Time // When LoadTemplates first succeeded; zero until then
 	logger    *logrus.Entry
-	configFS  embed.FS
+	configFS  fs.FS
+
+	updateMu    sync.Mutex        // Serializes Reload, PutTemplate and DeleteTemplate
+	overrides   map[string][]byte // Uploaded template files by type, read instead of configFS
+	overrideDir string            // Directory persisting overrides; empty keeps them in memory
 }
 
-// NewManager creates a new template manager with embedded filesystem
-func NewManager(configFS embed.FS) *Manager {
+// templateMetaKey is the top-level template key holding metadata; it never reaches generated configs
+const templateMetaKey = "_meta"
+
+// templateMeta holds the optional "_meta" object of a template file
+type templateMeta struct {
+	DisplayName       string   `json:"display_name,omitempty"`        // Human-readable name shown instead of the type
+	Description       string   `json:"description,omitempty"`         // What the template is for
+	RecommendedFor    []string `json:"recommended_for,omitempty"`     // Devices or clients it suits (e.g., android, routers)
+	MinSingboxVersion string   `json:"min_singbox_version,omitempty"` // Oldest sing-box release that accepts it
+	Schema            string   `json:"schema,omitempty"`              // Output schema without a schema parameter: legacy or modern
+}
+
+// loadedTemplate is a parsed template file with its metadata split off
+type loadedTemplate struct {
+	config map[string]interface{}
+	meta   templateMeta
+	text   *texttemplate.Template // Set for templated files; config then holds a sample rendering
+	loaded time.Time              // When the file was last (re)loaded
+}
+
+// NewManager creates a new template manager reading <type>.json files from configFS
+func NewManager(configFS fs.FS) *Manager {
 	return &Manager{
-		templates: make(map[string]map[string]interface{}),
+		templates: make(map[string]*loadedTemplate),
 		logger:    logrus.WithField("component", "templates"),
 		configFS:  configFS,
+		overrides: make(map[string][]byte),
 	}
 }
 
-// LoadTemplates loads embedded configuration templates from main package
+// LoadTemplates loads configuration templates for the given types together with
+// their variants (<type>.<variant>.json files next to <type>.json)
 func (m *Manager) LoadTemplates(types []string) error {
-	m.logger.WithField("types", types).Info("Loading embedded configuration templates from templates directory")
+	// Uploaded types are loaded even when they are not configured
+	m.mu.RLock()
+	for templateType := range m.overrides {
+		if !containsString(types, templateType) {
+			types = append(types, templateType)
+		}
+	}
+	m.mu.RUnlock()
+	m.logger.WithField("types", types).Info("Loading configuration templates")
 
+	// Collect failures across all types so a broken deployment reports every problem at once
+	loaded := make(map[string]*loadedTemplate, len(types))
+	var errs []error
 	for _, templateType := range types {
-		if err := m.loadTemplate(templateType); err != nil {
-			return fmt.Errorf("failed to load template %s: %w", templateType, err)
+		variants, err := m.discoverVariants(templateType)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, key := range append([]string{templateType}, variantKeys(templateType, variants)...) {
+			template, err := m.loadTemplate(key)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to load template %s: %w", key, err))
+				continue
+			}
+			loaded[key] = template
 		}
 	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	m.mu.Lock()
+	m.templates = loaded
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
 
-	m.logger.WithField("count", len(m.templates)).Info("All templates loaded successfully")
+	m.logger.WithField("count", len(loaded)).Info("All templates loaded successfully")
 	return nil
 }
 
-// loadTemplate loads a single embedded template file from main package
-func (m *Manager) loadTemplate(templateType string) error {
-	templateFile := "templates/" + templateType + ".json"
+// Reload re-reads every loaded template type and its variants and returns the outcome per file.
+// Templates that fail to load keep serving their previous version; new variant files are picked
+// up and variants whose files were removed are dropped.
+func (m *Manager) Reload() map[string]error {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+
+	types := m.GetTemplateTypes()
+	results := make(map[string]error, len(types))
+
+	m.mu.RLock()
+	reloaded := make(map[string]*loadedTemplate, len(m.templates))
+	for key, template := range m.templates {
+		reloaded[key] = template
+	}
+	m.mu.RUnlock()
+
+	for _, templateType := range types {
+		keys := []string{templateType}
+		if variants, err := m.discoverVariants(templateType); err != nil {
+			m.logger.WithError(err).WithField("type", templateType).Error("Failed to list template variants, keeping previous ones")
+			keys = append(keys, variantKeys(templateType, m.GetVariants(templateType))...)
+		} else {
+			keys = append(keys, variantKeys(templateType, variants)...)
+			for _, variant := range m.GetVariants(templateType) {
+				if !containsString(variants, variant) {
+					delete(reloaded, variantKey(templateType, variant))
+				}
+			}
+		}
+
+		for _, key := range keys {
+			template, err := m.loadTemplate(key)
+			results[templateFileName(key)] = err
+			if err != nil {
+				m.logger.WithError(err).WithField("type", key).Error("Failed to reload template, keeping previous version")
+				continue
+			}
+			reloaded[key] = template
+		}
+	}
+
+	m.mu.Lock()
+	m.templates = reloaded
+	m.mu.Unlock()
+
+	return results
+}
+
+// Status reports whether templates have been loaded, for readiness and health checks
+type Status struct {
+	Loaded       bool                 `json:"loaded"`
+	LoadedAt     *time.Time           `json:"loaded_at,omitempty"`
+	Types        []string             `json:"types"`
+	TypeLoadedAt map[string]time.Time `json:"type_loaded_at"`         // When each type's main file was last (re)loaded
+	Files        int                  `json:"files"`                  // Loaded template files, including variants
+	FetchErrors  map[string]string    `json:"fetch_errors,omitempty"` // Remote files served from the cache
+	Overrides    []string             `json:"overrides,omitempty"`    // Types served from uploaded templates
+}
+
+// Status returns the current loading state of the templates
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	status := Status{Loaded: !m.loadedAt.IsZero(), Files: len(m.templates), TypeLoadedAt: make(map[string]time.Time)}
+	for key, template := range m.templates {
+		if !strings.Contains(key, variantSeparator) {
+			status.TypeLoadedAt[key] = template.loaded.UTC()
+		}
+	}
+	if status.Loaded {
+		loadedAt := m.loadedAt.UTC()
+		status.LoadedAt = &loadedAt
+	}
+	for templateType := range m.overrides {
+		status.Overrides = append(status.Overrides, templateType)
+	}
+	m.mu.RUnlock()
+	sort.Strings(status.Overrides)
+
+	status.Types = m.GetTemplateTypes()
+	status.FetchErrors = m.FetchErrors()
+	return status
+}
+
+// variantSeparator separates the template type from the variant name in template keys and file names
+const variantSeparator = "."
+
+// variantKey returns the template key of a type's variant; an empty or default variant selects the type itself
+func variantKey(templateType, variant string) string {
+	if variant == "" || variant == config.DefaultVariant {
+		return templateType
+	}
+	return templateType + variantSeparator + variant
+}
+
+// variantKeys returns the template keys of the given variants of a type
+func variantKeys(templateType string, variants []string) []string {
+	keys := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		keys = append(keys, variantKey(templateType, variant))
+	}
+	return keys
+}
+
+// baseType returns the template type of a template key, stripping any variant
+func baseType(key string) string {
+	templateType, _, _ := strings.Cut(key, variantSeparator)
+	return templateType
+}
+
+// discoverVariants lists the variants of a type that have a <type>.<variant>.json file, sorted by name
+func (m *Manager) discoverVariants(templateType string) ([]string, error) {
+	matches, err := fs.Glob(m.configFS, templateType+variantSeparator+"*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list variants of %s: %w", templateType, err)
+	}
+
+	var variants []string
+	for _, match := range matches {
+		variant := strings.TrimSuffix(strings.TrimPrefix(match, templateType+variantSeparator), ".json")
+		if !config.IsValidVariant(variant) || variant == config.DefaultVariant {
+			m.logger.WithField("file", match).Warn("Ignoring template file with an invalid variant name")
+			continue
+		}
+		variants = append(variants, variant)
+	}
+	sort.Strings(variants)
+	return variants, nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// templateFileName returns the file name of a template type within the template filesystem
+func templateFileName(templateType string) string {
+	return templateType + ".json"
+}
+
+// loadTemplate reads and parses a single template file, preferring a runtime override;
+// key is a type or <type>.<variant>
+func (m *Manager) loadTemplate(key string) (*loadedTemplate, error) {
+	m.logger.WithFields(logrus.Fields{
+		"type": key,
+		"file": templateFileName(key),
+	}).Debug("Loading template file")
+
+	m.mu.RLock()
+	data, overridden := m.overrides[key]
+	m.mu.RUnlock()
+	if !overridden {
+		var err error
+		if data, err = fs.ReadFile(m.configFS, templateFileName(key)); err != nil {
+			return nil, fmt.Errorf("failed to read template file: %w", err)
+		}
+	}
 
+	template, err := parseTemplate(key, data)
+	if err != nil {
+		return nil, err
+	}
 	m.logger.WithFields(logrus.Fields{
-		"type": templateType,
-		"file": templateFile,
-	}).Debug("Loading embedded template file")
+		"type":       key,
+		"templated":  template.text != nil,
+		"overridden": overridden,
+	}).Info("Template loaded successfully")
+	return template, nil
+}
+
+// parseTemplate parses and validates the contents of a template file, splitting off its "_meta" object
+func parseTemplate(key string, data []byte) (*loadedTemplate, error) {
+	templateFile := templateFileName(key)
+
+	// Templated files are checked by rendering them with the built-in defaults
+	var text *texttemplate.Template
+	var err error
+	if hasPlaceholders(data) {
+		if text, err = parsePlaceholders(templateFile, data); err != nil {
+			return nil, err
+		}
+		data, err = renderPlaceholders(text, placeholderData{
+			DynamicConfig: config.DefaultDynamicConfig(),
+			UUID:          placeholderSampleUUID,
+			Type:          baseType(key),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	data, err := m.configFS.ReadFile(templateFile)
+	template, meta, err := decodeTemplate(templateFile, data)
 	if err != nil {
-		return fmt.Errorf("failed to read embedded template file: %w", err)
+		return nil, err
+	}
+	if errs := validateTemplate(baseType(key), template); len(errs) > 0 {
+		return nil, &TemplateError{File: templateFile, Problems: errs}
 	}
+	return &loadedTemplate{config: template, meta: meta, text: text, loaded: time.Now()}, nil
+}
 
+// decodeTemplate parses template JSON and splits off its "_meta" object
+func decodeTemplate(templateFile string, data []byte) (map[string]interface{}, templateMeta, error) {
 	var template map[string]interface{}
-	if err := json.Unmarshal(data, &template); err != nil {
-		return fmt.Errorf("failed to parse template JSON: %w", err)
+	if err := utils.DecodeJSON(data, &template); err != nil {
+		return nil, templateMeta{}, fmt.Errorf("failed to parse template JSON: %w", err)
+	}
+	var file struct {
+		Meta templateMeta `json:"_meta"`
 	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, templateMeta{}, fmt.Errorf("invalid %s object in %s: %w", templateMetaKey, templateFile, err)
+	}
+	if file.Meta.Schema != "" && !config.IsSchema(file.Meta.Schema) {
+		return nil, templateMeta{}, fmt.Errorf("invalid %s.schema %q in %s: must be %s or %s", templateMetaKey, file.Meta.Schema, templateFile, config.SchemaLegacy, config.SchemaModern)
+	}
+	delete(template, templateMetaKey)
+	return template, file.Meta, nil
+}
 
-	m.templates[templateType] = template
+// lookup returns the loaded template for a type or <type>.<variant> key without copying it; callers must not modify it
+func (m *Manager) lookup(key string) (map[string]interface{}, bool) {
+	loaded, exists := m.lookupLoaded(key)
+	if !exists {
+		return nil, false
+	}
+	return loaded.config, true
+}
 
-	m.logger.WithField("type", templateType).Info("Template loaded successfully")
-	return nil
+// lookupLoaded returns the loaded template file for a type or <type>.<variant> key
+func (m *Manager) lookupLoaded(key string) (*loadedTemplate, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loaded, exists := m.templates[key]
+	return loaded, exists
 }
 
 // GetTemplate returns a copy of the template for the specified type
 func (m *Manager) GetTemplate(templateType string) (map[string]interface{}, bool) {
-	template, exists := m.templates[templateType]
+	template, exists := m.lookup(templateType)
 	if !exists {
 		return nil, false
 	}
 
 	// Return a deep copy to avoid modifying the original template
-	return m.deepCopyMap(template), true
+	return utils.DeepCopyMap(template), true
 }
 
-// GetTemplateTypes returns all available template types
+// HasTemplate reports whether a template of the given type is loaded; variants are not types
+func (m *Manager) HasTemplate(templateType string) bool {
+	if strings.Contains(templateType, variantSeparator) {
+		return false
+	}
+	_, exists := m.lookup(templateType)
+	return exists
+}
+
+// GetTemplateTypes returns all available template types sorted by name; see GetVariants for their variants
 func (m *Manager) GetTemplateTypes() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	types := make([]string, 0, len(m.templates))
-	for templateType := range m.templates {
-		types = append(types, templateType)
+	for key := range m.templates {
+		if !strings.Contains(key, variantSeparator) {
+			types = append(types, key)
+		}
 	}
+	sort.Strings(types)
 	return types
 }
 
-// GenerateConfig creates a configuration with dynamic parameters
-func (m *Manager) GenerateConfig(templateType, uuid string, dynamicCfg *config.DynamicConfig) (map[string]interface{}, error) {
-	template, exists := m.GetTemplate(templateType)
+// GetVariants returns the named variants loaded for a template type sorted by name, excluding the default
+func (m *Manager) GetVariants(templateType string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	prefix := templateType + variantSeparator
+	var variants []string
+	for key := range m.templates {
+		if variant, ok := strings.CutPrefix(key, prefix); ok {
+			variants = append(variants, variant)
+		}
+	}
+	sort.Strings(variants)
+	return variants
+}
+
+// TemplateInfo describes a loaded template type for the API and the home page
+type TemplateInfo struct {
+	Type              string   `json:"type"`
+	Label             string   `json:"label"`                         // _meta.display_name, or the upper-cased type
+	Description       string   `json:"description"`                   // _meta.description, or the built-in description
+	RecommendedFor    []string `json:"recommended_for,omitempty"`     // _meta.recommended_for
+	MinSingboxVersion string   `json:"min_singbox_version,omitempty"` // _meta.min_singbox_version
+	Transports        []string `json:"transports"`                    // Supported transport modes, the template default first
+	DefaultPort       int      `json:"default_port"`                  // Server port used when the port parameter is omitted
+	Variants          []string `json:"variants"`                      // Selectable variant parameter values, "default" first
+}
+
+// templateDescriptions holds human-readable descriptions of the built-in template types
+var templateDescriptions = map[string]string{
+	"vless":         "VLESS over WebSocket with TLS",
+	"vless-reality": "VLESS with XTLS Vision over REALITY",
+	"vmess":         "VMess over WebSocket with TLS",
+	"trojan":        "Trojan over WebSocket with TLS",
+	"shadowsocks":   "Shadowsocks 2022 (2022-blake3-aes-128-gcm)",
+	"hysteria2":     "Hysteria2 over QUIC",
+}
+
+// GetTemplateInfo describes a loaded template type, preferring the values of its "_meta" object.
+// DefaultPort is left unset; Describe fills it from the deployment defaults.
+func (m *Manager) GetTemplateInfo(templateType string) (TemplateInfo, bool) {
+	if strings.Contains(templateType, variantSeparator) {
+		return TemplateInfo{}, false
+	}
+	m.mu.RLock()
+	loaded, exists := m.templates[templateType]
+	m.mu.RUnlock()
 	if !exists {
+		return TemplateInfo{}, false
+	}
+
+	info := TemplateInfo{
+		Type:              templateType,
+		Label:             strings.ToUpper(templateType),
+		Description:       templateDescriptions[templateType],
+		RecommendedFor:    loaded.meta.RecommendedFor,
+		MinSingboxVersion: loaded.meta.MinSingboxVersion,
+		Transports:        m.templateTransports(templateType),
+		Variants:          append([]string{config.DefaultVariant}, m.GetVariants(templateType)...),
+	}
+	if loaded.meta.DisplayName != "" {
+		info.Label = loaded.meta.DisplayName
+	}
+	if loaded.meta.Description != "" {
+		info.Description = loaded.meta.Description
+	}
+	return info, true
+}
+
+// Describe returns metadata for every loaded template, sorted by type; defaults supply the default port
+func (m *Manager) Describe(defaults *config.DynamicConfig) []TemplateInfo {
+	types := m.GetTemplateTypes()
+	infos := make([]TemplateInfo, 0, len(types))
+	for _, templateType := range types {
+		if info, exists := m.GetTemplateInfo(templateType); exists {
+			info.DefaultPort = defaults.ServerPort
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// templateTransports lists the transport modes a template supports, its own transport first
+func (m *Manager) templateTransports(templateType string) []string {
+	template, _ := m.lookup(templateType)
+	outbound := m.proxyOutbound(template)
+	outboundType, _ := outbound["type"].(string)
+
+	switch {
+	case outboundType == "hysteria2":
+		return []string{"quic"}
+	case !v2rayTransportOutbounds[outboundType]:
+		return []string{"tcp"}
+	}
+
+	current := "tcp"
+	if transport, ok := outbound["transport"].(map[string]interface{}); ok {
+		if transportType, ok := transport["type"].(string); ok {
+			current = transportType
+		}
+	}
+	transports := []string{current}
+	for _, transport := range []string{"ws", "grpc", "tcp"} {
+		if transport != current {
+			transports = append(transports, transport)
+		}
+	}
+	return transports
+}
+
+// proxyOutbound returns the first proxy outbound of a template (matched by type), or nil when it has none
+func (m *Manager) proxyOutbound(template map[string]interface{}) map[string]interface{} {
+	outbound, _ := utils.ProxyOutbound(template)
+	return outbound
+}
+
+// proxyTag returns the tag of the template's first proxy outbound, used as the detour for downloads
+func proxyTag(template map[string]interface{}) string {
+	outbound, _ := utils.ProxyOutbound(template)
+	if tag, ok := outbound["tag"].(string); ok && tag != "" {
+		return tag
+	}
+	return "proxy"
+}
+
+// GenerateConfig creates a configuration with dynamic parameters from the requested variant of a template type
+func (m *Manager) GenerateConfig(templateType, uuid string, dynamicCfg *config.DynamicConfig) (map[string]interface{}, error) {
+	cfg, err := m.buildConfig(templateType, uuid, dynamicCfg)
+	if err == nil {
+		metrics.ConfigGenerated(templateType)
+	}
+	return cfg, err
+}
+
+// CheckConfig generates a configuration without returning or counting it, for dry runs and readiness checks
+func (m *Manager) CheckConfig(templateType, uuid string, dynamicCfg *config.DynamicConfig) error {
+	_, err := m.buildConfig(templateType, uuid, dynamicCfg)
+	return err
+}
+
+// buildConfig generates the configuration for GenerateConfig
+func (m *Manager) buildConfig(templateType, uuid string, dynamicCfg *config.DynamicConfig) (map[string]interface{}, error) {
+	if !m.HasTemplate(templateType) {
 		return nil, fmt.Errorf("template type %s not found", templateType)
 	}
+	loaded, exists := m.lookupLoaded(variantKey(templateType, dynamicCfg.Variant))
+	if !exists {
+		return nil, fmt.Errorf("%w: unknown variant %q for %s (available: %s)", ErrInvalidParameter,
+			dynamicCfg.Variant, templateType, strings.Join(append([]string{config.DefaultVariant}, m.GetVariants(templateType)...), ", "))
+	}
+	modern := outputSchema(loaded.meta, dynamicCfg) == config.SchemaModern
+	if loaded.text != nil {
+		if len(dynamicCfg.Servers) > 0 {
+			return nil, fmt.Errorf("%w: servers is not supported by templated files", ErrInvalidParameter)
+		}
+		cfg, err := m.generateTemplatedConfig(loaded.text, templateType, uuid, dynamicCfg, modern)
+		if err != nil {
+			return nil, err
+		}
+		return cfg, checkCredentials(cfg)
+	}
+	template := utils.DeepCopyMap(loaded.config)
 
 	// Apply dynamic configuration to the template
-	m.updateTemplateWithDynamicConfig(template, dynamicCfg)
-
-	// Set UUID in the first outbound (proxy)
-	if outbounds, ok := template["outbounds"].([]interface{}); ok && len(outbounds) > 0 {
-		if outbound, ok := outbounds[0].(map[string]interface{}); ok {
-			if outbound["type"] == "vless" {
-				outbound["uuid"] = uuid
-				m.logger.WithField("uuid", uuid).Debug("UUID set in configuration")
-			}
+	if err := m.updateTemplateWithDynamicConfig(template, dynamicCfg); err != nil {
+		return nil, err
+	}
+
+	// Set the credential (UUID or password) in every proxy outbound
+	for _, outbound := range utils.ProxyOutbounds(template) {
+		outboundType, _ := outbound["type"].(string)
+		if setCredential, ok := credentialSetters[outboundType]; ok {
+			setCredential(outbound, uuid)
+			m.logger.WithField("outbound_type", outboundType).Debug("Credential set in configuration")
 		}
 	}
 
+	if len(dynamicCfg.Servers) > 0 {
+		if err := m.applyServerGroups(template, loaded.config, uuid, dynamicCfg); err != nil {
+			return nil, err
+		}
+	}
+	if dynamicCfg.Warp {
+		m.addWarpOutbound(template, dynamicCfg)
+	}
+
+	if modern {
+		applyModernSchema(template)
+	}
+
+	// Apply user overrides last so they can change any generated field
+	if dynamicCfg.Patch != nil {
+		template = utils.MergePatch(template, dynamicCfg.Patch).(map[string]interface{})
+	}
+
+	if err := checkCredentials(template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// generateTemplatedConfig renders a templated file with the dynamic parameters and credential.
+// The rendered template sets every value itself; only the schema rewrite and the user's patch are applied on top.
+func (m *Manager) generateTemplatedConfig(text *texttemplate.Template, templateType, uuid string, dynamicCfg *config.DynamicConfig, modern bool) (map[string]interface{}, error) {
+	data, err := renderPlaceholders(text, placeholderData{
+		DynamicConfig: dynamicCfg,
+		UUID:          uuid,
+		Type:          templateType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	template, _, err := decodeTemplate(text.Name(), data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTemplateRender, err)
+	}
+
+	if modern {
+		applyModernSchema(template)
+	}
+	if dynamicCfg.Patch != nil {
+		template = utils.MergePatch(template, dynamicCfg.Patch).(map[string]interface{})
+	}
 	return template, nil
 }
 
+// RequiresUUID reports whether the template's proxy outbound is keyed by a UUID rather than a password
+func (m *Manager) RequiresUUID(templateType string) bool {
+	template, exists := m.lookup(templateType)
+	if !exists {
+		return false
+	}
+	outboundType, _ := m.proxyOutbound(template)["type"].(string)
+	return uuidOutbounds[outboundType]
+}
+
+// uuidOutbounds lists outbound types whose credential is a UUID
+var uuidOutbounds = map[string]bool{
+	"vless": true,
+	"vmess": true,
+}
+
+// credentialSetter stores the per-request secret in a proxy outbound
+type credentialSetter func(outbound map[string]interface{}, credential string)
+
+// credentialSetters maps sing-box outbound types to the field holding their secret
+var credentialSetters = map[string]credentialSetter{
+	"vless":       setUUIDCredential,
+	"vmess":       setUUIDCredential,
+	"trojan":      setPasswordCredential,
+	"shadowsocks": setPasswordCredential,
+	"hysteria2":   setPasswordCredential,
+}
+
+// setUUIDCredential stores the credential as the outbound UUID
+func setUUIDCredential(outbound map[string]interface{}, credential string) {
+	outbound["uuid"] = credential
+}
+
+// setPasswordCredential stores the credential as the outbound password
+func setPasswordCredential(outbound map[string]interface{}, credential string) {
+	outbound["password"] = credential
+}
+
 // updateTemplateWithDynamicConfig updates a template with dynamic configuration values
-func (m *Manager) updateTemplateWithDynamicConfig(template map[string]interface{}, dynamicCfg *config.DynamicConfig) {
-	// Update server address and port in the template
-	if outbounds, ok := template["outbounds"].([]interface{}); ok && len(outbounds) > 0 {
-		if outbound, ok := outbounds[0].(map[string]interface{}); ok {
-			outbound["server"] = dynamicCfg.Server
-			outbound["server_port"] = dynamicCfg.ServerPort
-
-			// Update WebSocket path and Host header
-			if transport, ok := outbound["transport"].(map[string]interface{}); ok {
-				transport["path"] = dynamicCfg.WSPath
-				if headers, ok := transport["headers"].(map[string]interface{}); ok {
-					headers["Host"] = dynamicCfg.Server
-				}
-			}
+func (m *Manager) updateTemplateWithDynamicConfig(template map[string]interface{}, dynamicCfg *config.DynamicConfig) error {
+	// Update every proxy outbound (matched by type, wherever it appears in the list)
+	for _, outbound := range utils.ProxyOutbounds(template) {
+		if err := m.updateProxyOutbound(outbound, dynamicCfg); err != nil {
+			return err
+		}
+	}
 
-			// Update TLS server name if it exists
-			if tls, ok := outbound["tls"].(map[string]interface{}); ok {
-				if _, hasServerName := tls["server_name"]; hasServerName {
-					tls["server_name"] = dynamicCfg.Server
-				}
+	// Update DNS server addresses by tag (see remoteDNSTag and directDNSTag)
+	if _, ok := template["dns"].(map[string]interface{}); ok {
+		dns := dnsSectionOf(template)
+		dns.setServerAddress(remoteDNSTag, dynamicCfg.DNSServer)
+		dns.setServerAddress(directDNSTag, dynamicCfg.DOHServer)
+	}
+
+	if err := m.updateInbounds(template, dynamicCfg); err != nil {
+		return err
+	}
+
+	if dynamicCfg.FakeIP {
+		applyFakeIP(template, dynamicCfg)
+	}
+
+	// Ad blocking goes first so bypassed destinations still get ads filtered
+	if dynamicCfg.BlockAds {
+		m.addAdBlockRules(template)
+	}
+	m.addBypassRules(template, dynamicCfg)
+
+	if dynamicCfg.ClashAPIPort != 0 {
+		m.addClashAPI(template, dynamicCfg)
+	}
+	return nil
+}
+
+// updateProxyOutbound applies the server, transport, TLS and protocol settings to a proxy outbound
+func (m *Manager) updateProxyOutbound(outbound map[string]interface{}, dynamicCfg *config.DynamicConfig) error {
+	outbound["server"] = dynamicCfg.Server
+	outbound["server_port"] = dynamicCfg.ServerPort
+
+	// Update transport (WebSocket path and Host header, or gRPC service)
+	if err := m.updateTransport(outbound, dynamicCfg); err != nil {
+		return err
+	}
+
+	// Update multiplex settings
+	if dynamicCfg.Mux {
+		if err := m.updateMultiplex(outbound, dynamicCfg); err != nil {
+			return err
+		}
+	}
+
+	// Update Hysteria2 bandwidth and obfuscation settings
+	if outbound["type"] == "hysteria2" {
+		outbound["up_mbps"] = dynamicCfg.UpMbps
+		outbound["down_mbps"] = dynamicCfg.DownMbps
+		if dynamicCfg.ObfsPassword != "" {
+			outbound["obfs"] = map[string]interface{}{
+				"type":     "salamander",
+				"password": dynamicCfg.ObfsPassword,
 			}
+		} else {
+			delete(outbound, "obfs")
+		}
+	}
+
+	// Drop the TLS block for plaintext deployments
+	if tls, ok := outbound["tls"].(map[string]interface{}); ok && !dynamicCfg.TLS {
+		if reality, ok := tls["reality"].(map[string]interface{}); ok && reality["enabled"] == true {
+			return fmt.Errorf("%w: reality configurations require TLS", ErrInvalidParameter)
 		}
+		if outbound["type"] == "hysteria2" {
+			return fmt.Errorf("%w: hysteria2 configurations require TLS", ErrInvalidParameter)
+		}
+		delete(outbound, "tls")
 	}
 
-	// Update DNS servers
-	if dns, ok := template["dns"].(map[string]interface{}); ok {
-		if servers, ok := dns["servers"].([]interface{}); ok && len(servers) >= 2 {
-			if server0, ok := servers[0].(map[string]interface{}); ok {
-				server0["address"] = dynamicCfg.DNSServer
+	// Update TLS server name if it exists
+	if tls, ok := outbound["tls"].(map[string]interface{}); ok {
+		if _, hasServerName := tls["server_name"]; hasServerName {
+			tls["server_name"] = dynamicCfg.TLSServerName()
+		}
+
+		// Update uTLS fingerprint
+		if utls, ok := tls["utls"].(map[string]interface{}); ok {
+			if !config.IsValidFingerprint(dynamicCfg.Fingerprint) {
+				return fmt.Errorf("%w: unknown fingerprint %q (supported: %s)",
+					ErrInvalidParameter, dynamicCfg.Fingerprint, strings.Join(config.Fingerprints, ", "))
 			}
-			if server1, ok := servers[1].(map[string]interface{}); ok {
-				server1["address"] = dynamicCfg.DOHServer
+			utls["fingerprint"] = dynamicCfg.Fingerprint
+		}
+
+		// Update ALPN protocols
+		if len(dynamicCfg.ALPN) > 0 {
+			tls["alpn"] = dynamicCfg.ALPN
+		}
+
+		// Update Reality public key and short ID
+		if reality, ok := tls["reality"].(map[string]interface{}); ok && reality["enabled"] == true {
+			if dynamicCfg.RealityPublicKey == "" {
+				return fmt.Errorf("%w: pbk is required for reality configurations", ErrMissingParameter)
 			}
+			reality["public_key"] = dynamicCfg.RealityPublicKey
+			reality["short_id"] = dynamicCfg.RealityShortID
 		}
 	}
+	return nil
+}
+
+// clashAPIUIDownloadURL is the default dashboard served from external_ui
+const clashAPIUIDownloadURL = "https://github.com/MetaCubeX/metacubexd/archive/refs/heads/gh-pages.zip"
+
+// addClashAPI enables the Clash API external controller on localhost
+func (m *Manager) addClashAPI(template map[string]interface{}, dynamicCfg *config.DynamicConfig) {
+	experimental, ok := template["experimental"].(map[string]interface{})
+	if !ok {
+		experimental = make(map[string]interface{})
+		template["experimental"] = experimental
+	}
+	experimental["clash_api"] = map[string]interface{}{
+		"external_controller":         fmt.Sprintf("127.0.0.1:%d", dynamicCfg.ClashAPIPort),
+		"secret":                      dynamicCfg.ClashAPISecret,
+		"external_ui":                 "ui",
+		"external_ui_download_url":    clashAPIUIDownloadURL,
+		"external_ui_download_detour": proxyTag(template),
+	}
+}
 
-	// Update TUN address and MTU
-	if inbounds, ok := template["inbounds"].([]interface{}); ok {
-		if len(inbounds) > 0 {
-			if inbound, ok := inbounds[0].(map[string]interface{}); ok {
-				inbound["inet4_address"] = []string{dynamicCfg.TunAddress}
-				inbound["mtu"] = dynamicCfg.TunMTU
-			}
+// adsRuleSetTag and adsRuleSetURL identify the sing-geosite ads rule set
+const (
+	adsRuleSetTag = "geosite-category-ads-all"
+	adsRuleSetURL = "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/geosite-category-ads-all.srs"
+)
+
+// addAdBlockRules routes ad domains to a block outbound, reusing an existing one when present
+func (m *Manager) addAdBlockRules(template map[string]interface{}) {
+	blockTag := ""
+	outbounds, _ := template["outbounds"].([]interface{})
+	for _, item := range outbounds {
+		if outbound, ok := item.(map[string]interface{}); ok && outbound["type"] == "block" {
+			blockTag, _ = outbound["tag"].(string)
+			break
+		}
+	}
+	if blockTag == "" {
+		blockTag = "block"
+		template["outbounds"] = append(outbounds, map[string]interface{}{
+			"tag":  blockTag,
+			"type": "block",
+		})
+	}
+
+	addRemoteRuleSet(template, adsRuleSetTag, adsRuleSetURL)
+	insertRouteRules(template, []interface{}{
+		map[string]interface{}{
+			"action":   "route",
+			"rule_set": []string{adsRuleSetTag},
+			"outbound": blockTag,
+		},
+	})
+}
+
+// geoIPRuleSetURL is the sing-geoip binary rule set location for a country code
+const geoIPRuleSetURL = "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/geoip-%s.srs"
+
+// addBypassRules routes the requested domains, CIDRs and GeoIP countries to the direct outbound
+func (m *Manager) addBypassRules(template map[string]interface{}, dynamicCfg *config.DynamicConfig) {
+	var rules []interface{}
+	if len(dynamicCfg.BypassDomains) > 0 {
+		rules = append(rules, map[string]interface{}{
+			"action":        "route",
+			"domain_suffix": dynamicCfg.BypassDomains,
+			"outbound":      "direct",
+		})
+	}
+	if len(dynamicCfg.BypassCIDRs) > 0 {
+		rules = append(rules, map[string]interface{}{
+			"action":   "route",
+			"ip_cidr":  dynamicCfg.BypassCIDRs,
+			"outbound": "direct",
+		})
+	}
+	if len(dynamicCfg.BypassGeoIP) > 0 {
+		tags := make([]string, 0, len(dynamicCfg.BypassGeoIP))
+		for _, code := range dynamicCfg.BypassGeoIP {
+			tag := "geoip-" + code
+			addRemoteRuleSet(template, tag, fmt.Sprintf(geoIPRuleSetURL, code))
+			tags = append(tags, tag)
+		}
+		rules = append(rules, map[string]interface{}{
+			"action":   "route",
+			"rule_set": tags,
+			"outbound": "direct",
+		})
+	}
+
+	if len(rules) > 0 {
+		insertRouteRules(template, rules)
+	}
+}
+
+// addRemoteRuleSet adds a remote binary rule set definition unless one with the same tag exists
+func addRemoteRuleSet(template map[string]interface{}, tag, url string) {
+	route := routeSection(template)
+	ruleSets, _ := route["rule_set"].([]interface{})
+	for _, item := range ruleSets {
+		if ruleSet, ok := item.(map[string]interface{}); ok && ruleSet["tag"] == tag {
+			return
+		}
+	}
+	route["rule_set"] = append(ruleSets, map[string]interface{}{
+		"tag":             tag,
+		"type":            "remote",
+		"format":          "binary",
+		"url":             url,
+		"download_detour": proxyTag(template),
+	})
+}
+
+// insertRouteRules inserts rules before the first catch-all rule (one with no matchers), or appends them
+func insertRouteRules(template map[string]interface{}, newRules []interface{}) {
+	route := routeSection(template)
+	rules, _ := route["rules"].([]interface{})
+
+	position := len(rules)
+	for i, item := range rules {
+		if rule, ok := item.(map[string]interface{}); ok && isCatchAllRule(rule) {
+			position = i
+			break
+		}
+	}
+
+	result := make([]interface{}, 0, len(rules)+len(newRules))
+	result = append(result, rules[:position]...)
+	result = append(result, newRules...)
+	result = append(result, rules[position:]...)
+	route["rules"] = result
+}
+
+// isCatchAllRule reports whether a route rule has no matching conditions
+func isCatchAllRule(rule map[string]interface{}) bool {
+	for key := range rule {
+		if key != "action" && key != "outbound" {
+			return false
+		}
+	}
+	return true
+}
+
+// routeSection returns the template's route object, creating it if missing
+func routeSection(template map[string]interface{}) map[string]interface{} {
+	route, ok := template["route"].(map[string]interface{})
+	if !ok {
+		route = make(map[string]interface{})
+		template["route"] = route
+	}
+	return route
+}
+
+// updateInbounds updates the TUN and mixed inbounds (matched by type) and removes disabled ones
+func (m *Manager) updateInbounds(template map[string]interface{}, dynamicCfg *config.DynamicConfig) error {
+	if !dynamicCfg.TunEnabled && !dynamicCfg.MixedEnabled {
+		return fmt.Errorf("%w: at least one of tun or mixed inbounds must be enabled", ErrInvalidParameter)
+	}
+
+	inbounds, ok := template["inbounds"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	kept := make([]interface{}, 0, len(inbounds))
+	removedTags := make(map[string]bool)
+	for _, item := range inbounds {
+		inbound, ok := item.(map[string]interface{})
+		if !ok {
+			kept = append(kept, item)
+			continue
 		}
 
-		// Update mixed port
-		if len(inbounds) > 1 {
-			if inboundMixed, ok := inbounds[1].(map[string]interface{}); ok {
-				inboundMixed["listen_port"] = dynamicCfg.MixedPort
+		switch inbound["type"] {
+		case "tun":
+			if !dynamicCfg.TunEnabled {
+				if tag, ok := inbound["tag"].(string); ok {
+					removedTags[tag] = true
+				}
+				continue
 			}
+			inbound["inet4_address"] = []string{dynamicCfg.TunAddress}
+			inbound["mtu"] = dynamicCfg.TunMTU
+		case "mixed":
+			if !dynamicCfg.MixedEnabled {
+				if tag, ok := inbound["tag"].(string); ok {
+					removedTags[tag] = true
+				}
+				continue
+			}
+			inbound["listen_port"] = dynamicCfg.MixedPort
 		}
+		kept = append(kept, inbound)
 	}
+	template["inbounds"] = kept
+
+	if len(removedTags) > 0 {
+		removeInboundRules(template, removedTags)
+	}
+	return nil
 }
 
-// deepCopyMap creates a deep copy of a map
-func (m *Manager) deepCopyMap(original map[string]interface{}) map[string]interface{} {
-	result := make(map[string]interface{})
-	for key, value := range original {
-		switch v := value.(type) {
-		case map[string]interface{}:
-			result[key] = m.deepCopyMap(v)
+// removeInboundRules drops route rules that only match removed inbound tags
+func removeInboundRules(template map[string]interface{}, removedTags map[string]bool) {
+	route, ok := template["route"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	rules, ok := route["rules"].([]interface{})
+	if !ok {
+		return
+	}
+
+	kept := make([]interface{}, 0, len(rules))
+	for _, item := range rules {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			kept = append(kept, item)
+			continue
+		}
+
+		switch inbound := rule["inbound"].(type) {
+		case string:
+			if removedTags[inbound] {
+				continue
+			}
 		case []interface{}:
-			result[key] = m.deepCopySlice(v)
-		default:
-			result[key] = value
+			remaining := make([]interface{}, 0, len(inbound))
+			for _, tag := range inbound {
+				if name, ok := tag.(string); !ok || !removedTags[name] {
+					remaining = append(remaining, tag)
+				}
+			}
+			if len(remaining) == 0 {
+				continue
+			}
+			rule["inbound"] = remaining
 		}
+		kept = append(kept, rule)
 	}
-	return result
+	route["rules"] = kept
 }
 
-// deepCopySlice creates a deep copy of a slice
-func (m *Manager) deepCopySlice(original []interface{}) []interface{} {
-	result := make([]interface{}, len(original))
-	for i, value := range original {
-		switch v := value.(type) {
-		case map[string]interface{}:
-			result[i] = m.deepCopyMap(v)
-		case []interface{}:
-			result[i] = m.deepCopySlice(v)
-		default:
-			result[i] = value
+// v2rayTransportOutbounds lists outbound types that support v2ray transports
+var v2rayTransportOutbounds = map[string]bool{
+	"vless":  true,
+	"vmess":  true,
+	"trojan": true,
+}
+
+// updateTransport applies the requested transport mode to a proxy outbound
+func (m *Manager) updateTransport(outbound map[string]interface{}, dynamicCfg *config.DynamicConfig) error {
+	switch dynamicCfg.Transport {
+	case "", "ws":
+		// Keep the template's WebSocket transport, if any
+		if transport, ok := outbound["transport"].(map[string]interface{}); ok {
+			transport["path"] = dynamicCfg.WSPath
+			if headers, ok := transport["headers"].(map[string]interface{}); ok {
+				headers["Host"] = dynamicCfg.HostHeader()
+			}
 		}
+	case "grpc":
+		outboundType, _ := outbound["type"].(string)
+		if !v2rayTransportOutbounds[outboundType] {
+			return fmt.Errorf("%w: transport grpc is not supported for %s outbounds", ErrInvalidParameter, outboundType)
+		}
+		// Replace the ws block (or add one if the template has no transport)
+		outbound["transport"] = map[string]interface{}{
+			"type":         "grpc",
+			"service_name": dynamicCfg.GRPCServiceName,
+		}
+	case "tcp":
+		// Plain TCP: no v2ray transport block at all
+		delete(outbound, "transport")
+	default:
+		return fmt.Errorf("%w: unsupported transport %q", ErrInvalidParameter, dynamicCfg.Transport)
+	}
+
+	// Apply VLESS flow; plain TCP defaults to XTLS Vision
+	flow := dynamicCfg.Flow
+	if flow == "" && dynamicCfg.Transport == "tcp" && outbound["type"] == "vless" {
+		flow = "xtls-rprx-vision"
+	}
+	if flow != "" {
+		if outbound["type"] != "vless" {
+			return fmt.Errorf("%w: flow is only supported for vless outbounds", ErrInvalidParameter)
+		}
+		outbound["flow"] = flow
+	}
+
+	return nil
+}
+
+// muxOutbounds lists outbound types that support sing-box multiplex
+var muxOutbounds = map[string]bool{
+	"vless":       true,
+	"vmess":       true,
+	"trojan":      true,
+	"shadowsocks": true,
+}
+
+// updateMultiplex adds the multiplex block to a proxy outbound
+func (m *Manager) updateMultiplex(outbound map[string]interface{}, dynamicCfg *config.DynamicConfig) error {
+	outboundType, _ := outbound["type"].(string)
+	if !muxOutbounds[outboundType] {
+		return fmt.Errorf("%w: mux is not supported for %s outbounds", ErrInvalidParameter, outboundType)
+	}
+	if flow, _ := outbound["flow"].(string); flow != "" {
+		return fmt.Errorf("%w: mux cannot be combined with flow %s", ErrInvalidParameter, flow)
 	}
-	return result
+
+	multiplex := map[string]interface{}{
+		"enabled":  true,
+		"protocol": dynamicCfg.MuxProtocol,
+	}
+	if dynamicCfg.MuxMaxStreams > 0 {
+		multiplex["max_streams"] = dynamicCfg.MuxMaxStreams
+	}
+	outbound["multiplex"] = multiplex
+	return nil
 }
@@ -0,0 +1,426 @@
+package templates
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/utils"
+)
+
+// Credentials used by the tests
+const (
+	testUUID       = "bae71742-94e0-4dd5-935f-070339819ba0"
+	testRealityKey = "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0"
+	testSSKey      = "AAECAwQFBgcICQoLDA0ODw=="
+)
+
+// testTypes are the template types shipped in the repository
+var testTypes = []string{"vless", "vless-reality", "trojan", "vmess", "shadowsocks", "hysteria2"}
+
+func init() {
+	logrus.SetOutput(io.Discard)
+}
+
+// testCredential returns a credential accepted by templateType: the 16-byte key of the
+// shipped 2022-blake3-aes-128-gcm shadowsocks template, testUUID for the rest
+func testCredential(templateType string) string {
+	if templateType == "shadowsocks" {
+		return testSSKey
+	}
+	return testUUID
+}
+
+// newTestManager loads the repository templates
+func newTestManager(t testing.TB) *Manager {
+	t.Helper()
+	m := NewManager(os.DirFS("../../templates"))
+	if err := m.LoadTemplates(testTypes); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	return m
+}
+
+func TestGenerateConfigConcurrent(t *testing.T) {
+	// Run with -race: concurrent requests mutate their configs while templates are reloaded
+	m := newTestManager(t)
+	vless, err := os.ReadFile("../../templates/vless.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				dynamicCfg := config.DefaultDynamicConfig()
+				dynamicCfg.TunEnabled = true
+				dynamicCfg.RealityPublicKey = testRealityKey
+				templateType := testTypes[i%len(testTypes)]
+				cfg, err := m.GenerateConfig(templateType, testCredential(templateType), dynamicCfg)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mutateAll(cfg)
+				if _, err := json.Marshal(cfg); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			for file, err := range m.Reload() {
+				if err != nil {
+					t.Errorf("Reload %s: %v", file, err)
+				}
+			}
+			if err := m.PutTemplate("vless", vless); err != nil {
+				t.Errorf("PutTemplate: %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	// Templates still generate the same config after all of it
+	first, err := m.GenerateConfig("vless", testUUID, config.DefaultDynamicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := m.GenerateConfig("vless", testUUID, config.DefaultDynamicConfig())
+	a, _ := json.Marshal(first)
+	b, _ := json.Marshal(second)
+	if string(a) != string(b) {
+		t.Fatal("generated configs differ after concurrent mutation")
+	}
+}
+
+// mutateAll overwrites every string in a generated config, including inside typed slices
+func mutateAll(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if _, ok := item.(string); ok {
+				v[key] = "mutated"
+				continue
+			}
+			mutateAll(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			if _, ok := item.(string); ok {
+				v[i] = "mutated"
+				continue
+			}
+			mutateAll(item)
+		}
+	case []string:
+		for i := range v {
+			v[i] = "mutated"
+		}
+	}
+}
+
+func BenchmarkGenerateConfig(b *testing.B) {
+	m := newTestManager(b)
+	dynamicCfg := config.DefaultDynamicConfig()
+	dynamicCfg.RealityPublicKey = testRealityKey
+	for _, templateType := range []string{"vless", "vless-reality", "shadowsocks"} {
+		b.Run(templateType, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.GenerateConfig(templateType, testCredential(templateType), dynamicCfg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTemplateCopy compares walking the decoded template with unmarshalling a cached
+// copy of its JSON, the two ways of handing every request its own template
+func BenchmarkTemplateCopy(b *testing.B) {
+	m := newTestManager(b)
+	template, ok := m.lookup("vless")
+	if !ok {
+		b.Fatal("vless template missing")
+	}
+	raw, err := json.Marshal(template)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("walk", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = utils.DeepCopyMap(template)
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var copied map[string]interface{}
+			if err := utils.DecodeJSON(raw, &copied); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGenerateConfigCDNFronting(t *testing.T) {
+	m := newTestManager(t)
+	tests := []struct {
+		name, sni, host             string
+		wantSNI, wantHost           string
+		wantQuerySNI, wantQueryHost string
+	}{
+		{"CDN IP with real-domain SNI", "real.example.com", "", "real.example.com", "real.example.com", "real.example.com", "real.example.com"},
+		{"separate Host header", "real.example.com", "ws.example.com", "real.example.com", "ws.example.com", "real.example.com", "ws.example.com"},
+		{"no SNI falls back to the server", "", "", "104.16.1.1", "104.16.1.1", "", "104.16.1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := url.Values{"server": {"104.16.1.1"}}
+			if tt.sni != "" {
+				values.Set("sni", tt.sni)
+			}
+			if tt.host != "" {
+				values.Set("host", tt.host)
+			}
+			dynamicCfg, paramErrs := config.ParseDynamicConfig(values, nil)
+			if len(paramErrs) > 0 {
+				t.Fatal(paramErrs)
+			}
+			cfg, err := m.GenerateConfig("vless", testUUID, dynamicCfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outbound, err := utils.ProxyOutbound(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outbound["server"] != "104.16.1.1" {
+				t.Errorf("server = %v, want the CDN IP", outbound["server"])
+			}
+			if got := outbound["tls"].(map[string]interface{})["server_name"]; got != tt.wantSNI {
+				t.Errorf("tls.server_name = %v, want %s", got, tt.wantSNI)
+			}
+			headers := outbound["transport"].(map[string]interface{})["headers"].(map[string]interface{})
+			if headers["Host"] != tt.wantHost {
+				t.Errorf("Host header = %v, want %s", headers["Host"], tt.wantHost)
+			}
+
+			shareURL, err := utils.GenerateVlessURL(cfg, testUUID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := url.Parse(shareURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Hostname() != "104.16.1.1" {
+				t.Errorf("share URL host = %s, want the CDN IP", parsed.Hostname())
+			}
+			if got := parsed.Query().Get("sni"); got != tt.wantQuerySNI {
+				t.Errorf("share URL sni = %q, want %q", got, tt.wantQuerySNI)
+			}
+			if got := parsed.Query().Get("host"); got != tt.wantQueryHost {
+				t.Errorf("share URL host = %q, want %q", got, tt.wantQueryHost)
+			}
+		})
+	}
+}
+
+// reorderedTemplate puts a selector before two proxy outbounds, the mixed inbound
+// before the TUN one and extra DNS servers around the tagged ones
+const reorderedTemplate = `{
+  "dns": {
+    "servers": [
+      {"tag": "dns-local", "address": "local"},
+      {"tag": "dns-direct", "address": ""},
+      {"tag": "dns-extra", "address": "1.1.1.1"},
+      {"tag": "dns-remote", "address": ""}
+    ]
+  },
+  "inbounds": [
+    {"tag": "mixed-in", "type": "mixed", "listen": "127.0.0.1", "listen_port": 0},
+    {"tag": "socks-in", "type": "socks", "listen_port": 1080},
+    {"tag": "tun-in", "type": "tun", "inet4_address": [""], "mtu": 0}
+  ],
+  "outbounds": [
+    {"tag": "select", "type": "selector", "outbounds": ["proxy-a", "proxy-b"]},
+    {"tag": "direct", "type": "direct"},
+    {
+      "tag": "proxy-a", "type": "vless", "server": "", "server_port": 0, "uuid": "",
+      "tls": {"enabled": true, "server_name": ""},
+      "transport": {"type": "ws", "path": "", "headers": {"Host": ""}}
+    },
+    {
+      "tag": "proxy-b", "type": "vless", "server": "", "server_port": 0, "uuid": "",
+      "tls": {"enabled": true, "server_name": ""},
+      "transport": {"type": "ws", "path": "", "headers": {"Host": ""}}
+    }
+  ],
+  "route": {
+    "rules": [
+      {"inbound": ["tun-in"], "action": "sniff"},
+      {"inbound": ["mixed-in", "tun-in"], "outbound": "select"}
+    ]
+  }
+}`
+
+func TestGenerateConfigReorderedTemplate(t *testing.T) {
+	m := NewManager(fstest.MapFS{"vless.json": {Data: []byte(reorderedTemplate)}})
+	if err := m.LoadTemplates([]string{"vless"}); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+
+	t.Run("updates matches wherever they are", func(t *testing.T) {
+		dynamicCfg := config.DefaultDynamicConfig()
+		cfg, err := m.GenerateConfig("vless", testUUID, dynamicCfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		outbounds := cfg["outbounds"].([]interface{})
+		selector := outbounds[0].(map[string]interface{})
+		if _, ok := selector["uuid"]; ok {
+			t.Errorf("selector outbound got a uuid: %v", selector)
+		}
+		if _, ok := selector["server"]; ok {
+			t.Errorf("selector outbound got a server: %v", selector)
+		}
+		for _, i := range []int{2, 3} {
+			proxy := outbounds[i].(map[string]interface{})
+			if proxy["uuid"] != testUUID {
+				t.Errorf("%s uuid = %v, want %s", proxy["tag"], proxy["uuid"], testUUID)
+			}
+			if proxy["server"] != dynamicCfg.Server || proxy["server_port"] != dynamicCfg.ServerPort {
+				t.Errorf("%s server = %v:%v, want %s:%d", proxy["tag"], proxy["server"], proxy["server_port"], dynamicCfg.Server, dynamicCfg.ServerPort)
+			}
+		}
+
+		inbounds := cfg["inbounds"].([]interface{})
+		mixed := inbounds[0].(map[string]interface{})
+		if mixed["listen_port"] != dynamicCfg.MixedPort {
+			t.Errorf("mixed listen_port = %v, want %d", mixed["listen_port"], dynamicCfg.MixedPort)
+		}
+		if _, ok := mixed["mtu"]; ok {
+			t.Errorf("mixed inbound got TUN settings: %v", mixed)
+		}
+		socks := inbounds[1].(map[string]interface{})
+		if port, _ := socks["listen_port"].(json.Number); port != "1080" {
+			t.Errorf("socks listen_port = %v, want it untouched", socks["listen_port"])
+		}
+		tun := inbounds[2].(map[string]interface{})
+		if tun["mtu"] != dynamicCfg.TunMTU {
+			t.Errorf("tun mtu = %v, want %d", tun["mtu"], dynamicCfg.TunMTU)
+		}
+
+		want := map[string]string{
+			"dns-local":  "local",
+			"dns-direct": dynamicCfg.DOHServer,
+			"dns-extra":  "1.1.1.1",
+			"dns-remote": dynamicCfg.DNSServer,
+		}
+		for _, item := range cfg["dns"].(map[string]interface{})["servers"].([]interface{}) {
+			server := item.(map[string]interface{})
+			tag := server["tag"].(string)
+			if server["address"] != want[tag] {
+				t.Errorf("%s address = %v, want %s", tag, server["address"], want[tag])
+			}
+		}
+	})
+
+	t.Run("removes a disabled inbound that is not first", func(t *testing.T) {
+		dynamicCfg := config.DefaultDynamicConfig()
+		dynamicCfg.TunEnabled = false
+		cfg, err := m.GenerateConfig("vless", testUUID, dynamicCfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var tags []string
+		for _, item := range cfg["inbounds"].([]interface{}) {
+			tags = append(tags, item.(map[string]interface{})["tag"].(string))
+		}
+		if strings.Join(tags, ",") != "mixed-in,socks-in" {
+			t.Errorf("inbounds = %v, want mixed-in,socks-in", tags)
+		}
+
+		rules := cfg["route"].(map[string]interface{})["rules"].([]interface{})
+		if len(rules) != 1 {
+			t.Fatalf("route rules = %v, want only the mixed rule", rules)
+		}
+		inbound := rules[0].(map[string]interface{})["inbound"].([]interface{})
+		if len(inbound) != 1 || inbound[0] != "mixed-in" {
+			t.Errorf("rule inbound = %v, want [mixed-in]", inbound)
+		}
+	})
+}
+
+func TestGenerateConfigKeepsLargeIntegers(t *testing.T) {
+	data, err := os.ReadFile("../../templates/vless.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// default_mark is a uint32 that float64 decoding would write as 4.294967295e+09
+	var template map[string]interface{}
+	if err := utils.DecodeJSON(data, &template); err != nil {
+		t.Fatal(err)
+	}
+	template["route"].(map[string]interface{})["default_mark"] = json.Number("4294967295")
+	template["experimental"] = map[string]interface{}{"cache_file": map[string]interface{}{"store_rdrc_ttl": json.Number("9007199254740993")}}
+	data, err = json.Marshal(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestManager(t)
+	if err := m.PutTemplate("vless", data); err != nil {
+		t.Fatal(err)
+	}
+	dynamicCfg := config.DefaultDynamicConfig()
+	cfg, err := m.GenerateConfig("vless", testUUID, dynamicCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"default_mark":4294967295`, `"store_rdrc_ttl":9007199254740993`, `"mtu":9000`, `"server_port":443`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("generated config is missing %s", want)
+		}
+	}
+
+	// The share URL reads the json.Number port of an untouched outbound
+	outbound, err := utils.ProxyOutbound(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbound["server_port"] = json.Number("8443")
+	shareURL, err := utils.GenerateVlessURL(cfg, testUUID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed, err := url.Parse(shareURL); err != nil || parsed.Port() != "8443" {
+		t.Errorf("share URL %s, want port 8443", shareURL)
+	}
+}
@@ -0,0 +1,190 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Errors returned by PutTemplate and DeleteTemplate
+var (
+	// ErrInvalidType is returned for template type names other than lowercase letters, digits and hyphens
+	ErrInvalidType = errors.New("invalid template type")
+	// ErrNoOverride is returned when deleting a type that was not uploaded
+	ErrNoOverride = errors.New("template has no runtime override")
+)
+
+// maxTypeNameLength bounds the length of uploaded template type names
+const maxTypeNameLength = 32
+
+// TemplateError reports why a template file was rejected, with every validation problem found
+type TemplateError struct {
+	File     string
+	Problems []error
+}
+
+// Error lists the problems of the template
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("invalid template %s: %v", e.File, errors.Join(e.Problems...))
+}
+
+// Unwrap returns the problems of the template
+func (e *TemplateError) Unwrap() []error {
+	return e.Problems
+}
+
+// LoadOverrides persists uploaded templates in dir and loads the ones uploaded before a
+// restart; call it before LoadTemplates. Without it uploads only last until the process exits.
+func (m *Manager) LoadOverrides(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create template overrides directory: %w", err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list template overrides: %w", err)
+	}
+
+	overrides := make(map[string][]byte, len(matches))
+	for _, match := range matches {
+		templateType := strings.TrimSuffix(filepath.Base(match), ".json")
+		if !isValidTypeName(templateType) {
+			m.logger.WithField("file", match).Warn("Ignoring template override with an invalid type name")
+			continue
+		}
+		data, err := os.ReadFile(match)
+		if err != nil {
+			return fmt.Errorf("failed to read template override: %w", err)
+		}
+		overrides[templateType] = data
+	}
+
+	m.mu.Lock()
+	m.overrideDir = dir
+	m.overrides = overrides
+	m.mu.Unlock()
+
+	if len(overrides) > 0 {
+		m.logger.WithFields(logrus.Fields{"dir": dir, "count": len(overrides)}).Info("Loaded template overrides")
+	}
+	return nil
+}
+
+// PutTemplate validates data as the template of a type and serves it instead of the embedded
+// or on-disk file, persisting it when an overrides directory is set. Invalid templates are
+// reported as a *TemplateError and change nothing.
+func (m *Manager) PutTemplate(templateType string, data []byte) error {
+	if !isValidTypeName(templateType) {
+		return fmt.Errorf("%w: %q", ErrInvalidType, templateType)
+	}
+	template, err := parseTemplate(templateType, data)
+	if err != nil {
+		var templateErr *TemplateError
+		if !errors.As(err, &templateErr) {
+			templateErr = &TemplateError{File: templateFileName(templateType), Problems: []error{err}}
+		}
+		return templateErr
+	}
+
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+
+	if m.overrideDir != "" {
+		if err := writeFileAtomic(filepath.Join(m.overrideDir, templateFileName(templateType)), data); err != nil {
+			return fmt.Errorf("failed to persist template override: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	m.overrides[templateType] = data
+	m.templates[templateType] = template
+	m.mu.Unlock()
+
+	m.logger.WithField("type", templateType).Info("Template override installed")
+	return nil
+}
+
+// DeleteTemplate removes the uploaded template of a type and serves the embedded or on-disk
+// file again; a type that only existed as an upload is removed together with its variants
+func (m *Manager) DeleteTemplate(templateType string) error {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+
+	m.mu.RLock()
+	_, overridden := m.overrides[templateType]
+	m.mu.RUnlock()
+	if !overridden {
+		return ErrNoOverride
+	}
+
+	// Check the fallback before touching anything, so a broken file never replaces a working upload
+	var fallback *loadedTemplate
+	data, err := fs.ReadFile(m.configFS, templateFileName(templateType))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read template file: %w", err)
+	default:
+		if fallback, err = parseTemplate(templateType, data); err != nil {
+			return err
+		}
+	}
+
+	if m.overrideDir != "" {
+		err := os.Remove(filepath.Join(m.overrideDir, templateFileName(templateType)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove template override: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	delete(m.overrides, templateType)
+	if fallback != nil {
+		m.templates[templateType] = fallback
+	} else {
+		for key := range m.templates {
+			if baseType(key) == templateType {
+				delete(m.templates, key)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	m.logger.WithFields(logrus.Fields{"type": templateType, "removed": fallback == nil}).Info("Template override deleted")
+	return nil
+}
+
+// isValidTypeName reports whether name can be used as an uploaded template type: lowercase
+// letters, digits and hyphens, which keeps it a single path segment and file name
+func isValidTypeName(name string) bool {
+	if name == "" || len(name) > maxTypeNameLength {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,167 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vless-generator/internal/config"
+)
+
+// uploadedVless returns the repository vless template with its log level changed, so
+// configs generated from the upload can be told apart
+func uploadedVless(t *testing.T, level string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../templates/vless.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := strings.Replace(string(data), `"level": "panic"`, `"level": "`+level+`"`, 1)
+	if upload == string(data) {
+		t.Fatal("vless.json has no panic log level to replace")
+	}
+	return []byte(upload)
+}
+
+// generatedLogLevel returns the log level of the vless config generated by m
+func generatedLogLevel(t *testing.T, m *Manager) string {
+	t.Helper()
+	cfg, err := m.GenerateConfig("vless", testUUID, config.DefaultDynamicConfig())
+	if err != nil {
+		t.Fatalf("GenerateConfig: %v", err)
+	}
+	log, _ := cfg["log"].(map[string]interface{})
+	level, _ := log["level"].(string)
+	return level
+}
+
+func TestPutTemplate(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.PutTemplate("vless", uploadedVless(t, "warn")); err != nil {
+		t.Fatalf("PutTemplate: %v", err)
+	}
+	if level := generatedLogLevel(t, m); level != "warn" {
+		t.Errorf("log level = %q, want the uploaded warn", level)
+	}
+	if overrides := m.Status().Overrides; len(overrides) != 1 || overrides[0] != "vless" {
+		t.Errorf("Status().Overrides = %v, want [vless]", overrides)
+	}
+
+	// Reloading keeps serving the upload rather than the file underneath
+	for file, err := range m.Reload() {
+		if err != nil {
+			t.Errorf("Reload %s: %v", file, err)
+		}
+	}
+	if level := generatedLogLevel(t, m); level != "warn" {
+		t.Errorf("log level after Reload = %q, want the uploaded warn", level)
+	}
+}
+
+func TestPutTemplateRejects(t *testing.T) {
+	tests := []struct {
+		name         string
+		templateType string
+		data         string
+		wantType     bool // ErrInvalidType rather than a *TemplateError
+	}{
+		{"uppercase type", "Vless", `{}`, true},
+		{"path in type", "../vless", `{}`, true},
+		{"empty type", "", `{}`, true},
+		{"long type", strings.Repeat("a", maxTypeNameLength+1), `{}`, true},
+		{"invalid JSON", "vless", `{"outbounds": [`, false},
+		{"no proxy outbound", "vless", `{"outbounds": [{"type": "direct", "tag": "direct"}]}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			err := m.PutTemplate(tt.templateType, []byte(tt.data))
+			var templateErr *TemplateError
+			switch {
+			case tt.wantType && !errors.Is(err, ErrInvalidType):
+				t.Fatalf("PutTemplate error = %v, want ErrInvalidType", err)
+			case !tt.wantType && !errors.As(err, &templateErr):
+				t.Fatalf("PutTemplate error = %v, want a *TemplateError", err)
+			case !tt.wantType && len(templateErr.Problems) == 0:
+				t.Error("TemplateError lists no problems")
+			}
+			if level := generatedLogLevel(t, m); level != "panic" {
+				t.Errorf("log level = %q, want the repository template still served", level)
+			}
+		})
+	}
+}
+
+func TestTemplateOverridesPersist(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(os.DirFS("../../templates"))
+	if err := m.LoadOverrides(dir); err != nil {
+		t.Fatalf("LoadOverrides: %v", err)
+	}
+	if err := m.LoadTemplates(testTypes); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	upload := uploadedVless(t, "warn")
+	if err := m.PutTemplate("vless", upload); err != nil {
+		t.Fatalf("PutTemplate: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "vless.json")); err != nil || string(data) != string(upload) {
+		t.Fatalf("override file = %q, %v, want the upload", data, err)
+	}
+
+	// A restarted manager serves the upload
+	restarted := NewManager(os.DirFS("../../templates"))
+	if err := restarted.LoadOverrides(dir); err != nil {
+		t.Fatalf("LoadOverrides: %v", err)
+	}
+	if err := restarted.LoadTemplates(testTypes); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	if level := generatedLogLevel(t, restarted); level != "warn" {
+		t.Errorf("log level after restart = %q, want the uploaded warn", level)
+	}
+
+	// Deleting removes the file, so the next restart serves the repository template
+	if err := restarted.DeleteTemplate("vless"); err != nil {
+		t.Fatalf("DeleteTemplate: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vless.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("override file after DeleteTemplate: %v, want it removed", err)
+	}
+}
+
+func TestDeleteTemplate(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.DeleteTemplate("vless"); !errors.Is(err, ErrNoOverride) {
+		t.Fatalf("DeleteTemplate without an upload error = %v, want ErrNoOverride", err)
+	}
+
+	if err := m.PutTemplate("vless", uploadedVless(t, "warn")); err != nil {
+		t.Fatalf("PutTemplate: %v", err)
+	}
+	if err := m.DeleteTemplate("vless"); err != nil {
+		t.Fatalf("DeleteTemplate: %v", err)
+	}
+	if level := generatedLogLevel(t, m); level != "panic" {
+		t.Errorf("log level = %q, want the repository template back", level)
+	}
+	if err := m.DeleteTemplate("vless"); !errors.Is(err, ErrNoOverride) {
+		t.Errorf("second DeleteTemplate error = %v, want ErrNoOverride", err)
+	}
+
+	// A type that only existed as an upload disappears
+	if err := m.PutTemplate("custom", uploadedVless(t, "warn")); err != nil {
+		t.Fatalf("PutTemplate: %v", err)
+	}
+	if !m.HasTemplate("custom") {
+		t.Fatal("uploaded type is not served")
+	}
+	if err := m.DeleteTemplate("custom"); err != nil {
+		t.Fatalf("DeleteTemplate: %v", err)
+	}
+	if m.HasTemplate("custom") {
+		t.Error("uploaded type is still served after DeleteTemplate")
+	}
+}
@@ -0,0 +1,61 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	texttemplate "text/template"
+
+	"vless-generator/internal/config"
+)
+
+// ErrTemplateRender is returned when a templated file cannot be executed or does not produce valid JSON
+var ErrTemplateRender = errors.New("template rendering failed")
+
+// placeholderSampleUUID is the credential used to check templated files at load time
+const placeholderSampleUUID = "00000000-0000-4000-8000-000000000000"
+
+// placeholderData is the data of templated files: every DynamicConfig field and method
+// (e.g. {{ .Server }}, {{ .WSPath }}, {{ .TLSServerName }}) plus the credential and type
+type placeholderData struct {
+	*config.DynamicConfig
+	UUID string // Request credential (UUID or password)
+	Type string // Template type, without the variant
+}
+
+// hasPlaceholders reports whether a template file uses text/template placeholders
+func hasPlaceholders(data []byte) bool {
+	return strings.Contains(string(data), "{{")
+}
+
+// placeholderFuncs are the functions available in templated files; {{ json .Name }}
+// writes a value as a JSON literal, quoting and escaping strings
+var placeholderFuncs = texttemplate.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// parsePlaceholders parses a templated file; missing map keys fail instead of printing <no value>
+func parsePlaceholders(name string, data []byte) (*texttemplate.Template, error) {
+	tmpl, err := texttemplate.New(name).Funcs(placeholderFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template placeholders: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderPlaceholders executes a templated file and checks that the result is valid JSON
+func renderPlaceholders(tmpl *texttemplate.Template, data placeholderData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrTemplateRender, tmpl.Name(), err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("%w: %s did not produce valid JSON", ErrTemplateRender, tmpl.Name())
+	}
+	return buf.Bytes(), nil
+}
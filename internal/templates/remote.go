@@ -0,0 +1,145 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxRemoteTemplateBytes caps the size of a fetched template file
+const maxRemoteTemplateBytes = 4 << 20
+
+// RemoteFS serves template files fetched from <baseURL>/<name>. Every fetched
+// file is cached on disk; when a fetch fails the cached copy is served instead,
+// so a restart keeps working while the URL is down. Variants are not discovered
+// remotely since a plain HTTP location cannot be listed.
+type RemoteFS struct {
+	baseURL  string
+	cacheDir string
+	cache    fs.FS
+	client   *http.Client
+	logger   *logrus.Entry
+
+	mu     sync.Mutex
+	errors map[string]string // Last fetch error per file; cleared on success
+}
+
+// NewRemoteFS creates a remote template filesystem caching files in cacheDir
+func NewRemoteFS(baseURL, cacheDir string, timeout time.Duration) (*RemoteFS, error) {
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create templates cache directory: %w", err)
+	}
+	return &RemoteFS{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		cacheDir: cacheDir,
+		cache:    os.DirFS(cacheDir),
+		client:   &http.Client{Timeout: timeout},
+		logger:   logrus.WithField("component", "remote_templates"),
+		errors:   make(map[string]string),
+	}, nil
+}
+
+// Open fetches name from the remote location, refreshes the cache and opens the cached copy
+func (r *RemoteFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	// The root is only opened for listing, which is served from the cache
+	if name == "." {
+		return r.cache.Open(name)
+	}
+
+	if err := r.fetch(name); err != nil {
+		r.logger.WithError(err).WithField("file", name).Warn("Failed to fetch template, using cached copy")
+		r.setError(name, err)
+	} else {
+		r.setError(name, nil)
+	}
+	return r.cache.Open(name)
+}
+
+// fetch downloads a file and atomically replaces its cached copy
+func (r *RemoteFS) fetch(name string) error {
+	resp, err := r.client.Get(r.baseURL + "/" + name)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: unexpected status %s", name, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteTemplateBytes+1))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxRemoteTemplateBytes {
+		return fmt.Errorf("%s exceeds %d bytes", name, maxRemoteTemplateBytes)
+	}
+	// Never let a broken response replace a working cached copy
+	if !json.Valid(data) {
+		return fmt.Errorf("%s is not valid JSON", name)
+	}
+
+	tmp, err := os.CreateTemp(r.cacheDir, ".fetch-*")
+	if err != nil {
+		return fmt.Errorf("failed to cache %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to cache %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to cache %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(r.cacheDir, filepath.FromSlash(name))); err != nil {
+		return fmt.Errorf("failed to cache %s: %w", name, err)
+	}
+	return nil
+}
+
+// setError records or clears the last fetch error of a file
+func (r *RemoteFS) setError(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		delete(r.errors, name)
+		return
+	}
+	r.errors[name] = err.Error()
+}
+
+// FetchErrors returns the last fetch error of every file currently served from the cache
+func (r *RemoteFS) FetchErrors() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errors := make(map[string]string, len(r.errors))
+	for name, err := range r.errors {
+		errors[name] = err
+	}
+	return errors
+}
+
+// fetchErrorReporter is implemented by template filesystems that fetch files remotely
+type fetchErrorReporter interface {
+	FetchErrors() map[string]string
+}
+
+// FetchErrors returns the fetch failures of a remote template source (files served from
+// the cache or their previous version), or nil when templates are read locally
+func (m *Manager) FetchErrors() map[string]string {
+	if reporter, ok := m.configFS.(fetchErrorReporter); ok {
+		return reporter.FetchErrors()
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package templates
 
 import (
 	"embed"
+	"fmt"
 	"html/template"
-	"strings"
+	"io"
+	"net/url"
 
 	"vless-generator/internal/config"
 	"vless-generator/internal/i18n"
@@ -31,7 +33,7 @@ func NewTemplateRenderer(htmlFS embed.FS) *TemplateRenderer {
 func (tr *TemplateRenderer) LoadTemplates() error {
 	tr.logger.Info("Loading embedded HTML templates from web/templates")
 
-	templateNames := []string{"home", "config"}
+	templateNames := []string{"home", "config", "error"}
 
 	for _, name := range templateNames {
 		templateFile := "web/templates/" + name + ".html"
@@ -41,7 +43,7 @@ func (tr *TemplateRenderer) LoadTemplates() error {
 			return err
 		}
 
-		tmpl, err := template.New(name).Parse(string(templateContent))
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(templateContent))
 		if err != nil {
 			return err
 		}
@@ -54,55 +56,134 @@ func (tr *TemplateRenderer) LoadTemplates() error {
 	return nil
 }
 
+// templateFuncs are the functions available in HTML templates
+var templateFuncs = template.FuncMap{
+	"t": translate,
+}
+
+// translate formats a parameterized text from name/value pairs, e.g.
+// {{t .Texts "error_invalid_param_value" "param" .Param "value" .Value}}
+func translate(texts i18n.Texts, key string, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("t %s: odd number of name/value arguments", key)
+	}
+	args := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		name, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("t %s: argument name %v is not a string", key, pairs[i])
+		}
+		args[name] = fmt.Sprint(pairs[i+1])
+	}
+	return texts.Format(key, args), nil
+}
+
 // HomePageData represents data for home page template
 type HomePageData struct {
 	Title         string
 	Language      string
+	Direction     string // Text direction of the language (ltr or rtl), set as dir on <html>
 	Texts         i18n.Texts
+	Languages     []i18n.Language // Loaded languages linking to this page, for the language selector
 	DefaultConfig *config.DynamicConfig
+	TemplateTypes []TemplateInfo
+	UUIDEndpoint  string // Endpoint returning a server-generated UUID
+	NewUUIDPath   string // UUID path segment that redirects to a freshly generated UUID (e.g., "new")
+
+	// Rejected form submission: the submitted fields are shown again with their errors
+	Submitted url.Values
+	Errors    []config.ParamError
+}
+
+// Value returns the submitted value of a form field, or fallback when the form was not submitted
+func (d HomePageData) Value(name string, fallback interface{}) interface{} {
+	if d.Submitted == nil {
+		return fallback
+	}
+	return d.Submitted.Get(name)
+}
+
+// Checked reports whether a form checkbox is checked, or fallback when the form was not submitted
+func (d HomePageData) Checked(name string, fallback bool) bool {
+	if d.Submitted == nil {
+		return fallback
+	}
+	return d.Submitted.Get(name) != ""
+}
+
+// FieldError returns the localized error of a form field, or an empty string
+func (d HomePageData) FieldError(name string) string {
+	for _, paramErr := range d.Errors {
+		if paramErr.Param == name {
+			return paramErr.Message
+		}
+	}
+	return ""
 }
 
 // ConfigPageData represents data for config page template
 type ConfigPageData struct {
 	Title          string
 	Language       string
+	Direction      string // Text direction of the language (ltr or rtl), set as dir on <html>
 	Texts          i18n.Texts
-	ConfigType     string // Uppercase for display (e.g., "VLESS")
-	ConfigTypeOrig string // Original lowercase for URLs (e.g., "vless")
+	Languages      []i18n.Language // Loaded languages linking to this page, for the language selector
+	ConfigType     string          // Uppercase for display (e.g., "VLESS")
+	ConfigTypeOrig string          // Original lowercase for URLs (e.g., "vless")
 	UUID           string
-	QRCode         string
 	VlessURL       string
-	QueryString    string
+	QueryString    template.URL        // Raw query string forwarded to download and QR links
+	BaseURL        string              // Scheme and host of the service, for absolute download and QR links
+	Remark         string              // Display name of the configuration (URL fragment)
+	MuxEnabled     bool                // Multiplex is on; it only applies to the downloaded JSON
+	ConfigJSON     string              // Indented generated configuration shown in a <pre> block; empty hides it
+	JSONTruncated  bool                // ConfigJSON was cut at the preview size limit
+	SingBoxImport  template.URL        // sing-box://import-remote-profile deep link to the JSON download
+	V2RayNGImport  template.URL        // v2rayng://install-config deep link to the Xray download; empty when unsupported
+	ProfileQRCode  template.URL        // PNG data URL of a QR code encoding SingBoxImport; empty hides it
+	Errors         []config.ParamError // Rejected query parameters; the page shows these instead of the config
+}
+
+// ErrorPageData represents data for error page template
+type ErrorPageData struct {
+	Title      string
+	Language   string
+	Direction  string // Text direction of the language (ltr or rtl), set as dir on <html>
+	Texts      i18n.Texts
+	StatusCode int    // HTTP status code shown on the page
+	Heading    string // Short localized description of the status
+	Message    string // Localized error detail
+	HomeURL    string // Link back to the home page, keeping the language
 }
 
-// RenderHomePage renders the home page template
-func (tr *TemplateRenderer) RenderHomePage(data HomePageData) (string, error) {
+// RenderHomePage executes the home page template into w. When it fails part of the page may
+// already have been written.
+func (tr *TemplateRenderer) RenderHomePage(w io.Writer, data HomePageData) error {
 	tmpl, exists := tr.templates["home"]
 	if !exists {
-		return "", ErrTemplateNotFound{"home"}
+		return ErrTemplateNotFound{"home"}
 	}
-
-	var buf strings.Builder
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", err
-	}
-
-	return buf.String(), nil
+	return tmpl.Execute(w, data)
 }
 
-// RenderConfigPage renders the config page template
-func (tr *TemplateRenderer) RenderConfigPage(data ConfigPageData) (string, error) {
+// RenderConfigPage executes the config page template into w. When it fails part of the page may
+// already have been written.
+func (tr *TemplateRenderer) RenderConfigPage(w io.Writer, data ConfigPageData) error {
 	tmpl, exists := tr.templates["config"]
 	if !exists {
-		return "", ErrTemplateNotFound{"config"}
+		return ErrTemplateNotFound{"config"}
 	}
+	return tmpl.Execute(w, data)
+}
 
-	var buf strings.Builder
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", err
+// RenderErrorPage executes the error page template into w. When it fails part of the page may
+// already have been written.
+func (tr *TemplateRenderer) RenderErrorPage(w io.Writer, data ErrorPageData) error {
+	tmpl, exists := tr.templates["error"]
+	if !exists {
+		return ErrTemplateNotFound{"error"}
 	}
-
-	return buf.String(), nil
+	return tmpl.Execute(w, data)
 }
 
 // ErrTemplateNotFound represents a template not found error
@@ -0,0 +1,44 @@
+package templates
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"vless-generator/internal/i18n"
+)
+
+func TestTranslate(t *testing.T) {
+	texts := i18n.Texts{"port_range": "Port must be between {min} and {max} for <{server}>"}
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr string
+	}{
+		{"all args", `{{t .Texts "port_range" "min" 1 "max" 65535 "server" .Server}}`, "Port must be between 1 and 65535 for &lt;a&amp;b&gt;", ""},
+		{"missing arg", `{{t .Texts "port_range" "min" 1}}`, "Port must be between 1 and {max} for &lt;{server}&gt;", ""},
+		{"missing key", `{{t .Texts "no_such_key" "min" 1}}`, "no_such_key", ""},
+		{"odd arguments", `{{t .Texts "port_range" "min"}}`, "", "odd number"},
+		{"non-string name", `{{t .Texts "port_range" 1 2}}`, "", "is not a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := template.Must(template.New(tt.name).Funcs(templateFuncs).Parse(tt.tmpl))
+			var out strings.Builder
+			err := tmpl.Execute(&out, map[string]interface{}{"Texts": texts, "Server": "a&b"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package templates
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/utils"
+)
+
+// Mutation is one change generation made to a template: the JSON pointer of the value and
+// its value before and after. Old or New is nil where a value was added or removed.
+type Mutation struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// secretKeys are the template fields holding credentials, masked in mutations
+var secretKeys = map[string]bool{
+	"uuid":        true,
+	"password":    true,
+	"private_key": true,
+	"secret":      true,
+}
+
+// ReplayConfig generates a configuration like GenerateConfig without counting it, and returns
+// every mutation of the template on the way, ordered by path with credentials masked
+func (m *Manager) ReplayConfig(templateType, uuid string, dynamicCfg *config.DynamicConfig) (map[string]interface{}, []Mutation, error) {
+	cfg, err := m.buildConfig(templateType, uuid, dynamicCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Templated files are compared with their sample rendering
+	var base map[string]interface{}
+	if loaded, exists := m.lookupLoaded(variantKey(templateType, dynamicCfg.Variant)); exists {
+		base = loaded.config
+	}
+	mutations := []Mutation{}
+	diffValues(&mutations, "", base, cfg)
+	return cfg, mutations, nil
+}
+
+// diffValues appends the mutations turning old into new at path; maps and arrays of the same
+// length are compared element by element, anything else is replaced as a whole
+func diffValues(mutations *[]Mutation, path string, old, new interface{}) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := make([]string, 0, len(oldMap)+len(newMap))
+		for key := range oldMap {
+			keys = append(keys, key)
+		}
+		for key := range newMap {
+			if _, exists := oldMap[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			diffValues(mutations, path+"/"+escapePointer(key), oldMap[key], newMap[key])
+		}
+		return
+	}
+
+	oldSlice, oldIsSlice := old.([]interface{})
+	newSlice, newIsSlice := new.([]interface{})
+	if oldIsSlice && newIsSlice && len(oldSlice) == len(newSlice) {
+		for i := range oldSlice {
+			diffValues(mutations, path+"/"+strconv.Itoa(i), oldSlice[i], newSlice[i])
+		}
+		return
+	}
+
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+	secret := secretKeys[path[strings.LastIndex(path, "/")+1:]]
+	*mutations = append(*mutations, Mutation{Path: path, Old: redactValue(old, secret), New: redactValue(new, secret)})
+}
+
+// redactValue masks credentials with utils.RedactSecret: value itself when secret is set, and
+// the secret fields of objects nested in it, such as a whole added outbound
+func redactValue(value interface{}, secret bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		if secret {
+			return utils.RedactSecret(v)
+		}
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			redacted[key] = redactValue(item, secretKeys[key])
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactValue(item, secret)
+		}
+		return redacted
+	}
+	return value
+}
+
+// escapePointer escapes a map key as a JSON pointer reference token (RFC 6901)
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,80 @@
+package templates
+
+import (
+	"expvar"
+	"reflect"
+	"testing"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/utils"
+)
+
+func TestReplayConfig(t *testing.T) {
+	m := newTestManager(t)
+	dynamicCfg := config.DefaultDynamicConfig()
+	dynamicCfg.Server = "x.example.com"
+
+	generated := expvar.Get("configs_generated").(*expvar.Int).Value()
+	cfg, trace, err := m.ReplayConfig("vless", testUUID, dynamicCfg)
+	if err != nil {
+		t.Fatalf("ReplayConfig: %v", err)
+	}
+	if got := expvar.Get("configs_generated").(*expvar.Int).Value(); got != generated {
+		t.Errorf("configs_generated changed by %d, want replays uncounted", got-generated)
+	}
+	if proxy := utils.ProxyOutbounds(cfg)[0]; proxy["uuid"] != testUUID {
+		t.Errorf("config uuid = %v, want the unmasked credential", proxy["uuid"])
+	}
+
+	mutations := make(map[string]Mutation, len(trace))
+	for _, mutation := range trace {
+		mutations[mutation.Path] = mutation
+	}
+	if server := mutations["/outbounds/0/server"]; server.Old != "" || server.New != "x.example.com" {
+		t.Errorf("server mutation = %+v, want \"\" -> x.example.com", server)
+	}
+	if uuid := mutations["/outbounds/0/uuid"]; uuid.New != utils.RedactSecret(testUUID) {
+		t.Errorf("uuid mutation = %+v, want the credential masked", uuid)
+	}
+	if _, ok := mutations["/outbounds/1/type"]; ok {
+		t.Error("trace reports unchanged values")
+	}
+
+	if _, _, err := m.ReplayConfig("unknown", testUUID, dynamicCfg); err == nil {
+		t.Error("unknown type: want an error")
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	old := map[string]interface{}{
+		"a/b":       "x",
+		"removed":   true,
+		"list":      []interface{}{"1", "2"},
+		"outbounds": []interface{}{map[string]interface{}{"type": "vless"}},
+	}
+	new := map[string]interface{}{
+		"a/b":   "y",
+		"added": 1,
+		"list":  []interface{}{"1", "3"},
+		"outbounds": []interface{}{
+			map[string]interface{}{"type": "vless"},
+			map[string]interface{}{"type": "wireguard", "private_key": "0123456789abcdef"},
+		},
+	}
+	var got []Mutation
+	diffValues(&got, "", old, new)
+
+	want := []Mutation{
+		{Path: "/a~1b", Old: "x", New: "y"},
+		{Path: "/added", New: 1},
+		{Path: "/list/1", Old: "2", New: "3"},
+		{Path: "/outbounds", Old: old["outbounds"], New: []interface{}{
+			map[string]interface{}{"type": "vless"},
+			map[string]interface{}{"type": "wireguard", "private_key": utils.RedactSecret("0123456789abcdef")},
+		}},
+		{Path: "/removed", Old: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mutations = %+v\nwant        %+v", got, want)
+	}
+}
@@ -0,0 +1,251 @@
+package templates
+
+import (
+	"strings"
+
+	"vless-generator/internal/config"
+)
+
+// outputSchema returns the schema a config is generated in: the schema parameter, then the
+// template's _meta.schema hint, then legacy
+func outputSchema(meta templateMeta, dynamicCfg *config.DynamicConfig) string {
+	switch {
+	case dynamicCfg.Schema != "":
+		return dynamicCfg.Schema
+	case meta.Schema != "":
+		return meta.Schema
+	default:
+		return config.SchemaLegacy
+	}
+}
+
+// tunAddressFields maps the per-family tun inbound fields deprecated in sing-box 1.10
+// to the merged field replacing each pair
+var tunAddressFields = []struct {
+	inet4, inet6, merged string
+}{
+	{"inet4_address", "inet6_address", "address"},
+	{"inet4_route_address", "inet6_route_address", "route_address"},
+	{"inet4_route_exclude_address", "inet6_route_exclude_address", "route_exclude_address"},
+}
+
+// applyModernSchema rewrites legacy fields that sing-box 1.10 and 1.11 deprecated into their
+// current form: merged tun addresses, sniff and resolve route actions instead of inbound
+// fields, reject or hijack-dns actions instead of block and dns outbounds or rcode:// servers,
+// and wireguard endpoints instead of outbounds
+func applyModernSchema(template map[string]interface{}) {
+	modernizeInbounds(template)
+	modernizeSpecialOutbounds(template)
+	modernizeRcodeServers(template)
+	modernizeWireGuardOutbounds(template)
+}
+
+// modernizeInbounds merges tun address fields and replaces the inbound sniff and
+// domain_strategy fields with sniff and resolve rules placed before every route rule
+func modernizeInbounds(template map[string]interface{}) {
+	inbounds, _ := template["inbounds"].([]interface{})
+
+	var sniffTags []interface{}
+	resolveTags := make(map[string][]interface{})
+	var strategies []string
+	for _, item := range inbounds {
+		inbound, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if inbound["type"] == "tun" {
+			for _, fields := range tunAddressFields {
+				merged := append(anyList(inbound[fields.inet4]), anyList(inbound[fields.inet6])...)
+				delete(inbound, fields.inet4)
+				delete(inbound, fields.inet6)
+				if len(merged) > 0 {
+					inbound[fields.merged] = append(anyList(inbound[fields.merged]), merged...)
+				}
+			}
+		}
+
+		// Rules select inbounds by tag, so untagged inbounds just lose the deprecated fields
+		tag, _ := inbound["tag"].(string)
+		if sniff, _ := inbound["sniff"].(bool); sniff && tag != "" {
+			sniffTags = append(sniffTags, tag)
+		}
+		if strategy, _ := inbound["domain_strategy"].(string); strategy != "" && tag != "" {
+			if _, seen := resolveTags[strategy]; !seen {
+				strategies = append(strategies, strategy)
+			}
+			resolveTags[strategy] = append(resolveTags[strategy], tag)
+		}
+		delete(inbound, "sniff")
+		delete(inbound, "sniff_override_destination")
+		delete(inbound, "sniff_timeout")
+		delete(inbound, "domain_strategy")
+	}
+
+	var rules []interface{}
+	if len(sniffTags) > 0 {
+		rules = append(rules, map[string]interface{}{"inbound": sniffTags, "action": "sniff"})
+	}
+	for _, strategy := range strategies {
+		rules = append(rules, map[string]interface{}{"inbound": resolveTags[strategy], "action": "resolve", "strategy": strategy})
+	}
+	if len(rules) == 0 {
+		return
+	}
+	// Sniffing must run before hijack-dns rules that match the sniffed protocol
+	route := routeSection(template)
+	existing, _ := route["rules"].([]interface{})
+	route["rules"] = append(rules, existing...)
+}
+
+// specialOutboundActions maps the special outbound types deprecated in sing-box 1.11
+// to the rule action replacing a route to them
+var specialOutboundActions = map[string]string{
+	"block": "reject",
+	"dns":   "hijack-dns",
+}
+
+// modernizeSpecialOutbounds turns route rules and the final outbound targeting block or dns
+// outbounds into reject or hijack-dns actions and removes those outbounds
+func modernizeSpecialOutbounds(template map[string]interface{}) {
+	outbounds, _ := template["outbounds"].([]interface{})
+	actions := make(map[string]string)
+	kept := make([]interface{}, 0, len(outbounds))
+	for _, item := range outbounds {
+		if outbound, ok := item.(map[string]interface{}); ok {
+			outboundType, _ := outbound["type"].(string)
+			tag, _ := outbound["tag"].(string)
+			if action, special := specialOutboundActions[outboundType]; special && tag != "" {
+				actions[tag] = action
+				continue
+			}
+		}
+		kept = append(kept, item)
+	}
+	if len(actions) == 0 {
+		return
+	}
+	template["outbounds"] = kept
+
+	route := routeSection(template)
+	rules, _ := route["rules"].([]interface{})
+	for _, item := range rules {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		tag, _ := rule["outbound"].(string)
+		if action, special := actions[tag]; special && (rule["action"] == nil || rule["action"] == "route") {
+			rule["action"] = action
+			delete(rule, "outbound")
+		}
+	}
+	if final, _ := route["final"].(string); actions[final] != "" {
+		delete(route, "final")
+	}
+}
+
+// rcodeAddressPrefix marks the legacy DNS servers that answer with a fixed response code
+const rcodeAddressPrefix = "rcode://"
+
+// modernizeRcodeServers replaces DNS rules pointing at rcode:// servers, deprecated in
+// sing-box 1.11, with reject actions and removes those servers
+func modernizeRcodeServers(template map[string]interface{}) {
+	dns, ok := template["dns"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	section := &dnsSection{raw: dns}
+
+	rcodeTags := make(map[string]bool)
+	servers := section.list("servers")
+	kept := make([]interface{}, 0, len(servers))
+	for _, item := range servers {
+		if server, ok := item.(map[string]interface{}); ok {
+			address, _ := server["address"].(string)
+			tag, _ := server["tag"].(string)
+			if strings.HasPrefix(address, rcodeAddressPrefix) && tag != "" {
+				rcodeTags[tag] = true
+				continue
+			}
+		}
+		kept = append(kept, item)
+	}
+	if len(rcodeTags) == 0 {
+		return
+	}
+	dns["servers"] = kept
+
+	for _, item := range section.list("rules") {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if server, _ := rule["server"].(string); rcodeTags[server] {
+			rule["action"] = "reject"
+			delete(rule, "server")
+		}
+	}
+	if final, _ := dns["final"].(string); rcodeTags[final] {
+		delete(dns, "final")
+	}
+}
+
+// anyList returns a JSON array field as []interface{}, accepting the []string values set
+// by the generator and a single string
+func anyList(value interface{}) []interface{} {
+	switch list := value.(type) {
+	case []interface{}:
+		return list
+	case []string:
+		items := make([]interface{}, len(list))
+		for i, item := range list {
+			items[i] = item
+		}
+		return items
+	case string:
+		if list != "" {
+			return []interface{}{list}
+		}
+	}
+	return nil
+}
+
+// modernizeWireGuardOutbounds moves wireguard outbounds, deprecated in sing-box 1.11, to
+// wireguard endpoints with a single peer. Endpoints keep the tag, so detours still resolve.
+func modernizeWireGuardOutbounds(template map[string]interface{}) {
+	outbounds, _ := template["outbounds"].([]interface{})
+	kept := make([]interface{}, 0, len(outbounds))
+	var endpoints []interface{}
+	for _, item := range outbounds {
+		outbound, ok := item.(map[string]interface{})
+		if !ok || outbound["type"] != "wireguard" || outbound["peers"] != nil {
+			kept = append(kept, item)
+			continue
+		}
+		endpoint := map[string]interface{}{
+			"type":        "wireguard",
+			"tag":         outbound["tag"],
+			"address":     outbound["local_address"],
+			"private_key": outbound["private_key"],
+			"peers": []interface{}{
+				map[string]interface{}{
+					"address":     outbound["server"],
+					"port":        outbound["server_port"],
+					"public_key":  outbound["peer_public_key"],
+					"allowed_ips": []string{"0.0.0.0/0", "::/0"},
+				},
+			},
+		}
+		if mtu, ok := outbound["mtu"]; ok {
+			endpoint["mtu"] = mtu
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+	template["outbounds"] = kept
+	existing, _ := template["endpoints"].([]interface{})
+	template["endpoints"] = append(existing, endpoints...)
+}
@@ -0,0 +1,67 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vless-generator/internal/config"
+)
+
+// update rewrites the golden files with the current output: go test ./internal/templates -update
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// assertGolden compares cfg, indented as in downloads, with testdata/<name>
+func assertGolden(t *testing.T, name string, cfg map[string]interface{}) {
+	t.Helper()
+	var got bytes.Buffer
+	encoder := json.NewEncoder(&got)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("output differs from %s (run go test -update and review the diff):\n%s", path, got.Bytes())
+	}
+}
+
+func TestSchemaGolden(t *testing.T) {
+	m := newTestManager(t)
+	tests := []struct {
+		golden string
+		schema string
+		fakeIP bool
+	}{
+		{"vless.legacy.json", config.SchemaLegacy, false},
+		{"vless.modern.json", config.SchemaModern, false},
+		{"vless.modern.fakeip.json", config.SchemaModern, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			dynamicCfg := config.DefaultDynamicConfig()
+			dynamicCfg.Schema = tt.schema
+			dynamicCfg.FakeIP = tt.fakeIP
+			cfg, err := m.GenerateConfig("vless", testUUID, dynamicCfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, tt.golden, cfg)
+		})
+	}
+}
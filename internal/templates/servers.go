@@ -0,0 +1,105 @@
+package templates
+
+import (
+	"fmt"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/utils"
+)
+
+// urlTestTag is the tag of the urltest group added to multi-server configs
+const urlTestTag = "auto"
+
+// applyServerGroups replaces the template's first proxy outbound with one outbound per node of
+// the servers parameter, grouped by a urltest outbound and a selector that keeps the original
+// tag, so rules and detours pointing at the proxy now go through the selector. The route's
+// final outbound is set to the selector.
+func (m *Manager) applyServerGroups(template, raw map[string]interface{}, uuid string, dynamicCfg *config.DynamicConfig) error {
+	outbounds, _ := template["outbounds"].([]interface{})
+	rawProxy := m.proxyOutbound(raw)
+	if rawProxy == nil {
+		return fmt.Errorf("%w: servers requires a template with a proxy outbound", ErrInvalidParameter)
+	}
+	selectorTag := proxyTag(template)
+
+	// Node tags must not collide with each other or with the outbounds kept from the template
+	used := make(map[string]bool)
+	for _, item := range outbounds {
+		if outbound, ok := item.(map[string]interface{}); ok {
+			if tag, ok := outbound["tag"].(string); ok {
+				used[tag] = true
+			}
+		}
+	}
+	groupTag := uniqueTag(urlTestTag, used)
+
+	nodes := make([]interface{}, 0, len(dynamicCfg.Servers))
+	nodeTags := make([]string, 0, len(dynamicCfg.Servers))
+	for _, node := range dynamicCfg.Servers {
+		nodeCfg := dynamicCfg.Clone()
+		nodeCfg.Server = node.Host
+		if node.Port != 0 {
+			nodeCfg.ServerPort = node.Port
+		}
+
+		outbound := utils.DeepCopyMap(rawProxy)
+		if err := m.updateProxyOutbound(outbound, nodeCfg); err != nil {
+			return err
+		}
+		outboundType, _ := outbound["type"].(string)
+		if setCredential, ok := credentialSetters[outboundType]; ok {
+			setCredential(outbound, uuid)
+		}
+
+		name := node.Name
+		if name == "" {
+			name = node.Host
+		}
+		tag := uniqueTag(name, used)
+		outbound["tag"] = tag
+		nodes = append(nodes, outbound)
+		nodeTags = append(nodeTags, tag)
+	}
+
+	group := []interface{}{
+		map[string]interface{}{
+			"type":      "selector",
+			"tag":       selectorTag,
+			"outbounds": append([]string{groupTag}, nodeTags...),
+			"default":   groupTag,
+		},
+		map[string]interface{}{
+			"type":      "urltest",
+			"tag":       groupTag,
+			"outbounds": nodeTags,
+		},
+	}
+	group = append(group, nodes...)
+
+	// Put the group where the proxy outbound was, so it stays the default when it came first
+	replaced := make([]interface{}, 0, len(outbounds)+len(group))
+	done := false
+	for _, item := range outbounds {
+		if outbound, ok := item.(map[string]interface{}); ok && !done {
+			if outboundType, _ := outbound["type"].(string); utils.ProxyOutboundTypes[outboundType] {
+				replaced = append(replaced, group...)
+				done = true
+				continue
+			}
+		}
+		replaced = append(replaced, item)
+	}
+	template["outbounds"] = replaced
+	routeSection(template)["final"] = selectorTag
+	return nil
+}
+
+// uniqueTag returns tag, or tag with the lowest free numeric suffix when it is taken, and marks it used
+func uniqueTag(tag string, used map[string]bool) string {
+	candidate := tag
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", tag, i)
+	}
+	used[candidate] = true
+	return candidate
+}
@@ -0,0 +1,84 @@
+package templates
+
+import (
+	"fmt"
+
+	"vless-generator/internal/utils"
+)
+
+// expectedOutbounds maps built-in template types to the type of their proxy outbound
+var expectedOutbounds = map[string]string{
+	"vless":         "vless",
+	"vless-reality": "vless",
+	"vmess":         "vmess",
+	"trojan":        "trojan",
+	"shadowsocks":   "shadowsocks",
+	"hysteria2":     "hysteria2",
+}
+
+// wsTemplates lists template types whose proxy outbound must carry a WebSocket transport
+var wsTemplates = map[string]bool{
+	"vless":  true,
+	"vmess":  true,
+	"trojan": true,
+}
+
+// validateTemplate checks the structure GenerateConfig and the share URL builders rely on
+// and returns every problem found. Types without a known outbound only need a supported one.
+func validateTemplate(templateType string, tpl map[string]interface{}) []error {
+	var errs []error
+
+	if _, ok := tpl["inbounds"].([]interface{}); !ok {
+		errs = append(errs, fmt.Errorf("inbounds must be an array"))
+	}
+	if _, ok := tpl["dns"].(map[string]interface{}); !ok {
+		errs = append(errs, fmt.Errorf("dns block is missing"))
+	}
+
+	if _, ok := tpl["outbounds"].([]interface{}); !ok {
+		return append(errs, fmt.Errorf("outbounds must be an array"))
+	}
+	proxies := utils.ProxyOutbounds(tpl)
+	if len(proxies) == 0 {
+		return append(errs, fmt.Errorf("outbounds must contain a proxy outbound"))
+	}
+
+	for i, proxy := range proxies {
+		outboundType, _ := proxy["type"].(string)
+		if expected, known := expectedOutbounds[templateType]; known && outboundType != expected {
+			errs = append(errs, fmt.Errorf("proxy outbound %d type must be %q, got %q", i, expected, outboundType))
+		}
+		if _, supported := credentialSetters[outboundType]; !supported {
+			errs = append(errs, fmt.Errorf("proxy outbound %d type %q is not a supported proxy type", i, outboundType))
+		}
+
+		if wsTemplates[templateType] {
+			errs = append(errs, validateWSTransport(i, proxy)...)
+		}
+	}
+
+	return errs
+}
+
+// validateWSTransport checks that the index-th proxy outbound has a WebSocket transport with a path and Host header
+func validateWSTransport(index int, proxy map[string]interface{}) []error {
+	transport, ok := proxy["transport"].(map[string]interface{})
+	if !ok {
+		return []error{fmt.Errorf("proxy outbound %d transport is missing", index)}
+	}
+
+	var errs []error
+	if transport["type"] != "ws" {
+		errs = append(errs, fmt.Errorf("proxy outbound %d transport type must be \"ws\", got %v", index, transport["type"]))
+	}
+	if _, ok := transport["path"].(string); !ok {
+		errs = append(errs, fmt.Errorf("proxy outbound %d transport path is missing", index))
+	}
+	headers, ok := transport["headers"].(map[string]interface{})
+	if !ok {
+		errs = append(errs, fmt.Errorf("proxy outbound %d transport headers is missing", index))
+	} else if _, ok := headers["Host"].(string); !ok {
+		errs = append(errs, fmt.Errorf("proxy outbound %d transport headers.Host is missing", index))
+	}
+	return errs
+}
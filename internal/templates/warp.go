@@ -0,0 +1,43 @@
+package templates
+
+import (
+	"vless-generator/internal/config"
+	"vless-generator/internal/utils"
+)
+
+// Cloudflare WARP endpoint settings of the wireguard outbound added by the warp parameter
+const (
+	warpTag    = "warp"
+	warpServer = "engage.cloudflareclient.com"
+	warpPort   = 2408
+	warpMTU    = 1280
+)
+
+// addWarpOutbound appends a wireguard outbound to Cloudflare WARP and sets it as the detour of
+// every proxy outbound, including each node of a multi-server config
+func (m *Manager) addWarpOutbound(template map[string]interface{}, dynamicCfg *config.DynamicConfig) {
+	outbounds, _ := template["outbounds"].([]interface{})
+	used := make(map[string]bool)
+	for _, item := range outbounds {
+		if outbound, ok := item.(map[string]interface{}); ok {
+			if tag, ok := outbound["tag"].(string); ok {
+				used[tag] = true
+			}
+		}
+	}
+	tag := uniqueTag(warpTag, used)
+
+	for _, outbound := range utils.ProxyOutbounds(template) {
+		outbound["detour"] = tag
+	}
+	template["outbounds"] = append(outbounds, map[string]interface{}{
+		"type":            "wireguard",
+		"tag":             tag,
+		"server":          warpServer,
+		"server_port":     warpPort,
+		"local_address":   dynamicCfg.WarpAddress,
+		"private_key":     dynamicCfg.WarpPrivateKey,
+		"peer_public_key": dynamicCfg.WarpPeerPublicKey,
+		"mtu":             warpMTU,
+	})
+}
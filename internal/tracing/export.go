@@ -0,0 +1,236 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Batching of the exporter: spans are posted when a batch fills up or the interval passes,
+// and dropped while the queue is full so a slow collector never blocks requests
+const (
+	exportBatchSize = 256
+	exportInterval  = 5 * time.Second
+	exportQueueSize = 4096
+	exportTimeout   = 10 * time.Second
+)
+
+// tracesPath is the OTLP/HTTP path appended to collector endpoints given without one
+const tracesPath = "/v1/traces"
+
+// Exporter batches finished spans and posts them to an OTLP/HTTP collector as JSON
+type Exporter struct {
+	url      string
+	resource otlpResource
+	client   *http.Client
+	logger   *logrus.Entry
+
+	spans   chan *Span
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64 // Spans dropped since the last export
+}
+
+// NewExporter starts an exporter posting to endpoint, a collector URL such as
+// http://otel-collector:4318 (the /v1/traces path is added when missing)
+func NewExporter(endpoint, serviceName, serviceVersion string) (*Exporter, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: expected an http or https URL", endpoint)
+	}
+	if strings.Trim(parsed.Path, "/") == "" {
+		parsed.Path = tracesPath
+	}
+
+	e := &Exporter{
+		url: parsed.String(),
+		resource: otlpResource{Attributes: []otlpAttribute{
+			stringAttribute("service.name", serviceName),
+			stringAttribute("service.version", serviceVersion),
+		}},
+		client: &http.Client{Timeout: exportTimeout},
+		logger: logrus.WithField("component", "tracing"),
+		spans:  make(chan *Span, exportQueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// enqueue queues a finished span, dropping it when the queue is full
+func (e *Exporter) enqueue(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Shutdown exports the queued spans and stops the exporter, waiting until ctx is done at most
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	close(e.stop)
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects spans into batches until Shutdown
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.export(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case span := <-e.spans:
+			if batch = append(batch, span); len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export posts a batch of spans; failures are logged and the batch is discarded
+func (e *Exporter) export(batch []*Span) {
+	if dropped := e.dropped.Swap(0); dropped > 0 {
+		e.logger.WithField("dropped", dropped).Warn("Trace export queue full, dropped spans")
+	}
+
+	spans := make([]otlpSpan, len(batch))
+	for i, span := range batch {
+		spans[i] = span.otlp()
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "vless-generator"}, Spans: spans}},
+	}}})
+	if err != nil {
+		e.logger.WithError(err).Error("Failed to encode trace export")
+		return
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		e.logger.WithError(err).WithField("spans", len(batch)).Warn("Failed to export traces")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		e.logger.WithFields(logrus.Fields{
+			"status_code": resp.StatusCode,
+			"spans":       len(batch),
+		}).Warn("Trace collector rejected export")
+	}
+}
+
+// OTLP/HTTP JSON request body (opentelemetry-proto ExportTraceServiceRequest); ids are hex
+// and 64-bit integers are strings, as the protobuf JSON mapping requires
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    string  `json:"intValue,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// otlpStatusError is the OTLP status code of failed spans
+const otlpStatusError = 2
+
+// stringAttribute returns an OTLP string attribute
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+// otlp converts a finished span to its OTLP JSON form
+func (s *Span) otlp() otlpSpan {
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for _, attr := range s.attributes {
+		if attr.isNum {
+			span.Attributes = append(span.Attributes, otlpAttribute{Key: attr.key, Value: otlpValue{IntValue: strconv.FormatInt(attr.num, 10)}})
+		} else {
+			span.Attributes = append(span.Attributes, stringAttribute(attr.key, attr.str))
+		}
+	}
+	if s.errMessage != "" {
+		span.Status = &otlpStatus{Code: otlpStatusError, Message: s.errMessage}
+	}
+	return span
+}
@@ -0,0 +1,196 @@
+// Package tracing records request spans in the OpenTelemetry data model and exports them to an
+// OTLP/HTTP collector. Until Enable is called every function is a no-op that allocates nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"vless-generator/internal/middleware"
+	"vless-generator/internal/router"
+	"vless-generator/internal/utils"
+)
+
+// Span kinds of the OTLP data model
+const (
+	kindInternal = 1
+	kindServer   = 2
+)
+
+// current receives finished spans; nil disables tracing
+var current atomic.Pointer[Exporter]
+
+// Enable records spans and sends them to exporter from now on; nil disables tracing again
+func Enable(exporter *Exporter) {
+	current.Store(exporter)
+}
+
+// Enabled reports whether spans are recorded
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// spanKey is the context key of the active span
+type spanKey struct{}
+
+// attribute is a string or integer span attribute
+type attribute struct {
+	key   string
+	str   string
+	num   int64
+	isNum bool
+}
+
+// Span is one timed operation of a trace. Its methods do nothing on a nil *Span, which is what
+// Start returns while tracing is disabled.
+type Span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte // Zero for root spans
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes []attribute
+	errMessage string // Non-empty marks the span as failed
+	exporter   *Exporter
+}
+
+// Start begins a span as a child of the span in ctx, or as the root of a new trace, and returns
+// a context carrying it
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	exporter := current.Load()
+	if exporter == nil {
+		return ctx, nil
+	}
+	span := &Span{name: name, kind: kindInternal, start: time.Now(), exporter: exporter}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else {
+		span.traceID = newTraceID()
+	}
+	span.spanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetString sets a string attribute
+func (s *Span) SetString(key, value string) {
+	if s != nil {
+		s.attributes = append(s.attributes, attribute{key: key, str: value})
+	}
+}
+
+// SetInt sets an integer attribute
+func (s *Span) SetInt(key string, value int64) {
+	if s != nil {
+		s.attributes = append(s.attributes, attribute{key: key, num: value, isNum: true})
+	}
+}
+
+// SetError marks the span as failed when err is not nil
+func (s *Span) SetError(err error) {
+	if s != nil && err != nil {
+		s.errMessage = err.Error()
+	}
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s != nil {
+		s.end = time.Now()
+		s.exporter.enqueue(s)
+	}
+}
+
+// parseTraceparent reads the trace and parent span ids of a W3C traceparent header
+// (00-<trace id>-<parent id>-<flags>); ok is false for missing or malformed headers
+func parseTraceparent(header string) (traceID [16]byte, parentID [8]byte, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return traceID, parentID, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == [16]byte{} {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil || parentID == [8]byte{} {
+		return traceID, parentID, false
+	}
+	return traceID, parentID, true
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *statusRecorder) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Middleware returns a wrapper recording a server span per request with its route, status and
+// client, continuing the trace of an incoming traceparent header. Client addresses are found
+// like in access logs. While tracing is disabled the wrapper returns handlers unchanged.
+func Middleware(trustedProxies []netip.Prefix) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if !Enabled() {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := Start(r.Context(), r.Method)
+			if span == nil {
+				next(w, r)
+				return
+			}
+			span.kind = kindServer
+			if traceID, parentID, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+				span.traceID, span.parentID = traceID, parentID
+			}
+
+			rw := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next(rw, r.WithContext(ctx))
+
+			// Route patterns start with the method, as OpenTelemetry names server spans
+			if route := router.Pattern(r); route != "" {
+				span.name = route
+				_, path, _ := strings.Cut(route, " ")
+				span.SetString("http.route", path)
+			}
+			span.SetString("http.request.method", r.Method)
+			span.SetString("url.path", utils.RedactPath(r.URL.Path))
+			span.SetInt("http.response.status_code", int64(rw.statusCode))
+			span.SetString("client.address", middleware.ClientIP(r, trustedProxies))
+			span.SetString("user_agent.original", r.UserAgent())
+			if rw.statusCode >= 500 {
+				span.errMessage = http.StatusText(rw.statusCode)
+			}
+			span.End()
+		}
+	}
+}
+
+// newTraceID returns a random non-zero trace id
+func newTraceID() (id [16]byte) {
+	for id == [16]byte{} {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// newSpanID returns a random non-zero span id
+func newSpanID() (id [8]byte) {
+	for id == [8]byte{} {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
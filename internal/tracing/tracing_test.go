@@ -0,0 +1,184 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// collector is an OTLP/HTTP endpoint keeping the spans posted to it
+type collector struct {
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, resourceSpans := range req.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			c.spans = append(c.spans, scopeSpans.Spans...)
+		}
+	}
+}
+
+// enableTracing enables tracing with an exporter posting to a test collector until the test ends
+func enableTracing(tb testing.TB) *Exporter {
+	tb.Helper()
+	srv := httptest.NewServer(&collector{})
+	tb.Cleanup(srv.Close)
+	exporter := enableTracingTo(tb, srv.URL)
+	tb.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = exporter.Shutdown(ctx)
+	})
+	return exporter
+}
+
+// enableTracingTo enables tracing with a quiet exporter posting to endpoint; the caller shuts it down
+func enableTracingTo(tb testing.TB, endpoint string) *Exporter {
+	tb.Helper()
+	exporter, err := NewExporter(endpoint, "vless-generator", "test")
+	if err != nil {
+		tb.Fatalf("NewExporter: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	exporter.logger = logrus.NewEntry(logger)
+
+	Enable(exporter)
+	tb.Cleanup(func() { Enable(nil) })
+	return exporter
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"future version with extra fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"empty", "", false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"version 00 with extra fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"zero parent id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"short trace id", "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+		{"not hex", "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, ok := parseTraceparent(tt.header); ok != tt.ok {
+				t.Errorf("parseTraceparent(%q) ok = %v, want %v", tt.header, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMiddlewareExportsSpans(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	exporter := enableTracingTo(t, srv.URL)
+
+	handler := Middleware(nil)(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "generate")
+		span.SetString("template", "vless")
+		span.End()
+		w.WriteHeader(http.StatusTeapot)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/config/vless", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(c.spans))
+	}
+	child, server := c.spans[0], c.spans[1]
+	if server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("server span trace = %s parent = %s, want the traceparent ids", server.TraceID, server.ParentSpanID)
+	}
+	if server.Kind != kindServer {
+		t.Errorf("server span kind = %d, want %d", server.Kind, kindServer)
+	}
+	if child.TraceID != server.TraceID || child.ParentSpanID != server.SpanID {
+		t.Errorf("child span trace = %s parent = %s, want %s %s", child.TraceID, child.ParentSpanID, server.TraceID, server.SpanID)
+	}
+	var status string
+	for _, attr := range server.Attributes {
+		if attr.Key == "http.response.status_code" {
+			status = attr.Value.IntValue
+		}
+	}
+	if status != "418" {
+		t.Errorf("http.response.status_code = %q, want 418", status)
+	}
+}
+
+func TestDisabledAllocations(t *testing.T) {
+	Enable(nil)
+	handler := Middleware(nil)(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "generate")
+		span.SetString("template", "vless")
+		span.SetInt("size", 1)
+		span.SetError(io.EOF)
+		span.End()
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	if allocs := testing.AllocsPerRun(100, func() { handler(w, req) }); allocs != 0 {
+		t.Errorf("disabled tracing allocates %v times per request, want 0", allocs)
+	}
+}
+
+// BenchmarkMiddleware compares requests with tracing disabled and enabled; the disabled case
+// must report 0 allocs/op
+func BenchmarkMiddleware(b *testing.B) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "generate")
+		span.SetString("template", "vless")
+		span.End()
+	}
+	run := func(b *testing.B) {
+		handler := Middleware(nil)(next)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			handler(w, req)
+		}
+	}
+
+	b.Run("disabled", func(b *testing.B) {
+		Enable(nil)
+		run(b)
+	})
+	b.Run("enabled", func(b *testing.B) {
+		enableTracing(b)
+		run(b)
+	})
+}
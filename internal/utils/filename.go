@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxFilenameLength caps download file names in bytes
+const MaxFilenameLength = 128
+
+// SanitizeFilename makes name safe to save on any platform: control characters are dropped,
+// path separators and characters reserved on Windows become underscores, and leading or
+// trailing spaces and dots are trimmed
+func SanitizeFilename(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		switch {
+		case unicode.IsControl(c):
+		case strings.ContainsRune(`/\:*?"<>|`, c):
+			b.WriteByte('_')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return strings.Trim(b.String(), " .")
+}
+
+// ContentDisposition returns an attachment Content-Disposition header value for filename, with
+// an ASCII fallback in filename and the exact name RFC 5987-encoded in filename* when it differs
+func ContentDisposition(filename string) string {
+	filename = SanitizeFilename(filename)
+	fallback := strings.Map(func(c rune) rune {
+		if c > unicode.MaxASCII {
+			return '_'
+		}
+		return c
+	}, filename)
+
+	value := fmt.Sprintf("attachment; filename=%q", fallback)
+	if fallback != filename {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return value
+}
+
+// encodeRFC5987 percent-encodes every byte of value outside the RFC 5987 attr-char set
+func encodeRFC5987(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"mime"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	for name, want := range map[string]string{
+		"home-vless-config.json":       "home-vless-config.json",
+		`evil"name.json`:               "evil_name.json",
+		"line\r\nSet-Cookie: x=1.json": "lineSet-Cookie_ x=1.json",
+		"../../etc/passwd":             "_.._etc_passwd",
+		`C:\Users\me\config.json`:      "C__Users_me_config.json",
+		"a<b>c|d?e*f.json":             "a_b_c_d_e_f.json",
+		"  .hidden. ":                  "hidden",
+		"tab\tand\x00null.json":        "tabandnull.json",
+		"Мой сервер 🇩🇪.json":           "Мой сервер 🇩🇪.json",
+	} {
+		if got := SanitizeFilename(name); got != want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name         string
+		filename     string
+		wantFallback string
+		wantExact    string // filename*; empty when the name is ASCII
+	}{
+		{"ascii", "home-vless-config.json", "home-vless-config.json", ""},
+		{"quote", `my "best" node.json`, "my _best_ node.json", ""},
+		{"newline injection", "node\r\nSet-Cookie: session=1\r\n.json", "nodeSet-Cookie_ session=1.json", ""},
+		{"semicolon", "a; filename=evil.exe", "a; filename=evil.exe", ""},
+		{"backslash", `a\"b.json`, "a__b.json", ""},
+		{"cyrillic", "Мой сервер.json", "___ ______.json", "Мой сервер.json"},
+		{"emoji", "🇩🇪 node.json", "__ node.json", "🇩🇪 node.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := ContentDisposition(tt.filename)
+			if strings.ContainsAny(value, "\r\n\x00") {
+				t.Fatalf("header value %q contains a line break", value)
+			}
+			disposition, params, err := mime.ParseMediaType(value)
+			if err != nil {
+				t.Fatalf("%q does not parse: %v", value, err)
+			}
+			if disposition != "attachment" {
+				t.Errorf("disposition = %q, want attachment", disposition)
+			}
+			// mime prefers filename* over filename, so check the fallback on its own
+			_, fallback, err := mime.ParseMediaType(strings.Split(value, "; filename*=")[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fallback["filename"] != tt.wantFallback {
+				t.Errorf("filename = %q, want %q", fallback["filename"], tt.wantFallback)
+			}
+			want := tt.wantExact
+			if want == "" {
+				want = tt.wantFallback
+				if strings.Contains(value, "filename*=") {
+					t.Errorf("ASCII name got filename*: %s", value)
+				}
+			}
+			if params["filename"] != want {
+				t.Errorf("decoded filename = %q, want %q", params["filename"], want)
+			}
+			if len(params) != 1 {
+				t.Errorf("params = %v, want only the file name", params)
+			}
+		})
+	}
+}
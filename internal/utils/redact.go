@@ -0,0 +1,72 @@
+package utils
+
+import "strings"
+
+// redactSecrets enables masking by RedactSecret and RedactPath; set at startup by SetSecretRedaction
+var redactSecrets = true
+
+// redactedPrefixLength is how many leading characters of a secret stay visible in logs
+const redactedPrefixLength = 8
+
+// SetSecretRedaction turns masking of UUIDs and other credentials in logs on or off
+func SetSecretRedaction(enabled bool) {
+	redactSecrets = enabled
+}
+
+// RedactSecret masks a credential for logging, keeping only its first 8 characters followed
+// by "…"; shorter secrets are masked entirely. It returns secret unchanged when redaction is off.
+func RedactSecret(secret string) string {
+	if !redactSecrets || secret == "" {
+		return secret
+	}
+	runes := []rune(secret)
+	if len(runes) <= redactedPrefixLength {
+		return "…"
+	}
+	return string(runes[:redactedPrefixLength]) + "…"
+}
+
+// RedactShareURL masks the credential of a share URL with RedactSecret: the user info of
+// vless://, trojan://, hysteria2:// and SIP002 ss:// links, or the whole encoded payload of
+// vmess:// and legacy ss:// links, which carry the credential inside. Fragments (remarks)
+// stay readable.
+func RedactShareURL(shareURL string) string {
+	if !redactSecrets {
+		return shareURL
+	}
+	scheme, rest, ok := strings.Cut(shareURL, "://")
+	if !ok {
+		return RedactSecret(shareURL)
+	}
+	authority := rest
+	if end := strings.IndexAny(rest, "/?#"); end >= 0 {
+		authority = rest[:end]
+	}
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		return scheme + "://" + RedactSecret(authority[:at]) + rest[at:]
+	}
+	payload, fragment, hasFragment := strings.Cut(rest, "#")
+	if hasFragment {
+		return scheme + "://" + RedactSecret(payload) + "#" + fragment
+	}
+	return scheme + "://" + RedactSecret(payload)
+}
+
+// RedactPath masks UUID-shaped segments of a URL path with RedactSecret, keeping a file
+// extension such as .json or .png
+func RedactPath(path string) string {
+	if !redactSecrets {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		uuidLength := len(UUIDFormat)
+		if len(segment) < uuidLength || !IsValidUUID(segment[:uuidLength]) {
+			continue
+		}
+		if len(segment) == uuidLength || segment[uuidLength] == '.' {
+			segments[i] = RedactSecret(segment[:uuidLength]) + segment[uuidLength:]
+		}
+	}
+	return strings.Join(segments, "/")
+}
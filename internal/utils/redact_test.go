@@ -0,0 +1,79 @@
+package utils
+
+import "testing"
+
+// setSecretRedaction changes redaction for one test and restores it afterwards
+func setSecretRedaction(t *testing.T, enabled bool) {
+	t.Helper()
+	previous := redactSecrets
+	SetSecretRedaction(enabled)
+	t.Cleanup(func() { SetSecretRedaction(previous) })
+}
+
+func TestRedactSecret(t *testing.T) {
+	setSecretRedaction(t, true)
+	for secret, want := range map[string]string{
+		testUUID:         "bae71742…",
+		"":               "",
+		"short":          "…",
+		"12345678":       "…",
+		"123456789":      "12345678…",
+		"пароль-секрет1": "пароль-с…",
+	} {
+		if got := RedactSecret(secret); got != want {
+			t.Errorf("RedactSecret(%q) = %q, want %q", secret, got, want)
+		}
+	}
+}
+
+func TestRedactPath(t *testing.T) {
+	setSecretRedaction(t, true)
+	for path, want := range map[string]string{
+		"/vless/" + testUUID:                  "/vless/bae71742…",
+		"/config/vless/" + testUUID + ".json": "/config/vless/bae71742….json",
+		"/qrcode/vless/" + testUUID + ".png":  "/qrcode/vless/bae71742….png",
+		"/sub/" + testUUID:                    "/sub/bae71742…",
+		"/vless/" + testUUID + "/extra":       "/vless/bae71742…/extra",
+		"/vless/" + testUUID + "x":            "/vless/" + testUUID + "x",
+		"/vless/not-a-uuid":                   "/vless/not-a-uuid",
+		"/api/v1/config":                      "/api/v1/config",
+		"/":                                   "/",
+		"":                                    "",
+	} {
+		if got := RedactPath(path); got != want {
+			t.Errorf("RedactPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRedactShareURL(t *testing.T) {
+	setSecretRedaction(t, true)
+	for shareURL, want := range map[string]string{
+		"vless://" + testUUID + "@x.example.com:443?security=tls#My%20node": "vless://bae71742…@x.example.com:443?security=tls#My%20node",
+		"trojan://password123@x.example.com:443#remark":                     "trojan://password…@x.example.com:443#remark",
+		"hysteria2://short@x.example.com:443":                               "hysteria2://…@x.example.com:443",
+		"ss://MjAyMi1ibGFrZTMtYWVzLTEyOC1nY206a2V5@x.example.com:8388#ss":   "ss://MjAyMi1i…@x.example.com:8388#ss",
+		"vmess://eyJ2IjoiMiIsImlkIjoiYmFlNzE3NDIifQ==":                      "vmess://eyJ2Ijoi…",
+		"vmess://eyJ2Ijoi/MiIsImlk#remark":                                  "vmess://eyJ2Ijoi…#remark",
+		"ss://YWVzLTEyOC1nY206cGFzc0BleGFtcGxlLmNvbTo4Mzg4":                 "ss://YWVzLTEy…",
+		"not a share url " + testUUID:                                       "not a sh…",
+		"":                                                                  "",
+	} {
+		if got := RedactShareURL(shareURL); got != want {
+			t.Errorf("RedactShareURL(%q) = %q, want %q", shareURL, got, want)
+		}
+	}
+}
+
+func TestRedactionDisabled(t *testing.T) {
+	setSecretRedaction(t, false)
+	if got := RedactSecret(testUUID); got != testUUID {
+		t.Errorf("RedactSecret = %q, want the full value", got)
+	}
+	if shareURL := "vless://" + testUUID + "@x.example.com:443"; RedactShareURL(shareURL) != shareURL {
+		t.Errorf("RedactShareURL = %q, want %q", RedactShareURL(shareURL), shareURL)
+	}
+	if path := "/config/vless/" + testUUID + ".json"; RedactPath(path) != path {
+		t.Errorf("RedactPath = %q, want %q", RedactPath(path), path)
+	}
+}
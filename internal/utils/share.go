@@ -0,0 +1,659 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+)
+
+// shareParams holds the outbound values needed to build a share URL
+type shareParams struct {
+	Server      string
+	ServerPort  int
+	Network     string // Transport type: ws, grpc or tcp
+	Path        string
+	Host        string
+	ServiceName string
+	TLS         bool
+	SNI         string
+	Fingerprint string
+	ALPN        []string
+	Flow        string
+}
+
+// setTLS adds the TLS fingerprint and ALPN parameters to a share URL query
+func (p *shareParams) setTLS(query url.Values) {
+	query.Set("fp", p.Fingerprint)
+	if len(p.ALPN) > 0 {
+		query.Set("alpn", strings.Join(p.ALPN, ","))
+	}
+}
+
+// setTransport adds the transport parameters to a share URL query
+func (p *shareParams) setTransport(query url.Values) {
+	query.Set("type", p.Network)
+	switch p.Network {
+	case "grpc":
+		query.Set("serviceName", p.ServiceName)
+	case "ws":
+		query.Set("path", p.Path)
+		query.Set("host", p.Host)
+	}
+}
+
+// buildShareURL assembles a share URL with a properly escaped userinfo, host, query and remark fragment
+func buildShareURL(scheme, credential, server string, port int, path string, query url.Values, remark string) string {
+	shareURL := url.URL{
+		Scheme:   scheme,
+		User:     url.User(credential),
+		Host:     net.JoinHostPort(server, strconv.Itoa(port)),
+		Path:     path,
+		RawQuery: query.Encode(),
+		Fragment: remark,
+	}
+	return shareURL.String()
+}
+
+// shareSchemes lists the URL schemes accepted as share links
+var shareSchemes = []string{"vless://", "trojan://", "vmess://", "ss://", "hysteria2://"}
+
+// IsShareURL reports whether the URL uses a supported share link scheme
+func IsShareURL(shareURL string) bool {
+	for _, scheme := range shareSchemes {
+		if strings.HasPrefix(shareURL, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateShareURL generates a share URL for the given configuration type with a remark (display name)
+func GenerateShareURL(configType string, template map[string]interface{}, credential, remark string) (string, error) {
+	switch configType {
+	case "vless", "vless-reality":
+		return GenerateVlessURL(template, credential, remark)
+	case "trojan":
+		return GenerateTrojanURL(template, credential, remark)
+	case "vmess":
+		return GenerateVmessURL(template, credential, remark)
+	case "shadowsocks":
+		return GenerateShadowsocksURL(template, credential, remark)
+	case "hysteria2":
+		return GenerateHysteria2URL(template, credential, remark)
+	default:
+		return "", fmt.Errorf("share URL not supported for config type %s", configType)
+	}
+}
+
+// GenerateVlessURL generates a VLESS URL from template configuration
+func GenerateVlessURL(template map[string]interface{}, uuid, remark string) (string, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"component": "utils",
+		"function":  "GenerateVlessURL",
+		"uuid":      RedactSecret(uuid),
+	})
+
+	// Reality configurations use plain TCP with XTLS Vision instead of WebSocket
+	if reality := extractRealityParams(template); reality != nil {
+		query := url.Values{}
+		query.Set("type", "tcp")
+		query.Set("security", "reality")
+		query.Set("pbk", reality.PublicKey)
+		query.Set("sid", reality.ShortID)
+		query.Set("sni", reality.SNI)
+		query.Set("fp", reality.Fingerprint)
+		query.Set("flow", reality.Flow)
+		vlessURL := buildShareURL("vless", uuid, reality.Server, reality.ServerPort, "", query, remark)
+
+		logger.WithField("url", RedactShareURL(vlessURL)).Debug("Generated VLESS Reality URL")
+		return vlessURL, nil
+	}
+
+	params, err := extractShareParams(template, logger)
+	if err != nil {
+		return "", err
+	}
+
+	// Build VLESS URL
+	query := url.Values{}
+	params.setTransport(query)
+	if params.Flow != "" {
+		query.Set("flow", params.Flow)
+	}
+	if params.TLS {
+		query.Set("security", "tls")
+		// sni is implied by the host for ws/grpc unless it differs from the connect address
+		if params.Network == "tcp" || params.SNI != params.Server {
+			query.Set("sni", params.SNI)
+		}
+		params.setTLS(query)
+	} else {
+		query.Set("security", "none")
+	}
+	vlessURL := buildShareURL("vless", uuid, params.Server, params.ServerPort, "", query, remark)
+
+	logger.WithField("url", RedactShareURL(vlessURL)).Debug("Generated VLESS URL")
+	return vlessURL, nil
+}
+
+// GenerateVlessURLs generates one VLESS URL per proxy outbound of a multi-server configuration,
+// in template order. Each URL is named by the remark at its index, falling back to the outbound tag.
+func GenerateVlessURLs(template map[string]interface{}, uuid string, remarks []string) ([]string, error) {
+	proxies := ProxyOutbounds(template)
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no proxy outbound found")
+	}
+
+	urls := make([]string, 0, len(proxies))
+	for i, outbound := range proxies {
+		remark, _ := outbound["tag"].(string)
+		if i < len(remarks) {
+			remark = remarks[i]
+		}
+		// The single-node generator reads the first proxy outbound, so give it a view holding only this one
+		node := map[string]interface{}{"outbounds": []interface{}{outbound}}
+		vlessURL, err := GenerateVlessURL(node, uuid, remark)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, vlessURL)
+	}
+	return urls, nil
+}
+
+// GenerateTrojanURL generates a Trojan URL from template configuration
+func GenerateTrojanURL(template map[string]interface{}, password, remark string) (string, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"component": "utils",
+		"function":  "GenerateTrojanURL",
+	})
+
+	params, err := extractShareParams(template, logger)
+	if err != nil {
+		return "", err
+	}
+
+	// Build Trojan URL
+	query := url.Values{}
+	params.setTransport(query)
+	if params.TLS {
+		query.Set("security", "tls")
+		query.Set("sni", params.SNI)
+		params.setTLS(query)
+	} else {
+		query.Set("security", "none")
+	}
+	trojanURL := buildShareURL("trojan", password, params.Server, params.ServerPort, "", query, remark)
+
+	logger.WithField("url", RedactShareURL(trojanURL)).Debug("Generated Trojan URL")
+	return trojanURL, nil
+}
+
+// vmessLink represents the v2rayN-style JSON payload of a vmess:// link
+type vmessLink struct {
+	Version     string `json:"v"`
+	Remark      string `json:"ps"`
+	Address     string `json:"add"`
+	Port        string `json:"port"`
+	ID          string `json:"id"`
+	AlterID     string `json:"aid"`
+	Security    string `json:"scy"`
+	Network     string `json:"net"`
+	Type        string `json:"type"`
+	Host        string `json:"host"`
+	Path        string `json:"path"`
+	TLS         string `json:"tls"`
+	SNI         string `json:"sni"`
+	ALPN        string `json:"alpn,omitempty"`
+	Fingerprint string `json:"fp"`
+}
+
+// GenerateVmessURL generates a v2rayN-style vmess:// URL (base64-encoded JSON) from template configuration
+func GenerateVmessURL(template map[string]interface{}, uuid, remark string) (string, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"component": "utils",
+		"function":  "GenerateVmessURL",
+		"uuid":      RedactSecret(uuid),
+	})
+
+	params, err := extractShareParams(template, logger)
+	if err != nil {
+		return "", err
+	}
+
+	link := vmessLink{
+		Version:     "2",
+		Remark:      remark,
+		Address:     params.Server,
+		Port:        strconv.Itoa(params.ServerPort),
+		ID:          uuid,
+		AlterID:     "0",
+		Security:    "auto",
+		Network:     "ws",
+		Type:        "none",
+		Host:        params.Host,
+		Path:        params.Path,
+		TLS:         "tls",
+		SNI:         params.SNI,
+		ALPN:        strings.Join(params.ALPN, ","),
+		Fingerprint: params.Fingerprint,
+	}
+	// v2rayN carries the gRPC service name in the path field
+	switch params.Network {
+	case "grpc":
+		link.Network = "grpc"
+		link.Type = "gun"
+		link.Host = ""
+		link.Path = params.ServiceName
+	case "tcp":
+		link.Network = "tcp"
+		link.Host = ""
+		link.Path = ""
+	}
+	if !params.TLS {
+		link.TLS = ""
+		link.SNI = ""
+		link.ALPN = ""
+		link.Fingerprint = ""
+	}
+
+	payload, err := json.Marshal(link)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode vmess link: %w", err)
+	}
+
+	// Build VMess URL
+	vmessURL := "vmess://" + EncodeBase64(payload)
+
+	logger.WithField("url", RedactShareURL(vmessURL)).Debug("Generated VMess URL")
+	return vmessURL, nil
+}
+
+// GenerateShadowsocksURL generates a SIP002 ss:// URL from template configuration
+func GenerateShadowsocksURL(template map[string]interface{}, password, remark string) (string, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"component": "utils",
+		"function":  "GenerateShadowsocksURL",
+	})
+
+	outbound, err := ProxyOutbound(template)
+	if err != nil {
+		return "", err
+	}
+
+	server, ok := outbound["server"].(string)
+	if !ok {
+		return "", fmt.Errorf("invalid server configuration")
+	}
+
+	method, ok := outbound["method"].(string)
+	if !ok || method == "" {
+		return "", fmt.Errorf("invalid method configuration")
+	}
+
+	serverPort := outboundPort(outbound, logger)
+
+	// Build SIP002 URL: ss://base64(method:password)@host:port#remark
+	userInfo := EncodeBase64URL([]byte(method + ":" + password))
+	ssURL := buildShareURL("ss", userInfo, server, serverPort, "", url.Values{}, remark)
+
+	logger.WithField("url", RedactShareURL(ssURL)).Debug("Generated Shadowsocks URL")
+	return ssURL, nil
+}
+
+// GenerateHysteria2URL generates a hysteria2:// URL from template configuration
+func GenerateHysteria2URL(template map[string]interface{}, password, remark string) (string, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"component": "utils",
+		"function":  "GenerateHysteria2URL",
+	})
+
+	outbound, err := ProxyOutbound(template)
+	if err != nil {
+		return "", err
+	}
+
+	server, ok := outbound["server"].(string)
+	if !ok {
+		return "", fmt.Errorf("invalid server configuration")
+	}
+
+	serverPort := outboundPort(outbound, logger)
+
+	sni := server
+	if tls, ok := outbound["tls"].(map[string]interface{}); ok {
+		if serverName, ok := tls["server_name"].(string); ok && serverName != "" {
+			sni = serverName
+		}
+	}
+
+	// Build Hysteria2 URL
+	query := url.Values{}
+	query.Set("sni", sni)
+	if obfs, ok := outbound["obfs"].(map[string]interface{}); ok {
+		if obfsPassword, ok := obfs["password"].(string); ok && obfsPassword != "" {
+			query.Set("obfs", "salamander")
+			query.Set("obfs-password", obfsPassword)
+		}
+	}
+	hysteria2URL := buildShareURL("hysteria2", password, server, serverPort, "/", query, remark)
+
+	logger.WithField("url", RedactShareURL(hysteria2URL)).Debug("Generated Hysteria2 URL")
+	return hysteria2URL, nil
+}
+
+// realityParams holds the outbound values needed to build a VLESS Reality share URL
+type realityParams struct {
+	Server      string
+	ServerPort  int
+	PublicKey   string
+	ShortID     string
+	SNI         string
+	Fingerprint string
+	Flow        string
+}
+
+// extractRealityParams reads Reality settings from the proxy outbound, or returns nil if Reality is not enabled
+func extractRealityParams(template map[string]interface{}) *realityParams {
+	outbound, err := ProxyOutbound(template)
+	if err != nil {
+		return nil
+	}
+
+	tls, ok := outbound["tls"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	reality, ok := tls["reality"].(map[string]interface{})
+	if !ok || reality["enabled"] != true {
+		return nil
+	}
+
+	params := &realityParams{
+		Fingerprint: "chrome",
+		Flow:        "xtls-rprx-vision",
+	}
+	params.Server, _ = outbound["server"].(string)
+	params.ServerPort = outboundPort(outbound, logrus.WithField("component", "utils"))
+	params.PublicKey, _ = reality["public_key"].(string)
+	params.ShortID, _ = reality["short_id"].(string)
+
+	params.SNI = params.Server
+	if serverName, ok := tls["server_name"].(string); ok && serverName != "" {
+		params.SNI = serverName
+	}
+	if utls, ok := tls["utls"].(map[string]interface{}); ok {
+		if fingerprint, ok := utls["fingerprint"].(string); ok && fingerprint != "" {
+			params.Fingerprint = fingerprint
+		}
+	}
+	if flow, ok := outbound["flow"].(string); ok && flow != "" {
+		params.Flow = flow
+	}
+
+	return params
+}
+
+// extractShareParams reads server, port and ws transport values from the proxy outbound
+func extractShareParams(template map[string]interface{}, logger *logrus.Entry) (*shareParams, error) {
+	outbound, err := ProxyOutbound(template)
+	if err != nil {
+		return nil, err
+	}
+
+	server, ok := outbound["server"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid server configuration")
+	}
+
+	serverPort := outboundPort(outbound, logger)
+
+	params := &shareParams{
+		Server:      server,
+		ServerPort:  serverPort,
+		SNI:         server,
+		Fingerprint: "chrome",
+	}
+
+	// TLS server name falls back to the server address
+	if tls, ok := outbound["tls"].(map[string]interface{}); ok {
+		params.TLS = tls["enabled"] != false
+		if serverName, ok := tls["server_name"].(string); ok && serverName != "" {
+			params.SNI = serverName
+		}
+		if utls, ok := tls["utls"].(map[string]interface{}); ok {
+			if fingerprint, ok := utls["fingerprint"].(string); ok && fingerprint != "" {
+				params.Fingerprint = fingerprint
+			}
+		}
+		params.ALPN = StringSlice(tls["alpn"])
+	}
+
+	if flow, ok := outbound["flow"].(string); ok {
+		params.Flow = flow
+	}
+
+	// No transport block means plain TCP
+	if _, exists := outbound["transport"]; !exists {
+		params.Network = "tcp"
+		return params, nil
+	}
+
+	transport, ok := outbound["transport"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid transport configuration")
+	}
+
+	if transport["type"] == "grpc" {
+		serviceName, _ := transport["service_name"].(string)
+		params.Network = "grpc"
+		params.ServiceName = serviceName
+		return params, nil
+	}
+
+	path, ok := transport["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid path configuration")
+	}
+
+	headers, ok := transport["headers"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid headers configuration")
+	}
+
+	host, ok := headers["Host"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid host configuration")
+	}
+
+	params.Network = "ws"
+	params.Path = path
+	params.Host = host
+	return params, nil
+}
+
+// ProxyOutboundTypes lists the sing-box outbound types that carry the generated credential
+var ProxyOutboundTypes = map[string]bool{
+	"vless":       true,
+	"vmess":       true,
+	"trojan":      true,
+	"shadowsocks": true,
+	"hysteria2":   true,
+}
+
+// ProxyOutbounds returns every proxy outbound of a configuration in template order, matched by type
+// so that selector, urltest or direct outbounds may appear anywhere in the list
+func ProxyOutbounds(template map[string]interface{}) []map[string]interface{} {
+	outbounds, _ := template["outbounds"].([]interface{})
+	var proxies []map[string]interface{}
+	for _, item := range outbounds {
+		if outbound, ok := item.(map[string]interface{}); ok {
+			if outboundType, _ := outbound["type"].(string); ProxyOutboundTypes[outboundType] {
+				proxies = append(proxies, outbound)
+			}
+		}
+	}
+	return proxies
+}
+
+// ProxyOutbound returns the first proxy outbound of a configuration
+func ProxyOutbound(template map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := template["outbounds"].([]interface{}); !ok {
+		return nil, fmt.Errorf("invalid outbounds configuration")
+	}
+
+	proxies := ProxyOutbounds(template)
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no proxy outbound found")
+	}
+
+	return proxies[0], nil
+}
+
+// outboundPort reads server_port from an outbound - an int once generated, a json.Number as decoded from the template
+func outboundPort(outbound map[string]interface{}, logger *logrus.Entry) int {
+	switch v := outbound["server_port"].(type) {
+	case int:
+		return v
+	case json.Number:
+		if port, err := v.Int64(); err == nil {
+			return int(port)
+		}
+		logger.WithField("value", v.String()).Warn("Non-integer server_port, using fallback")
+		return 443
+	case float64:
+		return int(v)
+	default:
+		logger.WithField("type", fmt.Sprintf("%T", v)).Warn("Unexpected type for server_port, using fallback")
+		return 443 // fallback
+	}
+}
+
+// StringSlice converts a decoded JSON array ([]interface{}) or []string into a []string
+func StringSlice(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// ErrInconsistentShareURL is returned by ParseVlessURL for links whose parameters contradict
+// their security, such as reality keys on a tls link
+var ErrInconsistentShareURL = errors.New("inconsistent vless URL")
+
+// ParseVlessURL parses a vless:// share URL into a dynamic configuration, the config type
+// (vless-reality for security=reality, vless otherwise) and the UUID. Settings the link does
+// not carry, such as DNS or the tun inbound, come from defaults (the built-in
+// DefaultDynamicConfig when nil). All problems found are reported together in the returned error.
+func ParseVlessURL(rawURL string, defaults *config.DynamicConfig) (*config.DynamicConfig, string, string, error) {
+	if defaults == nil {
+		defaults = config.DefaultDynamicConfig()
+	}
+	shareURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("invalid vless URL: %w", err)
+	}
+
+	var problems []string
+	if shareURL.Scheme != "vless" {
+		problems = append(problems, fmt.Sprintf("scheme must be vless, got %q", shareURL.Scheme))
+	}
+
+	uuid := shareURL.User.Username()
+	if !IsValidUUID(uuid) {
+		problems = append(problems, fmt.Sprintf("malformed UUID %q", uuid))
+	}
+
+	dynamicCfg := defaults.Clone()
+	dynamicCfg.Server = shareURL.Hostname()
+	dynamicCfg.Name = shareURL.Fragment
+
+	query := shareURL.Query()
+	switch network := query.Get("type"); network {
+	case "", "tcp":
+		dynamicCfg.Transport = "tcp"
+	case "ws":
+		dynamicCfg.Transport = "ws"
+		if path := query.Get("path"); path != "" {
+			dynamicCfg.WSPath = path
+		}
+		dynamicCfg.Host = query.Get("host")
+	case "grpc":
+		dynamicCfg.Transport = "grpc"
+		dynamicCfg.GRPCServiceName = query.Get("serviceName")
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported transport type %q", network))
+	}
+
+	// The link decides the security, whatever the deployment defaults say
+	dynamicCfg.TLS = true
+	dynamicCfg.RealityPublicKey, dynamicCfg.RealityShortID = "", ""
+	configType := "vless"
+	security := query.Get("security")
+	switch security {
+	case "tls":
+	case "", "none":
+		dynamicCfg.TLS = false
+	case "reality":
+		configType = "vless-reality"
+		dynamicCfg.RealityPublicKey = query.Get("pbk")
+		dynamicCfg.RealityShortID = query.Get("sid")
+		if dynamicCfg.RealityPublicKey == "" {
+			problems = append(problems, "missing pbk for reality security")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported security %q", security))
+	}
+
+	// Without a port, plaintext links use 80 like the port parameter of config routes
+	if port := shareURL.Port(); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 1 || p > 65535 {
+			problems = append(problems, fmt.Sprintf("invalid port %q", port))
+		}
+		dynamicCfg.ServerPort = p
+	} else if !dynamicCfg.TLS {
+		dynamicCfg.ServerPort = 80
+	}
+
+	dynamicCfg.SNI = query.Get("sni")
+	dynamicCfg.Flow = query.Get("flow")
+	if fp := query.Get("fp"); fp != "" {
+		dynamicCfg.Fingerprint = fp
+	}
+	if alpn := query.Get("alpn"); alpn != "" {
+		dynamicCfg.ALPN = strings.Split(alpn, ",")
+	}
+
+	for _, paramErr := range dynamicCfg.Validate() {
+		problems = append(problems, paramErr.Error())
+	}
+
+	if len(problems) > 0 {
+		return nil, "", "", fmt.Errorf("invalid vless URL: %s", strings.Join(problems, "; "))
+	}
+	// Reality keys on a non-reality link mean the link was built for another server setup
+	if configType != "vless-reality" && (query.Has("pbk") || query.Has("sid")) {
+		return nil, "", "", fmt.Errorf("%w: pbk and sid need security=reality, got security=%q", ErrInconsistentShareURL, security)
+	}
+	return dynamicCfg, configType, uuid, nil
+}
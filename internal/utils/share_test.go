@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"vless-generator/internal/config"
+)
+
+const testUUID = "bae71742-94e0-4dd5-935f-070339819ba0"
+
+func TestParseVlessURL(t *testing.T) {
+	defaults := config.DefaultDynamicConfig()
+	defaults.DNSServer = "9.9.9.9"
+	defaults.TunAddress = "10.10.0.1/30"
+	defaults.ServerPort = 8443
+
+	tests := []struct {
+		name  string
+		url   string
+		check func(t *testing.T, cfg *config.DynamicConfig)
+	}{
+		{"ws with encoded path and remark", "vless://" + testUUID + "@x.example.com:443?type=ws&path=%2Fws%3Fed%3D2048&host=cdn.example.com&security=tls&sni=x.example.com#My%20Server%20%231", func(t *testing.T, cfg *config.DynamicConfig) {
+			expect(t, "transport", cfg.Transport, "ws")
+			expect(t, "ws-path", cfg.WSPath, "/ws?ed=2048")
+			expect(t, "host", cfg.Host, "cdn.example.com")
+			expect(t, "name", cfg.Name, "My Server #1")
+			expect(t, "port", cfg.ServerPort, 443)
+			expect(t, "tls", cfg.TLS, true)
+		}},
+		{"grpc", "vless://" + testUUID + "@x.example.com:443?type=grpc&serviceName=tun&security=tls", func(t *testing.T, cfg *config.DynamicConfig) {
+			expect(t, "transport", cfg.Transport, "grpc")
+			expect(t, "grpc-service", cfg.GRPCServiceName, "tun")
+		}},
+		{"deployment defaults kept", "vless://" + testUUID + "@x.example.com?security=tls", func(t *testing.T, cfg *config.DynamicConfig) {
+			expect(t, "dns-server", cfg.DNSServer, "9.9.9.9")
+			expect(t, "tun-address", cfg.TunAddress, "10.10.0.1/30")
+			expect(t, "port", cfg.ServerPort, 8443)
+			expect(t, "server", cfg.Server, "x.example.com")
+		}},
+		{"plaintext without port uses 80", "vless://" + testUUID + "@x.example.com?type=ws&security=none", func(t *testing.T, cfg *config.DynamicConfig) {
+			expect(t, "tls", cfg.TLS, false)
+			expect(t, "port", cfg.ServerPort, 80)
+		}},
+		{"plaintext with port", "vless://" + testUUID + "@x.example.com:8080?type=ws", func(t *testing.T, cfg *config.DynamicConfig) {
+			expect(t, "tls", cfg.TLS, false)
+			expect(t, "port", cfg.ServerPort, 8080)
+		}},
+		{"reality", "vless://" + testUUID + "@x.example.com:443?security=reality&pbk=jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0&sid=ab12&fp=firefox&flow=xtls-rprx-vision", func(t *testing.T, cfg *config.DynamicConfig) {
+			expect(t, "pbk", cfg.RealityPublicKey, "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0")
+			expect(t, "sid", cfg.RealityShortID, "ab12")
+			expect(t, "fp", cfg.Fingerprint, "firefox")
+			expect(t, "flow", cfg.Flow, "xtls-rprx-vision")
+			expect(t, "transport", cfg.Transport, "tcp")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, uuid, err := ParseVlessURL(tt.url, defaults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect(t, "uuid", uuid, testUUID)
+			tt.check(t, cfg)
+		})
+	}
+
+	if defaults.ServerPort != 8443 || !defaults.TLS {
+		t.Fatal("ParseVlessURL modified the defaults")
+	}
+}
+
+func TestParseVlessURLRejects(t *testing.T) {
+	tests := map[string]struct {
+		url          string
+		problems     []string
+		inconsistent bool
+	}{
+		"malformed uuid":       {"vless://not-a-uuid@x.example.com:443", []string{"malformed UUID"}, false},
+		"wrong scheme":         {"vmess://" + testUUID + "@x.example.com:443", []string{"scheme must be vless"}, false},
+		"bad port":             {"vless://" + testUUID + "@x.example.com:70000", []string{"invalid port"}, false},
+		"unknown transport":    {"vless://" + testUUID + "@x.example.com:443?type=kcp", []string{"unsupported transport"}, false},
+		"reality without pbk":  {"vless://" + testUUID + "@x.example.com:443?security=reality", []string{"missing pbk"}, false},
+		"pbk on tls link":      {"vless://" + testUUID + "@x.example.com:443?security=tls&pbk=abc", []string{"pbk and sid need security=reality"}, true},
+		"sid without security": {"vless://" + testUUID + "@x.example.com:443?sid=ab12", []string{"pbk and sid need security=reality"}, true},
+		"several problems":     {"vless://bad@x.example.com:0?type=kcp&security=xtls", []string{"malformed UUID", "invalid port", "unsupported transport", "unsupported security"}, false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, _, err := ParseVlessURL(tt.url, nil)
+			if err == nil {
+				t.Fatal("want an error")
+			}
+			if got := errors.Is(err, ErrInconsistentShareURL); got != tt.inconsistent {
+				t.Errorf("errors.Is(err, ErrInconsistentShareURL) = %v, want %v", got, tt.inconsistent)
+			}
+			for _, problem := range tt.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("error %q does not mention %q", err, problem)
+				}
+			}
+		})
+	}
+}
+
+// expect reports a field whose value differs from want
+func expect[T comparable](t *testing.T, field string, got, want T) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %v, want %v", field, got, want)
+	}
+}
+
+func TestVlessURLRoundTrip(t *testing.T) {
+	paths := []string{"/ws", "/ws?ed=2048", "/a&b=c", "/x#y", "/путь/🚀", "/100%", "/with space"}
+	remarks := []string{"plain", "a#b", "100% off", "with  spaces ", "Сервер 🇩🇪", "a&b=c?d", "%41"}
+	for _, path := range paths {
+		for _, remark := range remarks {
+			template := map[string]interface{}{
+				"outbounds": []interface{}{map[string]interface{}{
+					"type": "vless", "tag": "proxy", "server": "x.example.com", "server_port": 443, "uuid": testUUID,
+					"tls":       map[string]interface{}{"enabled": true, "server_name": "x.example.com"},
+					"transport": map[string]interface{}{"type": "ws", "path": path, "headers": map[string]interface{}{"Host": "cdn.example.com"}},
+				}},
+			}
+			shareURL, err := GenerateVlessURL(template, testUUID, remark)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(shareURL, " ") || strings.Count(shareURL, "#") != 1 {
+				t.Errorf("%q: unescaped space or #", shareURL)
+			}
+
+			cfg, uuid, configType := parseVless(t, shareURL)
+			if uuid != testUUID || configType != "vless" {
+				t.Errorf("%q: uuid/type = %s/%s", shareURL, uuid, configType)
+			}
+			if cfg.WSPath != path {
+				t.Errorf("%q: path = %q, want %q", shareURL, cfg.WSPath, path)
+			}
+			if cfg.Name != remark {
+				t.Errorf("%q: name = %q, want %q", shareURL, cfg.Name, remark)
+			}
+			if cfg.Host != "cdn.example.com" {
+				t.Errorf("%q: host = %q", shareURL, cfg.Host)
+			}
+		}
+	}
+}
+
+func TestShareURLRemarkEscaping(t *testing.T) {
+	const remark = "DE #1 100% Сервер"
+	outbound := func(outboundType string) map[string]interface{} {
+		return map[string]interface{}{"outbounds": []interface{}{map[string]interface{}{
+			"type": outboundType, "tag": "proxy", "server": "x.example.com", "server_port": 443,
+			"password": "p@ss/word", "method": "2022-blake3-aes-128-gcm",
+			"tls": map[string]interface{}{"enabled": true, "server_name": "x.example.com"},
+		}}}
+	}
+	for _, configType := range []string{"trojan", "shadowsocks", "hysteria2"} {
+		shareURL, err := GenerateShareURL(configType, outbound(configType), "p@ss/word", remark)
+		if err != nil {
+			t.Fatalf("%s: %v", configType, err)
+		}
+		parsed, err := url.Parse(shareURL)
+		if err != nil {
+			t.Fatalf("%s: %q does not parse: %v", configType, shareURL, err)
+		}
+		if parsed.Fragment != remark {
+			t.Errorf("%s: remark = %q, want %q", configType, parsed.Fragment, remark)
+		}
+		if parsed.Hostname() != "x.example.com" || parsed.Port() != "443" {
+			t.Errorf("%s: host = %q", configType, parsed.Host)
+		}
+		if configType != "shadowsocks" && parsed.User.Username() != "p@ss/word" {
+			t.Errorf("%s: password = %q, want p@ss/word", configType, parsed.User.Username())
+		}
+	}
+}
+
+// parseVless parses a generated vless:// URL, failing the test on errors
+func parseVless(t *testing.T, shareURL string) (*config.DynamicConfig, string, string) {
+	t.Helper()
+	cfg, configType, uuid, err := ParseVlessURL(shareURL, nil)
+	if err != nil {
+		t.Fatalf("%q: %v", shareURL, err)
+	}
+	return cfg, uuid, configType
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Query parameters carrying a URL signature
+const (
+	SignatureParam = "sig"
+	ExpiresParam   = "exp"
+)
+
+// unsignedParams are left out of signatures: the signature itself, the page language,
+// which config pages change in their own language links, and the output format,
+// indentation and file name, which change how a config is written but not what it contains
+var unsignedParams = []string{SignatureParam, "lang", "format", "pretty", "filename"}
+
+// Signature verification errors
+var (
+	ErrSignatureMissing = errors.New("missing sig or exp parameter")
+	ErrSignatureInvalid = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signature expired")
+)
+
+// SignURL adds exp (unix seconds) and sig parameters to query and returns path with the
+// signed query. The signature is hex(HMAC-SHA256(key, path + "?" + sorted query without sig)).
+func SignURL(key []byte, path string, query url.Values, expires time.Time) string {
+	signed := url.Values{}
+	for name, values := range query {
+		signed[name] = append([]string(nil), values...)
+	}
+	signed.Del(SignatureParam)
+	signed.Set(ExpiresParam, strconv.FormatInt(expires.Unix(), 10))
+	signed.Set(SignatureParam, signature(key, path, signed))
+	return path + "?" + signed.Encode()
+}
+
+// VerifyURL checks the sig and exp parameters of query for path. Links stay valid
+// through the second given by exp.
+func VerifyURL(key []byte, path string, query url.Values, now time.Time) error {
+	sig, exp := query.Get(SignatureParam), query.Get(ExpiresParam)
+	if sig == "" || exp == "" {
+		return ErrSignatureMissing
+	}
+	given, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	expected, _ := hex.DecodeString(signature(key, path, query))
+	if !hmac.Equal(given, expected) {
+		return ErrSignatureInvalid
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if now.Unix() > expires {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// signature computes the hex HMAC of path and the query without unsigned parameters;
+// url.Values.Encode sorts by name, so parameter order does not matter
+func signature(key []byte, path string, query url.Values) string {
+	canonical := url.Values{}
+	for name, values := range query {
+		canonical[name] = values
+	}
+	for _, name := range unsignedParams {
+		canonical.Del(name)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "?" + canonical.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedQuery signs path and query and returns the resulting query parameters
+func signedQuery(t *testing.T, key []byte, path string, query url.Values, expires time.Time) url.Values {
+	t.Helper()
+	signed, err := url.Parse(SignURL(key, path, query, expires))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Path != path {
+		t.Fatalf("signed path = %s, want %s", signed.Path, path)
+	}
+	return signed.Query()
+}
+
+func TestSignURL(t *testing.T) {
+	key := []byte("signing-key")
+	expires := time.Unix(1767225600, 0)
+	query := url.Values{"server": {"x.example.com"}, "port": {"8443"}, SignatureParam: {"stale"}}
+
+	signed := signedQuery(t, key, "/vless/"+testUUID, query, expires)
+	if got := signed.Get(ExpiresParam); got != strconv.FormatInt(expires.Unix(), 10) {
+		t.Errorf("exp = %s, want %d", got, expires.Unix())
+	}
+	if sig := signed.Get(SignatureParam); len(sig) != 64 || sig == "stale" {
+		t.Errorf("sig = %q, want a fresh hex SHA-256 HMAC", sig)
+	}
+	if len(signed[SignatureParam]) != 1 {
+		t.Errorf("sig values = %v, want exactly one", signed[SignatureParam])
+	}
+	if query.Get(SignatureParam) != "stale" || query.Get(ExpiresParam) != "" {
+		t.Errorf("SignURL modified the caller's query: %v", query)
+	}
+	if again := signedQuery(t, key, "/vless/"+testUUID, query, expires); again.Get(SignatureParam) != signed.Get(SignatureParam) {
+		t.Errorf("signing twice gave %s and %s", signed.Get(SignatureParam), again.Get(SignatureParam))
+	}
+}
+
+func TestVerifyURL(t *testing.T) {
+	key := []byte("signing-key")
+	path := "/vless/" + testUUID
+	expires := time.Unix(1767225600, 0)
+	signed := signedQuery(t, key, path, url.Values{"server": {"x.example.com"}, "port": {"8443"}, "name": {"My Node #1"}}, expires)
+
+	// modify returns a copy of the signed query changed by fn
+	modify := func(fn func(url.Values)) url.Values {
+		query := url.Values{}
+		for name, values := range signed {
+			query[name] = append([]string(nil), values...)
+		}
+		fn(query)
+		return query
+	}
+
+	tests := []struct {
+		name  string
+		key   []byte
+		path  string
+		query url.Values
+		now   time.Time
+		want  error
+	}{
+		{"valid", key, path, signed, expires.Add(-time.Hour), nil},
+		{"one second before expiry", key, path, signed, expires.Add(-time.Second), nil},
+		{"at expiry", key, path, signed, expires, nil},
+		{"last nanosecond of the expiry second", key, path, signed, expires.Add(time.Second - time.Nanosecond), nil},
+		{"one second after expiry", key, path, signed, expires.Add(time.Second), ErrSignatureExpired},
+		{"long expired", key, path, signed, expires.Add(24 * time.Hour), ErrSignatureExpired},
+		{"wrong key", []byte("other-key"), path, signed, expires, ErrSignatureInvalid},
+		{"empty key", nil, path, signed, expires, ErrSignatureInvalid},
+		{"other path", key, "/trojan/" + testUUID, signed, expires, ErrSignatureInvalid},
+		{"tampered value", key, path, modify(func(q url.Values) { q.Set("server", "evil.example.com") }), expires, ErrSignatureInvalid},
+		{"added parameter", key, path, modify(func(q url.Values) { q.Set("mux", "true") }), expires, ErrSignatureInvalid},
+		{"removed parameter", key, path, modify(func(q url.Values) { q.Del("port") }), expires, ErrSignatureInvalid},
+		{"repeated parameter", key, path, modify(func(q url.Values) { q.Add("server", "evil.example.com") }), expires, ErrSignatureInvalid},
+		{"extended expiry", key, path, modify(func(q url.Values) { q.Set(ExpiresParam, "9999999999") }), expires, ErrSignatureInvalid},
+		{"non-numeric expiry", key, path, modify(func(q url.Values) { q.Set(ExpiresParam, "soon") }), expires, ErrSignatureInvalid},
+		{"flipped signature", key, path, modify(func(q url.Values) {
+			sig := []byte(q.Get(SignatureParam))
+			sig[0] ^= 1
+			q.Set(SignatureParam, string(sig))
+		}), expires, ErrSignatureInvalid},
+		{"truncated signature", key, path, modify(func(q url.Values) { q.Set(SignatureParam, q.Get(SignatureParam)[:32]) }), expires, ErrSignatureInvalid},
+		{"non-hex signature", key, path, modify(func(q url.Values) { q.Set(SignatureParam, strings.Repeat("z", 64)) }), expires, ErrSignatureInvalid},
+		{"upper-case signature", key, path, modify(func(q url.Values) { q.Set(SignatureParam, strings.ToUpper(q.Get(SignatureParam))) }), expires, nil},
+		{"unsigned parameters may change", key, path, modify(func(q url.Values) {
+			q.Set("lang", "ru")
+			q.Set("format", "xray")
+			q.Set("pretty", "false")
+			q.Set("filename", "phone")
+		}), expires, nil},
+		{"missing sig", key, path, modify(func(q url.Values) { q.Del(SignatureParam) }), expires, ErrSignatureMissing},
+		{"missing exp", key, path, modify(func(q url.Values) { q.Del(ExpiresParam) }), expires, ErrSignatureMissing},
+		{"empty sig", key, path, modify(func(q url.Values) { q.Set(SignatureParam, "") }), expires, ErrSignatureMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyURL(tt.key, tt.path, tt.query, tt.now)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("VerifyURL = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyURLParameterOrder(t *testing.T) {
+	key := []byte("signing-key")
+	path := "/vless/" + testUUID
+	expires := time.Unix(1767225600, 0)
+	signed, err := url.Parse(SignURL(key, path, url.Values{"server": {"x.example.com"}, "port": {"8443"}, "mux": {"true"}}, expires))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reverse the encoded parameters, as a client or proxy rewriting the query might
+	pairs := strings.Split(signed.RawQuery, "&")
+	for i, j := 0, len(pairs)-1; i < j; i, j = i+1, j-1 {
+		pairs[i], pairs[j] = pairs[j], pairs[i]
+	}
+	reordered, err := url.ParseQuery(strings.Join(pairs, "&"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(pairs, "&") == signed.RawQuery {
+		t.Fatal("reordering did not change the query")
+	}
+	if err := VerifyURL(key, path, reordered, expires); err != nil {
+		t.Errorf("reordered query: %v", err)
+	}
+
+	// Values of a repeated parameter are signed in order
+	multi := signedQuery(t, key, path, url.Values{"server": {"a.example.com", "b.example.com"}}, expires)
+	if err := VerifyURL(key, path, multi, expires); err != nil {
+		t.Errorf("repeated parameter: %v", err)
+	}
+	multi["server"] = []string{"b.example.com", "a.example.com"}
+	if err := VerifyURL(key, path, multi, expires); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("swapped repeated values: err = %v, want %v", err, ErrSignatureInvalid)
+	}
+}
@@ -1,117 +1,228 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
 
-	"github.com/sirupsen/logrus"
+	"vless-generator/internal/b64"
 )
 
-// DeepCopyMap creates a deep copy of a map[string]interface{}
+// DeepCopyMap creates a deep copy of a map[string]interface{}. Walking the decoded template
+// is several times faster than unmarshalling a cached copy of its JSON on every request.
 func DeepCopyMap(original map[string]interface{}) map[string]interface{} {
-	copy := make(map[string]interface{})
+	copy := make(map[string]interface{}, len(original))
 	for key, value := range original {
-		switch v := value.(type) {
-		case map[string]interface{}:
-			copy[key] = DeepCopyMap(v)
-		case []interface{}:
-			copy[key] = deepCopySlice(v)
-		default:
-			copy[key] = v
-		}
+		copy[key] = DeepCopy(value)
 	}
 	return copy
 }
 
-// deepCopySlice creates a deep copy of a slice
-func deepCopySlice(original []interface{}) []interface{} {
-	copy := make([]interface{}, len(original))
-	for i, value := range original {
-		switch v := value.(type) {
-		case map[string]interface{}:
-			copy[i] = DeepCopyMap(v)
-		case []interface{}:
-			copy[i] = deepCopySlice(v)
-		default:
-			copy[i] = v
+// DeepCopy returns a copy of value sharing no maps, slices or arrays with it, whatever their
+// element types. The types JSON decodes to are copied directly; other maps and slices, such
+// as []string or map[string]string set by generation code, are copied by reflection.
+// Pointers, channels and functions are shared as they are.
+func DeepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, json.Number, float64, int, int64:
+		return v
+	case map[string]interface{}:
+		return DeepCopyMap(v)
+	case []interface{}:
+		copy := make([]interface{}, len(v))
+		for i, item := range v {
+			copy[i] = DeepCopy(item)
+		}
+		return copy
+	case []string:
+		if v == nil {
+			return v
 		}
+		return append(make([]string, 0, len(v)), v...)
 	}
-	return copy
+	return deepCopyValue(reflect.ValueOf(value)).Interface()
 }
 
-// EncodeBase64 encodes bytes to base64 string
-func EncodeBase64(data []byte) string {
-	return base64.StdEncoding.EncodeToString(data)
+// deepCopyValue copies maps, slices, arrays and interfaces by reflection
+func deepCopyValue(original reflect.Value) reflect.Value {
+	switch original.Kind() {
+	case reflect.Interface:
+		if original.IsNil() {
+			return reflect.Zero(original.Type())
+		}
+		return reflect.ValueOf(DeepCopy(original.Interface()))
+	case reflect.Map:
+		if original.IsNil() {
+			return original
+		}
+		copy := reflect.MakeMapWithSize(original.Type(), original.Len())
+		iter := original.MapRange()
+		for iter.Next() {
+			copy.SetMapIndex(iter.Key(), deepCopyValue(iter.Value()))
+		}
+		return copy
+	case reflect.Slice:
+		if original.IsNil() {
+			return original
+		}
+		copy := reflect.MakeSlice(original.Type(), original.Len(), original.Len())
+		for i := 0; i < original.Len(); i++ {
+			copy.Index(i).Set(deepCopyValue(original.Index(i)))
+		}
+		return copy
+	case reflect.Array:
+		copy := reflect.New(original.Type()).Elem()
+		for i := 0; i < original.Len(); i++ {
+			copy.Index(i).Set(deepCopyValue(original.Index(i)))
+		}
+		return copy
+	}
+	return original
 }
 
-// GenerateVlessURL generates a VLESS URL from template configuration
-func GenerateVlessURL(template map[string]interface{}, uuid string) (string, error) {
-	logger := logrus.WithFields(logrus.Fields{
-		"component": "utils",
-		"function":  "GenerateVlessURL",
-		"uuid":      uuid,
-	})
-
-	// Extract configuration from template
-	outbounds, ok := template["outbounds"].([]interface{})
-	if !ok || len(outbounds) == 0 {
-		return "", fmt.Errorf("invalid outbounds configuration")
+// DecodeJSON decodes a single JSON value, keeping numbers as json.Number so that
+// integers such as 4294967295 round-trip exactly instead of becoming float64
+func DecodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
 	}
-
-	outbound, ok := outbounds[0].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("invalid outbound configuration")
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after top-level JSON value")
 	}
+	return nil
+}
 
-	server, ok := outbound["server"].(string)
-	if !ok {
-		return "", fmt.Errorf("invalid server configuration")
-	}
+// UUIDFormat is the RFC 4122 string form shown in validation errors
+const UUIDFormat = "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
 
-	// Handle server_port - it could be int or float64
-	var serverPort int
-	switch v := outbound["server_port"].(type) {
-	case int:
-		serverPort = v
-	case float64:
-		serverPort = int(v)
-	default:
-		logger.WithField("type", fmt.Sprintf("%T", v)).Warn("Unexpected type for server_port, using fallback")
-		serverPort = 443 // fallback
-	}
+// uuidPattern matches the canonical 8-4-4-4-12 hex UUID form
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 
-	transport, ok := outbound["transport"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("invalid transport configuration")
+// IsValidUUID reports whether value is a canonical UUID string
+func IsValidUUID(value string) bool {
+	return uuidPattern.MatchString(value)
+}
+
+// NewUUID generates a random (version 4) UUID using crypto/rand
+func NewUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
 	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
 
-	path, ok := transport["path"].(string)
-	if !ok {
-		return "", fmt.Errorf("invalid path configuration")
+// ValidateUUID returns an error describing the expected format when value is not a canonical UUID
+func ValidateUUID(value string) error {
+	if !IsValidUUID(value) {
+		return fmt.Errorf("invalid UUID %q: expected format %s", value, UUIDFormat)
 	}
+	return nil
+}
 
-	headers, ok := transport["headers"].(map[string]interface{})
+// MergePatch applies an RFC 7386 JSON merge patch to target and returns the result.
+// Objects are merged recursively, null deletes a member, and any other value
+// (including arrays) replaces the target value.
+func MergePatch(target, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("invalid headers configuration")
+		return patch
 	}
 
-	host, ok := headers["Host"].(string)
+	targetMap, ok := target.(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("invalid host configuration")
+		targetMap = make(map[string]interface{})
+	}
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+			continue
+		}
+		targetMap[key] = MergePatch(targetMap[key], value)
 	}
+	return targetMap
+}
+
+// EncodeBase64 encodes bytes to a padded standard base64 string, as vmess links and
+// subscription bodies expect
+func EncodeBase64(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// EncodeBase64URL encodes bytes to unpadded base64url, safe in query strings and paths
+func EncodeBase64URL(data []byte) string {
+	return b64.EncodeURL(data)
+}
+
+// DecodeBase64Lenient decodes base64 in either alphabet, with or without padding, ignoring
+// line wrapping and reading spaces as the + they replaced in query strings
+func DecodeBase64Lenient(encoded string) ([]byte, error) {
+	return b64.DecodeLenient(encoded)
+}
 
-	// Build VLESS URL
-	vlessURL := fmt.Sprintf("vless://%s@%s:%d?type=ws&path=%s&host=%s&security=tls&fp=chrome",
-		uuid, server, serverPort, path, host)
+// UniqueRemarks makes every remark distinct, suffixing duplicates with an index
+func UniqueRemarks(names []string) []string {
+	remarks := make([]string, len(names))
+	seen := make(map[string]int)
+	for i, name := range names {
+		seen[name]++
+		if seen[name] > 1 {
+			remarks[i] = fmt.Sprintf("%s-%d", name, seen[name])
+		} else {
+			remarks[i] = name
+		}
+	}
+	return remarks
+}
 
-	logger.WithField("url", vlessURL).Debug("Generated VLESS URL")
-	return vlessURL, nil
+// EncodeSubscription encodes share URLs as a base64 subscription body (one URL per line)
+func EncodeSubscription(links []string) string {
+	return EncodeBase64([]byte(strings.Join(links, "\n")))
 }
 
 // GetScheme determines HTTP scheme from request
-func GetScheme(hasTLS bool) string {
-	if hasTLS {
+func GetScheme(r *http.Request) string {
+	if r.TLS != nil {
 		return "https"
 	}
 	return "http"
 }
+
+// BaseURL returns the scheme and host clients use to reach the service (e.g., https://vpn.example.com).
+// X-Forwarded-Proto and X-Forwarded-Host are only honored when trustProxy is set, since any
+// client can send them when the service is reached directly.
+func BaseURL(r *http.Request, trustProxy bool) string {
+	scheme, host := GetScheme(r), r.Host
+	if trustProxy {
+		if proto := strings.ToLower(firstHeaderValue(r, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHost != "" && !strings.ContainsAny(forwardedHost, "/\\@?# \t") {
+			host = forwardedHost
+		}
+	}
+	return scheme + "://" + host
+}
+
+// firstHeaderValue returns the first entry of a comma-separated header; the proxy closest
+// to the client writes it first
+func firstHeaderValue(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
+
+// AbsoluteURL builds an absolute URL for path on the service's base URL
+func AbsoluteURL(r *http.Request, trustProxy bool, path string) string {
+	return BaseURL(r, trustProxy) + path
+}
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func TestDeepCopyMap(t *testing.T) {
+	original := map[string]interface{}{
+		"inet4_address": []string{"172.19.0.1/28"},
+		"headers":       map[string]string{"Host": "cdn.example.com"},
+		"outbounds": []map[string]interface{}{
+			{"tag": "proxy", "alpn": []interface{}{"h2", []string{"nested"}}},
+		},
+		"matrix":  [][]int{{1, 2}, {3}},
+		"ports":   [2][]int{{443}, {8443}},
+		"number":  json.Number("4294967295"),
+		"null":    nil,
+		"empty":   []string{},
+		"nilList": []string(nil),
+		"nilMap":  map[string]string(nil),
+		"dns":     map[string]interface{}{"servers": []interface{}{map[string]interface{}{"address": "1.1.1.1"}}},
+	}
+	want := map[string]interface{}{
+		"inet4_address": []string{"172.19.0.1/28"},
+		"headers":       map[string]string{"Host": "cdn.example.com"},
+		"outbounds": []map[string]interface{}{
+			{"tag": "proxy", "alpn": []interface{}{"h2", []string{"nested"}}},
+		},
+		"matrix":  [][]int{{1, 2}, {3}},
+		"ports":   [2][]int{{443}, {8443}},
+		"number":  json.Number("4294967295"),
+		"null":    nil,
+		"empty":   []string{},
+		"nilList": []string(nil),
+		"nilMap":  map[string]string(nil),
+		"dns":     map[string]interface{}{"servers": []interface{}{map[string]interface{}{"address": "1.1.1.1"}}},
+	}
+
+	copied := DeepCopyMap(original)
+	if !reflect.DeepEqual(copied, original) {
+		t.Fatalf("copy differs:\n got %#v\nwant %#v", copied, original)
+	}
+
+	// Mutating every nested value of the copy must leave the original untouched
+	copied["inet4_address"].([]string)[0] = "10.0.0.1/8"
+	copied["headers"].(map[string]string)["Host"] = "evil.example.com"
+	outbound := copied["outbounds"].([]map[string]interface{})[0]
+	outbound["tag"] = "direct"
+	outbound["alpn"].([]interface{})[1].([]string)[0] = "changed"
+	copied["matrix"].([][]int)[0][0] = 9
+	copied["ports"].([2][]int)[1][0] = 1
+	copied["dns"].(map[string]interface{})["servers"].([]interface{})[0].(map[string]interface{})["address"] = "8.8.8.8"
+
+	if !reflect.DeepEqual(original, want) {
+		t.Fatalf("original changed through the copy:\n got %#v\nwant %#v", original, want)
+	}
+	if copied["nilList"].([]string) != nil || copied["nilMap"].(map[string]string) != nil {
+		t.Fatal("nil slices and maps must stay nil")
+	}
+	if copied["empty"].([]string) == nil {
+		t.Fatal("empty slice became nil, which encodes as null")
+	}
+}
+
+func TestDeepCopyConcurrentMutation(t *testing.T) {
+	// Run with -race: copies handed to concurrent requests must not share memory
+	template := map[string]interface{}{
+		"inbounds": []interface{}{map[string]interface{}{"type": "tun", "inet4_address": []string{"172.19.0.1/28"}}},
+		"headers":  map[string]string{"Host": "a"},
+		"rules":    []map[string]interface{}{{"domain": []string{"example.com"}}},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				cfg := DeepCopyMap(template)
+				inbound := cfg["inbounds"].([]interface{})[0].(map[string]interface{})
+				inbound["inet4_address"].([]string)[0] = "10.0.0.1/8"
+				cfg["headers"].(map[string]string)["Host"] = "b"
+				cfg["rules"].([]map[string]interface{})[0]["domain"].([]string)[0] = "other.example.com"
+				if _, err := json.Marshal(cfg); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if got := template["headers"].(map[string]string)["Host"]; got != "a" {
+		t.Fatalf("template mutated through a copy: Host = %q", got)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// The examples of RFC 7386 appendix A, plus sing-box shaped cases
+	tests := []struct {
+		name, target, patch, want string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"null deletes", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"null deletes one of several", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"array replaces array", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"value replaces array", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"nested merge and delete", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"array elements are not merged", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"object replaces scalar", `{"a":"foo"}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{"null member of a new object", `{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{"empty patch", `{"a":"b"}`, `{}`, `{"a":"b"}`},
+		{
+			"sing-box log level and outbounds",
+			`{"log":{"level":"info","timestamp":true},"outbounds":[{"type":"vless"},{"type":"direct"}]}`,
+			`{"log":{"level":"debug"},"outbounds":[{"type":"block"}]}`,
+			`{"log":{"level":"debug","timestamp":true},"outbounds":[{"type":"block"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decode := func(document string) interface{} {
+				var value interface{}
+				if err := json.Unmarshal([]byte(document), &value); err != nil {
+					t.Fatal(err)
+				}
+				return value
+			}
+			if got := MergePatch(decode(tt.target), decode(tt.patch)); !reflect.DeepEqual(got, decode(tt.want)) {
+				t.Errorf("MergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+			}
+		})
+	}
+
+	// A patch that is not an object replaces the whole target
+	if got := MergePatch(map[string]interface{}{"a": "b"}, []interface{}{"c"}); !reflect.DeepEqual(got, []interface{}{"c"}) {
+		t.Errorf("array patch = %v, want [c]", got)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		tls        bool
+		header     http.Header
+		trustProxy bool
+		want       string
+	}{
+		{"direct http", false, nil, false, "http://vpn.example.com"},
+		{"direct https", true, nil, false, "https://vpn.example.com"},
+		{"proxied", false, http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"public.example.com"}}, true, "https://public.example.com"},
+		{"proxied with port", false, http.Header{"X-Forwarded-Proto": {"HTTPS"}, "X-Forwarded-Host": {"public.example.com:8443"}}, true, "https://public.example.com:8443"},
+		{"proxy chain uses the first hop", false, http.Header{"X-Forwarded-Proto": {"https, http"}, "X-Forwarded-Host": {"public.example.com, internal.lan"}}, true, "https://public.example.com"},
+		{"proxied proto only", false, http.Header{"X-Forwarded-Proto": {"https"}}, true, "https://vpn.example.com"},
+		{"untrusted proxy headers ignored", false, http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.example.com"}}, false, "http://vpn.example.com"},
+		{"untrusted over tls", true, http.Header{"X-Forwarded-Proto": {"http"}}, false, "https://vpn.example.com"},
+		{"unknown proto ignored", false, http.Header{"X-Forwarded-Proto": {"javascript"}}, true, "http://vpn.example.com"},
+		{"host with path ignored", false, http.Header{"X-Forwarded-Host": {"evil.example.com/x"}}, true, "http://vpn.example.com"},
+		{"host with userinfo ignored", false, http.Header{"X-Forwarded-Host": {"user@evil.example.com"}}, true, "http://vpn.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/vless/"+testUUID, nil)
+			r.Host = "vpn.example.com"
+			if !tt.tls {
+				r.TLS = nil
+			} else if r.TLS == nil {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			if got := BaseURL(r, tt.trustProxy); got != tt.want {
+				t.Errorf("BaseURL = %s, want %s", got, tt.want)
+			}
+			if got, want := AbsoluteURL(r, tt.trustProxy, "/sub/vless"), tt.want+"/sub/vless"; got != want {
+				t.Errorf("AbsoluteURL = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	qrPNG, err := qrcode.Encode("vless://"+testUUID+"@x.example.com:443#Сервер", qrcode.Medium, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string][]byte{
+		"qr png":          qrPNG,
+		"unicode remarks": []byte("vless://" + testUUID + "@x.example.com:443#Сервер%20🚀\ntrojan://p@y.example.com:443#سرور 東京"),
+		"one byte tail":   []byte("a"),
+		"two byte tail":   []byte("ab"),
+		"empty":           {},
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			encoded := EncodeBase64URL(input)
+			if strings.ContainsAny(encoded, "+/=") {
+				t.Errorf("EncodeBase64URL = %q, want the unpadded url alphabet", encoded)
+			}
+			for _, variant := range []string{
+				encoded,
+				EncodeBase64(input),
+				strings.TrimRight(EncodeBase64(input), "="),
+				strings.ReplaceAll(EncodeBase64(input), "+", " "),
+			} {
+				decoded, err := DecodeBase64Lenient(variant)
+				if err != nil || !bytes.Equal(decoded, input) {
+					t.Errorf("DecodeBase64Lenient(%.40q) = %.40x, %v, want the input", variant, decoded, err)
+				}
+			}
+		})
+	}
+
+	if _, err := DecodeBase64Lenient("ab!c"); err == nil {
+		t.Error("DecodeBase64Lenient accepted a character outside both alphabets")
+	}
+}
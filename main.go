@@ -1,36 +1,99 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
 
+	"vless-generator/internal/audit"
+	"vless-generator/internal/buildinfo"
 	"vless-generator/internal/config"
 	"vless-generator/internal/handlers"
 	"vless-generator/internal/i18n"
 	"vless-generator/internal/middleware"
+	"vless-generator/internal/qr"
+	"vless-generator/internal/shortlink"
 	"vless-generator/internal/templates"
+	"vless-generator/internal/tracing"
+	"vless-generator/internal/utils"
 )
 
 func main() {
+	// The first argument may name a subcommand; serve is the default so existing invocations keep working
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "generate":
+			os.Exit(runGenerate(os.Args[2:]))
+		case "qr":
+			os.Exit(runQR(os.Args[2:]))
+		case "validate-templates":
+			os.Exit(runValidateTemplates(os.Args[2:]))
+		case "serve":
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+	serve()
+}
+
+// serve runs the HTTP service until a shutdown signal arrives
+func serve() {
 	// Load configuration from command line flags
 	cfg := config.LoadConfig()
 
 	// Setup structured logging with logrus
-	config.SetupLogging(cfg)
+	if err := config.SetupLogging(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	utils.SetSecretRedaction(cfg.Service.RedactSecrets)
 
 	logger := logrus.WithField("component", "main")
+	if len(cfg.FromEnv) > 0 {
+		logger.WithField("variables", cfg.FromEnv).Info("Configuration values loaded from environment")
+	}
+	if err := cfg.Server.ValidateTLS(); err != nil {
+		logger.WithError(err).Fatal("Invalid TLS configuration")
+	}
+	if err := cfg.Templates.ValidateSource(); err != nil {
+		logger.WithError(err).Fatal("Invalid templates configuration")
+	}
+	if errs := config.ValidateDefaults(cfg.Defaults); len(errs) > 0 {
+		logger.WithField("errors", errs).Fatal("Invalid default dynamic parameters")
+	}
 	logger.WithFields(logrus.Fields{
 		"service": "vless-generator",
-		"version": "1.0.0",
+		"version": buildinfo.Version,
 		"port":    cfg.Server.Port,
 	}).Info("Starting VLESS Config Generator service")
 
+	// Tracing stays a no-op unless a collector is configured
+	var traceExporter *tracing.Exporter
+	if cfg.Server.OTelEndpoint != "" {
+		var err error
+		traceExporter, err = tracing.NewExporter(cfg.Server.OTelEndpoint, "vless-generator", buildinfo.Version)
+		if err != nil {
+			logger.WithError(err).Fatal("Invalid tracing configuration")
+		}
+		tracing.Enable(traceExporter)
+		logger.WithField("endpoint", cfg.Server.OTelEndpoint).Info("Exporting traces over OTLP/HTTP")
+	}
+
 	// Initialize i18n manager
 	i18nManager := i18n.NewI18n()
+	if cfg.Templates.TranslationsDirectory != "" {
+		i18nManager = i18n.NewI18nFS(os.DirFS(cfg.Templates.TranslationsDirectory))
+	}
 	if err := i18nManager.LoadTranslations(); err != nil {
 		logger.WithError(err).Fatal("Failed to load translations")
 	}
@@ -41,55 +104,418 @@ func main() {
 		logger.WithError(err).Fatal("Failed to load HTML templates")
 	}
 
-	// Initialize template manager with embedded configuration templates
-	templateManager := templates.NewManager(configTemplates)
+	// Initialize template manager with embedded, local or remote configuration templates
+	configFS := templateFS(cfg.Templates.Directory)
+	if cfg.Templates.URL != "" {
+		remoteFS, err := templates.NewRemoteFS(cfg.Templates.URL, cfg.Templates.CacheDirectory, cfg.Templates.FetchTimeout)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to set up remote templates")
+		}
+		logger.WithFields(logrus.Fields{
+			"url":       cfg.Templates.URL,
+			"cache_dir": cfg.Templates.CacheDirectory,
+		}).Info("Loading configuration templates from URL")
+		configFS = remoteFS
+	}
+	templateManager := templates.NewManager(configFS)
+	// Templates uploaded at runtime survive restarts when they can be written next to the templates
+	if cfg.Templates.Directory != "" {
+		if err := templateManager.LoadOverrides(filepath.Join(cfg.Templates.Directory, templateOverridesDir)); err != nil {
+			logger.WithError(err).Fatal("Failed to load template overrides")
+		}
+	}
 
 	// Load configuration templates
 	if err := templateManager.LoadTemplates(cfg.Templates.Types); err != nil {
 		logger.WithError(err).Fatal("Failed to load configuration templates")
 	}
 
+	// Open the short link store when enabled; expired links are purged hourly
+	var shortLinks shortlink.Store
+	if cfg.Service.ShortLinkDB != "" {
+		store, err := shortlink.OpenFileStore(cfg.Service.ShortLinkDB)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open short link store")
+		}
+		defer store.Close()
+		shortLinks = store
+		go purgeShortLinks(store, shortLinkPurgeInterval, logger)
+	}
+
+	// Record generated configs in the queryable store and the audit log when either is
+	// enabled; the recorder is closed after the server has shut down so queued records are
+	// still written
+	var auditStore audit.Store
+	var auditAppenders []audit.Appender
+	if cfg.Service.AuditStore != "" {
+		store, err := audit.OpenFileStore(cfg.Service.AuditStore)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open audit store")
+		}
+		auditStore = store
+		auditAppenders = append(auditAppenders, store)
+		if cfg.Service.AuditRetention > 0 {
+			go purgeAuditRecords(store, cfg.Service.AuditRetention, auditPurgeInterval, logger)
+		}
+	}
+	if cfg.Service.AuditLog != "" {
+		auditLog, err := audit.OpenLog(cfg.Service.AuditLog, cfg.Service.AuditLogMaxBytes, cfg.Service.AuditLogKeep)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open audit log")
+		}
+		auditAppenders = append(auditAppenders, auditLog)
+	}
+	var auditRecorder *audit.Recorder
+	if len(auditAppenders) > 0 {
+		auditRecorder = audit.NewRecorder(audit.Tee(auditAppenders...), auditBufferSize, logger)
+		defer auditRecorder.Close()
+	}
+
+	// Identical page and download requests are answered from memory; with tokens or signed
+	// links every request must be checked, and auditing must see every generated config, so
+	// the cache stays off
+	var responseCache *middleware.ResponseCache
+	if cfg.Service.ResponseCacheTTL > 0 {
+		switch {
+		case len(cfg.Server.AuthTokens) > 0 || cfg.Server.SigningKey != "":
+			logger.Warn("Response cache disabled: -auth-token and -signing-key require checking every request")
+		case auditRecorder != nil:
+			logger.Warn("Response cache disabled: -audit-store and -audit-log must record every generated config")
+		default:
+			responseCache = middleware.NewResponseCache(cfg.Service.ResponseCacheTTL, cfg.Service.ResponseCacheMaxEntries)
+		}
+	}
+
+	// Every QR encode runs on a bounded pool so bursts of large codes cannot take every CPU
+	qrPool := qr.NewPool(cfg.Service.QRWorkers, cfg.Service.QRQueueDepth)
+	logger.WithFields(logrus.Fields{
+		"workers":     qrPool.Workers(),
+		"queue_depth": cfg.Service.QRQueueDepth,
+	}).Debug("QR worker pool ready")
+
 	// Initialize HTTP handlers
-	handler := handlers.NewHandler(templateManager, templateRenderer, i18nManager)
+	handler := handlers.NewHandler(templateManager, templateRenderer, i18nManager, handlers.Options{
+		MaxBatchSize: cfg.Service.MaxBatchSize,
+		PreviewBytes: cfg.Service.PreviewBytes,
+		TrustProxy:   cfg.Server.TrustProxyHeaders,
+		AuthTokens:   cfg.Server.AuthTokens,
+		SigningKey:   []byte(cfg.Server.SigningKey),
+		CacheMaxAge:  cfg.Service.ConfigCacheMaxAge,
+		ProfileQR:    cfg.Service.ProfileQR,
+		StrictRender: cfg.Service.StrictRender,
+		QRPool:       qrPool,
+		ShortLinks:   shortLinks,
+		ShortLinkTTL: cfg.Service.ShortLinkTTL,
+		Audit:        auditRecorder,
+		AuditStore:   auditStore,
+		Defaults:     cfg.Defaults,
 
-	// Setup HTTP routes with middleware
-	http.HandleFunc("/", middleware.LoggingMiddleware(handler.HomePageHandler))
-	http.HandleFunc("/vless/", middleware.LoggingMiddleware(handler.ConfigPageHandler))
-	http.HandleFunc("/config/", middleware.LoggingMiddleware(handler.ConfigDownloadHandler))
-	http.HandleFunc("/qrcode", middleware.LoggingMiddleware(handler.QRCodeHandler))
-	http.HandleFunc("/health", middleware.LoggingMiddleware(handler.HealthHandler))
+		TrustedProxies: cfg.Server.TrustedProxies,
+		Cache:          responseCache,
+	})
+	if len(cfg.Server.AuthTokens) == 0 {
+		logger.Info("Admin routes disabled: set -auth-token to enable /admin/*")
+	}
+	if cfg.Service.Maintenance {
+		handler.SetMaintenance(true, cfg.Service.MaintenanceMessage)
+		logger.Warn("Starting in maintenance mode: generation routes answer 503")
+	}
 
-	// Setup static file serving with embedded files
-	http.Handle("/static/", http.StripPrefix("/static/", embeddedFileServer()))
+	// Setup HTTP routes with middleware on a mux owned by the server
+	mux := newRouter(cfg, handler, responseCache)
 
-	// Setup graceful shutdown
-	setupGracefulShutdown(logger)
+	// Start HTTP server on a TCP address or unix socket
+	serverAddr := cfg.Server.ListenAddress()
+	listener, err := listen(cfg.Server)
+	if err != nil {
+		logger.WithError(err).WithField("address", serverAddr).Fatal("Failed to listen")
+	}
+	if socketPath, ok := cfg.Server.UnixSocket(); ok {
+		defer removeSocket(socketPath, logger)
+	}
+	server := newServer(serverAddr, mux, &cfg.Server)
+	connections := trackConnections(server)
 
-	// Start HTTP server
-	serverAddr := ":" + cfg.Server.Port
-	logger.WithField("address", serverAddr).Info("HTTP server starting")
+	scheme := "http"
+	if cfg.Server.TLSEnabled() {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s", scheme, displayAddress(listener))
+	logger.WithFields(logrus.Fields{
+		"address": serverAddr,
+		"scheme":  scheme,
+	}).Info("HTTP server starting")
 
 	logger.Info("Service endpoints available:")
-	logger.Infof("  Home page: http://localhost:%s/", cfg.Server.Port)
-	logger.Infof("  Config pages: http://localhost:%s/<type>/<uuid>?server=example.com&port=443&ws-path=/websocket&lang=ru", cfg.Server.Port)
-	logger.Info("  Available types: vless")
-	logger.Infof("  Health check: http://localhost:%s/health", cfg.Server.Port)
-	logger.Infof("  Config downloads: http://localhost:%s/config/<type>/<uuid>.json?server=example.com", cfg.Server.Port)
+	logger.Infof("  Home page: %s/", baseURL)
+	logger.Infof("  Config pages: %s/<type>/<uuid>?server=example.com&port=443&ws-path=/websocket&lang=ru", baseURL)
+	logger.Infof("  Available types: %s", strings.Join(cfg.Templates.Types, ", "))
+	logger.Infof("  Health check: %s/health", baseURL)
+	logger.Infof("  Readiness check: %s/ready", baseURL)
+	logger.Infof("  Config downloads: %s/config/<type>/<uuid>.json?server=example.com", baseURL)
+	logger.Infof("  Subscriptions: %s/sub/<uuid>?servers=a.example.com,b.example.com", baseURL)
+
+	// SIGHUP reloads templates and translations without dropping connections
+	go reloadOnSignal(handler, logger)
+	if cfg.Templates.URL != "" && cfg.Templates.RefreshInterval > 0 {
+		go refreshTemplates(templateManager, responseCache, cfg.Templates.RefreshInterval, logger)
+	}
+
+	servers := []*http.Server{server}
+	serverErr := make(chan error, 3)
+	if cfg.Server.DebugAddr != "" {
+		debugServer := newDebugServer(cfg, handler, logger)
+		debugListener, err := net.Listen("tcp", cfg.Server.DebugAddr)
+		if err != nil {
+			logger.WithError(err).WithField("address", cfg.Server.DebugAddr).Fatal("Failed to listen for debug server")
+		}
+		servers = append(servers, debugServer)
+		logger.WithField("address", cfg.Server.DebugAddr).Warn("Debug server with pprof, config dump and request replay enabled; keep it off public networks")
+		go func() {
+			serverErr <- debugServer.Serve(debugListener)
+		}()
+	}
+	switch {
+	case len(cfg.Server.ACMEDomains) > 0:
+		certManager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.Server.ACMEDomains...),
+			Cache:      autocert.DirCache(cfg.Server.ACMECacheDir),
+		}
+		server.TLSConfig = certManager.TLSConfig()
+
+		// :80 answers ACME HTTP-01 challenges and redirects everything else to https
+		redirectServer := &http.Server{
+			Addr:              ":80",
+			Handler:           certManager.HTTPHandler(nil),
+			ReadHeaderTimeout: cfg.Server.ReadTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+		}
+		servers = append(servers, redirectServer)
+
+		logger.WithFields(logrus.Fields{
+			"domains":   cfg.Server.ACMEDomains,
+			"cache_dir": cfg.Server.ACMECacheDir,
+		}).Info("Obtaining TLS certificates automatically via ACME")
+		go func() {
+			serverErr <- redirectServer.ListenAndServe()
+		}()
+		go func() {
+			serverErr <- server.ServeTLS(listener, "", "")
+		}()
+	case cfg.Server.TLSCert != "":
+		go func() {
+			serverErr <- server.ServeTLS(listener, cfg.Server.TLSCert, cfg.Server.TLSKey)
+		}()
+	default:
+		go func() {
+			serverErr <- server.Serve(listener)
+		}()
+	}
+
+	err = waitForShutdown(servers, connections, serverErr, cfg.Server.ShutdownTimeout, logger)
+	if traceExporter != nil {
+		// Spans of the drained requests are still queued
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := traceExporter.Shutdown(ctx); err != nil {
+			logger.WithError(err).Warn("Failed to flush traces")
+		}
+		cancel()
+	}
+	if err != nil {
+		logger.WithError(err).Error("HTTP server did not shut down cleanly")
+		if socketPath, ok := cfg.Server.UnixSocket(); ok {
+			removeSocket(socketPath, logger)
+		}
+		os.Exit(1)
+	}
+	logger.Info("VLESS Config Generator service stopped")
+}
+
+// reloadOnSignal reloads templates and translations whenever SIGHUP arrives
+func reloadOnSignal(handler *handlers.Handler, logger *logrus.Entry) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		logger.Info("Received SIGHUP, reloading templates and translations")
+		handler.Reload()
+	}
+}
+
+// refreshTemplates re-fetches remote templates every interval and purges cached responses;
+// failed files keep their previous version
+func refreshTemplates(templateManager *templates.Manager, cache *middleware.ResponseCache, interval time.Duration, logger *logrus.Entry) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		// Reload logs files that fail to parse; they keep serving their previous version
+		templateManager.Reload()
+		cache.Purge()
+		if fetchErrors := templateManager.FetchErrors(); len(fetchErrors) > 0 {
+			logger.WithField("errors", fetchErrors).Warn("Some templates could not be fetched and are served from the cache")
+		}
+	}
+}
+
+// shortLinkPurgeInterval is how often expired short links are removed from the store
+const shortLinkPurgeInterval = time.Hour
 
-	if err := http.ListenAndServe(serverAddr, nil); err != nil {
-		logger.WithError(err).Fatal("HTTP server failed to start")
+// purgeShortLinks removes expired short links every interval; lookups already treat them as missing
+func purgeShortLinks(store shortlink.Store, interval time.Duration, logger *logrus.Entry) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		removed, err := store.DeleteExpired(now)
+		if err != nil {
+			logger.WithError(err).Error("Failed to purge expired short links")
+			continue
+		}
+		if removed > 0 {
+			logger.WithField("removed", removed).Info("Purged expired short links")
+		}
 	}
 }
 
-// setupGracefulShutdown configures graceful shutdown handling
-func setupGracefulShutdown(logger *logrus.Entry) {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+// templateOverridesDir is the subdirectory of -templates-dir persisting templates uploaded at runtime,
+// kept apart so that deleting an upload falls back to the file it replaced
+const templateOverridesDir = "overrides"
+
+// auditBufferSize is how many audit records may wait for the background writer before new ones are dropped
+const auditBufferSize = 4096
+
+// auditPurgeInterval is how often audit records older than the retention are removed
+const auditPurgeInterval = time.Hour
+
+// purgeAuditRecords removes audit records older than retention every interval
+func purgeAuditRecords(store audit.Store, retention, interval time.Duration, logger *logrus.Entry) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		removed, err := store.DeleteBefore(now.Add(-retention))
+		if err != nil {
+			logger.WithError(err).Error("Failed to purge old audit records")
+			continue
+		}
+		if removed > 0 {
+			logger.WithField("removed", removed).Info("Purged old audit records")
+		}
+	}
+}
 
-	go func() {
-		sig := <-c
+// socketMode is the permission of the unix socket: owner and group (e.g., the reverse proxy) may connect
+const socketMode = 0o660
+
+// listen opens the configured TCP address or unix socket. A stale socket file
+// left by a previous run is removed before binding.
+func listen(serverCfg config.ServerConfig) (net.Listener, error) {
+	socketPath, ok := serverCfg.UnixSocket()
+	if !ok {
+		return net.Listen("tcp", serverCfg.ListenAddress())
+	}
+
+	if info, err := os.Stat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, socketMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// removeSocket deletes the unix socket file on shutdown
+func removeSocket(socketPath string, logger *logrus.Entry) {
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		logger.WithError(err).WithField("socket", socketPath).Warn("Failed to remove unix socket")
+	}
+}
+
+// displayAddress returns a host:port (or socket path) suitable for the startup log
+func displayAddress(listener net.Listener) string {
+	address := listener.Addr().String()
+	if listener.Addr().Network() == "unix" {
+		return "unix:" + address
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// waitForShutdown blocks until a server fails or a SIGINT/SIGTERM arrives, then drains
+// in-flight requests for up to timeout before closing the remaining connections
+func waitForShutdown(servers []*http.Server, connections *int64, serverErr <-chan error, timeout time.Duration, logger *logrus.Entry) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("HTTP server failed: %w", err)
+	case sig := <-signals:
 		logger.WithField("signal", sig.String()).Info("Received shutdown signal")
-		logger.Info("VLESS Config Generator service shutting down gracefully")
-		os.Exit(0)
-	}()
+	}
+
+	logger.WithFields(logrus.Fields{
+		"active_connections": atomic.LoadInt64(connections),
+		"timeout":            timeout.String(),
+	}).Info("VLESS Config Generator service shutting down gracefully, draining connections")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var shutdownErr error
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			logger.WithFields(logrus.Fields{
+				"address":            server.Addr,
+				"active_connections": atomic.LoadInt64(connections),
+			}).Warn("Shutdown timeout reached, closing remaining connections")
+			server.Close()
+			shutdownErr = fmt.Errorf("graceful shutdown failed: %w", err)
+		}
+	}
+	return shutdownErr
+}
+
+// newServer returns the HTTP server for handler, with the timeouts and header limit that
+// disconnect slow or oversized clients
+func newServer(addr string, handler http.Handler, serverCfg *config.ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: serverCfg.ReadTimeout,
+		ReadTimeout:       serverCfg.ReadTimeout,
+		WriteTimeout:      serverCfg.WriteTimeout,
+		IdleTimeout:       serverCfg.IdleTimeout,
+		MaxHeaderBytes:    serverCfg.MaxHeaderBytes,
+	}
+}
+
+// trackConnections counts open connections on the server so shutdown can report what it waits on
+func trackConnections(server *http.Server) *int64 {
+	var active int64
+	server.ConnState = func(_ net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.AddInt64(&active, 1)
+		case http.StateHijacked, http.StateClosed:
+			atomic.AddInt64(&active, -1)
+		}
+	}
+	return &active
 }
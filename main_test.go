@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"vless-generator/internal/config"
+)
+
+// startServer serves handler through newServer on a local port and returns its address
+func startServer(t *testing.T, handler http.Handler, serverCfg *config.ServerConfig) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newServer(listener.Addr().String(), handler, serverCfg)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String()
+}
+
+// waitForClose reads from conn until the server closes it and fails when that takes longer than limit
+func waitForClose(t *testing.T, conn net.Conn, limit time.Duration) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(limit))
+	_, err := io.ReadAll(conn)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatalf("connection still open after %s", limit)
+	}
+}
+
+func TestServerDisconnectsSlowClients(t *testing.T) {
+	serverCfg := &config.ServerConfig{
+		ReadTimeout:    200 * time.Millisecond,
+		WriteTimeout:   time.Second,
+		IdleTimeout:    time.Second,
+		MaxHeaderBytes: 1 << 10,
+	}
+	bodyErrs := make(chan error, 1)
+	addr := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		bodyErrs <- err
+	}), serverCfg)
+
+	t.Run("headers never finish", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		// A slowloris client sends part of the headers and then trickles nothing more
+		fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n")
+		start := time.Now()
+		waitForClose(t, conn, 2*time.Second)
+		if elapsed := time.Since(start); elapsed < serverCfg.ReadTimeout {
+			t.Errorf("closed after %s, before the read timeout", elapsed)
+		}
+	})
+
+	t.Run("body trickles in", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 100\r\n\r\n0123456789")
+		select {
+		case err := <-bodyErrs:
+			if err == nil {
+				t.Error("handler read the whole body of a stalled client")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("handler still waiting for the body")
+		}
+		waitForClose(t, conn, 2*time.Second)
+	})
+
+	t.Run("oversized headers", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nX-Padding: %s\r\n\r\n", strings.Repeat("a", 8<<10))
+		response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+			t.Errorf("status = %d, want 431", response.StatusCode)
+		}
+	})
+}
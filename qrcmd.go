@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/qr"
+	"vless-generator/internal/utils"
+)
+
+// Values of the qr subcommand's -format flag
+const (
+	formatPNG = "png"
+	formatSVG = "svg"
+)
+
+// runQR implements `vless-generator qr`: it writes the QR code of a config's share URL, or of
+// a raw -url, to a file and returns the exit code (1 for invalid parameters, 2 for usage errors)
+func runQR(args []string) int {
+	flags := flag.NewFlagSet("qr", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	configType := flags.String("type", "vless", "Configuration template type")
+	uuid := flags.String("uuid", "", "UUID or password of the client (required unless -url is given)")
+	rawURL := flags.String("url", "", "Share URL to encode as is instead of generating one (e.g., vless://...)")
+	out := flags.String("out", "", "File to write the QR code to, or - for stdout (required)")
+	format := flags.String("format", "", "Image format: png or svg (default: from the -out extension, else png)")
+	size := flags.Int("size", qr.DefaultSize, fmt.Sprintf("Image size in pixels (%d-%d)", qr.MinSize, qr.MaxSize))
+	ecc := flags.String("ecc", "M", "Error correction level: L, M, Q or H")
+	templatesDir := flags.String("templates-dir", "", "Directory to load configuration templates from instead of the embedded ones")
+	defaultsFile := flags.String("defaults-file", "", "JSON or YAML file with default dynamic parameters (flags take precedence)")
+	values := registerParamFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: vless-generator qr -uuid <uuid> [dynamic parameters...] -out <file>")
+		fmt.Fprintln(flags.Output(), "       vless-generator qr -url <share URL> -out <file>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return 2
+	}
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "-out is required")
+		return 2
+	}
+	if (*uuid == "") == (*rawURL == "") {
+		fmt.Fprintln(os.Stderr, "exactly one of -uuid and -url is required")
+		return 2
+	}
+	if *format == "" {
+		*format = formatPNG
+		if strings.EqualFold(filepath.Ext(*out), ".svg") {
+			*format = formatSVG
+		}
+	}
+	if *format != formatPNG && *format != formatSVG {
+		fmt.Fprintf(os.Stderr, "-format must be png or svg, got %q\n", *format)
+		return 2
+	}
+	if *size < qr.MinSize || *size > qr.MaxSize {
+		fmt.Fprintf(os.Stderr, "-size must be between %d and %d, got %d\n", qr.MinSize, qr.MaxSize, *size)
+		return 2
+	}
+	level, ok := qr.ParseLevel(*ecc)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "-ecc must be L, M, Q or H, got %q\n", *ecc)
+		return 2
+	}
+
+	// Only problems are worth printing; stdout may carry the image
+	logrus.SetLevel(logrus.WarnLevel)
+
+	content := *rawURL
+	if content != "" {
+		if !utils.IsShareURL(content) {
+			fmt.Fprintf(os.Stderr, "invalid -url: unsupported share link scheme in %q\n", content)
+			return 1
+		}
+	} else {
+		cfg, dynamicCfg, code := generateConfig(*configType, *uuid, values, *templatesDir, *defaultsFile)
+		if cfg == nil {
+			return code
+		}
+		// Same share URL as the /qrcode route
+		shareURL, err := utils.GenerateShareURL(*configType, cfg, *uuid, dynamicCfg.Remark(*configType))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		content = shareURL
+	}
+
+	render := qr.PNG
+	if *format == formatSVG {
+		render = qr.SVG
+	}
+	image, err := render(content, *size, level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate QR code: %v\n", err)
+		return 1
+	}
+
+	if *out == "-" {
+		_, err = os.Stdout.Write(image)
+	} else {
+		err = os.WriteFile(*out, image, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write QR code: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vless-generator/internal/qr"
+)
+
+func TestRunQR(t *testing.T) {
+	const uuid = "bae71742-94e0-4dd5-935f-070339819ba0"
+	tests := []struct {
+		name string
+		args []string
+		file string
+		size int // PNG width and height; 0 for SVG output
+	}{
+		{"generated link", []string{"-uuid", uuid, "-server", "x.example.com"}, "qr.png", qr.DefaultSize},
+		{"size flag", []string{"-uuid", uuid, "-server", "x.example.com", "-size", "512", "-ecc", "H"}, "qr.png", 512},
+		{"minimum size", []string{"-type", "trojan", "-uuid", uuid, "-size", "128"}, "qr.png", 128},
+		{"raw url", []string{"-url", "vless://" + uuid + "@x.example.com:443?security=tls#home", "-size", "300", "-ecc", "L"}, "qr.png", 300},
+		{"explicit png format", []string{"-url", "trojan://secret@x.example.com:443", "-format", "png", "-size", "200"}, "qr.img", 200},
+		{"svg from extension", []string{"-uuid", uuid}, "qr.svg", 0},
+		{"svg format flag", []string{"-uuid", uuid, "-format", "svg"}, "qr.out", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), tt.file)
+			if code := runQR(append(tt.args, "-out", out)); code != 0 {
+				t.Fatalf("exit code %d", code)
+			}
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.size == 0 {
+				if !bytes.HasPrefix(data, []byte("<svg")) && !bytes.HasPrefix(data, []byte("<?xml")) {
+					t.Errorf("output is not SVG: %.40q", data)
+				}
+				return
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("output is not a PNG: %v", err)
+			}
+			if bounds := img.Bounds(); bounds.Dx() != tt.size || bounds.Dy() != tt.size {
+				t.Errorf("PNG is %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), tt.size, tt.size)
+			}
+		})
+	}
+}
+
+func TestRunQRUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"missing out", []string{"-uuid", "bae71742-94e0-4dd5-935f-070339819ba0"}, 2},
+		{"neither uuid nor url", []string{"-out", "qr.png"}, 2},
+		{"both uuid and url", []string{"-uuid", "bae71742-94e0-4dd5-935f-070339819ba0", "-url", "vless://x@y:443", "-out", "qr.png"}, 2},
+		{"size too small", []string{"-url", "vless://x@y:443", "-size", "10", "-out", "qr.png"}, 2},
+		{"size too large", []string{"-url", "vless://x@y:443", "-size", "100000", "-out", "qr.png"}, 2},
+		{"unknown ecc", []string{"-url", "vless://x@y:443", "-ecc", "X", "-out", "qr.png"}, 2},
+		{"unknown format", []string{"-url", "vless://x@y:443", "-format", "gif", "-out", "qr.png"}, 2},
+		{"extra arguments", []string{"-url", "vless://x@y:443", "-out", "qr.png", "extra"}, 2},
+		{"unsupported url scheme", []string{"-url", "https://example.com", "-out", "qr.png"}, 1},
+		{"invalid parameter", []string{"-uuid", "bae71742-94e0-4dd5-935f-070339819ba0", "-port", "70000", "-out", "qr.png"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				if strings.HasPrefix(arg, "qr.") {
+					arg = filepath.Join(dir, arg)
+				}
+				args[i] = arg
+			}
+			if code := runQR(args); code != tt.code {
+				t.Errorf("exit code %d, want %d", code, tt.code)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+				t.Errorf("failed run wrote %s", entries[0].Name())
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/handlers"
+	"vless-generator/internal/metrics"
+	"vless-generator/internal/middleware"
+	"vless-generator/internal/router"
+	"vless-generator/internal/tracing"
+)
+
+// newRouter registers every public route of the service with its middleware
+func newRouter(cfg *config.Config, handler *handlers.Handler, responseCache *middleware.ResponseCache) *router.Router {
+	accessLog := middleware.NewLogger(middleware.LoggerOptions{
+		TrustedProxies:   cfg.Server.TrustedProxies,
+		DebugPaths:       cfg.Service.LogDebugPaths,
+		StaticSampleRate: cfg.Service.LogStaticSampleRate,
+	})
+	logRequests := accessLog.Middleware
+	trace := tracing.Middleware(cfg.Server.TrustedProxies)
+	// Compression runs inside logging so access logs report the bytes actually sent
+	route := func(next http.HandlerFunc) http.HandlerFunc {
+		return logRequests(metrics.Middleware(trace(middleware.Compress(next))))
+	}
+	// JSON API routes may be called cross-origin; HTML pages never get CORS headers
+	allowCORS := middleware.NewCORSMiddleware(cfg.Server.CORSOrigins)
+	// Routes are declared with their methods; other methods get 405 with an Allow header
+	mux := router.New()
+	mux.NotFound = route(handler.NotFoundHandler)
+	mux.NotAllowed = route(handler.MethodNotAllowedHandler)
+	corsRoute := func(pattern string, next http.HandlerFunc) {
+		mux.Handle(pattern, route(allowCORS(next)))
+		if len(cfg.Server.CORSOrigins) > 0 {
+			// Browsers send a preflight before cross-origin calls; other OPTIONS requests are not allowed
+			_, path, _ := strings.Cut(pattern, " ")
+			mux.Handle(http.MethodOptions+" "+path, route(allowCORS(mux.MethodNotAllowed)))
+		}
+	}
+	apiRoute := func(pattern string, next http.HandlerFunc) {
+		corsRoute(pattern, handler.RequireToken(next))
+	}
+	// Routes revealing configs require -auth-token when set, or a signed link with -signing-key;
+	// /, /qrcode, /s/, /health, /ready and /static/ stay open. Generation routes answer 503
+	// while maintenance mode is on.
+	protectedRoute := func(pattern string, next http.HandlerFunc) {
+		mux.Handle(pattern, route(handler.RequireService(handler.RequireSignature(next))))
+	}
+	mux.Handle("GET /", route(handler.HomePageHandler))
+	mux.Handle("POST /", route(handler.HomePageHandler))
+	// Pages link to absolute URLs built from the host, and downloads negotiate their format with
+	// the Accept header, so those headers are part of their cache keys
+	cachePage := responseCache.Middleware("Host", "X-Forwarded-Host", "X-Forwarded-Proto")
+	cacheDownload := responseCache.Middleware("Accept")
+	protectedRoute("GET /{type}/{uuid}", cachePage(handler.ConfigPageHandler))
+	corsRoute("GET /config/{type}/{uuid}.json", handler.RequireService(handler.RequireSignature(cacheDownload(handler.ConfigDownloadHandler))))
+	corsRoute("GET /config/{type}/{uuid}.yaml", handler.RequireService(handler.RequireSignature(cacheDownload(handler.ConfigDownloadHandler))))
+	protectedRoute("GET /sub/{uuid}", handler.SubscriptionHandler)
+	// Short links are created with a token (always with -signing-key, since they bypass
+	// signatures) and then work like signed links
+	mux.Handle("GET /s/{id}", route(handler.RequireService(handler.ShortLinkHandler)))
+	corsRoute("GET /s/{id}.json", handler.RequireService(handler.ShortLinkDownloadHandler))
+	apiRoute("POST /api/v1/config", handler.RequireService(handler.ConfigAPIHandler))
+	apiRoute("POST /api/v1/batch", handler.RequireService(handler.BatchHandler))
+	apiRoute("POST /api/v1/import", handler.RequireService(handler.ImportHandler))
+	apiRoute("GET /api/v1/defaults", handler.DefaultsHandler)
+	apiRoute("GET /api/v1/templates", handler.TemplatesHandler)
+	apiRoute("GET /api/v1/uuid", handler.UUIDHandler)
+	// Signing links needs the admin token; without -auth-token the route answers 404
+	corsRoute("POST /api/v1/sign", handler.RequireAdmin(handler.SignHandler))
+	corsRoute("POST /api/v1/shorten", handler.RequireLinkIssuer(handler.ShortenHandler))
+	apiRoute("GET /openapi.json", handler.OpenAPIHandler)
+	mux.Handle("GET /qrcode", route(handler.QRCodeHandler))
+	mux.Handle("POST /qrcode", route(handler.QRCodeHandler))
+	protectedRoute("GET /qrcode/{type}/{uuid}.png", handler.QRCodeConfigHandler)
+	protectedRoute("GET /bundle/{type}/{uuid}.zip", handler.BundleHandler)
+	mux.Handle("GET /health", route(handler.HealthHandler))
+	mux.Handle("GET /ready", route(handler.ReadyHandler))
+
+	// Admin routes always need a token; without -auth-token they answer 404
+	mux.Handle("POST /admin/reload", route(handler.RequireAdmin(handler.ReloadHandler)))
+	mux.Handle("GET /admin/configs", route(handler.RequireAdmin(handler.AuditConfigsHandler)))
+	mux.Handle("POST /admin/maintenance", route(handler.RequireAdmin(handler.MaintenanceHandler)))
+	mux.Handle("PUT /admin/templates/{type}", route(handler.RequireAdmin(handler.PutTemplateHandler)))
+	mux.Handle("DELETE /admin/templates/{type}", route(handler.RequireAdmin(handler.DeleteTemplateHandler)))
+
+	// Setup static file serving with embedded files
+	mux.Handle("GET /static/{path...}", logRequests(metrics.Middleware(http.StripPrefix("/static/", embeddedFileServer()).ServeHTTP)))
+
+	return mux
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/config"
+	"vless-generator/internal/handlers"
+	"vless-generator/internal/i18n"
+	"vless-generator/internal/openapi"
+	"vless-generator/internal/router"
+	"vless-generator/internal/shortlink"
+	"vless-generator/internal/templates"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	logrus.SetOutput(io.Discard)
+	cfg := &config.Config{}
+	cfg.Server.CORSOrigins = []string{"*"} // Registers the preflight routes too
+	mux := newRouter(cfg, handlers.NewHandler(nil, nil, nil, handlers.Options{}), nil)
+
+	document := openapi.Build(openapi.Options{Types: []string{"vless"}, Defaults: config.DefaultDynamicConfig()})
+	documented := make(map[string]bool)
+	for path, item := range document.Paths {
+		for method := range item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := make(map[string]bool)
+	for _, pattern := range mux.Patterns() {
+		method, path, _ := strings.Cut(pattern, " ")
+		if method == http.MethodOptions {
+			continue // CORS preflights are not operations of their own
+		}
+		// The document names a catch-all {path...} like a plain parameter
+		key := method + " " + strings.ReplaceAll(path, "...}", "}")
+		registered[key] = true
+		if !documented[key] {
+			t.Errorf("route %q is missing from the OpenAPI document (internal/openapi/paths.go)", pattern)
+		}
+	}
+	for key := range documented {
+		if !registered[key] {
+			t.Errorf("OpenAPI operation %q has no registered route", key)
+		}
+	}
+}
+
+// newTestRouter builds the public router over the repository templates and embedded pages
+func newTestRouter(t *testing.T, cfg *config.Config) *router.Router {
+	t.Helper()
+	return newRouter(cfg, newTestHandler(t, true, true, handlers.Options{Defaults: config.DefaultDynamicConfig()}), nil)
+}
+
+// newTestHandler returns a handler over the repository templates and embedded pages,
+// leaving templates or translations unloaded when asked to
+func newTestHandler(t testing.TB, loadTemplates, loadTranslations bool, options handlers.Options) *handlers.Handler {
+	t.Helper()
+	logrus.SetOutput(io.Discard)
+	manager := templates.NewManager(os.DirFS("templates"))
+	if loadTemplates {
+		if err := manager.LoadTemplates([]string{"vless", "vless-reality", "shadowsocks"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	renderer := templates.NewTemplateRenderer(htmlTemplates)
+	if err := renderer.LoadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	translations := i18n.NewI18n()
+	if loadTranslations {
+		if err := translations.LoadTranslations(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return handlers.NewHandler(manager, renderer, translations, options)
+}
+
+func TestCORSRoutes(t *testing.T) {
+	const admin = "https://admin.example.com"
+	const uuid = "bae71742-94e0-4dd5-935f-070339819ba0"
+	cfg := &config.Config{}
+	cfg.Server.CORSOrigins = []string{admin}
+	mux := newTestRouter(t, cfg)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		origin     string
+		preflight  bool
+		wantStatus int
+		wantOrigin string
+	}{
+		{"api preflight", http.MethodOptions, "/api/v1/config", admin, true, http.StatusNoContent, admin},
+		{"download preflight", http.MethodOptions, "/config/vless/" + uuid + ".json", admin, true, http.StatusNoContent, admin},
+		{"download", http.MethodGet, "/config/vless/" + uuid + ".json", admin, false, http.StatusOK, admin},
+		{"download from a disallowed origin", http.MethodGet, "/config/vless/" + uuid + ".json", "https://evil.example.com", false, http.StatusOK, ""},
+		{"api preflight from a disallowed origin", http.MethodOptions, "/api/v1/config", "https://evil.example.com", true, http.StatusNoContent, ""},
+		{"home page", http.MethodGet, "/", admin, false, http.StatusOK, ""},
+		{"config page", http.MethodGet, "/vless/" + uuid, admin, false, http.StatusOK, ""},
+		{"config page preflight", http.MethodOptions, "/vless/" + uuid, admin, true, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			r.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestReady(t *testing.T) {
+	noInbounds := config.DefaultDynamicConfig()
+	noInbounds.TunEnabled, noInbounds.MixedEnabled = false, false
+
+	tests := []struct {
+		name             string
+		loadTemplates    bool
+		loadTranslations bool
+		defaults         *config.DynamicConfig
+		status           int
+		components       map[string]string
+	}{
+		{"ready", true, true, config.DefaultDynamicConfig(), http.StatusOK,
+			map[string]string{"templates": "ok", "translations": "ok", "home_page": "ok", "generation": "ok"}},
+		{"templates not loaded", false, true, config.DefaultDynamicConfig(), http.StatusServiceUnavailable,
+			map[string]string{"templates": "failed", "translations": "ok", "home_page": "ok"}},
+		{"translations not loaded", true, false, config.DefaultDynamicConfig(), http.StatusServiceUnavailable,
+			map[string]string{"templates": "ok", "translations": "failed", "generation": "ok"}},
+		{"generation fails", true, true, noInbounds, http.StatusServiceUnavailable,
+			map[string]string{"templates": "ok", "translations": "ok", "home_page": "ok", "generation": "failed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newRouter(&config.Config{}, newTestHandler(t, tt.loadTemplates, tt.loadTranslations, handlers.Options{Defaults: tt.defaults}), nil)
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+			var body struct {
+				Status     string `json:"status"`
+				Components map[string]struct {
+					Status string `json:"status"`
+				} `json:"components"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]string{true: "ready", false: "not_ready"}[tt.status == http.StatusOK]; body.Status != want {
+				t.Errorf("status field = %q, want %q", body.Status, want)
+			}
+			got := make(map[string]string, len(body.Components))
+			for name, component := range body.Components {
+				got[name] = component.Status
+			}
+			if !reflect.DeepEqual(got, tt.components) {
+				t.Errorf("components = %v, want %v", got, tt.components)
+			}
+
+			// Liveness does not depend on readiness
+			w = httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("/health status = %d, want 200", w.Code)
+			}
+		})
+	}
+}
+
+func TestSignRequiresAdmin(t *testing.T) {
+	body := `{"path": "/vless/bae71742-94e0-4dd5-935f-070339819ba0"}`
+	tests := []struct {
+		name   string
+		tokens []string
+		token  string
+		status int
+	}{
+		{"signing key only", nil, "", http.StatusNotFound},
+		{"signing key only with a token", nil, "secret", http.StatusNotFound},
+		{"without token", []string{"secret"}, "", http.StatusUnauthorized},
+		{"wrong token", []string{"secret"}, "nope", http.StatusUnauthorized},
+		{"token", []string{"secret"}, "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := handlers.Options{Defaults: config.DefaultDynamicConfig(), SigningKey: []byte("key"), AuthTokens: tt.tokens}
+			mux := newRouter(&config.Config{}, newTestHandler(t, true, true, options), nil)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/sign", strings.NewReader(body))
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestShortLinkRoutes(t *testing.T) {
+	store, err := shortlink.OpenFileStore(filepath.Join(t.TempDir(), "links.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := handlers.Options{Defaults: config.DefaultDynamicConfig(), ShortLinks: store}
+	mux := newRouter(&config.Config{}, newTestHandler(t, true, true, options), nil)
+
+	w := httptest.NewRecorder()
+	body := `{"type": "vless", "uuid": "bae71742-94e0-4dd5-935f-070339819ba0", "params": {"server": "x.example.com"}}`
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("shorten status = %d: %s", w.Code, w.Body)
+	}
+	var link struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target      string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{"/s/" + link.ID, http.StatusOK, "text/html", "x.example.com"},
+		{"/s/" + link.ID + ".json", http.StatusOK, "application/json", `"server": "x.example.com"`},
+		{"/s/Unknown1", http.StatusNotFound, "text/html", ""},
+		{"/s/Unknown1.json", http.StatusNotFound, "application/json", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("body lacks %q", tt.contains)
+			}
+		})
+	}
+}
+
+// discardResponseWriter drops response bodies, so benchmarks measure rendering rather than
+// the growth of a recorder's buffer
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+var pageTargets = []struct{ name, target string }{
+	{"home", "/"},
+	{"config", "/vless/bae71742-94e0-4dd5-935f-070339819ba0?server=example.com"},
+}
+
+func TestStrictRenderMatchesStreaming(t *testing.T) {
+	streamed := newRouter(&config.Config{}, newTestHandler(t, true, true, handlers.Options{Defaults: config.DefaultDynamicConfig()}), nil)
+	buffered := newRouter(&config.Config{}, newTestHandler(t, true, true, handlers.Options{Defaults: config.DefaultDynamicConfig(), StrictRender: true}), nil)
+	for _, page := range pageTargets {
+		t.Run(page.name, func(t *testing.T) {
+			want := httptest.NewRecorder()
+			streamed.ServeHTTP(want, httptest.NewRequest(http.MethodGet, page.target, nil))
+			got := httptest.NewRecorder()
+			buffered.ServeHTTP(got, httptest.NewRequest(http.MethodGet, page.target, nil))
+
+			if want.Code != http.StatusOK || got.Code != want.Code {
+				t.Fatalf("status = %d streamed, %d buffered, want 200", want.Code, got.Code)
+			}
+			if got.Header().Get("Content-Type") != want.Header().Get("Content-Type") {
+				t.Errorf("Content-Type = %q buffered, %q streamed", got.Header().Get("Content-Type"), want.Header().Get("Content-Type"))
+			}
+			if got.Body.String() != want.Body.String() {
+				t.Error("buffered page differs from streamed page")
+			}
+		})
+	}
+}
+
+// BenchmarkPageRendering compares page views streamed into the response with pages rendered
+// into a pooled buffer first (-strict-render)
+func BenchmarkPageRendering(b *testing.B) {
+	for _, mode := range []struct {
+		name   string
+		strict bool
+	}{{"streamed", false}, {"buffered", true}} {
+		mux := newRouter(&config.Config{}, newTestHandler(b, true, true, handlers.Options{Defaults: config.DefaultDynamicConfig(), StrictRender: mode.strict}), nil)
+		for _, page := range pageTargets {
+			b.Run(page.name+"/"+mode.name, func(b *testing.B) {
+				req := httptest.NewRequest(http.MethodGet, page.target, nil)
+				w := &discardResponseWriter{header: make(http.Header)}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					mux.ServeHTTP(w, req)
+				}
+			})
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"embed"
 	"io/fs"
 	"net/http"
+	"os"
 	"path"
 	"strings"
 )
@@ -17,6 +18,18 @@ var configTemplates embed.FS
 //go:embed web/templates/*.html
 var htmlTemplates embed.FS
 
+// templateFS returns the configuration template filesystem: dir when set, otherwise the embedded templates
+func templateFS(dir string) fs.FS {
+	if dir != "" {
+		return os.DirFS(dir)
+	}
+	templatesFS, err := fs.Sub(configTemplates, "templates")
+	if err != nil {
+		panic("failed to create templates file system: " + err.Error())
+	}
+	return templatesFS
+}
+
 // embeddedFileServer creates an HTTP handler for embedded static files
 func embeddedFileServer() http.Handler {
 	// Get the sub-filesystem for web/static
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"vless-generator/internal/templates"
+)
+
+// runValidateTemplates implements `vless-generator validate-templates`: it lints every template
+// file of a directory, prints a pass/fail line per file with its problems and returns the exit
+// code (1 when any file fails, 2 for usage errors)
+func runValidateTemplates(args []string) int {
+	flags := flag.NewFlagSet("validate-templates", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	dir := flags.String("dir", "", "Directory of *.json templates to check (default: the embedded templates)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: vless-generator validate-templates -dir <directory>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return 2
+	}
+	if *dir != "" {
+		if info, err := os.Stat(*dir); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "-dir %q is not a directory\n", *dir)
+			return 2
+		}
+	}
+
+	// The report is the output; loader logs would only repeat it
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	results, err := templates.NewManager(templateFS(*dir)).Lint()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "no *.json template files found")
+		return 1
+	}
+
+	failed := 0
+	for _, result := range results {
+		if len(result.Problems) == 0 {
+			fmt.Printf("PASS %s\n", result.File)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", result.File)
+		for _, problem := range result.Problems {
+			fmt.Printf("  - %v\n", problem)
+		}
+	}
+	fmt.Printf("%d of %d template files passed\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}